@@ -27,6 +27,8 @@ var stats = examples.Stats{
 
 var simple = `Class == "軍師"`
 
+var single = `HitPoint > 50`
+
 var heavy = `
 	Class == "軍師" && Name =~ '^(諸葛亮|龐統|法正)' && Name != "" && (
 		BirthDate < '0190-01-01T00:00:00Z' && ActiveTimeBattleGauge >= '20s'
@@ -37,6 +39,16 @@ var heavy = `
 	)
 `
 
+var ascii = `
+	Class == "mage" && Name != "" && Title ==* "ARCHMAGE" && (
+		BirthDate < 0190-01-01T00:00:00Z && ActiveTimeBattleGauge >= 1m20s
+	) && (
+		HitPoint > 50 && MagicPoint > 100 && LifePoint != 0
+	) && (
+		Magic >= 20 || !(Speed < 20)
+	)
+`
+
 func BenchmarkParseSimple(b *testing.B) {
 	for b.Loop() {
 		if _, err := filter.Parse(simple); err != nil {
@@ -57,6 +69,26 @@ func BenchmarkEvalSimple(b *testing.B) {
 	}
 }
 
+func BenchmarkParseSingle(b *testing.B) {
+	for b.Loop() {
+		if _, err := filter.Parse(single); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEvalSingle(b *testing.B) {
+	expr, err := filter.Parse(single)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for b.Loop() {
+		if ok, err := expr.Eval(&stats); !ok || err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func BenchmarkParseHeavy(b *testing.B) {
 	for b.Loop() {
 		if _, err := filter.Parse(heavy); err != nil {
@@ -99,6 +131,34 @@ func BenchmarkEvalRepeated(b *testing.B) {
 	}
 }
 
+func BenchmarkParseRepeatedASCII(b *testing.B) {
+	input := repeatInput(ascii, 30)
+	for b.Loop() {
+		if _, err := filter.Parse(input); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseCachedHeavy(b *testing.B) {
+	filter.ClearParseCache()
+	for b.Loop() {
+		if _, err := filter.ParseCached(heavy); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseCachedRepeated(b *testing.B) {
+	input := repeatInput(heavy, 30)
+	filter.ClearParseCache()
+	for b.Loop() {
+		if _, err := filter.ParseCached(input); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func repeatInput(input string, n int) string {
 	if n <= 0 {
 		return input
@@ -0,0 +1,360 @@
+package filter
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// Operator is a comparison operator for NewComparison.
+type Operator int
+
+const (
+	// OperatorEQ is the == operator.
+	OperatorEQ Operator = iota
+
+	// OperatorNEQ is the != operator.
+	OperatorNEQ
+
+	// OperatorEQI is the ==* operator.
+	OperatorEQI
+
+	// OperatorNEQI is the !=* operator.
+	OperatorNEQI
+
+	// OperatorGT is the > operator.
+	OperatorGT
+
+	// OperatorGTE is the >= operator.
+	OperatorGTE
+
+	// OperatorLT is the < operator.
+	OperatorLT
+
+	// OperatorLTE is the <= operator.
+	OperatorLTE
+
+	// OperatorREQ is the =~ operator.
+	OperatorREQ
+
+	// OperatorNREQ is the !~ operator.
+	OperatorNREQ
+
+	// OperatorREQI is the =~* operator.
+	OperatorREQI
+
+	// OperatorNREQI is the !~* operator.
+	OperatorNREQI
+
+	// OperatorContainsAny is the containsany operator.
+	OperatorContainsAny
+
+	// OperatorContainsAll is the containsall operator.
+	OperatorContainsAll
+
+	// OperatorHas is the has operator.
+	OperatorHas
+
+	// OperatorHasI is the ihas operator.
+	OperatorHasI
+
+	// OperatorMatches is the matches operator.
+	OperatorMatches
+
+	// OperatorMatchesI is the imatches operator.
+	OperatorMatchesI
+
+	// OperatorApprox is the ~= operator.
+	OperatorApprox
+)
+
+// operatorTokens maps operators to their token types.
+var operatorTokens = [...]tokenType{
+	OperatorEQ:          tokenEQ,
+	OperatorNEQ:         tokenNEQ,
+	OperatorEQI:         tokenEQI,
+	OperatorNEQI:        tokenNEQI,
+	OperatorGT:          tokenGT,
+	OperatorGTE:         tokenGTE,
+	OperatorLT:          tokenLT,
+	OperatorLTE:         tokenLTE,
+	OperatorREQ:         tokenREQ,
+	OperatorNREQ:        tokenNREQ,
+	OperatorREQI:        tokenREQI,
+	OperatorNREQI:       tokenNREQI,
+	OperatorContainsAny: tokenContainsAny,
+	OperatorContainsAll: tokenContainsAll,
+	OperatorHas:         tokenHas,
+	OperatorHasI:        tokenHasI,
+	OperatorMatches:     tokenMatches,
+	OperatorMatchesI:    tokenMatchesI,
+	OperatorApprox:      tokenApprox,
+}
+
+// String returns the operator as written in the filter syntax.
+func (op Operator) String() string {
+	typ, ok := op.tokenType()
+	if !ok {
+		return "unknown"
+	}
+	return typ.literal()
+}
+
+// tokenType returns the token type of the operator.
+func (op Operator) tokenType() (tokenType, bool) {
+	if op < 0 || int(op) >= len(operatorTokens) {
+		return 0, false
+	}
+	return operatorTokens[op], true
+}
+
+// NewComparison builds an expression comparing a field with a value, like the one parsed from
+// field op value, without writing and parsing the filter syntax.
+// The kind of the literal follows the Go type of the value: string, bool, integer and float types,
+// time.Duration and time.Time are supported. containsany and containsall take a slice or array of
+// such values, and regex operators take a pattern string or a slice of them.
+// A string containing both a double quote or a backslash and a backquote cannot be written
+// as a literal, since strings are compared without interpreting escapes, and is rejected.
+func NewComparison(field string, op Operator, value any, opts ...Option) (*Expr, error) {
+	typ, ok := op.tokenType()
+	if !ok {
+		return nil, &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("invalid operator: %d", op),
+		}
+	}
+	if _, err := QuoteIdent(field); err != nil {
+		return nil, err
+	}
+	var (
+		vals []token
+		list = typ.isListOperatorType()
+	)
+	if v := reflect.ValueOf(value); value != nil && (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) {
+		if !list && !typ.isRegexOperatorType() {
+			return nil, &Error{
+				Kind: KindParse,
+				Err:  fmt.Errorf("operator %s does not take a list of values: %T", typ.literal(), value),
+			}
+		}
+		list = true
+		for i := range v.Len() {
+			val, err := literalToken(v.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			vals = append(vals, val)
+		}
+	} else {
+		if list {
+			return nil, &Error{
+				Kind: KindParse,
+				Err:  fmt.Errorf("operator %s requires a list of values, got %T", typ.literal(), value),
+			}
+		}
+		val, err := literalToken(value)
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, val)
+	}
+
+	// The input holds the expression as it would be written, so that String can render it.
+	var b strings.Builder
+	b.WriteString(field)
+	b.WriteString(" ")
+	opTok := position(token{typ: typ, v: typ.literal()}, &b)
+	b.WriteString(typ.literal())
+	b.WriteString(" ")
+	lp := position(token{typ: tokenLparen, v: "("}, &b)
+	if list {
+		b.WriteString("(")
+	}
+	for i := range vals {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		vals[i] = position(vals[i], &b)
+		b.WriteString(vals[i].v)
+	}
+	if list {
+		b.WriteString(")")
+	}
+	p, err := newParser(b.String(), opts...)
+	if err != nil {
+		return nil, err
+	}
+	ident := p.registerIdent(token{typ: tokenIdent, v: field, line: 1, col: 1})
+	var root int
+	if list {
+		regex := typ.isRegexOperatorType()
+		if regex && len(vals) == 0 {
+			return nil, &Error{
+				Kind: KindParse,
+				Err:  fmt.Errorf("empty pattern list at %d:%d", lp.line, lp.col),
+			}
+		}
+		items := make([]node, 0, len(vals))
+		for _, val := range vals {
+			if regex && !val.typ.isStringType() {
+				return nil, &Error{
+					Kind: KindParse,
+					Err:  fmt.Errorf("expected string pattern, got %s at %d:%d: %q", val.typ, val.line, val.col, val.v),
+				}
+			}
+			itemOp := opTok
+			if !regex {
+				itemOp = token{typ: tokenEQ, v: tokenEQ.literal(), pos: val.pos, line: val.line, col: val.col}
+			}
+			j, err := p.newComparison(ident, transformNone, itemOp, val)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, p.nodes[j])
+			p.nodes = p.nodes[:j]
+		}
+		root = newNodeComparison(&p, ident, opTok, lp)
+		p.nodes[root].items = items
+	} else {
+		root, err = p.newComparison(ident, transformNone, opTok, vals[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Expr{
+		parser: p,
+		root:   root,
+	}, nil
+}
+
+// Quote returns s as a string literal that is parsed back to exactly s, for embedding values
+// such as user input into a filter without changing its structure, as in "Name == " + q.
+// Escape sequences in quoted strings are checked but not interpreted, so s is written without them:
+// in double quotes if possible, otherwise in backquotes, otherwise in single quotes.
+// A string that cannot be written in any of them, such as one containing both a backslash
+// and a backquote, or that is not valid UTF-8 or contains U+FFFD, is rejected.
+func Quote(s string) (string, error) {
+	if strings.ContainsRune(s, utf8.RuneError) {
+		return "", &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("cannot write string %q as a literal: invalid utf8 encoding", s),
+		}
+	}
+	switch {
+	case !strings.ContainsAny(s, "\"\\\n"):
+		return `"` + s + `"`, nil
+	case !strings.Contains(s, "`"):
+		return "`" + s + "`", nil
+	case !strings.ContainsAny(s, "'\\\n"):
+		return "'" + s + "'", nil
+	default:
+		return "", &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("cannot write string %q as a literal", s),
+		}
+	}
+}
+
+// QuoteIdent returns name if it is written as a single field name, such as in
+// QuoteIdent(name) + " == " + q, and an error otherwise.
+// Field names have no quoted form, so names containing spaces, operators or other symbols,
+// and names that are keywords such as true or containsany, cannot be referenced and are rejected.
+func QuoteIdent(name string) (string, error) {
+	if !isIdentifier(name) {
+		return "", &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("invalid field name %q", name),
+		}
+	}
+	return name, nil
+}
+
+// position sets the position of the token to the end of b, where it is about to be written.
+func position(t token, b *strings.Builder) token {
+	t.pos = b.Len()
+	t.line = 1
+	t.col = runewidth.StringWidth(b.String()) + 1
+	return t
+}
+
+// literalToken returns the value token written for a Go value.
+func literalToken(value any) (token, error) {
+	switch v := value.(type) {
+	case string:
+		q, err := Quote(v)
+		if err != nil {
+			return token{}, err
+		}
+		if q[0] == '`' {
+			return token{typ: tokenRawString, v: q}, nil
+		}
+		return token{typ: tokenString, v: q}, nil
+	case bool:
+		return token{typ: tokenBool, v: strconv.FormatBool(v)}, nil
+	case int:
+		return token{typ: tokenNumber, v: strconv.FormatInt(int64(v), 10)}, nil
+	case int8:
+		return token{typ: tokenNumber, v: strconv.FormatInt(int64(v), 10)}, nil
+	case int16:
+		return token{typ: tokenNumber, v: strconv.FormatInt(int64(v), 10)}, nil
+	case int32:
+		return token{typ: tokenNumber, v: strconv.FormatInt(int64(v), 10)}, nil
+	case int64:
+		return token{typ: tokenNumber, v: strconv.FormatInt(v, 10)}, nil
+	case uint:
+		return token{typ: tokenNumber, v: strconv.FormatUint(uint64(v), 10)}, nil
+	case uint8:
+		return token{typ: tokenNumber, v: strconv.FormatUint(uint64(v), 10)}, nil
+	case uint16:
+		return token{typ: tokenNumber, v: strconv.FormatUint(uint64(v), 10)}, nil
+	case uint32:
+		return token{typ: tokenNumber, v: strconv.FormatUint(uint64(v), 10)}, nil
+	case uint64:
+		return token{typ: tokenNumber, v: strconv.FormatUint(v, 10)}, nil
+	case float32:
+		return floatToken(float64(v), 32)
+	case float64:
+		return floatToken(v, 64)
+	case time.Duration:
+		// Go writes microseconds with the micro sign, which is not a duration unit of the filter syntax.
+		return token{typ: tokenDuration, v: strings.ReplaceAll(v.String(), "µs", "us")}, nil
+	case time.Time:
+		return token{typ: tokenTime, v: v.Format(time.RFC3339Nano)}, nil
+	default:
+		return token{}, &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("unsupported value type %T", value),
+		}
+	}
+}
+
+// floatToken returns the number token written for a float value.
+func floatToken(f float64, bitSize int) (token, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return token{}, &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("invalid number %v: NaN and Inf are not supported", f),
+		}
+	}
+	return token{typ: tokenNumber, v: strconv.FormatFloat(f, 'g', -1, bitSize)}, nil
+}
+
+// isIdentifier reports whether s is lexed as a single identifier.
+func isIdentifier(s string) bool {
+	if s == "" || isBoolLiteral(s) || s == "now" || s == "containsany" || s == "containsall" || s == "has" || s == "ihas" || s == "matches" || s == "imatches" || s == "in" {
+		return false
+	}
+	for i, r := range s {
+		if !isAlphaNumeric(r) || (i == 0 && unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,258 @@
+package filter
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewComparison(t *testing.T) {
+	tests := []struct {
+		name     string
+		field    string
+		op       Operator
+		value    any
+		opts     []Option
+		expected string
+	}{
+		{name: "string", field: "String", op: OperatorEQ, value: "HelloWorld", expected: `String == "HelloWorld"`},
+		{name: "string with quote", field: "String", op: OperatorNEQ, value: `Hello"World`, expected: "String != `Hello\"World`"},
+		{name: "case-insensitive", field: "String", op: OperatorEQI, value: "helloworld", expected: `String ==* "helloworld"`},
+		{name: "not case-insensitive", field: "String", op: OperatorNEQI, value: "HELLOWORLD", expected: `String !=* "HELLOWORLD"`},
+		{name: "int", field: "Int", op: OperatorGT, value: 40, expected: `Int > 40`},
+		{name: "int64", field: "MaxInt64", op: OperatorEQ, value: int64(9223372036854775807), expected: `MaxInt64 == 9223372036854775807`},
+		{name: "uint64", field: "MaxUint64", op: OperatorGTE, value: uint64(18446744073709551615), expected: `MaxUint64 >= 18446744073709551615`},
+		{name: "negative", field: "Int", op: OperatorGT, value: int8(-1), expected: `Int > -1`},
+		{name: "float", field: "Float64", op: OperatorLT, value: 3.15, expected: `Float64 < 3.15`},
+		{name: "float exponent", field: "Float64", op: OperatorLTE, value: 1e21, expected: `Float64 <= 1e+21`},
+		{name: "float32", field: "Float32", op: OperatorEQ, value: float32(2.5), expected: `Float32 == 2.5`},
+		{name: "bool", field: "Bool", op: OperatorEQ, value: true, expected: `Bool == true`},
+		{name: "duration", field: "Duration", op: OperatorGTE, value: 1500 * time.Millisecond, expected: `Duration >= 1.5s`},
+		{name: "negative duration", field: "Skew", op: OperatorLT, value: -100 * time.Millisecond, expected: `Skew < -100ms`},
+		{name: "microseconds", field: "Duration", op: OperatorGT, value: 1500 * time.Microsecond, expected: `Duration > 1.5ms`},
+		{name: "sub-millisecond", field: "Duration", op: OperatorGT, value: 500 * time.Microsecond, expected: `Duration > 500us`},
+		{name: "time", field: "Time", op: OperatorEQ, value: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), expected: `Time == 2025-01-01T00:00:00Z`},
+		{name: "time offset", field: "Time", op: OperatorLT, value: time.Date(2025, 1, 1, 9, 0, 0, 500, time.FixedZone("", 9*60*60)), expected: `Time < 2025-01-01T09:00:00.0000005+09:00`},
+		{name: "regex", field: "String", op: OperatorREQ, value: "^Hello", expected: `String =~ "^Hello"`},
+		{name: "regex escape", field: "String", op: OperatorREQ, value: `World\b`, expected: "String =~ `World\\b`"},
+		{name: "regex case-insensitive", field: "String", op: OperatorNREQI, value: "^hello", expected: `String !~* "^hello"`},
+		{name: "regex list", field: "String", op: OperatorREQ, value: []string{"^Bye", "World$"}, expected: `String =~ ("^Bye", "World$")`},
+		{name: "containsany", field: "Tags", op: OperatorContainsAny, value: []string{"x", "b"}, expected: `Tags containsany ("x", "b")`},
+		{name: "has", field: "Message", op: OperatorHas, value: "Disk", expected: `Message has "Disk"`},
+		{name: "ihas", field: "Message", op: OperatorHasI, value: "disk", expected: `Message ihas "disk"`},
+		{name: "containsall", field: "Ints", op: OperatorContainsAll, value: [2]int{3, 1}, expected: `Ints containsall (3, 1)`},
+		{name: "containsall mixed", field: "Durations", op: OperatorContainsAll, value: []any{time.Minute, time.Second}, expected: `Durations containsall (1m0s, 1s)`},
+		{name: "empty list", field: "Tags", op: OperatorContainsAny, value: []string{}, expected: `Tags containsany ()`},
+		{name: "folded field", field: "STRING", op: OperatorEQ, value: "HelloWorld", opts: []Option{WithCaseInsensitiveFields()}, expected: `string == "HelloWorld"`},
+		{name: "unicode field", field: "名前", op: OperatorEQ, value: "孔明", expected: `名前 == "孔明"`},
+	}
+	target := CaseInsensitiveTarget(testObject)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			built, err := NewComparison(test.field, test.op, test.value, test.opts...)
+			if err != nil {
+				t.Fatalf(testTemplate, test.expected, "", err)
+			}
+			if actual := built.String(); actual != test.expected {
+				t.Errorf(testTemplate, test.name, test.expected, actual)
+			}
+			parsed, err := Parse(test.expected, test.opts...)
+			if err != nil {
+				t.Fatalf(testTemplate, test.expected, "", err)
+			}
+			expected, expectedErr := parsed.Eval(target)
+			actual, err := built.Eval(target)
+			if actual != expected || (err == nil) != (expectedErr == nil) {
+				t.Errorf(testTemplate, test.expected, []any{expected, expectedErr}, []any{actual, err})
+			}
+			if !equalNodes(built.parser.nodes, built.root, parsed.parser.nodes, parsed.root, sameLiteral) {
+				t.Errorf(testTemplate, test.expected, "equal nodes", built.String())
+			}
+		})
+	}
+}
+
+func TestNewComparison_combined(t *testing.T) {
+	hp, err := NewComparison("Int", OperatorGT, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, err := NewComparison("String", OperatorREQ, "^Hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	built := mustOr(t, mustAnd(t, hp, Not(name)), name)
+	parsed, err := Parse(`Int > 40 && !(String =~ "^Hello") || String =~ "^Hello"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if built.String() != parsed.String() {
+		t.Errorf(testTemplate, "combined", parsed.String(), built.String())
+	}
+	expected, _ := parsed.Eval(testObject)
+	if actual, err := built.Eval(testObject); err != nil || actual != expected {
+		t.Errorf(testTemplate, "combined", expected, actual)
+	}
+}
+
+func TestNewComparison_error(t *testing.T) {
+	tests := []struct {
+		name     string
+		field    string
+		op       Operator
+		value    any
+		expected string
+	}{
+		{name: "invalid operator", field: "Int", op: Operator(100), value: 1, expected: `parse error: invalid operator: 100`},
+		{name: "empty field", field: "", op: OperatorEQ, value: 1, expected: `parse error: invalid field name ""`},
+		{name: "field with space", field: "Hit Point", op: OperatorEQ, value: 1, expected: `parse error: invalid field name "Hit Point"`},
+		{name: "field with digit", field: "1st", op: OperatorEQ, value: 1, expected: `parse error: invalid field name "1st"`},
+		{name: "keyword field", field: "true", op: OperatorEQ, value: 1, expected: `parse error: invalid field name "true"`},
+		{name: "unsupported type", field: "Int", op: OperatorEQ, value: struct{}{}, expected: `parse error: unsupported value type struct {}`},
+		{name: "nil", field: "Int", op: OperatorEQ, value: nil, expected: `parse error: unsupported value type <nil>`},
+		{name: "nan", field: "Float64", op: OperatorEQ, value: math.NaN(), expected: `parse error: invalid number NaN: NaN and Inf are not supported`},
+		{name: "unwritable string", field: "String", op: OperatorEQ, value: "a\\`", expected: `parse error: cannot write string "a\\` + "`" + `" as a literal`},
+		{name: "list for scalar operator", field: "Int", op: OperatorEQ, value: []int{1}, expected: `parse error: operator == does not take a list of values: []int`},
+		{name: "scalar for list operator", field: "Tags", op: OperatorContainsAny, value: "a", expected: `parse error: operator containsany requires a list of values, got string`},
+		{name: "case-insensitive number", field: "String", op: OperatorEQI, value: 1, expected: `parse error: operator ==* requires a string value, got number at 1:8`},
+		{name: "invalid regex", field: "String", op: OperatorREQ, value: "[", expected: `parse error: invalid regex "[" at 1:11`},
+		{name: "empty pattern list", field: "String", op: OperatorREQ, value: []string{}, expected: `parse error: empty pattern list at 1:11`},
+		{name: "number pattern", field: "String", op: OperatorREQ, value: []any{"a", 1}, expected: `parse error: expected string pattern, got number at 1:17: "1"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewComparison(test.field, test.op, test.value)
+			if err == nil || !strings.HasPrefix(err.Error(), test.expected) {
+				t.Errorf(testTemplate, test.name, test.expected, err)
+			}
+		})
+	}
+}
+
+func TestOperator_String(t *testing.T) {
+	tests := []struct {
+		op       Operator
+		expected string
+	}{
+		{op: OperatorEQ, expected: "=="},
+		{op: OperatorNREQI, expected: "!~*"},
+		{op: OperatorContainsAll, expected: "containsall"},
+		{op: OperatorHas, expected: "has"},
+		{op: OperatorHasI, expected: "ihas"},
+		{op: OperatorMatchesI, expected: "imatches"},
+		{op: OperatorApprox, expected: "~="},
+		{op: Operator(-1), expected: "unknown"},
+		{op: Operator(100), expected: "unknown"},
+	}
+	for _, test := range tests {
+		if actual := test.op.String(); actual != test.expected {
+			t.Errorf(testTemplate, int(test.op), test.expected, actual)
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "plain", input: `HelloWorld`, expected: `"HelloWorld"`},
+		{name: "empty", input: ``, expected: `""`},
+		{name: "multibyte", input: `諸葛亮 孔明`, expected: `"諸葛亮 孔明"`},
+		{name: "double quote", input: `a"b`, expected: "`a\"b`"},
+		{name: "injection", input: `" || Name != "`, expected: "`\" || Name != \"`"},
+		{name: "double quote and backquote", input: "a\"`b", expected: `'a"` + "`" + `b'`},
+		{name: "backslash", input: `C:\Users`, expected: "`C:\\Users`"},
+		{name: "escape sequence", input: `a\"b`, expected: "`a\\\"b`"},
+		{name: "all quotes", input: `a"b'c`, expected: "`a\"b'c`"},
+		{name: "line break", input: "a\nb", expected: "`a\nb`"},
+		{name: "control characters", input: "a\tb\rc\x00", expected: "\"a\tb\rc\x00\""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := Quote(test.input)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			if actual != test.expected {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
+			}
+			expr, err := Parse(`Name == ` + actual + ` && Other == 1`)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			for _, name := range []string{test.input, test.input + "x", actual} {
+				ok, err := expr.Eval(testTarget{"Name": name, "Other": 1})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ok != (name == test.input) {
+					t.Errorf(testTemplate, name, name == test.input, ok)
+				}
+			}
+		})
+	}
+}
+
+func TestQuote_error(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "backslash and backquote", input: "a\\`", expected: `parse error: cannot write string "a\\` + "`" + `" as a literal`},
+		{name: "line break and backquote", input: "a\n`", expected: `parse error: cannot write string "a\n` + "`" + `" as a literal`},
+		{name: "invalid utf8", input: "a\xff", expected: `parse error: cannot write string "a\xff" as a literal: invalid utf8 encoding`},
+		{name: "replacement character", input: "a\ufffd", expected: "parse error: cannot write string \"a\ufffd\" as a literal: invalid utf8 encoding"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Quote(test.input)
+			if err == nil || err.Error() != test.expected {
+				t.Errorf(testTemplate, test.input, test.expected, err)
+			}
+		})
+	}
+}
+
+func TestQuoteIdent(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "plain", input: `HitPoint`},
+		{name: "underscore and digit", input: `hit_point2`},
+		{name: "multibyte", input: `体力`},
+		{name: "space", input: `Hit Point`, expected: `parse error: invalid field name "Hit Point"`},
+		{name: "injection", input: `A==1||B`, expected: `parse error: invalid field name "A==1||B"`},
+		{name: "leading digit", input: `1st`, expected: `parse error: invalid field name "1st"`},
+		{name: "keyword", input: `containsany`, expected: `parse error: invalid field name "containsany"`},
+		{name: "membership keyword", input: `in`, expected: `parse error: invalid field name "in"`},
+		{name: "bool", input: `TRUE`, expected: `parse error: invalid field name "TRUE"`},
+		{name: "empty", input: ``, expected: `parse error: invalid field name ""`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := QuoteIdent(test.input)
+			if test.expected != "" {
+				if err == nil || err.Error() != test.expected {
+					t.Errorf(testTemplate, test.input, test.expected, err)
+				}
+				return
+			}
+			if err != nil || actual != test.input {
+				t.Fatalf(testTemplate, test.input, test.input, err)
+			}
+			expr, err := Parse(actual + ` == 1`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok, err := expr.Eval(testTarget{test.input: 1}); !ok || err != nil {
+				t.Errorf(testTemplate, test.input, true, err)
+			}
+		})
+	}
+}
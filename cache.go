@@ -0,0 +1,124 @@
+package filter
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultParseCacheSize is the default maximum number of expressions held by the parse cache.
+const DefaultParseCacheSize = 128
+
+// parseCache stores parsed expressions keyed by source string for ParseCached.
+var parseCache = newLRU(DefaultParseCacheSize)
+
+// ParseCached parses a string expression into an Expr like Parse,
+// reusing the expression parsed earlier from the identical input if it is still cached.
+// The returned Expr is shared between callers, which is safe since evaluation does not modify it.
+// Errors are not cached.
+func ParseCached(input string) (*Expr, error) {
+	if expr, ok := parseCache.get(input); ok {
+		return expr, nil
+	}
+	expr, err := Parse(input)
+	if err != nil {
+		return nil, err
+	}
+	parseCache.add(input, expr)
+	return expr, nil
+}
+
+// SetParseCacheSize sets the maximum number of expressions held by the parse cache.
+// The least recently used expressions are evicted if the cache holds more than n.
+// A size of 0 or less disables caching.
+func SetParseCacheSize(n int) {
+	parseCache.resize(n)
+}
+
+// ClearParseCache removes all expressions from the parse cache.
+func ClearParseCache() {
+	parseCache.clear()
+}
+
+// lru is a concurrency-safe least recently used cache of expressions.
+type lru struct {
+	mu    sync.Mutex               // guards the fields below
+	size  int                      // maximum number of entries
+	ll    *list.List               // entries ordered from most to least recently used
+	items map[string]*list.Element // entries keyed by source string
+}
+
+// lruEntry is an entry of the lru cache.
+type lruEntry struct {
+	key  string
+	expr *Expr
+}
+
+// newLRU creates a new lru cache with the given maximum size.
+func newLRU(size int) *lru {
+	return &lru{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// get returns the cached expression and marks it as most recently used.
+func (c *lru) get(key string) (*Expr, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return el.Value.(*lruEntry).expr, true
+}
+
+// add caches the expression, evicting the least recently used entries beyond the size.
+func (c *lru) add(key string, expr *Expr) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.size <= 0 {
+		return
+	}
+	if el, ok := c.items[key]; ok {
+		el.Value.(*lruEntry).expr = expr
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&lruEntry{key: key, expr: expr})
+	c.evict()
+}
+
+// resize changes the maximum size, evicting entries beyond it.
+func (c *lru) resize(size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.size = size
+	c.evict()
+}
+
+// clear removes all entries.
+func (c *lru) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ll.Init()
+	clear(c.items)
+}
+
+// evict removes the least recently used entries beyond the size.
+// The caller must hold the lock.
+func (c *lru) evict() {
+	for c.ll.Len() > max(c.size, 0) {
+		el := c.ll.Back()
+		c.ll.Remove(el)
+		delete(c.items, el.Value.(*lruEntry).key)
+	}
+}
+
+// len returns the number of entries.
+func (c *lru) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
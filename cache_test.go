@@ -0,0 +1,124 @@
+package filter
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func resetParseCache(t *testing.T) {
+	t.Helper()
+	ClearParseCache()
+	SetParseCacheSize(DefaultParseCacheSize)
+	t.Cleanup(func() {
+		ClearParseCache()
+		SetParseCacheSize(DefaultParseCacheSize)
+	})
+}
+
+func TestParseCached(t *testing.T) {
+	resetParseCache(t)
+	input := `Int==42 && String=~"^Hello"`
+	first, err := ParseCached(input)
+	if err != nil {
+		t.Fatalf(testTemplate, input, "", err)
+	}
+	second, err := ParseCached(input)
+	if err != nil {
+		t.Fatalf(testTemplate, input, "", err)
+	}
+	if first != second {
+		t.Errorf(testTemplate, input, first, second)
+	}
+	ok, err := second.Eval(testObject)
+	if err != nil || !ok {
+		t.Errorf(testTemplate, input, true, err)
+	}
+	ClearParseCache()
+	third, err := ParseCached(input)
+	if err != nil {
+		t.Fatalf(testTemplate, input, "", err)
+	}
+	if third == first {
+		t.Errorf(testTemplate, input, "new expression", third)
+	}
+}
+
+func TestParseCached_error(t *testing.T) {
+	resetParseCache(t)
+	input := `Int==`
+	for range 2 {
+		expr, err := ParseCached(input)
+		if err == nil || !strings.Contains(err.Error(), "expected value") {
+			t.Errorf(testTemplate, input, "expected value", err)
+		}
+		if expr != nil {
+			t.Errorf(testTemplate, input, nil, expr)
+		}
+	}
+	if n := parseCache.len(); n != 0 {
+		t.Errorf(testTemplate, input, 0, n)
+	}
+}
+
+func TestSetParseCacheSize(t *testing.T) {
+	resetParseCache(t)
+	SetParseCacheSize(2)
+	a, _ := ParseCached(`A==1`)
+	b, _ := ParseCached(`B==1`)
+	if again, _ := ParseCached(`A==1`); again != a {
+		t.Errorf(testTemplate, `A==1`, a, again)
+	}
+	if _, err := ParseCached(`C==1`); err != nil {
+		t.Fatal(err)
+	}
+	if n := parseCache.len(); n != 2 {
+		t.Errorf(testTemplate, "len", 2, n)
+	}
+	if again, _ := ParseCached(`A==1`); again != a {
+		t.Errorf(testTemplate, `A==1`, a, again)
+	}
+	if again, _ := ParseCached(`B==1`); again == b {
+		t.Errorf(testTemplate, `B==1`, "evicted", again)
+	}
+	SetParseCacheSize(1)
+	if n := parseCache.len(); n != 1 {
+		t.Errorf(testTemplate, "len", 1, n)
+	}
+	SetParseCacheSize(0)
+	if n := parseCache.len(); n != 0 {
+		t.Errorf(testTemplate, "len", 0, n)
+	}
+	first, _ := ParseCached(`A==1`)
+	second, _ := ParseCached(`A==1`)
+	if first == second {
+		t.Errorf(testTemplate, `A==1`, "not cached", second)
+	}
+}
+
+func TestParseCached_concurrent(t *testing.T) {
+	resetParseCache(t)
+	SetParseCacheSize(8)
+	var wg sync.WaitGroup
+	for i := range 16 {
+		wg.Go(func() {
+			for j := range 100 {
+				input := `Int>` + strconv.Itoa((i+j)%12)
+				expr, err := ParseCached(input)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := expr.Eval(testObject); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+	if n := parseCache.len(); n > 8 {
+		t.Errorf(testTemplate, "len", 8, n)
+	}
+}
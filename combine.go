@@ -0,0 +1,155 @@
+package filter
+
+import "fmt"
+
+// And returns an expression that holds when both a and b hold, without parsing them again.
+// a is evaluated first, and b is skipped when a is false unless WithStrictEval is given.
+// Positions in errors of the result refer to the input of each part.
+// If either expression is nil, the other one is returned. An error is returned if a and b were
+// parsed with different options that apply when evaluating or formatting them, such as WithEpsilon.
+func And(a, b *Expr) (*Expr, error) {
+	return combine(a, b, tokenAND)
+}
+
+// Or returns an expression that holds when a or b holds, without parsing them again.
+// a is evaluated first, and b is skipped when a is true unless WithStrictEval is given.
+// Positions in errors of the result refer to the input of each part.
+// If either expression is nil, the other one is returned. An error is returned if a and b were
+// parsed with different options that apply when evaluating or formatting them, such as WithEpsilon.
+func Or(a, b *Expr) (*Expr, error) {
+	return combine(a, b, tokenOR)
+}
+
+// Not returns an expression that holds when a does not hold, without parsing it again.
+// It returns nil if a is nil.
+func Not(a *Expr) *Expr {
+	if a.empty() {
+		return nil
+	}
+	p := a.parser
+	p.nodes = append(make([]node, 0, len(a.parser.nodes)+1), a.parser.nodes...)
+	root := newNodeNOT(&p, a.root, token{typ: tokenNOT, v: tokenNOT.literal()})
+	return &Expr{
+		parser: p,
+		root:   root,
+	}
+}
+
+// combine joins a and b with the logical operator typ.
+// The nodes of b are appended after those of a by graft.
+func combine(a, b *Expr, typ tokenType) (*Expr, error) {
+	switch {
+	case a.empty():
+		return b, nil
+	case b.empty():
+		return a, nil
+	}
+	if name := a.parser.opts.mismatch(&b.parser.opts); name != "" {
+		return nil, &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("cannot combine expressions parsed with different options: %s", name),
+		}
+	}
+	p := a.parser
+	p.nodes = make([]node, 0, len(a.parser.nodes)+len(b.parser.nodes)+1)
+	p.nodes = append(p.nodes, a.parser.nodes...)
+	p.idents = make(map[string]struct{}, len(a.parser.idents)+len(b.parser.idents))
+	for ident := range a.parser.idents {
+		p.idents[ident] = struct{}{}
+	}
+	base := p.graft(&b.parser)
+	root := newNodeBinary(&p, a.root, token{typ: typ, v: typ.literal()}, b.root+base)
+	return &Expr{
+		parser: p,
+		root:   root,
+	}, nil
+}
+
+// mismatch returns the name of the first option that applies when evaluating or formatting an expression
+// and differs between o and q, or an empty string if there is none. Options that only apply when parsing
+// are already reflected in the nodes.
+func (o *options) mismatch(q *options) string {
+	switch {
+	case o.strict != q.strict:
+		return "WithStrictEval"
+	case o.threeValued != q.threeValued:
+		return "WithThreeValuedLogic"
+	case o.opAsFalse != q.opAsFalse:
+		return "WithUnsupportedOpAsFalse"
+	case o.normalize != q.normalize || o.form != q.form:
+		return "WithNormalization"
+	case o.coerce != q.coerce:
+		return "WithNumericStringCoercion"
+	case o.truthy != q.truthy:
+		return "WithTruthyBool"
+	case o.runes != q.runes:
+		return "WithRuneComparison"
+	case o.extendedUnits != q.extendedUnits:
+		return "WithExtendedDurationUnits"
+	case o.epsilon != q.epsilon:
+		return "WithEpsilon"
+	case o.tolerance != q.tolerance:
+		return "WithTolerance"
+	case o.decimalComma != q.decimalComma:
+		return "WithDecimalComma"
+	case o.byteLength != q.byteLength:
+		return "WithByteLength"
+	case o.floatVerb != q.floatVerb || o.floatPrec != q.floatPrec:
+		return "WithFloatFormat"
+	case o.maxMatchLen != q.maxMatchLen:
+		return "WithMaxMatchLen"
+	case o.collator.collate() != q.collator.collate():
+		return "WithCollator"
+	case o.ordering != q.ordering:
+		return "WithStringOrdering"
+	default:
+		return ""
+	}
+}
+
+// graft appends the nodes and identifiers of q to p and returns the index of its first node.
+// The input of q is appended to the input of p on a new line, and the child indices
+// and token positions of the nodes are shifted accordingly.
+func (p *parser) graft(q *parser) int {
+	offset := len(p.lexer.input) + 1
+	p.lexer.input += "\n" + q.lexer.input
+	base := len(p.nodes)
+	for _, n := range q.nodes {
+		switch n.typ {
+		case nodeBinary:
+			n.left += base
+			n.right += base
+		case nodeNOT:
+			n.left += base
+		}
+		p.nodes = append(p.nodes, shift(n, offset))
+	}
+	for ident := range q.idents {
+		p.idents[ident] = struct{}{}
+	}
+	return base
+}
+
+// shift returns a copy of the node with the positions of its tokens moved by offset.
+func shift(n node, offset int) node {
+	n.ident.pos += offset
+	n.op.pos += offset
+	n.val.pos += offset
+	n.ref.pos += offset
+	n.arith.pos += offset
+	n.arg.pos += offset
+	n.key.pos += offset
+	if n.items != nil {
+		items := make([]node, len(n.items))
+		for i, item := range n.items {
+			items[i] = shift(item, offset)
+		}
+		n.items = items
+	}
+	return n
+}
+
+// empty reports whether the expression has no nodes, such as a nil expression.
+func (e *Expr) empty() bool {
+	return e == nil || len(e.parser.nodes) == 0
+}
@@ -0,0 +1,200 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnd(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        string
+		b        string
+		expected bool
+	}{
+		{name: "both true", a: `Int==42`, b: `String=~"^Hello"`, expected: true},
+		{name: "left false", a: `Int==0`, b: `String=~"^Hello"`, expected: false},
+		{name: "right false", a: `Int==42`, b: `String=="x"`, expected: false},
+		{name: "nested", a: `Int>40 || Bool==false`, b: `!(Float64<3) && Duration==1500ms`, expected: true},
+		{name: "lists", a: `Tags containsany ("a")`, b: `String=~("^x", "World$")`, expected: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, b := mustParse(t, test.a), mustParse(t, test.b)
+			combined := mustAnd(t, a, b)
+			actual, err := combined.Eval(testObject)
+			if err != nil {
+				t.Fatalf(testTemplate, test.a, test.expected, err)
+			}
+			if actual != test.expected {
+				t.Errorf(testTemplate, test.a, test.expected, actual)
+			}
+			reparsed := mustParse(t, combined.String())
+			if actual, err := reparsed.Eval(testObject); err != nil || actual != test.expected {
+				t.Errorf(testTemplate, combined.String(), test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestOr(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        string
+		b        string
+		expected bool
+	}{
+		{name: "both false", a: `Int==0`, b: `String=="x"`, expected: false},
+		{name: "left true", a: `Int==42`, b: `String=="x"`, expected: true},
+		{name: "right true", a: `Int==0`, b: `String=="HelloWorld"`, expected: true},
+		{name: "nested", a: `Int==0 && Bool==true`, b: `!(Bool==false) && Int!=0`, expected: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := mustOr(t, mustParse(t, test.a), mustParse(t, test.b)).Eval(testObject)
+			if err != nil {
+				t.Fatalf(testTemplate, test.a, test.expected, err)
+			}
+			if actual != test.expected {
+				t.Errorf(testTemplate, test.a, test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestNot(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected bool
+	}{
+		{name: "comparison", input: `Int==42`, expected: false},
+		{name: "or", input: `Int==0 || Bool==false`, expected: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr := mustParse(t, test.input)
+			actual, err := Not(expr).Eval(testObject)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			if actual != test.expected {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
+			}
+			if actual, err := expr.Eval(testObject); err != nil || actual == test.expected {
+				t.Errorf(testTemplate, test.input, !test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestAnd_format(t *testing.T) {
+	a := mustParse(t, `Name=="a" || Name=~*'^B'`)
+	b := mustParse(t, `Tags containsany ("x", "y")`)
+	expected := `(Name == "a" || Name =~* '^B') && Tags containsany ("x", "y")`
+	if actual := mustAnd(t, a, b).String(); actual != expected {
+		t.Errorf(testTemplate, "and", expected, actual)
+	}
+	expected = `!(Name == "a" || Name =~* '^B') || Tags containsany ("x", "y")`
+	if actual := mustOr(t, Not(a), b).String(); actual != expected {
+		t.Errorf(testTemplate, "or", expected, actual)
+	}
+	if actual := a.String(); actual != `Name == "a" || Name =~* '^B'` {
+		t.Errorf(testTemplate, "unchanged", `Name == "a" || Name =~* '^B'`, actual)
+	}
+}
+
+func TestAnd_fields(t *testing.T) {
+	combined := mustAnd(t, mustParse(t, `Int==42`), mustParse(t, `String!="" && Int>0`))
+	_, fields, err := combined.EvalWithFields(testObject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 2 || len(combined.parser.idents) != 2 {
+		t.Errorf(testTemplate, "fields", 2, fields)
+	}
+}
+
+func TestAnd_error(t *testing.T) {
+	combined := mustAnd(t, mustParse(t, `Int==42`), mustParse(t, "String!=\"\" &&\n  Missing==1"))
+	_, err := combined.Eval(testObject)
+	if err == nil || !strings.Contains(err.Error(), `"Missing"`) {
+		t.Errorf(testTemplate, "error", "field not found", err)
+	}
+}
+
+func TestAnd_nil(t *testing.T) {
+	expr := mustParse(t, `Int==42`)
+	if mustAnd(t, nil, expr) != expr || mustAnd(t, expr, nil) != expr || mustOr(t, nil, expr) != expr || mustOr(t, expr, nil) != expr {
+		t.Errorf(testTemplate, "nil", "other expression", nil)
+	}
+	if mustAnd(t, nil, nil) != nil || Not(nil) != nil {
+		t.Errorf(testTemplate, "nil", nil, "expression")
+	}
+}
+
+func TestAnd_options(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        []Option
+		b        []Option
+		expected string
+	}{
+		{name: "same", a: []Option{WithEpsilon(0.1)}, b: []Option{WithEpsilon(0.1)}},
+		{name: "parse only", a: []Option{WithMaxNodes(10)}, b: []Option{WithRegexDisabled()}},
+		{name: "epsilon", a: []Option{WithEpsilon(0.1)}, expected: `parse error: cannot combine expressions parsed with different options: WithEpsilon`},
+		{name: "coercion", b: []Option{WithNumericStringCoercion()}, expected: `parse error: cannot combine expressions parsed with different options: WithNumericStringCoercion`},
+		{name: "three-valued", b: []Option{WithThreeValuedLogic()}, expected: `parse error: cannot combine expressions parsed with different options: WithThreeValuedLogic`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, err := Parse(`Int==42`, test.a...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := Parse(`Float64>3`, test.b...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, combine := range []func(a, b *Expr) (*Expr, error){And, Or} {
+				combined, err := combine(a, b)
+				if test.expected == "" {
+					if err != nil || combined == nil {
+						t.Errorf(testTemplate, test.name, "combined", err)
+					}
+					continue
+				}
+				if combined != nil || err == nil || err.Error() != test.expected {
+					t.Errorf(testTemplate, test.name, test.expected, err)
+				}
+			}
+		})
+	}
+}
+
+func mustAnd(t *testing.T, a, b *Expr) *Expr {
+	t.Helper()
+	expr, err := And(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return expr
+}
+
+func mustOr(t *testing.T, a, b *Expr) *Expr {
+	t.Helper()
+	expr, err := Or(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return expr
+}
+
+func mustParse(t *testing.T, input string) *Expr {
+	t.Helper()
+	expr, err := Parse(input)
+	if err != nil {
+		t.Fatalf(testTemplate, input, "", err)
+	}
+	return expr
+}
@@ -0,0 +1,172 @@
+package filter
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// maxExactInt is the largest magnitude up to which every integer is exactly representable as float64.
+const maxExactInt = 1 << 53
+
+// IsConstant reports whether the expression evaluates to the same value for every target,
+// and if so returns that value. The detection is conservative and only recognizes clear cases:
+// comparisons of the same field against number literals under AND whose ranges cannot overlap,
+// such as X>5 && X<1 or X==1 && X==2, comparisons of fields given to WithSkipFields,
+// which always hold, the literals true and false, and constants propagated through !, && and ||.
+// Such comparisons are assumed to apply to a number field, and evaluation errors, such as
+// a missing field, are not taken into account. If ok is false, nothing is known about the value.
+func (e *Expr) IsConstant() (val, ok bool) {
+	if e == nil || len(e.parser.nodes) == 0 {
+		return false, false
+	}
+	return e.constant(e.root)
+}
+
+// constant reports whether the node at index i has a constant value.
+func (e *Expr) constant(i int) (val, ok bool) {
+	n := e.parser.nodes[i]
+	switch n.typ {
+	case nodeNOT:
+		val, ok = e.constant(n.left)
+		return ok && !val, ok
+	case nodeBinary:
+		var operands []int
+		operands = e.operands(i, n.op.typ, operands)
+		// The operator short-circuits on this value, and yields the other one if no operand does.
+		short := n.op.typ == tokenOR
+		all := true
+		for _, j := range operands {
+			v, ok := e.constant(j)
+			if ok && v == short {
+				return short, true
+			}
+			all = all && ok
+		}
+		if all {
+			return !short, true
+		}
+		// Under three-valued logic, a contradiction of a missing field is unknown rather than false.
+		if n.op.typ == tokenAND && !e.parser.opts.threeValued {
+			if _, ok := e.disjoint(operands); ok {
+				return false, true
+			}
+		}
+	case nodeComparison:
+		if n.skip {
+			return true, true
+		}
+	case nodeBool:
+		return n.isTrue(), true
+	}
+	return false, false
+}
+
+// operands collects the operands of a chain of the same logical operator.
+func (e *Expr) operands(i int, typ tokenType, operands []int) []int {
+	n := e.parser.nodes[i]
+	if n.typ != nodeBinary || n.op.typ != typ {
+		return append(operands, i)
+	}
+	operands = e.operands(n.left, typ, operands)
+	return e.operands(n.right, typ, operands)
+}
+
+// checkContradictions returns an error if the operands of a chain of && in the tree under
+// the node at index i include number comparisons that cannot all hold, as IsConstant detects them.
+// Chains of || are only searched for such chains among their operands, and never reported as a whole.
+func (e *Expr) checkContradictions(i int) error {
+	n := e.parser.nodes[i]
+	switch n.typ {
+	case nodeNOT:
+		return e.checkContradictions(n.left)
+	case nodeBinary:
+		operands := e.operands(i, n.op.typ, nil)
+		if n.op.typ == tokenAND {
+			if j, ok := e.disjoint(operands); ok {
+				c := e.parser.nodes[j]
+				var b strings.Builder
+				e.format(&b, j)
+				return &Error{
+					Kind: KindParse,
+					Err:  fmt.Errorf("contradictory comparison of %q at %d:%d: %q", c.ident.v, c.ident.line, c.ident.col, b.String()),
+					Line: c.ident.line,
+					Col:  c.ident.col,
+				}
+			}
+		}
+		for _, j := range operands {
+			if err := e.checkContradictions(j); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// disjoint reports whether the number comparisons among the operands
+// cannot all hold for any value of a field, and if so returns the index
+// of the comparison that ruled out the last remaining values.
+func (e *Expr) disjoint(operands []int) (int, bool) {
+	ranges := make(map[string]interval)
+	for _, j := range operands {
+		n := e.parser.nodes[j]
+		if n.typ != nodeComparison || n.fn != transformNone || n.isList() || n.isArith() || n.isIndex() || n.skip || !n.hasNum || math.Abs(n.num) > maxExactInt {
+			continue
+		}
+		r, ok := ranges[n.ident.v]
+		if !ok {
+			r = interval{lo: math.Inf(-1), hi: math.Inf(1), loIncl: true, hiIncl: true}
+		}
+		eps := e.parser.opts.epsilon
+		switch n.op.typ {
+		case tokenGT:
+			r = r.above(n.num, false)
+		case tokenGTE:
+			r = r.above(n.num, true)
+		case tokenLT:
+			r = r.below(n.num, false)
+		case tokenLTE:
+			r = r.below(n.num, true)
+		case tokenEQ:
+			r = r.above(n.num-eps, true).below(n.num+eps, true)
+		case tokenApprox:
+			tol := e.parser.opts.tolerance
+			r = r.above(n.num-tol, true).below(n.num+tol, true)
+		default:
+			continue
+		}
+		if r.empty() {
+			return j, true
+		}
+		ranges[n.ident.v] = r
+	}
+	return 0, false
+}
+
+// interval is a range of numbers with open or closed bounds.
+type interval struct {
+	lo, hi         float64
+	loIncl, hiIncl bool
+}
+
+// above narrows the interval to the numbers greater than v, or equal to it if incl is true.
+func (r interval) above(v float64, incl bool) interval {
+	if v > r.lo || (v == r.lo && !incl) {
+		r.lo, r.loIncl = v, incl
+	}
+	return r
+}
+
+// below narrows the interval to the numbers less than v, or equal to it if incl is true.
+func (r interval) below(v float64, incl bool) interval {
+	if v < r.hi || (v == r.hi && !incl) {
+		r.hi, r.hiIncl = v, incl
+	}
+	return r
+}
+
+// empty reports whether no number is in the interval.
+func (r interval) empty() bool {
+	return r.lo > r.hi || (r.lo == r.hi && !(r.loIncl && r.hiIncl))
+}
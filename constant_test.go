@@ -0,0 +1,90 @@
+package filter
+
+import "testing"
+
+func TestExpr_IsConstant(t *testing.T) {
+	type constant struct {
+		val bool
+		ok  bool
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected constant
+	}{
+		{name: "comparison", input: `HP>50`, expected: constant{}},
+		{name: "contradiction", input: `HP>5 && HP<1`, expected: constant{val: false, ok: true}},
+		{name: "contradiction reversed", input: `HP<1 && HP>5`, expected: constant{val: false, ok: true}},
+		{name: "contradiction equality", input: `HP==1 && HP==2`, expected: constant{val: false, ok: true}},
+		{name: "contradiction equality and ordering", input: `HP==1 && HP>2`, expected: constant{val: false, ok: true}},
+		{name: "contradiction open bounds", input: `HP>1 && HP<1`, expected: constant{val: false, ok: true}},
+		{name: "contradiction in chain", input: `HP>=10 && Name=="a" && HP<=9`, expected: constant{val: false, ok: true}},
+		{name: "contradiction chained comparison", input: `5<HP<1`, expected: constant{val: false, ok: true}},
+		{name: "negated contradiction", input: `!(HP>5 && HP<1)`, expected: constant{val: true, ok: true}},
+		{name: "double negated contradiction", input: `!(!(HP>5 && HP<1))`, expected: constant{val: false, ok: true}},
+		{name: "contradiction under or", input: `HP>5 && HP<1 || MP>1`, expected: constant{}},
+		{name: "negated contradiction under or", input: `!(HP>5 && HP<1) || MP>1`, expected: constant{val: true, ok: true}},
+		{name: "contradiction under and", input: `MP>1 && (HP>5 && HP<1)`, expected: constant{val: false, ok: true}},
+		{name: "both contradictions under or", input: `HP>5 && HP<1 || MP>5 && MP<1`, expected: constant{val: false, ok: true}},
+		{name: "closed bounds", input: `HP>=1 && HP<=1`, expected: constant{}},
+		{name: "overlap", input: `HP>1 && HP<5`, expected: constant{}},
+		{name: "different fields", input: `HP>5 && MP<1`, expected: constant{}},
+		{name: "or", input: `HP>5 || HP<1`, expected: constant{}},
+		{name: "not equal", input: `HP!=1 && HP==1`, expected: constant{}},
+		{name: "equality within epsilon", input: `HP==1 && HP==1.1`, opts: []Option{WithEpsilon(0.1)}, expected: constant{}},
+		{name: "approx within tolerance", input: `HP~=1 && HP>=1.5`, opts: []Option{WithTolerance(0.5)}, expected: constant{}},
+		{name: "contradiction approx", input: `HP~=1 && HP>1.5`, opts: []Option{WithTolerance(0.4)}, expected: constant{val: false, ok: true}},
+		{name: "string function", input: `lower(Name)>"5" && lower(Name)<"1"`, expected: constant{}},
+		{name: "string values", input: `Name>"5" && Name<"1"`, expected: constant{}},
+		{name: "modulo", input: `HP%10==1 && HP>100`, expected: constant{}},
+		{name: "skipped field", input: `HP>5 || MP>1`, opts: []Option{WithSkipFields("HP")}, expected: constant{val: true, ok: true}},
+		{name: "skipped contradiction", input: `HP>5 && HP<1 && MP>1`, opts: []Option{WithSkipFields("HP")}, expected: constant{}},
+		{name: "contradiction under three-valued logic", input: `!(HP>5 && HP<1)`, opts: []Option{WithThreeValuedLogic()}, expected: constant{}},
+		{name: "beyond exact integers", input: `N>9007199254740993 && N<9007199254740994`, expected: constant{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			val, ok := expr.IsConstant()
+			if actual := (constant{val: val, ok: ok}); actual != test.expected {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestExpr_IsConstant_eval(t *testing.T) {
+	inputs := []string{
+		`Int>50 && Int<1`,
+		`!(Int>=43 && Int<=41)`,
+		`Float64==3.14 && Float64==2.71 || Uint8>200 && Uint8<100`,
+	}
+	for _, input := range inputs {
+		expr, err := Parse(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		val, ok := expr.IsConstant()
+		if !ok {
+			t.Fatalf(testTemplate, input, true, ok)
+		}
+		actual, err := expr.Eval(testObject)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != val {
+			t.Errorf(testTemplate, input, val, actual)
+		}
+	}
+}
+
+func TestExpr_IsConstant_nil(t *testing.T) {
+	var e *Expr
+	if val, ok := e.IsConstant(); val || ok {
+		t.Errorf(testTemplate, "nil", false, ok)
+	}
+}
@@ -0,0 +1,47 @@
+package filter
+
+// Relative costs of comparisons used by Cost.
+const (
+	costComparison = 1  // plain comparison
+	costCoerce     = 2  // ordering comparison that may parse a numeric string
+	costTransform  = 1  // string function applied to the field
+	costRegex      = 10 // regular expression match
+)
+
+// Cost returns an estimate of the work needed to evaluate the expression.
+// It is the sum of the weights of all comparisons, where a regex match weighs more than
+// an ordering comparison under WithNumericStringCoercion, which weighs more than a plain comparison.
+// A list weighs as much as one comparison or regex match per value. Short-circuiting is not taken into account.
+// The value is only meaningful relative to other expressions, such as for ordering operands.
+func (e *Expr) Cost() int {
+	if e == nil || len(e.parser.nodes) == 0 {
+		return 0
+	}
+	return e.cost(e.root)
+}
+
+// cost returns the cost of the node at index i and its children.
+func (e *Expr) cost(i int) int {
+	n := e.parser.nodes[i]
+	switch n.typ {
+	case nodeBinary:
+		return e.cost(n.left) + e.cost(n.right)
+	case nodeNOT:
+		return e.cost(n.left)
+	case nodeComparison:
+		c := costComparison
+		switch {
+		case n.op.typ.isRegexOperatorType():
+			c = costRegex * max(len(n.items), 1)
+		case n.op.typ.isOrderingOperatorType() && e.parser.opts.coerce:
+			c = costCoerce
+		case n.op.typ.isListOperatorType():
+			c = costComparison * len(n.items)
+		}
+		if n.fn != transformNone {
+			c += costTransform
+		}
+		return c
+	}
+	return 0
+}
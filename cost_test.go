@@ -0,0 +1,59 @@
+package filter
+
+import "testing"
+
+func TestExpr_Cost(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected int
+	}{
+		{name: "single comparison", input: `HP>50`, expected: 1},
+		{name: "numeric", input: `HP>50 && MP<10 || Level==3`, expected: 3},
+		{name: "not", input: `!(HP>50)`, expected: 1},
+		{name: "regex", input: `Name=~"^A"`, expected: 10},
+		{name: "negated case-insensitive regex", input: `Name!~*"^A"`, expected: 10},
+		{name: "coerced ordering", input: `Code>"100"`, opts: []Option{WithNumericStringCoercion()}, expected: 2},
+		{name: "coerced equality", input: `Code=="100"`, opts: []Option{WithNumericStringCoercion()}, expected: 1},
+		{name: "ordering without coercion", input: `Code>"100"`, expected: 1},
+		{name: "string function", input: `lower(Name)=="a"`, expected: 2},
+		{name: "list", input: `Tags containsany ("a", "b", "c")`, expected: 3},
+		{name: "regex list", input: `Path =~ ("a", "b")`, expected: 20},
+		{name: "empty list", input: `Tags containsall ()`, expected: 0},
+		{name: "chain", input: `1<HP<100`, expected: 2},
+		{name: "mixed", input: `HP>50 && (Name=~"^A" || lower(Tag)=~"x")`, expected: 22},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			if actual := expr.Cost(); actual != test.expected {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestExpr_Cost_regexHeavier(t *testing.T) {
+	numeric, err := Parse(`HP>50 && MP<10 && Level>=3 && Speed<=5`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	regex, err := Parse(`Name=~"^A" && Class=~"mage"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if numeric.Cost() >= regex.Cost() {
+		t.Errorf(testTemplate, "cost", "numeric < regex", []int{numeric.Cost(), regex.Cost()})
+	}
+}
+
+func TestExpr_Cost_nil(t *testing.T) {
+	var e *Expr
+	if actual := e.Cost(); actual != 0 {
+		t.Errorf(testTemplate, "nil", 0, actual)
+	}
+}
@@ -0,0 +1,156 @@
+package filter
+
+import (
+	"encoding/binary"
+	"hash"
+	"hash/fnv"
+	"math"
+	"strings"
+)
+
+// Equal reports whether a and b have the same expression tree: the same structure, operators,
+// fields and values. Whitespace, parentheses and the way values are written do not matter,
+// so (A == 0x10) equals A==16, Latency < 1h equals Latency < 60m, and "a" equals 'a' or `a`.
+// Chained comparisons are compared in their desugared form, while operands of && and ||
+// must be in the same order and grouping. Options of the expressions are not compared.
+// Two nil or empty expressions are equal.
+func Equal(a, b *Expr) bool {
+	if a.empty() || b.empty() {
+		return a.empty() == b.empty()
+	}
+	return equalNodes(a.parser.nodes, a.root, b.parser.nodes, b.root, sameValue)
+}
+
+// sameValue reports whether two comparisons have the same value, compared as its kind
+// rather than as written: numbers, durations and times by the value they denote,
+// booleans ignoring case, and strings regardless of their quotes.
+func sameValue(x, y node) bool {
+	switch {
+	case x.val.typ.isStringType() && y.val.typ.isStringType():
+		return x.val.v == y.val.v
+	case x.val.typ != y.val.typ:
+		return false
+	case x.hasInt && y.hasInt:
+		return x.int == y.int
+	case x.hasUint && y.hasUint:
+		return x.uint == y.uint
+	case x.hasNum && y.hasNum:
+		return x.num == y.num
+	case x.hasDur && y.hasDur:
+		return x.dur == y.dur
+	case x.hasTime && y.hasTime:
+		return x.time.Equal(y.time)
+	case x.val.typ == tokenNow:
+		return x.dur == y.dur
+	case x.val.typ == tokenBool:
+		return strings.EqualFold(x.val.v, y.val.v)
+	default:
+		return x.val.v == y.val.v
+	}
+}
+
+// Hash returns a hash of the expression tree that agrees with Equal: equal expressions have the
+// same hash, such as (A == 0x10) and A==16, while different ones rarely do. It is computed with
+// FNV-1a over the structure, operators, fields and values, so it is the same in every process
+// running the same version of this package, and can key a cache of results or deduplicate rules.
+// Options of the expression are not hashed.
+func (e *Expr) Hash() uint64 {
+	h := hasher{Hash64: fnv.New64a()}
+	if !e.empty() {
+		h.node(e.parser.nodes, e.root)
+	}
+	return h.Sum64()
+}
+
+// hasher writes the parts of an expression tree to a hash.
+type hasher struct {
+	hash.Hash64
+	buf [8]byte
+}
+
+// node writes the node at index i of nodes and its children.
+func (h *hasher) node(nodes []node, i int) {
+	n := nodes[i]
+	h.uint(uint64(n.typ))
+	h.uint(uint64(n.op.typ))
+	switch n.typ {
+	case nodeBinary:
+		h.node(nodes, n.left)
+		h.node(nodes, n.right)
+	case nodeNOT:
+		h.node(nodes, n.left)
+	case nodeComparison:
+		h.uint(uint64(len(n.items)))
+		for k := range n.items {
+			h.node(n.items, k)
+		}
+		h.string(n.ident.v)
+		h.uint(uint64(n.fn))
+		h.string(n.ref.v)
+		h.uint(uint64(n.arith.typ))
+		h.uint(n.bits)
+		h.key(n)
+		h.value(n)
+	case nodeBool:
+		if n.isTrue() {
+			h.uint(1)
+		} else {
+			h.uint(0)
+		}
+	}
+}
+
+// key writes the key of a map field as sameKey compares it.
+func (h *hasher) key(n node) {
+	switch {
+	case n.key.typ == tokenNumber:
+		i, _ := parseInt(n.key.v)
+		h.uint(1)
+		h.uint(uint64(i))
+	case n.isIndex():
+		h.uint(2)
+		h.string(n.key.v)
+	default:
+		h.uint(0)
+	}
+}
+
+// value writes the value of a comparison as sameValue compares it.
+func (h *hasher) value(n node) {
+	if n.val.typ.isStringType() {
+		h.uint(uint64(tokenString))
+		h.string(n.val.v)
+		return
+	}
+	h.uint(uint64(n.val.typ))
+	switch {
+	case n.hasNum:
+		// -0 and 0 are the same number.
+		h.uint(math.Float64bits(n.num + 0))
+	case n.hasInt:
+		h.uint(uint64(n.int))
+	case n.hasUint:
+		h.uint(n.uint)
+	case n.hasDur, n.val.typ == tokenNow:
+		h.uint(uint64(n.dur))
+	case n.hasTime:
+		h.uint(uint64(n.time.Unix()))
+		h.uint(uint64(n.time.Nanosecond()))
+	case n.val.typ == tokenBool:
+		h.string(strings.ToLower(n.val.v))
+	default:
+		h.string(n.val.v)
+	}
+}
+
+// uint writes v.
+func (h *hasher) uint(v uint64) {
+	binary.LittleEndian.PutUint64(h.buf[:], v)
+	_, _ = h.Write(h.buf[:])
+}
+
+// string writes s after its length, so that adjacent strings cannot run together.
+func (h *hasher) string(s string) {
+	h.uint(uint64(len(s)))
+	_, _ = h.Write([]byte(s))
+}
@@ -0,0 +1,125 @@
+package filter
+
+import "testing"
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        string
+		b        string
+		expected bool
+	}{
+		{name: "redundant parentheses", a: `(A==1)`, b: `((A==1))`, expected: true},
+		{name: "whitespace", a: `A==1&&B=="x"`, b: "A == 1\n\t&& B == \"x\"", expected: true},
+		{name: "different value", a: `A==1`, b: `A==2`, expected: false},
+		{name: "different field", a: `A==1`, b: `B==1`, expected: false},
+		{name: "different operator", a: `A==1`, b: `A!=1`, expected: false},
+		{name: "hex number", a: `A==0x10`, b: `A==16`, expected: true},
+		{name: "number forms", a: `A<1_000`, b: `A<1e3`, expected: true},
+		{name: "large unsigned", a: `A==18446744073709551615`, b: `A==0xFFFFFFFFFFFFFFFF`, expected: true},
+		{name: "duration", a: `Latency<1h`, b: `Latency<60m`, expected: true},
+		{name: "time zone", a: `Time<2025-01-01T09:00:00+09:00`, b: `Time<2025-01-01T00:00:00Z`, expected: true},
+		{name: "now offset", a: `Time>now-1h`, b: `Time>now-3600s`, expected: true},
+		{name: "now offset differs", a: `Time>now-1h`, b: `Time>now`, expected: false},
+		{name: "bool case", a: `Flag==TRUE`, b: `Flag==true`, expected: true},
+		{name: "string quotes", a: `Name=="a"`, b: "Name==`a`", expected: true},
+		{name: "string differs from number", a: `Name=="16"`, b: `Name==16`, expected: false},
+		{name: "string case", a: `Name=="a"`, b: `Name=="A"`, expected: false},
+		{name: "case-insensitive regex", a: `Name=~*"^a"`, b: `Name=~*'^a'`, expected: true},
+		{name: "function", a: `lower(Name)=="a"`, b: `Name=="a"`, expected: false},
+		{name: "list", a: `Tags containsany (0x1, "b")`, b: `Tags containsany (1, 'b')`, expected: true},
+		{name: "list order", a: `Tags containsany ("a", "b")`, b: `Tags containsany ("b", "a")`, expected: false},
+		{name: "chain", a: `1<A<5`, b: `A>1 && A<5`, expected: true},
+		{name: "not", a: `!(A==1)`, b: `!A==1`, expected: true},
+		{name: "operand order", a: `A==1 && B==2`, b: `B==2 && A==1`, expected: false},
+		{name: "grouping", a: `A==1 && (B==2 && C==3)`, b: `A==1 && B==2 && C==3`, expected: false},
+		{name: "field offset", a: `Deadline<Start+1h`, b: `Deadline<Start + 60m`, expected: true},
+		{name: "field offset sign", a: `Deadline<Start+1h`, b: `Deadline<Start - 1h`, expected: false},
+		{name: "modulo", a: `ID % 16 == 0`, b: `ID%0x10==0`, expected: true},
+		{name: "modulo divisor", a: `ID % 2 == 0`, b: `ID % 3 == 0`, expected: false},
+		{name: "modulo and plain", a: `ID % 2 == 0`, b: `ID == 0`, expected: false},
+		{name: "map key", a: `Headers["a"] == 1`, b: "Headers[`a`]==1", expected: true},
+		{name: "map key differs", a: `Headers["a"] == 1`, b: `Headers["b"] == 1`, expected: false},
+		{name: "map key and plain", a: `Headers["a"] == 1`, b: `Headers == 1`, expected: false},
+		{name: "integer map key", a: `Codes[0x10] == 1`, b: `Codes[16] == 1`, expected: true},
+		{name: "integer and string map key", a: `Codes[16] == 1`, b: `Codes["16"] == 1`, expected: false},
+		{name: "bitwise operator", a: `Perms & 4 == 4`, b: `Perms | 4 == 4`, expected: false},
+		{name: "bitwise negative operand", a: `Perms & -1 == 4`, b: `Perms & 0xFFFFFFFFFFFFFFFF == 4`, expected: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, err := Parse(test.a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := Parse(test.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := Equal(a, b); actual != test.expected {
+				t.Errorf(testTemplate, test.a+" | "+test.b, test.expected, actual)
+			}
+			if actual := Equal(b, a); actual != test.expected {
+				t.Errorf(testTemplate, test.b+" | "+test.a, test.expected, actual)
+			}
+			if actual := a.Hash() == b.Hash(); actual != test.expected {
+				t.Errorf(testTemplate, "hash of "+test.a+" | "+test.b, test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestEqual_nil(t *testing.T) {
+	e := mustParse(t, `A==1`)
+	tests := []struct {
+		name     string
+		a, b     *Expr
+		expected bool
+	}{
+		{name: "both nil", expected: true},
+		{name: "nil and expression", b: e, expected: false},
+		{name: "expression and nil", a: e, expected: false},
+	}
+	for _, test := range tests {
+		if actual := Equal(test.a, test.b); actual != test.expected {
+			t.Errorf(testTemplate, test.name, test.expected, actual)
+		}
+	}
+}
+
+func TestExpr_Hash(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        string
+		b        string
+		expected bool
+	}{
+		{name: "redundant parentheses", a: `(A==1)`, b: `((A==1))`, expected: true},
+		{name: "different value", a: `A==1`, b: `A==2`, expected: false},
+		{name: "negative zero", a: `A==-0`, b: `A==0.0`, expected: true},
+		{name: "string and raw string", a: `A=="1"`, b: "A==`1`", expected: true},
+		{name: "field and value", a: `AB=="C"`, b: `A=="BC"`, expected: false},
+		{name: "and and or", a: `A==1 && B==2`, b: `A==1 || B==2`, expected: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := mustParse(t, test.a)
+			b := mustParse(t, test.b)
+			if actual := a.Hash() == b.Hash(); actual != test.expected {
+				t.Errorf(testTemplate, test.a+" | "+test.b, test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestExpr_Hash_stable(t *testing.T) {
+	// The hash must not depend on the process, such as on a random seed.
+	const expected = uint64(6340356781162220167)
+	if actual := mustParse(t, `Status == "active" && HP > 50`).Hash(); actual != expected {
+		t.Errorf(testTemplate, "hash", expected, actual)
+	}
+	var e *Expr
+	if e.Hash() != (&Expr{}).Hash() {
+		t.Errorf(testTemplate, "nil", (&Expr{}).Hash(), e.Hash())
+	}
+}
@@ -1,9 +1,16 @@
 package filter
 
 import (
+	"errors"
 	"strings"
 )
 
+// ErrFieldNotFound reports that the target has no such field.
+// Targets should wrap it in the error returned by GetField for a missing field, such as
+// fmt.Errorf("%w: %q", filter.ErrFieldNotFound, key), so that callers can tell it from other
+// failures of GetField with errors.Is. Nil pointer fields are reported with it as well.
+var ErrFieldNotFound = errors.New("field not found")
+
 // ErrorKind represents the kind of error.
 type ErrorKind int
 
@@ -19,9 +26,12 @@ const (
 )
 
 // Error represents an error in the filter processing.
+// Line and Col locate the offending token when known, and are zero otherwise.
 type Error struct {
 	Kind ErrorKind
 	Err  error
+	Line int
+	Col  int
 }
 
 // Error returns the error message.
@@ -2,7 +2,9 @@ package filter
 
 import (
 	"errors"
+	"fmt"
 	"testing"
+	"time"
 )
 
 func TestError_Error(t *testing.T) {
@@ -128,3 +130,98 @@ func Test_message(t *testing.T) {
 		})
 	}
 }
+
+type testFailingTarget struct {
+	testTarget
+	err error
+}
+
+func (t testFailingTarget) GetField(key string) (any, error) {
+	if _, ok := t.testTarget[key]; ok {
+		return t.testTarget.GetField(key)
+	}
+	return nil, fmt.Errorf("lookup %q: %w", key, t.err)
+}
+
+func TestErrFieldNotFound(t *testing.T) {
+	timeout := errors.New("backend timeout")
+	tests := []struct {
+		name     string
+		input    string
+		target   Target
+		notFound bool
+		cause    error
+	}{
+		{name: "missing field", input: `Missing==1`, target: testTarget{}, notFound: true},
+		{name: "missing ignoring case", input: `missing==1`, target: CaseInsensitiveTarget{"HP": 1}, notFound: true},
+		{name: "nil pointer", input: `Ptr==1`, target: testTarget{"Ptr": (*int)(nil)}, notFound: true},
+		{name: "missing offset field", input: `Deadline<Start + 1h`, target: testTarget{"Deadline": time.Time{}}, notFound: true},
+		{name: "transient failure", input: `Missing==1`, target: testFailingTarget{testTarget: testTarget{}, err: timeout}, cause: timeout},
+		{name: "type mismatch", input: `Flag>1`, target: testTarget{"Flag": true}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = expr.Eval(test.target)
+			var e *Error
+			if !errors.As(err, &e) || e.Kind != KindEval {
+				t.Fatalf(testTemplate, test.input, "eval error", err)
+			}
+			if actual := errors.Is(err, ErrFieldNotFound); actual != test.notFound {
+				t.Errorf(testTemplate, test.input, test.notFound, actual)
+			}
+			if test.cause != nil && !errors.Is(err, test.cause) {
+				t.Errorf(testTemplate, test.input, test.cause, err)
+			}
+		})
+	}
+}
+
+type testPanickingTarget struct {
+	testTarget
+	field string
+}
+
+func (t testPanickingTarget) GetField(key string) (any, error) {
+	if key == t.field {
+		var m map[string]int
+		m[key] = 1
+	}
+	return t.testTarget.GetField(key)
+}
+
+func TestExpr_EvalPanickingTarget(t *testing.T) {
+	target := testPanickingTarget{testTarget: testTarget{"HP": 100, "Start": time.Time{}}, field: "Broken"}
+	tests := []struct {
+		name  string
+		input string
+		opts  []Option
+		line  int
+		col   int
+	}{
+		{name: "comparison", input: `Broken==1`, line: 1, col: 1},
+		{name: "after other field", input: "HP>1 &&\n  Broken==1", line: 2, col: 3},
+		{name: "offset field", input: `HP>1 && Start<Broken + 1h`, line: 1, col: 15},
+		{name: "three-valued", input: `Broken==1`, opts: []Option{WithThreeValuedLogic()}, line: 1, col: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = expr.Eval(target)
+			expected := `eval error: panic in GetField for "Broken": assignment to entry in nil map`
+			if err == nil || err.Error() != expected {
+				t.Fatalf(testTemplate, test.input, expected, err)
+			}
+			var e *Error
+			if !errors.As(err, &e) || e.Line != test.line || e.Col != test.col {
+				t.Errorf(testTemplate, test.input, fmt.Sprintf("%d:%d", test.line, test.col), fmt.Sprintf("%d:%d", e.Line, e.Col))
+			}
+		})
+	}
+}
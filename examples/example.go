@@ -3,6 +3,8 @@ package examples
 import (
 	"fmt"
 	"time"
+
+	"github.com/nekrassov01/filter"
 )
 
 // Stats represents the statistics of a character.
@@ -52,6 +54,6 @@ func (o *Stats) GetField(key string) (any, error) {
 	case "SPD", "Spd", "Speed":
 		return o.Speed, nil
 	default:
-		return nil, fmt.Errorf("field not found: %q", key)
+		return nil, fmt.Errorf("%w: %q", filter.ErrFieldNotFound, key)
 	}
 }
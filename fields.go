@@ -0,0 +1,47 @@
+package filter
+
+import "slices"
+
+// Fields returns the names of the fields that evaluating the expression may read, sorted and without duplicates,
+// including the fields on the right-hand side of comparisons such as Start of Deadline < Start + 1h.
+// Fields of comparisons that always hold under WithSkipFields are not read and are omitted.
+// Use it to fetch expensive fields in a batch before evaluating, such as with FuncTarget:
+//
+//	vals, err := fetch(ctx, id, expr.Fields())
+//	if err != nil {
+//		return err
+//	}
+//	ok, err := expr.Eval(filter.FuncTarget(func(key string) (any, error) {
+//		return vals[key], nil
+//	}))
+func (e *Expr) Fields() []string {
+	if e == nil || len(e.parser.nodes) == 0 {
+		return nil
+	}
+	var fields []string
+	e.fields(e.root, &fields)
+	slices.Sort(fields)
+	return slices.Compact(fields)
+}
+
+// fields appends the fields read by the node at index i and its children to fields.
+func (e *Expr) fields(i int, fields *[]string) {
+	n := e.parser.nodes[i]
+	switch n.typ {
+	case nodeBinary:
+		e.fields(n.left, fields)
+		e.fields(n.right, fields)
+	case nodeNOT:
+		e.fields(n.left, fields)
+	case nodeBool:
+		// true and false read no field.
+	default:
+		if n.skip {
+			return
+		}
+		*fields = append(*fields, n.ident.v)
+		if n.isOffset() {
+			*fields = append(*fields, n.ref.v)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package filter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpr_Fields(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected []string
+	}{
+		{name: "single", input: `HP > 50`, expected: []string{"HP"}},
+		{name: "sorted unique", input: `Name == "a" && (HP > 50 || Name == "b") && !(Class == "c")`, expected: []string{"Class", "HP", "Name"}},
+		{name: "chain", input: `1 < HP <= 100`, expected: []string{"HP"}},
+		{name: "offset", input: `Deadline < Start + 1h`, expected: []string{"Deadline", "Start"}},
+		{name: "transform and key", input: `lower(Name) == "a" && Headers["x"] == "b"`, expected: []string{"Headers", "Name"}},
+		{name: "skip", input: `Beta == 1 && HP > 50`, opts: []Option{WithSkipFields("Beta")}, expected: []string{"HP"}},
+		{name: "fold", input: `HP > 50 && hp < 100`, opts: []Option{WithCaseInsensitiveFields()}, expected: []string{"hp"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			if actual := expr.Fields(); !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestExpr_Fields_nil(t *testing.T) {
+	var e *Expr
+	if actual := e.Fields(); actual != nil {
+		t.Errorf(testTemplate, "nil", nil, actual)
+	}
+}
@@ -1,18 +1,37 @@
 package filter
 
 import (
+	"cmp"
+	"context"
+	"database/sql/driver"
 	"fmt"
 	"math"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 // Target implements the entity to be evaluated.
+// GetField should return an error wrapping ErrFieldNotFound if the field does not exist.
+// Errors returned by GetField are reported as eval errors that wrap them,
+// and a panic in GetField is recovered and reported as an eval error as well.
 type Target interface {
 	GetField(key string) (any, error)
 }
 
+// Comparable implements custom comparison for field values.
+// When a field value implements Comparable, CompareTo is called instead of the built-in comparison.
+// op is the operator as written in the expression: ">", ">=", "<", "<=", "==", "==*", "!=", "!=*", "~=",
+// "=~", "=~*", "!~", "!~*", "matches", "imatches", "has" or "ihas". literal is the value as written, without quotes,
+// and CompareTo is called for each pattern of a regex list such as =~ ("a", "b").
+// Errors returned by CompareTo are reported as eval errors.
+type Comparable interface {
+	CompareTo(op, literal string) (bool, error)
+}
+
 // Expr represents an expression in the parser.
 type Expr struct {
 	parser parser
@@ -20,38 +39,384 @@ type Expr struct {
 }
 
 // Eval evaluates the expression against a target.
+// Logical operators short-circuit by default, so errors from operands that are
+// not evaluated, such as a missing field on the right of a true OR, are not reported.
+// Use WithStrictEval to evaluate every operand.
 func (e *Expr) Eval(t Target) (bool, error) {
+	return e.EvalWithClock(t, time.Now)
+}
+
+// EvalWithClock evaluates the expression against a target using clock as the source of now literals.
+// The clock is called at most once per evaluation, so every now literal refers to the same instant.
+func (e *Expr) EvalWithClock(t Target, clock func() time.Time) (bool, error) {
 	var cache map[string]any
-	n := len(e.parser.idents)
-	if n > 0 {
+	if n := e.cacheSize(); n > 0 {
 		cache = make(map[string]any, n)
 	}
-	return eval(e.parser.nodes, e.root, t, cache)
+	return e.evalRoot(t, newState(cache, clock))
 }
 
-func eval(nodes []node, i int, t Target, cache map[string]any) (bool, error) {
-	n := nodes[i]
+// EvalWithFields evaluates the expression against a target and returns the field values read during evaluation.
+// Fields that were skipped by short-circuiting are absent from the returned map.
+func (e *Expr) EvalWithFields(t Target) (bool, map[string]any, error) {
+	fields := make(map[string]any, len(e.parser.idents))
+	ok, err := e.evalRoot(t, newState(fields, time.Now))
+	if err != nil {
+		return false, nil, err
+	}
+	return ok, fields, nil
+}
+
+// EvalPrefetched evaluates the expression against field values resolved in advance, without a Target,
+// such as for testing many expressions against the same values fetched once for the union of their Fields.
+// A field missing from values is reported like a field that GetField did not find, wrapping ErrFieldNotFound.
+// The map is only read.
+func (e *Expr) EvalPrefetched(values map[string]any) (bool, error) {
+	return e.evalRoot(prefetched(values), newState(nil, time.Now))
+}
+
+// prefetched is a Target backed by field values resolved in advance.
+type prefetched map[string]any
+
+// GetField returns the value of the key.
+func (p prefetched) GetField(key string) (any, error) {
+	if v, ok := p[key]; ok {
+		return v, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrFieldNotFound, key)
+}
+
+// EvalCaptures evaluates the expression against a target and returns the capture groups of regex matches.
+// For each field, the submatches of the first =~, =~*, matches or imatches comparison that matched,
+// or of the first matching pattern of a regex list, are stored under the field name, with the whole
+// match at index 0, and each named group is also stored under "field.name".
+// Captures are only populated by matching regex comparisons that were actually evaluated,
+// so comparisons skipped by short-circuiting and negated regex operators contribute nothing.
+func (e *Expr) EvalCaptures(t Target) (bool, map[string][]string, error) {
+	var cache map[string]any
+	if n := e.cacheSize(); n > 0 {
+		cache = make(map[string]any, n)
+	}
+	st := newState(cache, time.Now)
+	st.captures = make(map[string][]string)
+	ok, err := e.evalRoot(t, st)
+	if err != nil {
+		return false, nil, err
+	}
+	return ok, st.captures, nil
+}
+
+// EvalContext evaluates the expression against a target, stopping when ctx is done.
+// The context is checked before each node and before each regex match, and its error is returned wrapped.
+// Go's regexp cannot be interrupted in the middle of a match, so a single long match still runs to completion.
+func (e *Expr) EvalContext(ctx context.Context, t Target) (bool, error) {
+	var cache map[string]any
+	if n := e.cacheSize(); n > 0 {
+		cache = make(map[string]any, n)
+	}
+	st := newState(cache, time.Now)
+	st.done = ctx.Done()
+	st.cause = ctx.Err
+	return e.evalRoot(t, st)
+}
+
+// cacheSize returns the number of fields to reserve in the field cache of an evaluation,
+// or 0 if no cache is needed. A single comparison reads its field only once,
+// so it is evaluated without a cache, as is the most common filter such as Status == "active".
+func (e *Expr) cacheSize() int {
+	if len(e.parser.nodes) == 1 && !e.parser.nodes[0].isOffset() {
+		return 0
+	}
+	return len(e.parser.idents)
+}
+
+// state holds the state of a single evaluation.
+// The target is passed separately so that the field cache does not escape to the heap.
+type state struct {
+	cache  map[string]any   // field values resolved so far
+	clock  func() time.Time // source of now literals
+	now    time.Time        // cached result of clock
+	hasNow bool             // indicates if now is cached
+
+	captures map[string][]string // regex submatches by field, nil unless requested
+	metrics  Metrics             // counts of the work done so far
+	done     <-chan struct{}     // closed when the evaluation is canceled, nil unless requested
+	cause    func() error        // reason for the cancellation
+}
+
+// newState creates a new evaluation state.
+func newState(cache map[string]any, clock func() time.Time) *state {
+	return &state{
+		cache: cache,
+		clock: clock,
+	}
+}
+
+// field returns the value of the field, resolving it from the target at most once.
+func (st *state) field(t Target, key string) (any, error) {
+	if st.cache == nil {
+		st.metrics.FieldFetches++
+		return getField(t, key)
+	}
+	if v, ok := st.cache[key]; ok {
+		return v, nil
+	}
+	st.metrics.FieldFetches++
+	v, err := getField(t, key)
+	if err == nil {
+		st.cache[key] = v
+	}
+	return v, err
+}
+
+// getField returns the value of the field from the target,
+// converting a panic in GetField into an error so that a faulty target does not crash the caller.
+func getField(t Target, key string) (v any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			v, err = nil, fmt.Errorf("panic in GetField for %q: %v", key, r)
+		}
+	}()
+	return t.GetField(key)
+}
+
+// index returns the value of the key of the node in a map field, or the element at the index of the node
+// in a slice or array field, such as Coords[0]. A missing key, a nil map, or an index out of range,
+// including a negative index, is reported as an error wrapping ErrFieldNotFound,
+// so that WithThreeValuedLogic treats it as a missing field.
+func index(n node, field any) (any, error) {
+	switch m := field.(type) {
+	case []any:
+		if i, ok := elementIndex(n, len(m)); ok {
+			return m[i], nil
+		}
+	case []string:
+		if i, ok := elementIndex(n, len(m)); ok {
+			return m[i], nil
+		}
+	case map[string]any:
+		if n.key.typ.isStringType() {
+			if v, ok := m[n.key.v]; ok {
+				return v, nil
+			}
+			return nil, missingKey(n)
+		}
+	case map[string]string:
+		if n.key.typ.isStringType() {
+			if v, ok := m[n.key.v]; ok {
+				return v, nil
+			}
+			return nil, missingKey(n)
+		}
+	}
+	m := reflect.ValueOf(field)
+	if k := m.Kind(); k == reflect.Slice || k == reflect.Array {
+		if n.key.typ != tokenNumber {
+			return nil, &Error{
+				Kind: KindEval,
+				Err:  fmt.Errorf("cannot use %s key for %T field at %d:%d: %q", n.key.typ, field, n.key.line, n.key.col, n.key.v),
+				Line: n.key.line,
+				Col:  n.key.col,
+			}
+		}
+		i, ok := elementIndex(n, m.Len())
+		if !ok {
+			return nil, missingElement(n)
+		}
+		return m.Index(i).Interface(), nil
+	}
+	if m.Kind() != reflect.Map {
+		return nil, &Error{
+			Kind: KindEval,
+			Err:  fmt.Errorf("cannot index %T field at %d:%d: %q", field, n.ident.line, n.ident.col, n.ident.v),
+			Line: n.ident.line,
+			Col:  n.ident.col,
+		}
+	}
+	kt := m.Type().Key()
+	var k reflect.Value
+	switch kt.Kind() {
+	case reflect.String:
+		if n.key.typ.isStringType() {
+			k = reflect.ValueOf(n.key.v).Convert(kt)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, ok := parseInt(n.key.v); ok && n.key.typ == tokenNumber && !reflect.Zero(kt).OverflowInt(i) {
+			k = reflect.ValueOf(i).Convert(kt)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if u, ok := parseUint(n.key.v); ok && n.key.typ == tokenNumber && !reflect.Zero(kt).OverflowUint(u) {
+			k = reflect.ValueOf(u).Convert(kt)
+		}
+	}
+	if !k.IsValid() {
+		return nil, &Error{
+			Kind: KindEval,
+			Err:  fmt.Errorf("cannot use %s key for %T field at %d:%d: %q", n.key.typ, field, n.key.line, n.key.col, n.key.v),
+			Line: n.key.line,
+			Col:  n.key.col,
+		}
+	}
+	v := m.MapIndex(k)
+	if !v.IsValid() {
+		return nil, missingKey(n)
+	}
+	return v.Interface(), nil
+}
+
+// missingKey returns the error for a key that the map field of the node does not have.
+func missingKey(n node) error {
+	return &Error{
+		Kind: KindEval,
+		Err:  fmt.Errorf("%w: %q has no key %q at %d:%d", ErrFieldNotFound, n.ident.v, n.key.v, n.key.line, n.key.col),
+		Line: n.key.line,
+		Col:  n.key.col,
+	}
+}
+
+// elementIndex returns the index of the node into a slice or array of length n,
+// reporting false if it is not an integer in range.
+func elementIndex(nd node, n int) (int, bool) {
+	if nd.key.typ != tokenNumber {
+		return 0, false
+	}
+	i, ok := parseInt(nd.key.v)
+	if !ok || i < 0 || i >= int64(n) {
+		return 0, false
+	}
+	return int(i), true
+}
+
+// missingElement returns the error for an index that the slice or array field of the node does not have.
+func missingElement(n node) error {
+	return &Error{
+		Kind: KindEval,
+		Err:  fmt.Errorf("%w: %q has no element %s at %d:%d", ErrFieldNotFound, n.ident.v, n.key.v, n.key.line, n.key.col),
+		Line: n.key.line,
+		Col:  n.key.col,
+	}
+}
+
+// err returns an error if the context of the evaluation is done.
+// The context is kept as its done channel and error function rather than as an interface,
+// so that the field cache does not escape to the heap.
+func (st *state) err() error {
+	if st.done == nil {
+		return nil
+	}
+	select {
+	case <-st.done:
+		return &Error{
+			Kind: KindEval,
+			Err:  fmt.Errorf("evaluation stopped: %w", st.cause()),
+		}
+	default:
+		return nil
+	}
+}
+
+// match reports whether v matches the pattern of the node, or any of the patterns of a list.
+// The context is checked before each pattern, and submatches are recorded if requested and capture is true.
+func (st *state) match(n node, v string, capture bool) (bool, error) {
+	if !n.isList() {
+		return st.matchPattern(n, v, capture)
+	}
+	for _, item := range n.items {
+		ok, err := st.matchPattern(item, v, capture)
+		if ok || err != nil {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// matchPattern reports whether v matches the pattern of a single node.
+func (st *state) matchPattern(n node, v string, capture bool) (bool, error) {
+	if err := st.err(); err != nil {
+		return false, err
+	}
+	if n.re == nil {
+		return false, &Error{
+			Kind: KindEval,
+			Err:  fmt.Errorf("regex not compiled at %d:%d: %q", n.val.line, n.val.col, n.val.v),
+		}
+	}
+	st.metrics.RegexMatches++
+	if capture && st.captures != nil {
+		return st.capture(n, v), nil
+	}
+	return n.re.MatchString(v), nil
+}
+
+// capture matches v against the regex of the node and records the submatches
+// if this is the first match for the field.
+func (st *state) capture(n node, v string) bool {
+	m := n.re.FindStringSubmatch(v)
+	if m == nil {
+		return false
+	}
+	if _, ok := st.captures[n.ident.v]; ok {
+		return true
+	}
+	st.captures[n.ident.v] = m
+	for i, name := range n.re.SubexpNames() {
+		if name != "" {
+			st.captures[n.ident.v+"."+name] = m[i : i+1 : i+1]
+		}
+	}
+	return true
+}
+
+// current returns the instant that now literals refer to during this evaluation.
+func (st *state) current() time.Time {
+	if !st.hasNow {
+		st.now = st.clock()
+		st.hasNow = true
+	}
+	return st.now
+}
+
+// evalRoot evaluates the whole expression against a target, under three-valued logic if requested.
+func (e *Expr) evalRoot(t Target, st *state) (bool, error) {
+	if !e.parser.opts.threeValued {
+		return e.eval(e.root, t, st)
+	}
+	v, err := e.evalKleene(e.root, t, st)
+	return v == truthTrue, err
+}
+
+// eval evaluates the node at index i against a target.
+func (e *Expr) eval(i int, t Target, st *state) (bool, error) {
+	if err := st.err(); err != nil {
+		return false, err
+	}
+	n := e.parser.nodes[i]
 	switch n.typ {
 	case nodeBinary:
+		if e.parser.opts.strict {
+			return e.evalStrict(n, t, st)
+		}
 		switch n.op.typ {
 		case tokenAND:
-			left, err := eval(nodes, n.left, t, cache)
+			left, err := e.eval(n.left, t, st)
 			if err != nil {
 				return false, err
 			}
 			if !left {
+				st.metrics.ShortCircuits++
 				return false, nil
 			}
-			return eval(nodes, n.right, t, cache)
+			return e.eval(n.right, t, st)
 		case tokenOR:
-			left, err := eval(nodes, n.left, t, cache)
+			left, err := e.eval(n.left, t, st)
 			if err != nil {
 				return false, err
 			}
 			if left {
+				st.metrics.ShortCircuits++
 				return true, nil
 			}
-			return eval(nodes, n.right, t, cache)
+			return e.eval(n.right, t, st)
 		default:
 			return false, &Error{
 				Kind: KindEval,
@@ -59,34 +424,45 @@ func eval(nodes []node, i int, t Target, cache map[string]any) (bool, error) {
 			}
 		}
 	case nodeNOT:
-		v, err := eval(nodes, n.left, t, cache)
+		v, err := e.eval(n.left, t, st)
 		if err != nil {
 			return false, err
 		}
 		return !v, nil
+	case nodeBool:
+		return n.isTrue(), nil
 	case nodeComparison:
-		var field any
-		var err error
-		key := n.ident.v
-		if cache != nil {
-			if v, ok := cache[key]; ok {
-				field = v
-			} else {
-				field, err = t.GetField(key)
-				if err == nil {
-					cache[key] = field
-				}
-			}
-		} else {
-			field, err = t.GetField(key)
+		if n.skip {
+			return true, nil
 		}
+		st.metrics.Comparisons++
+		field, err := st.field(t, n.ident.v)
 		if err != nil {
 			return false, &Error{
 				Kind: KindEval,
 				Err:  err,
+				Line: n.ident.line,
+				Col:  n.ident.col,
+			}
+		}
+		if n.isIndex() {
+			if field, err = index(n, field); err != nil {
+				return false, err
 			}
 		}
-		return evalComparison(n, field)
+		if n.isOffset() {
+			ref, err := st.field(t, n.ref.v)
+			if err != nil {
+				return false, &Error{
+					Kind: KindEval,
+					Err:  err,
+					Line: n.ref.line,
+					Col:  n.ref.col,
+				}
+			}
+			return evalOffset(n, field, ref, st)
+		}
+		return e.evalComparison(n, field, st)
 	}
 	return false, &Error{
 		Kind: KindEval,
@@ -94,76 +470,611 @@ func eval(nodes []node, i int, t Target, cache map[string]any) (bool, error) {
 	}
 }
 
+// evalStrict evaluates both operands of a binary node without short-circuiting.
+// The first error encountered is returned.
+func (e *Expr) evalStrict(n node, t Target, st *state) (bool, error) {
+	left, lerr := e.eval(n.left, t, st)
+	right, rerr := e.eval(n.right, t, st)
+	if lerr != nil {
+		return false, lerr
+	}
+	if rerr != nil {
+		return false, rerr
+	}
+	switch n.op.typ {
+	case tokenAND:
+		return left && right, nil
+	case tokenOR:
+		return left || right, nil
+	default:
+		return false, &Error{
+			Kind: KindEval,
+			Err:  fmt.Errorf("invalid logical operator at %d:%d: %q", n.op.line, n.op.col, n.op.typ.literal()),
+		}
+	}
+}
+
 // evalComparison evaluates a comparison expression against a target field.
-func evalComparison(n node, field any) (bool, error) {
+// Pointer fields are dereferenced once, unless they implement Comparable, driver.Valuer or
+// fmt.Stringer through pointer receivers, and nil pointers are treated as missing fields.
+// Fields implementing driver.Valuer, such as sql.NullString, are compared as their value
+// unless they implement Comparable, and null values are treated as missing fields.
+func (e *Expr) evalComparison(n node, field any, st *state) (bool, error) {
+	if v := reflect.ValueOf(field); v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return false, &Error{
+				Kind: KindEval,
+				Err:  fmt.Errorf("%w: %q is nil at %d:%d", ErrFieldNotFound, n.ident.v, n.ident.line, n.ident.col),
+			}
+		}
+		if elem := v.Elem().Interface(); !pointerMethods(field, elem) {
+			field = elem
+		}
+	}
+	if _, ok := field.(Comparable); !ok {
+		if v, ok := field.(driver.Valuer); ok {
+			var err error
+			if field, err = driverValue(n.ident, v); err != nil {
+				return false, err
+			}
+		}
+	}
+	if n.isArith() {
+		return e.evalArith(n, field)
+	}
+	if n.fn == transformLen {
+		return e.evalLen(n, field)
+	}
+	if n.op.typ.isListOperatorType() {
+		return e.evalContains(n, field, st)
+	}
+	if n.fn != transformNone {
+		v, ok := field.(string)
+		if !ok {
+			return false, &Error{
+				Kind: KindEval,
+				Err:  fmt.Errorf("%s requires a string field at %d:%d: %q is %T", n.fn, n.ident.line, n.ident.col, n.ident.v, field),
+			}
+		}
+		return e.evalString(n, n.fn.apply(v), field, st)
+	}
+	if c, ok := field.(Comparable); ok {
+		return evalComparable(n, c)
+	}
+	if n.val.typ == tokenBool && e.parser.opts.truthy {
+		return evalTruthy(n, field)
+	}
+	if n.unit != 0 {
+		if d, ok, err := unitDuration(n, field); ok {
+			if err != nil {
+				return false, err
+			}
+			return e.evalDuration(n, d, field)
+		}
+	}
+	if e.parser.opts.runes && n.val.typ.isStringType() {
+		switch v := field.(type) {
+		case int32:
+			return evalRune(n, v, field)
+		case uint8:
+			return evalRune(n, rune(v), field)
+		}
+	}
 	switch v := field.(type) {
 	case string:
-		return evalString(n, v)
+		return e.evalString(n, v, field, st)
 	case int:
-		return evalNumber(n, float64(v))
+		return e.evalInt(n, int64(v), field)
 	case int8:
-		return evalNumber(n, float64(v))
+		return e.evalInt(n, int64(v), field)
 	case int16:
-		return evalNumber(n, float64(v))
+		return e.evalInt(n, int64(v), field)
 	case int32:
-		return evalNumber(n, float64(v))
+		return e.evalInt(n, int64(v), field)
 	case int64:
-		return evalNumber(n, float64(v))
+		return e.evalInt(n, v, field)
 	case uint:
-		return evalNumber(n, float64(v))
+		return e.evalUint(n, uint64(v), field)
 	case uint8:
-		return evalNumber(n, float64(v))
+		return e.evalNumber(n, float64(v), field)
 	case uint16:
-		return evalNumber(n, float64(v))
+		return e.evalNumber(n, float64(v), field)
 	case uint32:
-		return evalNumber(n, float64(v))
+		return e.evalNumber(n, float64(v), field)
 	case uint64:
-		return evalNumber(n, float64(v))
+		return e.evalUint(n, v, field)
 	case float32:
-		return evalNumber(n, float64(v))
+		return e.evalNumber(n, float64(v), field)
 	case float64:
-		return evalNumber(n, v)
+		return e.evalNumber(n, v, field)
+	case Seconds:
+		return e.evalSeconds(n, v)
 	case time.Time:
-		return evalTime(n, v)
+		return evalTime(n, v, field, st)
 	case time.Duration:
-		return evalDuration(n, v)
+		return e.evalDuration(n, v, field)
 	default:
-		return evalString(n, fmt.Sprint(v))
+		if n.val.typ == tokenNumber {
+			if ok, numeric, err := e.evalNamedNumber(n, field); numeric {
+				return ok, err
+			}
+		}
+		if s, ok := field.(fmt.Stringer); ok {
+			return e.evalString(n, s.String(), field, st)
+		}
+		if n.op.typ.isSubstringOperatorType() {
+			return false, &Error{
+				Kind: KindEval,
+				Err:  fmt.Errorf("invalid operator for %T field at %d:%d: %q", field, n.op.line, n.op.col, n.op.typ.literal()),
+			}
+		}
+		return e.evalString(n, fmt.Sprint(v), field, st)
+	}
+}
+
+// pointerMethods reports whether the pointer p implements Comparable, driver.Valuer or fmt.Stringer
+// through methods with pointer receivers, which its element elem lacks, so that it must not be dereferenced.
+func pointerMethods(p, elem any) bool {
+	implements := func(v any) (bool, bool, bool) {
+		_, c := v.(Comparable)
+		_, d := v.(driver.Valuer)
+		_, s := v.(fmt.Stringer)
+		return c, d, s
+	}
+	pc, pd, ps := implements(p)
+	ec, ed, es := implements(elem)
+	return pc && !ec || pd && !ed || ps && !es
+}
+
+// evalNamedNumber evaluates a comparison of a field of a named numeric type, such as type Level int,
+// with a number literal, so that it is compared as a number even if it implements fmt.Stringer.
+// The second result reports whether the field is numeric.
+func (e *Expr) evalNamedNumber(n node, field any) (bool, bool, error) {
+	v := reflect.ValueOf(field)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		ok, err := e.evalInt(n, v.Int(), field)
+		return ok, true, err
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		ok, err := e.evalUint(n, v.Uint(), field)
+		return ok, true, err
+	case reflect.Float32, reflect.Float64:
+		ok, err := e.evalNumber(n, v.Float(), field)
+		return ok, true, err
+	default:
+		return false, false, nil
+	}
+}
+
+// evalLen evaluates a comparison of the length of a field, such as len(Name) > 3.
+// Strings are counted in runes, or in bytes under WithByteLength, and slices, arrays and maps
+// in elements. Other fields are reported as eval errors.
+func (e *Expr) evalLen(n node, field any) (bool, error) {
+	if s, ok := field.(string); ok {
+		if e.parser.opts.byteLength {
+			return e.evalInt(n, int64(len(s)), field)
+		}
+		return e.evalInt(n, int64(utf8.RuneCountInString(s)), field)
+	}
+	v := reflect.ValueOf(field)
+	switch v.Kind() {
+	case reflect.String:
+		return e.evalLen(n, v.String())
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return e.evalInt(n, int64(v.Len()), field)
+	default:
+		return false, &Error{
+			Kind: KindEval,
+			Err:  fmt.Errorf("len requires a string, slice or map field at %d:%d: %q is %T", n.ident.line, n.ident.col, n.ident.v, field),
+		}
+	}
+}
+
+// evalArith evaluates a comparison of the result of an arithmetic operator on an integer field,
+// such as ID % 10 == 0 or Perms & 0x4 == 0x4. Signed fields are computed as int64 and unsigned
+// fields as uint64, so the remainder of % has the sign of the field as with the Go % operator.
+func (e *Expr) evalArith(n node, field any) (bool, error) {
+	switch v := field.(type) {
+	case int:
+		return e.evalInt(n, arithInt(n, int64(v)), field)
+	case int8:
+		return e.evalInt(n, arithInt(n, int64(v)), field)
+	case int16:
+		return e.evalInt(n, arithInt(n, int64(v)), field)
+	case int32:
+		return e.evalInt(n, arithInt(n, int64(v)), field)
+	case int64:
+		return e.evalInt(n, arithInt(n, v), field)
+	case uint:
+		return e.evalUint(n, arithUint(n, uint64(v)), field)
+	case uint8:
+		return e.evalUint(n, arithUint(n, uint64(v)), field)
+	case uint16:
+		return e.evalUint(n, arithUint(n, uint64(v)), field)
+	case uint32:
+		return e.evalUint(n, arithUint(n, uint64(v)), field)
+	case uint64:
+		return e.evalUint(n, arithUint(n, v), field)
+	default:
+		return false, &Error{
+			Kind: KindEval,
+			Err:  fmt.Errorf("%s requires an integer field at %d:%d: %q is %T", n.arith.typ.arithName(), n.ident.line, n.ident.col, n.ident.v, field),
+		}
+	}
+}
+
+// arithInt applies the arithmetic operator of the node to a signed integer.
+func arithInt(n node, v int64) int64 {
+	switch n.arith.typ {
+	case tokenMod:
+		return v % int64(n.bits)
+	case tokenBitAnd:
+		return v & int64(n.bits)
+	case tokenBitOr:
+		return v | int64(n.bits)
+	default:
+		return v ^ int64(n.bits)
+	}
+}
+
+// arithUint applies the arithmetic operator of the node to an unsigned integer.
+// The divisor of % is signed, and only its magnitude matters for an unsigned dividend.
+func arithUint(n node, v uint64) uint64 {
+	switch n.arith.typ {
+	case tokenMod:
+		d := n.bits
+		if int64(d) < 0 {
+			d = -d
+		}
+		return v % d
+	case tokenBitAnd:
+		return v & n.bits
+	case tokenBitOr:
+		return v | n.bits
+	default:
+		return v ^ n.bits
+	}
+}
+
+// driverValue returns the value of a field implementing driver.Valuer, with []byte as a string.
+// A null value, such as sql.NullString with Valid set to false, is reported as a missing field.
+func driverValue(ident token, v driver.Valuer) (any, error) {
+	val, err := v.Value()
+	if err != nil {
+		return nil, &Error{
+			Kind: KindEval,
+			Err:  fmt.Errorf("cannot get value of %q at %d:%d: %w", ident.v, ident.line, ident.col, err),
+		}
+	}
+	switch val := val.(type) {
+	case nil:
+		return nil, &Error{
+			Kind: KindEval,
+			Err:  fmt.Errorf("%w: %q is null at %d:%d", ErrFieldNotFound, ident.v, ident.line, ident.col),
+		}
+	case []byte:
+		return string(val), nil
+	default:
+		return val, nil
+	}
+}
+
+// evalContains evaluates a containsany or containsall expression against a slice or array field.
+// An element matches a value when == holds between them under the usual comparison rules.
+// containsany is false and containsall is true for an empty list.
+func (e *Expr) evalContains(n node, field any, st *state) (bool, error) {
+	v := reflect.ValueOf(field)
+	if k := v.Kind(); k != reflect.Slice && k != reflect.Array {
+		return false, &Error{
+			Kind: KindEval,
+			Err:  fmt.Errorf("invalid operator for non-slice field at %d:%d: %q", n.op.line, n.op.col, n.op.typ.literal()),
+		}
+	}
+	all := n.op.typ == tokenContainsAll
+	for _, item := range n.items {
+		found := false
+		for j := range v.Len() {
+			ok, err := e.evalComparison(item, v.Index(j).Interface(), st)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				found = true
+				break
+			}
+		}
+		if found != all {
+			return found, nil
+		}
+	}
+	return all, nil
+}
+
+// evalComparable evaluates a comparison expression against a field implementing Comparable.
+// CompareTo is called for each pattern of a regex list, which holds for =~, =~*, matches and imatches
+// if any call returns true, and for !~ and !~* if every call does, as for string fields.
+func evalComparable(n node, c Comparable) (bool, error) {
+	if n.isList() {
+		negated := n.op.typ == tokenNREQ || n.op.typ == tokenNREQI
+		for _, item := range n.items {
+			ok, err := evalComparable(item, c)
+			if err != nil || ok != negated {
+				return ok, err
+			}
+		}
+		return negated, nil
+	}
+	ok, err := c.CompareTo(n.op.typ.literal(), n.writtenValue())
+	if err != nil {
+		return false, &Error{
+			Kind: KindEval,
+			Err:  fmt.Errorf("cannot compare %q at %d:%d: %w", n.ident.v, n.op.line, n.op.col, err),
+		}
+	}
+	return ok, nil
+}
+
+// evalRune evaluates a comparison of a rune or byte field with a single-character string
+// by code point under WithRuneComparison.
+func evalRune(n node, v rune, field any) (bool, error) {
+	r, size := utf8.DecodeRuneInString(n.val.v)
+	if size == 0 || size != len(n.val.v) {
+		return false, &Error{
+			Kind: KindEval,
+			Err:  fmt.Errorf("cannot compare %T field with string of %d characters at %d:%d: %q", field, utf8.RuneCountInString(n.val.v), n.val.line, n.val.col, n.val.v),
+		}
+	}
+	switch n.op.typ {
+	case tokenEQ:
+		return v == r, nil
+	case tokenNEQ:
+		return v != r, nil
+	case tokenEQI:
+		return v == r || equalFoldRune(v, r), nil
+	case tokenNEQI:
+		return v != r && !equalFoldRune(v, r), nil
+	case tokenGT, tokenGTE, tokenLT, tokenLTE:
+		return compareOrdered(n.op.typ, cmp.Compare(v, r)), nil
+	default:
+		return false, &Error{
+			Kind: KindEval,
+			Err:  fmt.Errorf("invalid operator for %T field at %d:%d: %q", field, n.op.line, n.op.col, n.op.typ.literal()),
+		}
+	}
+}
+
+// evalTruthy evaluates a comparison with a boolean literal by the truthiness of the field under WithTruthyBool.
+func evalTruthy(n node, field any) (bool, error) {
+	var v bool
+	switch f := field.(type) {
+	case bool:
+		v = f
+	case string:
+		b, err := strconv.ParseBool(f)
+		if err != nil {
+			return false, &Error{
+				Kind: KindEval,
+				Err:  fmt.Errorf("cannot interpret string field as boolean at %d:%d: %q", n.ident.line, n.ident.col, f),
+			}
+		}
+		v = b
+	case int:
+		v = f != 0
+	case int8:
+		v = f != 0
+	case int16:
+		v = f != 0
+	case int32:
+		v = f != 0
+	case int64:
+		v = f != 0
+	case uint:
+		v = f != 0
+	case uint8:
+		v = f != 0
+	case uint16:
+		v = f != 0
+	case uint32:
+		v = f != 0
+	case uint64:
+		v = f != 0
+	default:
+		return false, &Error{
+			Kind: KindEval,
+			Err:  fmt.Errorf("cannot compare %T field with boolean at %d:%d: %q", field, n.val.line, n.val.col, n.val.v),
+		}
+	}
+	b, _ := strconv.ParseBool(n.val.v)
+	switch n.op.typ {
+	case tokenEQ:
+		return v == b, nil
+	case tokenNEQ:
+		return v != b, nil
+	default:
+		return false, &Error{
+			Kind: KindEval,
+			Err:  fmt.Errorf("invalid operator for boolean at %d:%d: %q", n.op.line, n.op.col, n.op.typ.literal()),
+		}
 	}
 }
 
 // evalString evaluates a string expression against a target.
-func evalString(n node, v string) (bool, error) {
+// Case-insensitive operators use simple Unicode case folding as strings.EqualFold does,
+// without locale-specific rules such as the Turkish dotted I.
+func (e *Expr) evalString(n node, v string, field any, st *state) (bool, error) {
+	if e.parser.opts.coerce && (n.op.typ.isOrderingOperatorType() || n.op.typ == tokenApprox) {
+		if f, ok := parseDecimal(v); ok {
+			return e.evalNumber(n, f, field)
+		}
+	}
+	s := n.val.v
+	if e.parser.opts.ordering && n.op.typ.isOrderingOperatorType() {
+		return compareOrdered(n.op.typ, e.parser.opts.collator.compare(v, s)), nil
+	}
+	if e.parser.opts.normalize && n.op.typ.isEqualityOperatorType() {
+		v = e.parser.opts.form.String(v)
+		s = e.parser.opts.form.String(s)
+	}
 	switch n.op.typ {
 	case tokenEQ:
-		return v == n.val.v, nil
+		if n.fold {
+			return strings.EqualFold(v, s), nil
+		}
+		return v == s, nil
 	case tokenEQI:
-		return strings.EqualFold(v, n.val.v), nil
+		return strings.EqualFold(v, s), nil
 	case tokenNEQ:
-		return v != n.val.v, nil
+		if n.fold {
+			return !strings.EqualFold(v, s), nil
+		}
+		return v != s, nil
 	case tokenNEQI:
-		return !strings.EqualFold(v, n.val.v), nil
-	case tokenREQ, tokenREQI:
-		return n.re.MatchString(v), nil
+		return !strings.EqualFold(v, s), nil
+	case tokenREQ, tokenREQI, tokenMatches, tokenMatchesI:
+		if err := e.checkMatchLen(n, v); err != nil {
+			return false, err
+		}
+		return st.match(n, v, true)
 	case tokenNREQ, tokenNREQI:
-		return !n.re.MatchString(v), nil
+		if err := e.checkMatchLen(n, v); err != nil {
+			return false, err
+		}
+		ok, err := st.match(n, v, false)
+		return !ok && err == nil, err
+	case tokenHas:
+		return strings.Contains(v, s), nil
+	case tokenHasI:
+		return containsFold(v, s), nil
 	default:
-		return false, &Error{
+		return e.invalidOperator(n, field)
+	}
+}
+
+// invalidOperator returns the result of a comparison whose operator does not apply to the type of the field:
+// false under WithUnsupportedOpAsFalse, and an eval error otherwise.
+func (e *Expr) invalidOperator(n node, field any) (bool, error) {
+	if e.parser.opts.opAsFalse {
+		return false, nil
+	}
+	return false, &Error{
+		Kind: KindEval,
+		Err:  fmt.Errorf("invalid operator for %T field at %d:%d: %q", field, n.op.line, n.op.col, n.op.typ.literal()),
+	}
+}
+
+// checkMatchLen reports an error if v is too long to be matched against a regex under WithMaxMatchLen.
+func (e *Expr) checkMatchLen(n node, v string) error {
+	if limit := e.parser.opts.maxMatchLen; limit > 0 && len(v) > limit {
+		return &Error{
 			Kind: KindEval,
-			Err:  fmt.Errorf("invalid operator for string field at %d:%d: %q", n.op.line, n.op.col, n.op.typ.literal()),
+			Err:  fmt.Errorf("value of %q too long to match: %d bytes exceeds limit %d at %d:%d", n.ident.v, len(v), limit, n.op.line, n.op.col),
+			Line: n.op.line,
+			Col:  n.op.col,
+		}
+	}
+	return nil
+}
+
+// containsFold reports whether substr is within s under simple Unicode case folding, as strings.EqualFold compares.
+func containsFold(s, substr string) bool {
+	for i := 0; ; {
+		if hasPrefixFold(s[i:], substr) {
+			return true
 		}
+		if i == len(s) {
+			return false
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+	}
+}
+
+// hasPrefixFold reports whether s begins with prefix under simple Unicode case folding.
+func hasPrefixFold(s, prefix string) bool {
+	for prefix != "" {
+		if s == "" {
+			return false
+		}
+		r, n := utf8.DecodeRuneInString(s)
+		p, m := utf8.DecodeRuneInString(prefix)
+		if r != p && !equalFoldRune(r, p) {
+			return false
+		}
+		s, prefix = s[n:], prefix[m:]
+	}
+	return true
+}
+
+// equalFoldRune reports whether r and p are equal under simple Unicode case folding.
+func equalFoldRune(r, p rune) bool {
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f == p {
+			return true
+		}
+	}
+	return false
+}
+
+// compareOrdered reports whether the ordering operator holds for the result c of comparing the field value with the literal.
+func compareOrdered(op tokenType, c int) bool {
+	switch op {
+	case tokenGT:
+		return c > 0
+	case tokenGTE:
+		return c >= 0
+	case tokenLT:
+		return c < 0
+	case tokenLTE:
+		return c <= 0
+	default:
+		return false
+	}
+}
+
+// parseDecimal parses a string holding a plain decimal number such as "123", "-0.5" or "1e3".
+// Leading zeros are decimal, and prefixed forms such as "0x10", digit separators,
+// surrounding spaces, Inf and NaN are not treated as numbers.
+func parseDecimal(s string) (float64, bool) {
+	if s == "" {
+		return 0, false
 	}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case '0' <= c && c <= '9', c == '.', c == '+', c == '-', c == 'e', c == 'E':
+		default:
+			return 0, false
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	return f, err == nil
 }
 
 // evalNumber evaluates a number expression against a target.
-func evalNumber(n node, v float64) (bool, error) {
+// NaN and infinite values are rejected on both sides, since they make comparisons meaningless.
+func (e *Expr) evalNumber(n node, v float64, field any) (bool, error) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return false, &Error{
+			Kind: KindEval,
+			Err:  fmt.Errorf("invalid number value of %T field at %d:%d: %v", field, n.ident.line, n.ident.col, v),
+		}
+	}
 	f := n.num
 	if !n.hasNum {
-		parsed, err := strconv.ParseFloat(n.val.v, 64)
-		if err != nil {
+		if !n.op.typ.isOrderingOperatorType() && !n.op.typ.isExactEqualityOperatorType() && n.op.typ != tokenApprox {
+			// The operator cannot apply whatever the value is, so it is reported before the value.
+			return e.invalidOperator(n, field)
+		}
+		if n.val.typ == tokenBool {
 			return false, &Error{
 				Kind: KindEval,
-				Err:  fmt.Errorf("invalid number at %d:%d: %q", n.val.line, n.val.col, n.val.v),
+				Err:  fmt.Errorf("cannot compare %T field with boolean at %d:%d: %q", field, n.val.line, n.val.col, n.val.v),
+			}
+		}
+		parsed, err := parseNumber(n.val.v)
+		if err != nil || math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+			return false, &Error{
+				Kind: KindEval,
+				Err:  fmt.Errorf("cannot compare %T field %q with non-numeric value %q at %d:%d", field, n.ident.v, n.val.v, n.val.line, n.val.col),
 			}
 		}
 		f = parsed
@@ -178,26 +1089,153 @@ func evalNumber(n node, v float64) (bool, error) {
 	case tokenLTE:
 		return v <= f, nil
 	case tokenEQ:
-		return math.Abs(v-f) <= Epsilon, nil
+		return math.Abs(v-f) <= e.parser.opts.epsilon, nil
 	case tokenNEQ:
-		return math.Abs(v-f) > Epsilon, nil
+		return math.Abs(v-f) > e.parser.opts.epsilon, nil
+	case tokenApprox:
+		return math.Abs(v-f) <= e.parser.opts.tolerance, nil
 	default:
-		return false, &Error{
-			Kind: KindEval,
-			Err:  fmt.Errorf("invalid operator for number field at %d:%d: %q", n.op.line, n.op.col, n.op.typ.literal()),
+		return e.invalidOperator(n, field)
+	}
+}
+
+// evalInt evaluates a signed integer expression against a target.
+// Integer literals are compared as int64 to avoid the precision loss of float64.
+func (e *Expr) evalInt(n node, v int64, field any) (bool, error) {
+	i, ok := n.int, n.hasInt
+	if !ok {
+		i, ok = parseInt(n.val.v)
+	}
+	if !ok {
+		return e.evalNumber(n, float64(v), field)
+	}
+	switch n.op.typ {
+	case tokenApprox:
+		return e.evalNumber(n, float64(v), field)
+	case tokenGT:
+		return v > i, nil
+	case tokenGTE:
+		return v >= i, nil
+	case tokenLT:
+		return v < i, nil
+	case tokenLTE:
+		return v <= i, nil
+	case tokenEQ:
+		return float64(distance(v, i)) <= e.parser.opts.epsilon, nil
+	case tokenNEQ:
+		return float64(distance(v, i)) > e.parser.opts.epsilon, nil
+	default:
+		return e.invalidOperator(n, field)
+	}
+}
+
+// distance returns the absolute difference of two signed integers without overflow.
+func distance(a, b int64) uint64 {
+	if a > b {
+		return uint64(a) - uint64(b)
+	}
+	return uint64(b) - uint64(a)
+}
+
+// evalUint evaluates an unsigned integer expression against a target.
+// Non-negative integer literals are compared as uint64 to avoid the precision loss of float64.
+func (e *Expr) evalUint(n node, v uint64, field any) (bool, error) {
+	u, ok := n.uint, n.hasUint
+	if !ok {
+		u, ok = parseUint(n.val.v)
+	}
+	if !ok {
+		f := n.num
+		if !n.hasNum {
+			f, _ = parseNumber(n.val.v) // invalid literals are reported by evalNumber
 		}
+		if f >= 0 || math.IsNaN(f) {
+			return e.evalNumber(n, float64(v), field)
+		}
+		// A negative literal is always less than an unsigned value.
+		switch n.op.typ {
+		case tokenGT, tokenGTE, tokenNEQ:
+			return true, nil
+		case tokenLT, tokenLTE, tokenEQ:
+			return false, nil
+		default:
+			return e.evalNumber(n, float64(v), field)
+		}
+	}
+	switch n.op.typ {
+	case tokenApprox:
+		return e.evalNumber(n, float64(v), field)
+	case tokenGT:
+		return v > u, nil
+	case tokenGTE:
+		return v >= u, nil
+	case tokenLT:
+		return v < u, nil
+	case tokenLTE:
+		return v <= u, nil
+	case tokenEQ:
+		return float64(max(v, u)-min(v, u)) <= e.parser.opts.epsilon, nil
+	case tokenNEQ:
+		return float64(max(v, u)-min(v, u)) > e.parser.opts.epsilon, nil
+	default:
+		return e.invalidOperator(n, field)
+	}
+}
+
+// parseNumber parses a number literal.
+// Integer literals with base prefixes such as 0x1A, 0o755 and 0b1011, which
+// strconv.ParseFloat rejects, are accepted as well.
+func parseNumber(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err == nil {
+		return f, nil
+	}
+	if i, ok := parseInt(s); ok {
+		return float64(i), nil
+	}
+	if u, ok := parseUint(s); ok {
+		return float64(u), nil
+	}
+	return f, err
+}
+
+// parseInt parses a signed integer literal such as 42, -0x1A, 0o755 or 0b1011.
+// A leading zero does not mean octal, consistent with strconv.ParseFloat.
+func parseInt(s string) (int64, bool) {
+	digits := strings.TrimLeft(s, "+-")
+	base := 0
+	if len(digits) > 1 && digits[0] == '0' && '0' <= digits[1] && digits[1] <= '9' {
+		base = 10
 	}
+	i, err := strconv.ParseInt(s, base, 64)
+	return i, err == nil
+}
+
+// parseUint parses an unsigned integer literal.
+// A leading zero does not mean octal, consistent with strconv.ParseFloat.
+func parseUint(s string) (uint64, bool) {
+	s = strings.TrimPrefix(s, "+")
+	base := 0
+	if len(s) > 1 && s[0] == '0' && '0' <= s[1] && s[1] <= '9' {
+		base = 10
+	}
+	u, err := strconv.ParseUint(s, base, 64)
+	return u, err == nil
 }
 
 // evalTime evaluates a time expression against a target.
-func evalTime(n node, v time.Time) (bool, error) {
+// A now literal is resolved with the clock of the evaluation plus its offset.
+func evalTime(n node, v time.Time, field any, st *state) (bool, error) {
 	t := n.time
-	if !n.hasTime {
-		parsed, err := time.Parse(time.RFC3339, n.val.v)
+	switch {
+	case n.val.typ == tokenNow:
+		t = st.current().Add(n.dur)
+	case !n.hasTime:
+		parsed, err := parseTime(n.val.v)
 		if err != nil {
 			return false, &Error{
 				Kind: KindEval,
-				Err:  fmt.Errorf("invalid time at %d:%d: %q", n.val.line, n.val.col, n.val.v),
+				Err:  fmt.Errorf("invalid time for %T field at %d:%d: %q", field, n.val.line, n.val.col, n.val.v),
 			}
 		}
 		t = parsed
@@ -218,20 +1256,187 @@ func evalTime(n node, v time.Time) (bool, error) {
 	default:
 		return false, &Error{
 			Kind: KindEval,
-			Err:  fmt.Errorf("invalid operator for time field at %d:%d: %q", n.op.line, n.op.col, n.op.typ.literal()),
+			Err:  fmt.Errorf("invalid operator for %T field at %d:%d: %q", field, n.op.line, n.op.col, n.op.typ.literal()),
+		}
+	}
+}
+
+// evalOffset evaluates a comparison of a time field against another time field moved by a duration offset.
+func evalOffset(n node, field, ref any, st *state) (bool, error) {
+	v, err := timeValue(n.ident, field)
+	if err != nil {
+		return false, err
+	}
+	r, err := timeValue(n.ref, ref)
+	if err != nil {
+		return false, err
+	}
+	n.time, n.hasTime = r.Add(n.dur), true
+	return evalTime(n, v, v, st)
+}
+
+// timeValue returns the time held by the field, dereferencing a pointer once
+// and taking the value of a driver.Valuer such as sql.NullTime.
+func timeValue(ident token, field any) (time.Time, error) {
+	if v := reflect.ValueOf(field); v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return time.Time{}, &Error{
+				Kind: KindEval,
+				Err:  fmt.Errorf("%w: %q is nil at %d:%d", ErrFieldNotFound, ident.v, ident.line, ident.col),
+			}
+		}
+		field = v.Elem().Interface()
+	}
+	if v, ok := field.(driver.Valuer); ok {
+		var err error
+		if field, err = driverValue(ident, v); err != nil {
+			return time.Time{}, err
+		}
+	}
+	v, ok := field.(time.Time)
+	if !ok {
+		return time.Time{}, &Error{
+			Kind: KindEval,
+			Err:  fmt.Errorf("field offset requires time fields at %d:%d: %q is %T", ident.line, ident.col, ident.v, field),
+		}
+	}
+	return v, nil
+}
+
+// parseTime parses a time literal, whose date and time may be separated by a space instead of T.
+// A time without a zone, such as 2023-01-01 12:00:00, is taken as UTC.
+func parseTime(s string) (time.Time, error) {
+	if len(s) > 10 && s[10] == ' ' {
+		s = s[:10] + "T" + s[11:]
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		if u, uerr := time.Parse("2006-01-02T15:04:05", s); uerr == nil {
+			return u, nil
+		}
+	}
+	return t, err
+}
+
+// parseDuration parses a duration literal.
+// If extended is true, d (24h) and w (168h) units are also accepted.
+// Like time.ParseDuration, a duration past the range of time.Duration is an error.
+func parseDuration(s string, extended bool) (time.Duration, error) {
+	if !extended || !strings.ContainsAny(s, "dw") {
+		return time.ParseDuration(s)
+	}
+	orig := s
+	neg := false
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	var d time.Duration
+	var rest strings.Builder
+	for s != "" {
+		i := 0
+		for i < len(s) && (s[i] == '.' || '0' <= s[i] && s[i] <= '9') {
+			i++
+		}
+		j := i
+		for j < len(s) && s[j] != '.' && (s[j] < '0' || '9' < s[j]) {
+			j++
+		}
+		switch s[i:j] {
+		case "d", "w":
+			f, err := strconv.ParseFloat(s[:i], 64)
+			if err != nil {
+				return 0, fmt.Errorf("time: invalid duration %q", orig)
+			}
+			unit := 24 * time.Hour
+			if s[i] == 'w' {
+				unit *= 7
+			}
+			v := f * float64(unit)
+			if v >= math.MaxInt64 || time.Duration(v) > math.MaxInt64-d {
+				return 0, fmt.Errorf("time: invalid duration %q", orig)
+			}
+			d += time.Duration(v)
+		default:
+			rest.WriteString(s[:j])
+		}
+		s = s[j:]
+	}
+	if rest.Len() > 0 {
+		r, err := time.ParseDuration(rest.String())
+		if err != nil {
+			return 0, fmt.Errorf("time: invalid duration %q", orig)
+		}
+		if r > 0 && r > math.MaxInt64-d {
+			return 0, fmt.Errorf("time: invalid duration %q", orig)
+		}
+		d += r
+	}
+	if neg {
+		d = -d
+	}
+	return d, nil
+}
+
+// evalSeconds evaluates a seconds expression against a target.
+func (e *Expr) evalSeconds(n node, v Seconds) (bool, error) {
+	if n.val.typ == tokenNumber {
+		return e.evalNumber(n, float64(v), v)
+	}
+	ns := math.Round(float64(v) * float64(time.Second))
+	if math.IsNaN(ns) || ns < math.MinInt64 || ns >= math.MaxInt64 {
+		return false, &Error{
+			Kind: KindEval,
+			Err:  fmt.Errorf("seconds out of range for %q at %d:%d: %v", n.ident.v, n.ident.line, n.ident.col, float64(v)),
 		}
 	}
+	return e.evalDuration(n, time.Duration(ns), v)
+}
+
+// unitDuration converts an integer field to a duration in the unit given to WithFieldDurationUnit.
+// It reports false if the field is not an integer, and an error if the duration is out of range.
+func unitDuration(n node, field any) (time.Duration, bool, error) {
+	var i int64
+	v := reflect.ValueOf(field)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i = v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return 0, true, unitRangeError(n, field)
+		}
+		i = int64(v.Uint())
+	default:
+		return 0, false, nil
+	}
+	unit := int64(n.unit)
+	if i > math.MaxInt64/unit || i < math.MinInt64/unit {
+		return 0, true, unitRangeError(n, field)
+	}
+	return time.Duration(i * unit), true, nil
+}
+
+// unitRangeError reports an integer field whose duration in its unit does not fit in time.Duration.
+func unitRangeError(n node, field any) error {
+	return &Error{
+		Kind: KindEval,
+		Err:  fmt.Errorf("duration out of range for %q at %d:%d: %v in units of %s", n.ident.v, n.ident.line, n.ident.col, field, n.unit),
+	}
 }
 
 // evalDuration evaluates a duration expression against a target.
-func evalDuration(n node, v time.Duration) (bool, error) {
+func (e *Expr) evalDuration(n node, v time.Duration, field any) (bool, error) {
 	d := n.dur
 	if !n.hasDur {
-		parsed, err := time.ParseDuration(n.val.v)
+		if !n.op.typ.isOrderingOperatorType() && !n.op.typ.isExactEqualityOperatorType() {
+			// The operator cannot apply whatever the value is, so it is reported before the value.
+			return e.invalidOperator(n, field)
+		}
+		parsed, err := parseDuration(n.val.v, e.parser.opts.extendedUnits)
 		if err != nil {
 			return false, &Error{
 				Kind: KindEval,
-				Err:  fmt.Errorf("invalid duration at %d:%d: %q", n.val.line, n.val.col, n.val.v),
+				Err:  fmt.Errorf("invalid duration for %T field at %d:%d: %q", field, n.val.line, n.val.col, n.val.v),
 			}
 		}
 		d = parsed
@@ -250,9 +1455,6 @@ func evalDuration(n node, v time.Duration) (bool, error) {
 	case tokenNEQ:
 		return v != d, nil
 	default:
-		return false, &Error{
-			Kind: KindEval,
-			Err:  fmt.Errorf("invalid operator for duration field at %d:%d: %q", n.op.line, n.op.col, n.op.typ.literal()),
-		}
+		return e.invalidOperator(n, field)
 	}
 }
@@ -1,7 +1,15 @@
 package filter
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -26,11 +34,37 @@ var testObject = testTarget{
 	"Uint16":       uint16(5),
 	"Uint32":       uint32(5),
 	"Uint64":       uint64(5),
+	"Uint64Zero":   uint64(0),
+	"MaxUint64":    uint64(math.MaxUint64),
+	"MaxInt64":     int64(math.MaxInt64),
+	"MinInt64":     int64(math.MinInt64),
+	"Mask":         int32(0o755),
+	"NaN":          math.NaN(),
+	"Inf":          math.Inf(1),
+	"NegInf":       float32(math.Inf(-1)),
 	"Float32":      float32(2.5),
 	"Float64":      3.14,
 	"Time":         time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	"Deadline":     time.Date(2025, 1, 1, 1, 0, 0, 0, time.UTC),
 	"Duration":     1500 * time.Millisecond,
+	"Skew":         -500 * time.Millisecond,
 	"Bool":         true,
+	"IntPtr":       new(42),
+	"StringPtr":    new("HelloWorld"),
+	"Float64Ptr":   any(new(3.14)),
+	"TimePtr":      new(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)),
+	"NilIntPtr":    (*int)(nil),
+	"Tags":         []string{"a", "b", "c"},
+	"Ints":         []int{1, 2, 3},
+	"Durations":    [2]time.Duration{time.Second, time.Minute},
+	"EmptyTags":    []string{},
+	"Labels":       []string{"a,b", "c(d)"},
+	"Seconds":      Seconds(2.5),
+	"Padded":       "  HelloWorld\t",
+	"Message":      "起動失敗: Disk Error",
+	"TinySeconds":  Seconds(0.0000000015),
+	"NaNSeconds":   Seconds(math.NaN()),
+	"HugeSeconds":  Seconds(1e10),
 }
 
 type testTarget map[string]any
@@ -40,7 +74,7 @@ func (t testTarget) GetField(key string) (any, error) {
 	if !ok {
 		return nil, &Error{
 			Kind: KindEval,
-			Err:  fmt.Errorf("field not found: %q", key),
+			Err:  fmt.Errorf("%w: %q", ErrFieldNotFound, key),
 		}
 	}
 	return v, nil
@@ -149,6 +183,51 @@ func TestEval(t *testing.T) {
 				val: false,
 			},
 		},
+		{
+			name:   "regex list match",
+			input:  `String=~("^Bye", "World$")`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "regex list no match",
+			input:  `String=~("^Bye", "^World")`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "regex list neg match",
+			input:  `String!~("^Bye", "World$")`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "regex list neg no match",
+			input:  `String!~("^Bye", "^World")`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "regex list case-insensitive",
+			input:  `String=~*("^bye", "^hello")`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
 		// Numeric comparisons
 		{
 			name:   "int gt",
@@ -305,16 +384,26 @@ func TestEval(t *testing.T) {
 				val: true,
 			}},
 		{
-			name:   "float32 gt",
-			input:  `Float32>2`,
+			name:   "uint64 max eq",
+			input:  `MaxUint64==18446744073709551615`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
 				val: true,
-			}},
+			},
+		},
 		{
-			name:   "float lt",
-			input:  `Float64<3.2`,
+			name:   "uint64 max eq adjacent false",
+			input:  `MaxUint64==18446744073709551614`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "uint64 max neq adjacent",
+			input:  `MaxUint64!=18446744073709551614`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
@@ -322,8 +411,8 @@ func TestEval(t *testing.T) {
 			},
 		},
 		{
-			name:   "float gte",
-			input:  `Float64>=3.14`,
+			name:   "uint64 max gt adjacent",
+			input:  `MaxUint64>18446744073709551614`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
@@ -331,8 +420,17 @@ func TestEval(t *testing.T) {
 			},
 		},
 		{
-			name:   "float eq epsilon",
-			input:  `Float64==3.1400000001`,
+			name:   "uint64 max lt false",
+			input:  `MaxUint64<18446744073709551615`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "uint64 max lte",
+			input:  `MaxUint64<=18446744073709551615`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
@@ -340,18 +438,17 @@ func TestEval(t *testing.T) {
 			},
 		},
 		{
-			name:   "float neq epsilon",
-			input:  `Float64!=3.1401`,
+			name:   "uint64 max gte",
+			input:  `MaxUint64>=18446744073709551615`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
 				val: true,
 			},
 		},
-		// Duration
 		{
-			name:   "duration gt",
-			input:  `Duration>1s`,
+			name:   "uint64 max eq hex",
+			input:  `MaxUint64==0xFFFFFFFFFFFFFFFF`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
@@ -359,26 +456,26 @@ func TestEval(t *testing.T) {
 			},
 		},
 		{
-			name:   "duration gte false",
-			input:  `Duration>=2s`,
+			name:   "uint64 max eq quoted",
+			input:  `MaxUint64=="18446744073709551615"`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
-				val: false,
+				val: true,
 			},
 		},
 		{
-			name:   "duration gt false",
-			input:  `Duration>2s`,
+			name:   "uint64 leading zero not octal",
+			input:  `Uint64==05`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
-				val: false,
+				val: true,
 			},
 		},
 		{
-			name:   "duration gte true",
-			input:  `Duration>=1500ms`,
+			name:   "uint64 fractional fallback",
+			input:  `Uint64<5.5`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
@@ -386,8 +483,8 @@ func TestEval(t *testing.T) {
 			},
 		},
 		{
-			name:   "duration lt",
-			input:  `Duration<2s`,
+			name:   "uint64 fractional eq fallback",
+			input:  `Uint64==5.0`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
@@ -395,17 +492,17 @@ func TestEval(t *testing.T) {
 			},
 		},
 		{
-			name:   "duration lt false",
-			input:  `Duration<1s`,
+			name:   "uint64 gt negative",
+			input:  `Uint64>-1`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
-				val: false,
+				val: true,
 			},
 		},
 		{
-			name:   "duration lte true",
-			input:  `Duration<=1500ms`,
+			name:   "uint64 gte negative",
+			input:  `Uint64>=-1`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
@@ -413,8 +510,8 @@ func TestEval(t *testing.T) {
 			},
 		},
 		{
-			name:   "duration lte false",
-			input:  `Duration<=1s`,
+			name:   "uint64 lt negative",
+			input:  `Uint64<-1`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
@@ -422,17 +519,17 @@ func TestEval(t *testing.T) {
 			},
 		},
 		{
-			name:   "duration eq",
-			input:  `Duration==1500ms`,
+			name:   "uint64 lte negative",
+			input:  `Uint64<=-1`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
-				val: true,
+				val: false,
 			},
 		},
 		{
-			name:   "duration eq false",
-			input:  `Duration==2s`,
+			name:   "uint64 eq negative",
+			input:  `Uint64==-5`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
@@ -440,8 +537,8 @@ func TestEval(t *testing.T) {
 			},
 		},
 		{
-			name:   "duration neq",
-			input:  `Duration!=2s`,
+			name:   "uint64 neq negative",
+			input:  `Uint64!=-5`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
@@ -449,54 +546,53 @@ func TestEval(t *testing.T) {
 			},
 		},
 		{
-			name:   "invalid operator duration",
-			input:  `Duration=~"1500ms"`,
+			name:   "uint64 zero gt negative",
+			input:  `Uint64Zero>-0.5`,
 			target: testObject,
 			expected: expected{
-				ok:  false,
-				err: `invalid operator for duration`,
+				ok:  true,
+				val: true,
 			},
 		},
 		{
-			name:   "duration neq false",
-			input:  `Duration!=1500ms`,
+			name:   "uint max gt",
+			input:  `Uint>4`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
-				val: false,
+				val: true,
 			},
 		},
 		{
-			name:   "duration invalid at eval",
-			input:  `Duration>bad`,
+			name:   "uint64 invalid operator",
+			input:  `Uint64=~"5"`,
 			target: testObject,
 			expected: expected{
 				ok:  false,
-				err: `expected value, got identifier`,
+				err: `eval error`,
 			},
 		},
-		// Time
 		{
-			name:   "time gt",
-			input:  `Time>'2024-01-01T00:00:00Z'`,
+			name:   "uint64 negative invalid operator",
+			input:  `Uint64=~"-5"`,
 			target: testObject,
 			expected: expected{
-				ok:  true,
-				val: true,
+				ok:  false,
+				err: `eval error`,
 			},
 		},
 		{
-			name:   "time gt false",
-			input:  `Time>'2026-01-01T00:00:00Z'`,
+			name:   "uint64 invalid number",
+			input:  `Uint64>"abc"`,
 			target: testObject,
 			expected: expected{
-				ok:  true,
-				val: false,
+				ok:  false,
+				err: `eval error`,
 			},
 		},
 		{
-			name:   "time gte",
-			input:  `Time>='2025-01-01T00:00:00Z'`,
+			name:   "int eq hex",
+			input:  `Int==0x2A`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
@@ -504,17 +600,17 @@ func TestEval(t *testing.T) {
 			},
 		},
 		{
-			name:   "time gte false",
-			input:  `Time>='2026-01-01T00:00:00Z'`,
+			name:   "int eq hex upper",
+			input:  `Int==0X2a`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
-				val: false,
+				val: true,
 			},
 		},
 		{
-			name:   "time lt",
-			input:  `Time<'2026-01-01T00:00:00Z'`,
+			name:   "int eq octal",
+			input:  `Mask==0o755`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
@@ -522,17 +618,17 @@ func TestEval(t *testing.T) {
 			},
 		},
 		{
-			name:   "time lt false",
-			input:  `Time<'2024-01-01T00:00:00Z'`,
+			name:   "int eq octal upper",
+			input:  `Mask==0O755`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
-				val: false,
+				val: true,
 			},
 		},
 		{
-			name:   "time lte",
-			input:  `Time<='2025-01-01T00:00:00Z'`,
+			name:   "int neq octal",
+			input:  `Mask!=0o644`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
@@ -540,17 +636,17 @@ func TestEval(t *testing.T) {
 			},
 		},
 		{
-			name:   "time lte false",
-			input:  `Time<='2024-01-01T00:00:00Z'`,
+			name:   "int eq binary",
+			input:  `Int==0b101010`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
-				val: false,
+				val: true,
 			},
 		},
 		{
-			name:   "time eq",
-			input:  `Time=='2025-01-01T00:00:00Z'`,
+			name:   "int gt binary",
+			input:  `Int>0b101001`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
@@ -558,17 +654,17 @@ func TestEval(t *testing.T) {
 			},
 		},
 		{
-			name:   "time eq false",
-			input:  `Time=='2024-01-01T00:00:00Z'`,
+			name:   "int lt hex",
+			input:  `Int<0x2B`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
-				val: false,
+				val: true,
 			},
 		},
 		{
-			name:   "time neq",
-			input:  `Time!='2024-01-01T00:00:00Z'`,
+			name:   "int eq negative hex",
+			input:  `Int>-0x2A`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
@@ -576,18 +672,17 @@ func TestEval(t *testing.T) {
 			},
 		},
 		{
-			name:   "time neq false",
-			input:  `Time!='2025-01-01T00:00:00Z'`,
+			name:   "int eq separator hex",
+			input:  `Int==0x_2A`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
-				val: false,
+				val: true,
 			},
 		},
-		// Combined logicals
 		{
-			name:   "combined logicals",
-			input:  `String=="HelloWorld" && Int==42 || Float64<3.0`,
+			name:   "int leading zero is decimal",
+			input:  `Int==042`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
@@ -595,17 +690,17 @@ func TestEval(t *testing.T) {
 			},
 		},
 		{
-			name:   "combined logicals false",
-			input:  `String=="HelloWorld" && Int==41 || Float64<3.0`,
+			name:   "int leading zero eq",
+			input:  `Int==0042`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
-				val: false,
+				val: true,
 			},
 		},
 		{
-			name:   "combined logicals with parens",
-			input:  `(String=="HelloWorld" && Int==41) || (Float64<3.2 && Bool==true)`,
+			name:   "int leading zero decimal eq",
+			input:  `Mask==0493`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
@@ -613,18 +708,17 @@ func TestEval(t *testing.T) {
 			},
 		},
 		{
-			name:   "combined logicals with parens false",
-			input:  `(String=="HelloWorld" && Int==41) || (Float64<3.0 && Bool==true)`,
+			name:   "int eq quoted hex",
+			input:  `Int=="0x2A"`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
-				val: false,
+				val: true,
 			},
 		},
-		// Bool
 		{
-			name:   "bool eq",
-			input:  `Bool==true`,
+			name:   "int eq float literal",
+			input:  `Int==42.0`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
@@ -632,8 +726,8 @@ func TestEval(t *testing.T) {
 			},
 		},
 		{
-			name:   "bool neq",
-			input:  `Bool!=false`,
+			name:   "int lt float literal",
+			input:  `Int<42.5`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
@@ -641,8 +735,8 @@ func TestEval(t *testing.T) {
 			},
 		},
 		{
-			name:   "and true",
-			input:  `Int>40&&Float64<4`,
+			name:   "int64 max eq",
+			input:  `MaxInt64==9223372036854775807`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
@@ -650,8 +744,8 @@ func TestEval(t *testing.T) {
 			},
 		},
 		{
-			name:   "and false",
-			input:  `Int>40&&Float64>4`,
+			name:   "int64 max eq adjacent false",
+			input:  `MaxInt64==9223372036854775806`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
@@ -659,8 +753,8 @@ func TestEval(t *testing.T) {
 			},
 		},
 		{
-			name:   "or true",
-			input:  `Int>100||Float64<4`,
+			name:   "int64 max gt adjacent",
+			input:  `MaxInt64>9223372036854775806`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
@@ -668,8 +762,8 @@ func TestEval(t *testing.T) {
 			},
 		},
 		{
-			name:   "or short-circuit left true",
-			input:  `Bool==true || InvalidField==1`,
+			name:   "int64 min eq",
+			input:  `MinInt64==-9223372036854775808`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
@@ -677,17 +771,17 @@ func TestEval(t *testing.T) {
 			},
 		},
 		{
-			name:   "not true->false",
-			input:  `!(Int>40)`,
+			name:   "int64 min neq adjacent",
+			input:  `MinInt64!=-9223372036854775807`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
-				val: false,
+				val: true,
 			},
 		},
 		{
-			name:   "not false->true",
-			input:  `!(Int<40)`,
+			name:   "int64 max lt uint literal",
+			input:  `MaxInt64<18446744073709551615`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
@@ -695,46 +789,44 @@ func TestEval(t *testing.T) {
 			},
 		},
 		{
-			name:   "and short-circuit left false",
-			input:  `Int>100 && InvalidField==1`,
+			name:   "float eq hex",
+			input:  `Float64>0x3`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
-				val: false,
+				val: true,
 			},
 		},
 		{
-			name:   "not inner eval error",
-			input:  `!(InvalidField==1)`,
+			name:   "float eq octal",
+			input:  `Float32<0o3`,
 			target: testObject,
 			expected: expected{
-				ok:  false,
-				err: `eval error`,
+				ok:  true,
+				val: true,
 			},
 		},
-		// Mixed
 		{
-			name:   "precedence",
-			input:  `Int>40&&Float64<4||Bool==false`,
+			name:   "uint eq hex",
+			input:  `Uint64==0x5`,
 			target: testObject,
 			expected: expected{
 				ok:  true,
 				val: true,
 			},
 		},
-		// Errors
 		{
-			name:   "binary left eval error",
-			input:  `UnknownField==1 && Bool==true`,
+			name:   "uint eq binary",
+			input:  `Uint==0b101`,
 			target: testObject,
 			expected: expected{
-				ok:  false,
-				err: `eval error`,
+				ok:  true,
+				val: true,
 			},
 		},
 		{
-			name:   "binary right eval error",
-			input:  `Bool==true && UnknownField==1`,
+			name:   "int invalid operator",
+			input:  `Int=~"0x2A"`,
 			target: testObject,
 			expected: expected{
 				ok:  false,
@@ -742,132 +834,2953 @@ func TestEval(t *testing.T) {
 			},
 		},
 		{
-			name:   "invalid field",
-			input:  `InvalidField==1`,
+			name:   "chain int in range",
+			input:  `40<Int<100`,
 			target: testObject,
 			expected: expected{
-				ok:  false,
-				err: `eval error`,
+				ok:  true,
+				val: true,
 			},
 		},
 		{
-			name:   "type mismatch 1",
-			input:  `Int>"abc"`,
+			name:   "chain int out of range",
+			input:  `42<Int<100`,
 			target: testObject,
 			expected: expected{
-				ok:  false,
-				err: `eval error`,
+				ok:  true,
+				val: false,
 			},
 		},
 		{
-			name:   "type mismatch 2",
-			input:  `String>"HelloWorld"`,
+			name:   "chain int inclusive",
+			input:  `42<=Int<=42`,
 			target: testObject,
 			expected: expected{
-				ok:  false,
-				err: `eval error`,
+				ok:  true,
+				val: true,
 			},
 		},
 		{
-			name:   "type mismatch 3",
-			input:  `Int=~"42"`,
+			name:   "chain descending",
+			input:  `100>Float64>3`,
 			target: testObject,
 			expected: expected{
-				ok:  false,
-				err: `eval error`,
+				ok:  true,
+				val: true,
 			},
 		},
 		{
-			name:   "invalid number right",
-			input:  `Int>1+0`,
+			name:   "chain duration",
+			input:  `1s<Duration<2s`,
 			target: testObject,
 			expected: expected{
-				ok:  false,
-				err: `parse error`,
+				ok:  true,
+				val: true,
 			},
 		},
 		{
-			name:   "invalid duration right",
-			input:  `Duration>1xs`,
+			name:   "int pointer eq",
+			input:  `IntPtr==42`,
 			target: testObject,
 			expected: expected{
-				ok:  false,
-				err: `parse error`,
+				ok:  true,
+				val: true,
 			},
 		},
 		{
-			name:   "regex compile error",
-			input:  `String=~"["`,
+			name:   "int pointer gt",
+			input:  `IntPtr>41`,
 			target: testObject,
 			expected: expected{
-				ok:  false,
-				err: `parse error`,
+				ok:  true,
+				val: true,
 			},
 		},
 		{
-			name:   "regex not found",
-			input:  `String=~""`,
+			name:   "int pointer lt false",
+			input:  `IntPtr<42`,
 			target: testObject,
 			expected: expected{
-				ok:  false,
-				err: `parse error`,
+				ok:  true,
+				val: false,
 			},
 		},
 		{
-			name:   "invalid time",
-			input:  `Time>'invalid-time'`,
+			name:   "string pointer eq",
+			input:  `StringPtr=="HelloWorld"`,
 			target: testObject,
 			expected: expected{
-				ok:  false,
-				err: `eval error`,
+				ok:  true,
+				val: true,
 			},
 		},
 		{
-			name:   "time invalid operator",
-			input:  `Time=~'2025-01-01T00:00:00Z'`,
+			name:   "string pointer regex",
+			input:  `StringPtr=~"^Hello"`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "float64 pointer in interface",
+			input:  `Float64Ptr>3.1`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "time pointer eq",
+			input:  `TimePtr==2025-01-01T00:00:00Z`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "nil int pointer",
+			input:  `NilIntPtr==0`,
 			target: testObject,
 			expected: expected{
 				ok:  false,
-				err: `eval error`,
+				err: `eval error: field not found: "NilIntPtr" is nil at 1:1`,
 			},
 		},
 		{
-			name:   "invalid duration",
-			input:  `Duration>'bad-duration'`,
+			name:   "containsany string one present",
+			input:  `Tags containsany ("x", "b")`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "containsany string all present",
+			input:  `Tags containsany ("a", "b", "c")`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "containsany string none present",
+			input:  `Tags containsany ("x", "y")`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "containsany string empty list",
+			input:  `Tags containsany ()`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "containsany empty slice",
+			input:  `EmptyTags containsany ("a")`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "containsall string all present",
+			input:  `Tags containsall ("c", "a")`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "containsall string one missing",
+			input:  `Tags containsall ("a", "x")`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "containsall string none present",
+			input:  `Tags containsall ("x", "y")`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "containsall string empty list",
+			input:  `Tags containsall ()`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "containsall empty slice",
+			input:  `EmptyTags containsall ("a")`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "containsall empty slice empty list",
+			input:  `EmptyTags containsall ()`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "containsany int one present",
+			input:  `Ints containsany (5, 3)`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "containsany int none present",
+			input:  `Ints containsany (4, 5)`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "containsall int all present",
+			input:  `Ints containsall (1, 2, 3)`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "containsall int hex",
+			input:  `Ints containsall (0x1, 0b10)`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "containsall int one missing",
+			input:  `Ints containsall (1, 4)`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "containsany duration array",
+			input:  `Durations containsany (60s)`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "contains not",
+			input:  `!(Tags containsany ("x"))`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "containsall quoted delimiters",
+			input:  "Labels containsall (\"a,b\", `c(d)`)",
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "containsany quoted delimiters split",
+			input:  `Labels containsany ("a", "b", "c", "d")`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "containsany non-slice field",
+			input:  `String containsany ("a")`,
 			target: testObject,
 			expected: expected{
 				ok:  false,
-				err: `eval error`,
+				err: "eval error: invalid operator for non-slice field at 1:8: \"containsany\"",
+			},
+		},
+		{
+			name:   "containsall non-slice field",
+			input:  `Int containsall (42)`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: "invalid operator for non-slice field",
+			},
+		},
+		{
+			name:   "containsany element type mismatch",
+			input:  `Ints containsany ("a")`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: "eval error",
+			},
+		},
+		{
+			name:   "seconds gt duration",
+			input:  `Seconds>2s`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "seconds eq duration",
+			input:  `Seconds==2500ms`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "seconds lt duration",
+			input:  `Seconds<3s`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "seconds gte duration false",
+			input:  `Seconds>=3s`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "seconds neq duration",
+			input:  `Seconds!=2s`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "seconds mixed units",
+			input:  `Seconds==2s500ms`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "seconds rounded to nanosecond",
+			input:  `TinySeconds==2ns`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "seconds eq number",
+			input:  `Seconds==2.5`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "seconds gt number",
+			input:  `Seconds>2`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "seconds nan",
+			input:  `NaNSeconds>1s`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: "seconds out of range for \"NaNSeconds\" at 1:1",
 			},
 		},
+		{
+			name:   "seconds overflow",
+			input:  `HugeSeconds>1s`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: "seconds out of range",
+			},
+		},
+		{
+			name:   "seconds invalid operator",
+			input:  `Seconds=~"2s"`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: "invalid operator for filter.Seconds field",
+			},
+		},
+		{
+			name:   "lower eq",
+			input:  `lower(String)=="helloworld"`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "lower eq false",
+			input:  `lower(String)=="HelloWorld"`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "upper eq",
+			input:  `upper(String)=="HELLOWORLD"`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "upper regex",
+			input:  `upper(String)=~"^HELLO"`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "trim eq",
+			input:  `trim(Padded)=="HelloWorld"`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "trim neq",
+			input:  `trim(Padded)!="  HelloWorld\t"`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "lower string pointer",
+			input:  `lower(StringPtr)=="helloworld"`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "upper containsall",
+			input:  `upper(Tags) containsall ("A", "C")`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "lower non-string field",
+			input:  `lower(Int)=="42"`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `eval error: lower requires a string field at 1:7: "Int" is int`,
+			},
+		},
+		{
+			name:   "float32 gt",
+			input:  `Float32>2`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			}},
+		{
+			name:   "float lt",
+			input:  `Float64<3.2`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "float gte",
+			input:  `Float64>=3.14`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "float eq epsilon",
+			input:  `Float64==3.1400000001`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "float neq epsilon",
+			input:  `Float64!=3.1401`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		// Duration
+		{
+			name:   "duration gt",
+			input:  `Duration>1s`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "duration gte false",
+			input:  `Duration>=2s`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "duration gt false",
+			input:  `Duration>2s`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "duration gte true",
+			input:  `Duration>=1500ms`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "duration lt",
+			input:  `Duration<2s`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "duration lt false",
+			input:  `Duration<1s`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "duration lte true",
+			input:  `Duration<=1500ms`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "duration lte false",
+			input:  `Duration<=1s`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "duration eq",
+			input:  `Duration==1500ms`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "duration eq false",
+			input:  `Duration==2s`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "duration neq",
+			input:  `Duration!=2s`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "invalid operator duration",
+			input:  `Duration=~"1500ms"`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `invalid operator for time.Duration field`,
+			},
+		},
+		{
+			name:   "duration neq false",
+			input:  `Duration!=1500ms`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "int with boolean",
+			input:  `Int==true`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `eval error: cannot compare int field with boolean at 1:6: "true"`,
+			},
+		},
+		{
+			name:   "uint64 with boolean",
+			input:  `Uint64!=false`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `eval error: cannot compare uint64 field with boolean at 1:9: "false"`,
+			},
+		},
+		{
+			name:   "float with boolean",
+			input:  `Float64>true`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `eval error: cannot compare float64 field with boolean at 1:9: "true"`,
+			},
+		},
+		{
+			name:   "negative duration lt negative",
+			input:  `Skew < -100ms`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "negative duration lt negative without spaces",
+			input:  `Skew<-100ms`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "negative duration gt negative",
+			input:  `Skew > -100ms`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "negative duration eq",
+			input:  `Skew == -500ms`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "negative duration eq fraction",
+			input:  `Skew == -.5s`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "negative duration gte compound",
+			input:  `Skew >= -1h30m`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "negative duration lt positive",
+			input:  `Skew < 100ms`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "negative duration gt positive",
+			input:  `Skew > +1s`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "negative duration chain",
+			input:  `-1s < Skew < 0s`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "positive duration gt negative",
+			input:  `Duration > -1s`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "duration invalid at eval",
+			input:  `Duration>bad`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `expected value (string, number, duration, time or bool), got identifier at 1:10: "bad"`,
+			},
+		},
+		// Time
+		{
+			name:   "time gt",
+			input:  `Time>'2024-01-01T00:00:00Z'`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "time gt false",
+			input:  `Time>'2026-01-01T00:00:00Z'`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "time gte",
+			input:  `Time>='2025-01-01T00:00:00Z'`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "time gte false",
+			input:  `Time>='2026-01-01T00:00:00Z'`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "time lt",
+			input:  `Time<'2026-01-01T00:00:00Z'`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "time lt false",
+			input:  `Time<'2024-01-01T00:00:00Z'`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "time lte",
+			input:  `Time<='2025-01-01T00:00:00Z'`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "time lte false",
+			input:  `Time<='2024-01-01T00:00:00Z'`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "time eq",
+			input:  `Time=='2025-01-01T00:00:00Z'`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "time eq false",
+			input:  `Time=='2024-01-01T00:00:00Z'`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "time space separator",
+			input:  `Time==2025-01-01 00:00:00Z`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "time space separator quoted",
+			input:  `Time=='2025-01-01 00:00:00Z'`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "time space separator offset",
+			input:  `Time==2025-01-01 09:00:00+09:00`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "time space separator nanoseconds",
+			input:  `Time<2025-01-01 00:00:00.000000001Z`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "time space separator without zone",
+			input:  `Time<2023-01-01 12:00:00`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "time without zone is utc",
+			input:  `Time==2025-01-01T00:00:00`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "time space separator without zone quoted",
+			input:  `Time=='2025-01-01 00:00:00.000000000'`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "time nanoseconds",
+			input:  `Time>2024-12-31T23:59:59.999999999Z`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "time nanoseconds false",
+			input:  `Time>2025-01-01T00:00:00.000000001Z`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "time neq",
+			input:  `Time!='2024-01-01T00:00:00Z'`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "time neq false",
+			input:  `Time!='2025-01-01T00:00:00Z'`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		// Combined logicals
+		{
+			name:   "combined logicals",
+			input:  `String=="HelloWorld" && Int==42 || Float64<3.0`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "combined logicals false",
+			input:  `String=="HelloWorld" && Int==41 || Float64<3.0`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "combined logicals with parens",
+			input:  `(String=="HelloWorld" && Int==41) || (Float64<3.2 && Bool==true)`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "combined logicals with parens false",
+			input:  `(String=="HelloWorld" && Int==41) || (Float64<3.0 && Bool==true)`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		// Bool
+		{
+			name:   "bool eq",
+			input:  `Bool==true`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "bool neq",
+			input:  `Bool!=false`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "and true",
+			input:  `Int>40&&Float64<4`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "and false",
+			input:  `Int>40&&Float64>4`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "or true",
+			input:  `Int>100||Float64<4`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "or short-circuit left true",
+			input:  `Bool==true || InvalidField==1`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "not true->false",
+			input:  `!(Int>40)`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "not false->true",
+			input:  `!(Int<40)`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "and short-circuit left false",
+			input:  `Int>100 && InvalidField==1`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "not inner eval error",
+			input:  `!(InvalidField==1)`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `eval error`,
+			},
+		},
+		// Mixed
+		{
+			name:   "precedence",
+			input:  `Int>40&&Float64<4||Bool==false`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		// Errors
+		{
+			name:   "binary left eval error",
+			input:  `UnknownField==1 && Bool==true`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `eval error`,
+			},
+		},
+		{
+			name:   "binary right eval error",
+			input:  `Bool==true && UnknownField==1`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `eval error`,
+			},
+		},
+		{
+			name:   "invalid field",
+			input:  `InvalidField==1`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `eval error`,
+			},
+		},
+		{
+			name:   "type mismatch 1",
+			input:  `Int>"abc"`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `eval error: cannot compare int field "Int" with non-numeric value "abc" at 1:5`,
+			},
+		},
+		{
+			name:   "type mismatch 2",
+			input:  `String>"HelloWorld"`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `eval error`,
+			},
+		},
+		{
+			name:   "type mismatch 3",
+			input:  `Int=~"42"`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `eval error`,
+			},
+		},
+		{
+			name:   "invalid number right",
+			input:  `Int>1+0`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `parse error`,
+			},
+		},
+		{
+			name:   "invalid duration right",
+			input:  `Duration>1xs`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `parse error`,
+			},
+		},
+		{
+			name:   "regex compile error",
+			input:  `String=~"["`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `parse error`,
+			},
+		},
+		{
+			name:   "regex not found",
+			input:  `String=~""`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `parse error`,
+			},
+		},
+		{
+			name:   "invalid time",
+			input:  `Time>'invalid-time'`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `eval error`,
+			},
+		},
+		{
+			name:   "time invalid operator",
+			input:  `Time=~'2025-01-01T00:00:00Z'`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `eval error`,
+			},
+		},
+		{
+			name:   "time offset lte boundary",
+			input:  `Deadline<=Time + 1h`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "time offset lt boundary",
+			input:  `Deadline<Time + 1h`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "time offset attached sign",
+			input:  `Deadline<Time+2h`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "time offset subtraction",
+			input:  `Time>Deadline - 90m`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "time offset attached subtraction",
+			input:  `Time==Deadline-1h`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "time offset not equal",
+			input:  `Time!=Deadline - 1h`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "time offset pointer",
+			input:  `TimePtr<Deadline - 30m`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "time offset number",
+			input:  `Deadline<Time + 1`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `parse error: offset of field "Time" must be a duration, got number at 1:17: "1"`,
+			},
+		},
+		{
+			name:   "time offset attached number",
+			input:  `Deadline<Time+1`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `parse error: offset of field "Time" must be a duration, got number at 1:14: "+1"`,
+			},
+		},
+		{
+			name:   "time offset regex",
+			input:  `Deadline=~Time + 1h`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `parse error: invalid operator for field offset at 1:9: "=~"`,
+			},
+		},
+		{
+			name:   "time offset non-time field",
+			input:  `Deadline<Int + 1h`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `eval error: field offset requires time fields at 1:10: "Int" is int`,
+			},
+		},
+		{
+			name:   "time offset missing field",
+			input:  `Deadline<Missing + 1h`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `eval error`,
+			},
+		},
+		{
+			name:   "has",
+			input:  `Message has "Disk"`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "has case-sensitive",
+			input:  `Message has "disk"`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "has multibyte",
+			input:  `Message has "失敗"`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "has empty",
+			input:  `Message has ""`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "has with function",
+			input:  `lower(Message) has "disk error"`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "ihas mixed case",
+			input:  `Message ihas "dISK eRROR"`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "ihas multibyte",
+			input:  `Message ihas "起動失敗: disk"`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "ihas kelvin sign",
+			input:  `Message ihas "disK"`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "ihas not found",
+			input:  `Message ihas "warning"`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:   "not has",
+			input:  `!(Message has "Warning")`,
+			target: testObject,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:   "has number field",
+			input:  `Int has "4"`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `eval error: invalid operator for int field at 1:5: "has"`,
+			},
+		},
+		{
+			name:   "ihas bool field",
+			input:  `Bool ihas "t"`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `eval error: invalid operator for bool field at 1:6: "ihas"`,
+			},
+		},
+		{
+			name:   "has duration field",
+			input:  `Duration has "1"`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `eval error`,
+			},
+		},
+		{
+			name:   "nan field eq",
+			input:  `NaN==1`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `eval error`,
+			},
+		},
+		{
+			name:   "nan field neq",
+			input:  `NaN!=1`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `eval error`,
+			},
+		},
+		{
+			name:   "nan field gt",
+			input:  `NaN>1`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `eval error`,
+			},
+		},
+		{
+			name:   "inf field gt",
+			input:  `Inf>1`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `eval error`,
+			},
+		},
+		{
+			name:   "negative inf field lt",
+			input:  `NegInf<0`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `eval error`,
+			},
+		},
+		{
+			name:   "nan literal",
+			input:  `Float64=='NaN'`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `eval error`,
+			},
+		},
+		{
+			name:   "inf literal",
+			input:  `Float64>"+Inf"`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `eval error`,
+			},
+		},
+		{
+			name:   "nan literal uint",
+			input:  `Uint64!='NaN'`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `eval error`,
+			},
+		},
+		{
+			name:   "inf ident literal",
+			input:  `Float64>Inf`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `parse error`,
+			},
+		},
+		{
+			name:   "nan ident literal",
+			input:  `Float64!=NaN`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `parse error`,
+			},
+		},
+		{
+			name:   "overflow literal",
+			input:  `Float64<1e999`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `parse error`,
+			},
+		},
+		{
+			name:   "invalid duration",
+			input:  `Duration>'bad-duration'`,
+			target: testObject,
+			expected: expected{
+				ok:  false,
+				err: `eval error`,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, parseError := Parse(test.input)
+			if test.expected.ok {
+				if parseError != nil {
+					t.Errorf(testTemplate, test.input, "", parseError)
+					return
+				}
+				actual, evalError := expr.Eval(test.target)
+				if evalError != nil {
+					t.Errorf(testTemplate, test.input, test.expected.val, evalError)
+					return
+				}
+				if actual != test.expected.val {
+					t.Errorf(testTemplate, test.input, test.expected.val, actual)
+				}
+				return
+			}
+			if parseError == nil {
+				_, evalError := expr.Eval(test.target)
+				if evalError == nil || !strings.Contains(evalError.Error(), test.expected.err) {
+					t.Errorf(testTemplate, test.input, test.expected.err, evalError)
+				}
+				return
+			}
+			if !strings.Contains(parseError.Error(), test.expected.err) {
+				t.Errorf(testTemplate, test.input, test.expected.err, parseError)
+			}
+		})
+	}
+}
+
+func TestExpr_EvalWithFields(t *testing.T) {
+	type expected struct {
+		val    bool
+		fields map[string]any
+		err    string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		target   testTarget
+		expected expected
+	}{
+		{
+			name:   "single field",
+			input:  `Int==42`,
+			target: testObject,
+			expected: expected{
+				val:    true,
+				fields: map[string]any{"Int": 42},
+			},
+		},
+		{
+			name:   "multiple fields",
+			input:  `Int==42 && String=="HelloWorld" && Int>0`,
+			target: testObject,
+			expected: expected{
+				val:    true,
+				fields: map[string]any{"Int": 42, "String": "HelloWorld"},
+			},
+		},
+		{
+			name:   "and short-circuit",
+			input:  `Int==0 && String=="HelloWorld"`,
+			target: testObject,
+			expected: expected{
+				val:    false,
+				fields: map[string]any{"Int": 42},
+			},
+		},
+		{
+			name:   "or short-circuit",
+			input:  `Bool==true || Float64>3`,
+			target: testObject,
+			expected: expected{
+				val:    true,
+				fields: map[string]any{"Bool": true},
+			},
+		},
+		{
+			name:   "eval error",
+			input:  `Int==42 && Unknown==1`,
+			target: testObject,
+			expected: expected{
+				err: `eval error`,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, "", err)
+			}
+			actual, fields, err := expr.EvalWithFields(test.target)
+			if test.expected.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expected.err) {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+				if fields != nil {
+					t.Errorf(testTemplate, test.input, nil, fields)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected.val, err)
+			}
+			if actual != test.expected.val {
+				t.Errorf(testTemplate, test.input, test.expected.val, actual)
+			}
+			if !reflect.DeepEqual(fields, test.expected.fields) {
+				t.Errorf(testTemplate, test.input, test.expected.fields, fields)
+			}
+		})
+	}
+}
+
+func TestExpr_EvalPrefetched(t *testing.T) {
+	inputs := []string{`HP > 50 && Name == "slime"`, `Tags containsany ("b") || HP < 10`, `Missing == 1`, `HP == 100 || Missing == 1`}
+	var fields []string
+	exprs := make([]*Expr, len(inputs))
+	for i, input := range inputs {
+		exprs[i] = mustParse(t, input)
+		fields = append(fields, exprs[i].Fields()...)
+	}
+	// Fetch the union of the fields once.
+	target := testTarget{"HP": 100, "Name": "slime", "Tags": []string{"a", "b"}}
+	values := make(map[string]any)
+	for _, f := range fields {
+		if v, err := target.GetField(f); err == nil {
+			values[f] = v
+		}
+	}
+	type expected struct {
+		val bool
+		err string
+	}
+	tests := []struct {
+		expr     *Expr
+		expected expected
+	}{
+		{expr: exprs[0], expected: expected{val: true}},
+		{expr: exprs[1], expected: expected{val: true}},
+		{expr: exprs[2], expected: expected{err: `eval error: field not found: "Missing"`}},
+		{expr: exprs[3], expected: expected{val: true}},
+	}
+	for _, test := range tests {
+		t.Run(test.expr.String(), func(t *testing.T) {
+			actual, err := test.expr.EvalPrefetched(values)
+			if test.expected.err != "" {
+				if err == nil || err.Error() != test.expected.err || !errors.Is(err, ErrFieldNotFound) {
+					t.Errorf(testTemplate, test.expr, test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.expr, test.expected.val, err)
+			}
+			if actual != test.expected.val {
+				t.Errorf(testTemplate, test.expr, test.expected.val, actual)
+			}
+			if ok, _ := test.expr.Eval(target); ok != actual {
+				t.Errorf(testTemplate, test.expr, ok, actual)
+			}
+		})
+	}
+	if len(values) != 3 {
+		t.Errorf(testTemplate, "values", 3, len(values))
+	}
+}
+
+func TestExpr_EvalCaptures(t *testing.T) {
+	target := testTarget{
+		"Path":   "/users/42",
+		"Method": "GET",
+		"Int":    42,
+	}
+	type expected struct {
+		val      bool
+		captures map[string][]string
+		err      string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		expected expected
+	}{
+		{
+			name:  "numbered group",
+			input: `Path=~"^/users/([0-9]+)$"`,
+			expected: expected{
+				val:      true,
+				captures: map[string][]string{"Path": {"/users/42", "42"}},
+			},
+		},
+		{
+			name:  "named group",
+			input: `Path=~"^/(?P<kind>[a-z]+)/(?P<id>[0-9]+)$"`,
+			expected: expected{
+				val: true,
+				captures: map[string][]string{
+					"Path":      {"/users/42", "users", "42"},
+					"Path.kind": {"users"},
+					"Path.id":   {"42"},
+				},
+			},
+		},
+		{
+			name:  "case-insensitive",
+			input: `Method=~*"^(get|head)$"`,
+			expected: expected{
+				val:      true,
+				captures: map[string][]string{"Method": {"GET", "GET"}},
+			},
+		},
+		{
+			name:  "matches",
+			input: `Path matches "/users/([0-9]+)"`,
+			expected: expected{
+				val:      true,
+				captures: map[string][]string{"Path": {"/users/42", "42"}},
+			},
+		},
+		{
+			name:  "imatches",
+			input: `Method imatches "(get|head)"`,
+			expected: expected{
+				val:      true,
+				captures: map[string][]string{"Method": {"GET", "GET"}},
+			},
+		},
+		{
+			name:  "unmatched",
+			input: `Path=~"^/groups/([0-9]+)$"`,
+			expected: expected{
+				val:      false,
+				captures: map[string][]string{},
+			},
+		},
+		{
+			name:  "first match per field",
+			input: `Path=~"^/(users)" && Path=~"([0-9]+)$"`,
+			expected: expected{
+				val:      true,
+				captures: map[string][]string{"Path": {"/users", "users"}},
+			},
+		},
+		{
+			name:  "unmatched then matched",
+			input: `Path=~"^/(groups)" || Path=~"([0-9]+)$"`,
+			expected: expected{
+				val:      true,
+				captures: map[string][]string{"Path": {"42", "42"}},
+			},
+		},
+		{
+			name:  "short-circuit",
+			input: `Int==42 || Path=~"([0-9]+)"`,
+			expected: expected{
+				val:      true,
+				captures: map[string][]string{},
+			},
+		},
+		{
+			name:  "regex list",
+			input: `Path=~("^/(groups)", "^/(users)")`,
+			expected: expected{
+				val:      true,
+				captures: map[string][]string{"Path": {"/users", "users"}},
+			},
+		},
+		{
+			name:  "negated regex",
+			input: `Path!~"^/(groups)"`,
+			expected: expected{
+				val:      true,
+				captures: map[string][]string{},
+			},
+		},
+		{
+			name:  "eval error",
+			input: `Path=~"([0-9]+)" && Unknown==1`,
+			expected: expected{
+				err: `eval error`,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, "", err)
+			}
+			actual, captures, err := expr.EvalCaptures(target)
+			if test.expected.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expected.err) {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+				if captures != nil {
+					t.Errorf(testTemplate, test.input, nil, captures)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected.val, err)
+			}
+			if actual != test.expected.val {
+				t.Errorf(testTemplate, test.input, test.expected.val, actual)
+			}
+			if !reflect.DeepEqual(captures, test.expected.captures) {
+				t.Errorf(testTemplate, test.input, test.expected.captures, captures)
+			}
+		})
+	}
+}
+
+type testCancelTarget struct {
+	testTarget
+	cancel context.CancelFunc
+	key    string
+	calls  []string
+}
+
+func (t *testCancelTarget) GetField(key string) (any, error) {
+	t.calls = append(t.calls, key)
+	if key == t.key {
+		t.cancel()
+	}
+	return t.testTarget.GetField(key)
+}
+
+func TestExpr_EvalFieldReads(t *testing.T) {
+	target := testTarget{
+		"Int":   42,
+		"Tags":  []string{"a", "b"},
+		"Start": time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	tests := []struct {
+		input string
+		calls []string
+	}{
+		{input: `Int==42`, calls: []string{"Int"}},
+		{input: `Tags containsall ("a", "b")`, calls: []string{"Tags"}},
+		{input: `40<Int<50`, calls: []string{"Int"}},
+		{input: `Int==42 && Int!=0 && !(Int>100)`, calls: []string{"Int"}},
+		{input: `Start<=Start+1h`, calls: []string{"Start"}},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			expr, err := Parse(test.input)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, "", err)
+			}
+			ct := &testCancelTarget{testTarget: target, cancel: func() {}}
+			if ok, err := expr.Eval(ct); !ok || err != nil {
+				t.Fatalf(testTemplate, test.input, true, err)
+			}
+			if !reflect.DeepEqual(ct.calls, test.calls) {
+				t.Errorf(testTemplate, test.input, test.calls, ct.calls)
+			}
+		})
+	}
+}
+
+func TestExpr_EvalContext(t *testing.T) {
+	target := testTarget{
+		"Int":    42,
+		"String": "HelloWorld",
+		"Bool":   true,
+	}
+	tests := []struct {
+		name     string
+		input    string
+		cancelOn string
+		calls    []string
+		val      bool
+		err      error
+	}{
+		{
+			name:  "not canceled",
+			input: `Int==42 && String=~"^Hello" && Bool==true`,
+			calls: []string{"Int", "String", "Bool"},
+			val:   true,
+		},
+		{
+			name:     "canceled between nodes",
+			input:    `Int==42 && String=="HelloWorld" && Bool==true`,
+			cancelOn: "Int",
+			calls:    []string{"Int"},
+			err:      context.Canceled,
+		},
+		{
+			name:     "canceled before regex match",
+			input:    `Int==42 && String=~"^Hello"`,
+			cancelOn: "String",
+			calls:    []string{"Int", "String"},
+			err:      context.Canceled,
+		},
+		{
+			name:     "canceled before negated regex match",
+			input:    `String!~"^Bye" || Int==0`,
+			cancelOn: "String",
+			calls:    []string{"String"},
+			err:      context.Canceled,
+		},
+		{
+			name:     "canceled after last node",
+			input:    `Int==42 && Bool==true`,
+			cancelOn: "Bool",
+			calls:    []string{"Int", "Bool"},
+			val:      true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, "", err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			ct := &testCancelTarget{testTarget: target, cancel: cancel, key: test.cancelOn}
+			actual, err := expr.EvalContext(ctx, ct)
+			if !reflect.DeepEqual(ct.calls, test.calls) {
+				t.Errorf(testTemplate, test.input, test.calls, ct.calls)
+			}
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Errorf(testTemplate, test.input, test.err, err)
+				}
+				var e *Error
+				if !errors.As(err, &e) || e.Kind != KindEval {
+					t.Errorf(testTemplate, test.input, "eval error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.val, err)
+			}
+			if actual != test.val {
+				t.Errorf(testTemplate, test.input, test.val, actual)
+			}
+		})
+	}
+}
+
+func TestExpr_EvalContext_done(t *testing.T) {
+	expr, err := Parse(`Int==42 || Bool==true`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	<-ctx.Done()
+	_, err = expr.EvalContext(ctx, testObject)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf(testTemplate, "deadline", context.DeadlineExceeded, err)
+	}
+	if want := "eval error: evaluation stopped: context deadline exceeded"; err == nil || err.Error() != want {
+		t.Errorf(testTemplate, "deadline", want, err)
+	}
+}
+
+func Test_parseDuration(t *testing.T) {
+	type expected struct {
+		ok  bool
+		val time.Duration
+	}
+	tests := []struct {
+		name     string
+		input    string
+		extended bool
+		expected expected
+	}{
+		{name: "standard", input: "1h30m", expected: expected{ok: true, val: 90 * time.Minute}},
+		{name: "day rejected by default", input: "7d", expected: expected{ok: false}},
+		{name: "standard extended", input: "1h30m", extended: true, expected: expected{ok: true, val: 90 * time.Minute}},
+		{name: "day", input: "7d", extended: true, expected: expected{ok: true, val: 7 * 24 * time.Hour}},
+		{name: "week", input: "2w", extended: true, expected: expected{ok: true, val: 2 * 7 * 24 * time.Hour}},
+		{name: "float day", input: "1.5d", extended: true, expected: expected{ok: true, val: 36 * time.Hour}},
+		{name: "mixed", input: "1w1d12h30m", extended: true, expected: expected{ok: true, val: 8*24*time.Hour + 12*time.Hour + 30*time.Minute}},
+		{name: "negative", input: "-1d12h", extended: true, expected: expected{ok: true, val: -36 * time.Hour}},
+		{name: "positive", input: "+1d", extended: true, expected: expected{ok: true, val: 24 * time.Hour}},
+		{name: "missing number", input: "d", extended: true, expected: expected{ok: false}},
+		{name: "invalid rest", input: "1d1x", extended: true, expected: expected{ok: false}},
+		{name: "overflow", input: "100000000w", extended: true, expected: expected{ok: false}},
+		{name: "overflow sum", input: "15000w15000w", extended: true, expected: expected{ok: false}},
+		{name: "overflow standard units", input: "15000w2562047h", extended: true, expected: expected{ok: false}},
+		{name: "max", input: "15250w47h47m16.854775807s", extended: true, expected: expected{ok: true, val: math.MaxInt64}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := parseDuration(test.input, test.extended)
+			if (err == nil) != test.expected.ok {
+				t.Fatalf(testTemplate, test.input, test.expected.ok, err)
+			}
+			if actual != test.expected.val {
+				t.Errorf(testTemplate, test.input, test.expected.val, actual)
+			}
+		})
+	}
+}
+
+func TestExpr_EvalWithClock(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time {
+		return now
+	}
+	target := testTarget{
+		"Now":        now,
+		"HourAgo":    now.Add(-time.Hour),
+		"JustBefore": now.Add(-time.Hour - time.Nanosecond),
+		"JustAfter":  now.Add(-time.Hour + time.Nanosecond),
+		"Future":     now.Add(30 * time.Minute),
+		"Duration":   time.Hour,
+		"String":     "now",
+	}
+	type expected struct {
+		ok  bool
+		val bool
+		err string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected expected
+	}{
+		{name: "now eq", input: `Now==now`, expected: expected{ok: true, val: true}},
+		{name: "now gt false", input: `Now>now`, expected: expected{ok: true, val: false}},
+		{name: "boundary gt", input: `HourAgo>now-1h`, expected: expected{ok: true, val: false}},
+		{name: "boundary gte", input: `HourAgo>=now-1h`, expected: expected{ok: true, val: true}},
+		{name: "boundary eq", input: `HourAgo==now-1h`, expected: expected{ok: true, val: true}},
+		{name: "boundary lte", input: `HourAgo<=now-1h`, expected: expected{ok: true, val: true}},
+		{name: "just before", input: `JustBefore>now-1h`, expected: expected{ok: true, val: false}},
+		{name: "just before lt", input: `JustBefore<now-1h`, expected: expected{ok: true, val: true}},
+		{name: "just after", input: `JustAfter>now-1h`, expected: expected{ok: true, val: true}},
+		{name: "positive offset", input: `Future<now+1h && Future>now+29m`, expected: expected{ok: true, val: true}},
+		{name: "extended unit offset", input: `HourAgo>now-1d`, opts: []Option{WithExtendedDurationUnits()}, expected: expected{ok: true, val: true}},
+		{name: "string field", input: `String==now`, expected: expected{ok: true, val: true}},
+		{name: "duration field", input: `Duration>now-1h`, expected: expected{ok: false, err: `invalid duration`}},
+		{name: "invalid operator", input: `Now=~now`, expected: expected{ok: false, err: `invalid operator for time.Time field`}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, "", err)
+			}
+			calls := 0
+			actual, err := expr.EvalWithClock(target, func() time.Time {
+				calls++
+				return clock()
+			})
+			if calls > 1 {
+				t.Errorf(testTemplate, test.input, 1, calls)
+			}
+			if !test.expected.ok {
+				if err == nil || !strings.Contains(err.Error(), test.expected.err) {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected.val, err)
+			}
+			if actual != test.expected.val {
+				t.Errorf(testTemplate, test.input, test.expected.val, actual)
+			}
+		})
+	}
+}
+
+type testIP net.IP
+
+func (ip testIP) CompareTo(op, literal string) (bool, error) {
+	v := net.ParseIP(literal)
+	if v == nil {
+		return false, fmt.Errorf("invalid IP address: %q", literal)
+	}
+	switch op {
+	case "==":
+		return net.IP(ip).Equal(v), nil
+	case "!=":
+		return !net.IP(ip).Equal(v), nil
+	default:
+		return false, fmt.Errorf("unsupported operator for IP address: %q", op)
+	}
+}
+
+type testCounter struct {
+	n int
+}
+
+func (c *testCounter) CompareTo(op, literal string) (bool, error) {
+	return op == "==" && literal == fmt.Sprint(c.n), nil
+}
+
+type testPattern string
+
+func (p testPattern) CompareTo(op, literal string) (bool, error) {
+	return op+" "+literal == string(p), nil
+}
+
+func TestExpr_EvalComparable(t *testing.T) {
+	target := testTarget{
+		"Addr":    testIP(net.ParseIP("10.0.0.1")),
+		"Addr6":   testIP(net.ParseIP("::ffff:10.0.0.1")),
+		"String":  "10.0.0.1",
+		"Counter": &testCounter{n: 3},
+		"Big":     big.NewInt(123),
+		"Pattern": testPattern("=~* abc"),
+		"Matches": testPattern("imatches abc"),
+		"Negated": testPattern("!~* x"),
+	}
+	type expected struct {
+		ok  bool
+		val bool
+		err string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		expected expected
+	}{
+		{name: "eq", input: `Addr=="10.0.0.1"`, expected: expected{ok: true, val: true}},
+		{name: "eq false", input: `Addr=="10.0.0.2"`, expected: expected{ok: true, val: false}},
+		{name: "eq parsed", input: `Addr6=="10.0.0.1"`, expected: expected{ok: true, val: true}},
+		{name: "neq", input: `Addr!="10.0.0.2"`, expected: expected{ok: true, val: true}},
+		{name: "eq with logical", input: `Addr=="10.0.0.1" && String=="10.0.0.1"`, expected: expected{ok: true, val: true}},
+		{name: "unsupported operator", input: `Addr>"10.0.0.0"`, expected: expected{ok: false, err: `eval error: cannot compare "Addr" at 1:5: unsupported operator for IP address: ">"`}},
+		{name: "invalid literal", input: `Addr=="localhost"`, expected: expected{ok: false, err: `invalid IP address: "localhost"`}},
+		{name: "case-insensitive regex literal", input: `Pattern=~*"abc"`, expected: expected{ok: true, val: true}},
+		{name: "imatches literal", input: `Matches imatches "abc"`, expected: expected{ok: true, val: true}},
+		{name: "regex list", input: `Pattern=~*("x", "abc")`, expected: expected{ok: true, val: true}},
+		{name: "regex list false", input: `Pattern=~*("x", "y")`, expected: expected{ok: true, val: false}},
+		{name: "negated regex list", input: `Negated!~*("x")`, expected: expected{ok: true, val: true}},
+		{name: "negated regex list false", input: `Negated!~*("x", "y")`, expected: expected{ok: true, val: false}},
+		{name: "pointer receiver", input: `Counter=="3"`, expected: expected{ok: true, val: true}},
+		{name: "pointer receiver false", input: `Counter=="4"`, expected: expected{ok: true, val: false}},
+		{name: "pointer stringer", input: `Big=="123"`, expected: expected{ok: true, val: true}},
+		{name: "pointer stringer regex", input: `Big=~"^12"`, expected: expected{ok: true, val: true}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, "", err)
+			}
+			actual, err := expr.Eval(target)
+			if !test.expected.ok {
+				if err == nil || !strings.Contains(err.Error(), test.expected.err) {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected.val, err)
+			}
+			if actual != test.expected.val {
+				t.Errorf(testTemplate, test.input, test.expected.val, actual)
+			}
+		})
+	}
+}
+
+type testVersion struct {
+	major, minor int
+}
+
+func (v testVersion) String() string {
+	return fmt.Sprintf("v%d.%d", v.major, v.minor)
+}
+
+type testLevel int
+
+func (l testLevel) String() string {
+	return fmt.Sprintf("level-%d", int(l))
+}
+
+func TestExpr_EvalTypeErrors(t *testing.T) {
+	target := testTarget{
+		"Int":      42,
+		"Uint8":    uint8(7),
+		"Float":    1.5,
+		"Name":     "slime",
+		"Timeout":  2 * time.Second,
+		"Interval": Seconds(1.5),
+		"Created":  time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "int regex", input: `Int=~"1"`, expected: `eval error: invalid operator for int field at 1:4: "=~"`},
+		{name: "int regex non-numeric", input: `Int=~"x"`, expected: `eval error: invalid operator for int field at 1:4: "=~"`},
+		{name: "float substring non-numeric", input: `Float has "x"`, expected: `eval error: invalid operator for float64 field at 1:7: "has"`},
+		{name: "duration regex invalid", input: `Timeout=~"x"`, expected: `eval error: invalid operator for time.Duration field at 1:8: "=~"`},
+		{name: "int non-numeric", input: `Int>"abc"`, expected: `eval error: cannot compare int field "Int" with non-numeric value "abc" at 1:5`},
+		{name: "uint8 substring", input: `Uint8 has "7"`, expected: `eval error: invalid operator for uint8 field at 1:7: "has"`},
+		{name: "float boolean", input: `Float == true`, expected: `eval error: cannot compare float64 field with boolean at 1:10: "true"`},
+		{name: "string approx", input: `Name ~= 1`, expected: `eval error: invalid operator for string field at 1:6: "~="`},
+		{name: "duration regex", input: `Timeout=~"2s"`, expected: `eval error: invalid operator for time.Duration field at 1:8: "=~"`},
+		{name: "duration invalid", input: `Timeout == "abc"`, expected: `eval error: invalid duration for time.Duration field at 1:12: "abc"`},
+		{name: "seconds substring", input: `Interval has "1s"`, expected: `eval error: invalid operator for filter.Seconds field at 1:10: "has"`},
+		{name: "time regex", input: `Created=~"2025-01-01T00:00:00Z"`, expected: `eval error: invalid operator for time.Time field at 1:8: "=~"`},
+		{name: "time invalid", input: `Created > "abc"`, expected: `eval error: invalid time for time.Time field at 1:11: "abc"`},
+		{name: "time case-insensitive", input: `Created ==* "2025-01-01T00:00:00Z"`, expected: `eval error: invalid operator for time.Time field at 1:9: "==*"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			if _, err := expr.Eval(target); err == nil || err.Error() != test.expected {
+				t.Errorf(testTemplate, test.input, test.expected, err)
+			}
+		})
+	}
+}
+
+func TestExpr_EvalStringer(t *testing.T) {
+	target := testTarget{
+		"Version":  testVersion{major: 1, minor: 2},
+		"Versions": []testVersion{{major: 1, minor: 0}, {major: 2, minor: 0}},
+		"Level":    testLevel(3),
+	}
+	type expected struct {
+		val bool
+		err string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		expected expected
+	}{
+		{name: "eq", input: `Version == "v1.2"`, expected: expected{val: true}},
+		{name: "eq false", input: `Version == "v1.3"`, expected: expected{val: false}},
+		{name: "neq", input: `Version != "v1.3"`, expected: expected{val: true}},
+		{name: "eqi", input: `Version ==* "V1.2"`, expected: expected{val: true}},
+		{name: "regex", input: `Version =~ "^v1[.]2$"`, expected: expected{val: true}},
+		{name: "negative regex", input: `Version !~ "^v2"`, expected: expected{val: true}},
+		{name: "substring", input: `Version has ".2"`, expected: expected{val: true}},
+		{name: "list", input: `Versions containsany ("v2.0")`, expected: expected{val: true}},
+		{name: "numeric", input: `Level == 3 && Level > 2 && Level ~= 3`, expected: expected{val: true}},
+		{name: "numeric false", input: `Level < 3`, expected: expected{val: false}},
+		{name: "numeric string", input: `Level == "level-3"`, expected: expected{val: true}},
+		{name: "numeric regex", input: `Level =~ "^level-"`, expected: expected{val: true}},
+		{name: "ordering string", input: `Version > "v1.0"`, expected: expected{err: `eval error: invalid operator for filter.testVersion field at 1:9: ">"`}},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			expr, parseError := Parse(test.input)
-			if test.expected.ok {
-				if parseError != nil {
-					t.Errorf(testTemplate, test.input, "", parseError)
-					return
+			expr, err := Parse(test.input)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			actual, err := expr.Eval(target)
+			if test.expected.err != "" {
+				if err == nil || err.Error() != test.expected.err {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
 				}
-				actual, evalError := expr.Eval(test.target)
-				if evalError != nil {
-					t.Errorf(testTemplate, test.input, test.expected.val, evalError)
-					return
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected.val, err)
+			}
+			if actual != test.expected.val {
+				t.Errorf(testTemplate, test.input, test.expected.val, actual)
+			}
+		})
+	}
+}
+
+func TestExpr_EvalDriverValuer(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	target := testTarget{
+		"Name":      sql.NullString{String: "slime", Valid: true},
+		"NullName":  sql.NullString{},
+		"HP":        sql.NullInt64{Int64: 100, Valid: true},
+		"NullHP":    sql.NullInt64{},
+		"Rate":      sql.NullFloat64{Float64: 0.5, Valid: true},
+		"Active":    sql.NullBool{Bool: true, Valid: true},
+		"Start":     sql.NullTime{Time: start, Valid: true},
+		"NullStart": sql.NullTime{},
+		"Deadline":  start.Add(30 * time.Minute),
+		"Level":     sql.Null[int]{V: 3, Valid: true},
+		"NamePtr":   &sql.NullString{String: "slime", Valid: true},
+		"NilPtr":    (*sql.NullString)(nil),
+		"Tags":      []sql.NullString{{String: "a", Valid: true}, {}},
+		"Broken":    testValuer{err: errors.New("connection reset")},
+		"Custom":    testComparableValuer{},
+	}
+	type expected struct {
+		ok       bool
+		val      bool
+		notFound bool
+		err      string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		expected expected
+	}{
+		{name: "string", input: `Name=="slime"`, expected: expected{ok: true, val: true}},
+		{name: "string case-insensitive", input: `Name==*"SLIME" && Name has "li"`, expected: expected{ok: true, val: true}},
+		{name: "string null", input: `NullName=="slime"`, expected: expected{ok: false, notFound: true, err: `eval error: field not found: "NullName" is null at 1:1`}},
+		{name: "int", input: `HP>50 && HP==100`, expected: expected{ok: true, val: true}},
+		{name: "int null", input: `NullHP>50`, expected: expected{ok: false, notFound: true, err: `eval error: field not found: "NullHP" is null at 1:1`}},
+		{name: "int null skipped", input: `HP>50 || NullHP>50`, expected: expected{ok: true, val: true}},
+		{name: "float", input: `Rate<1`, expected: expected{ok: true, val: true}},
+		{name: "bool", input: `Active==true`, expected: expected{ok: true, val: true}},
+		{name: "time", input: `Start>=2025-01-01T00:00:00Z`, expected: expected{ok: true, val: true}},
+		{name: "time null", input: `NullStart<now`, expected: expected{ok: false, notFound: true, err: `is null`}},
+		{name: "time offset", input: `Deadline<Start + 1h`, expected: expected{ok: true, val: true}},
+		{name: "time offset null", input: `Deadline<NullStart + 1h`, expected: expected{ok: false, notFound: true, err: `"NullStart" is null`}},
+		{name: "generic", input: `Level==3`, expected: expected{ok: true, val: true}},
+		{name: "pointer", input: `NamePtr=="slime"`, expected: expected{ok: true, val: true}},
+		{name: "nil pointer", input: `NilPtr=="slime"`, expected: expected{ok: false, notFound: true, err: `is nil`}},
+		{name: "list", input: `Tags containsany ("a")`, expected: expected{ok: true, val: true}},
+		{name: "value error", input: `Broken=="a"`, expected: expected{ok: false, err: `eval error: cannot get value of "Broken" at 1:1: connection reset`}},
+		{name: "comparable first", input: `Custom=="compared"`, expected: expected{ok: true, val: true}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, "", err)
+			}
+			actual, err := expr.Eval(target)
+			if !test.expected.ok {
+				if err == nil || !strings.Contains(err.Error(), test.expected.err) {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
 				}
-				if actual != test.expected.val {
-					t.Errorf(testTemplate, test.input, test.expected.val, actual)
+				if errors.Is(err, ErrFieldNotFound) != test.expected.notFound {
+					t.Errorf(testTemplate, test.input, test.expected.notFound, err)
 				}
 				return
 			}
-			if parseError == nil {
-				_, evalError := expr.Eval(test.target)
-				if evalError == nil || !strings.Contains(evalError.Error(), test.expected.err) {
-					t.Errorf(testTemplate, test.input, test.expected.err, evalError)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected.val, err)
+			}
+			if actual != test.expected.val {
+				t.Errorf(testTemplate, test.input, test.expected.val, actual)
+			}
+		})
+	}
+}
+
+type testValuer struct {
+	err error
+}
+
+func (v testValuer) Value() (driver.Value, error) {
+	return "value", v.err
+}
+
+type testComparableValuer struct {
+	testValuer
+}
+
+func (v testComparableValuer) CompareTo(op, literal string) (bool, error) {
+	return literal == "compared", nil
+}
+
+func TestExpr_EvalModulo(t *testing.T) {
+	sample, err := Parse(`ID % 10 == 0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []int{-20, -7, 0, 3, 10, 15, 100, 101} {
+		expected := id%10 == 0
+		actual, err := sample.Eval(testTarget{"ID": id})
+		if err != nil {
+			t.Fatalf(testTemplate, id, expected, err)
+		}
+		if actual != expected {
+			t.Errorf(testTemplate, id, expected, actual)
+		}
+	}
+
+	target := testTarget{
+		"Int":      -7,
+		"Int8":     int8(-128),
+		"Int64":    int64(math.MinInt64),
+		"Uint8":    uint8(255),
+		"Uint64":   uint64(math.MaxUint64),
+		"Float64":  10.0,
+		"String":   "10",
+		"IntPtr":   new(15),
+		"NullID":   sql.NullInt64{Int64: 42, Valid: true},
+		"Duration": time.Second,
+	}
+	type expected struct {
+		val bool
+		err string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		expected expected
+	}{
+		{name: "sign of dividend", input: `Int % 3 == -1`, expected: expected{val: true}},
+		{name: "negative divisor", input: `Int % -3 == -1`, expected: expected{val: true}},
+		{name: "int8", input: `Int8 % 100 == -28`, expected: expected{val: true}},
+		{name: "most negative by minus one", input: `Int64 % -1 == 0`, expected: expected{val: true}},
+		{name: "uint8", input: `Uint8 % 16 == 15`, expected: expected{val: true}},
+		{name: "uint64 beyond int64", input: `Uint64 % 10 == 5`, expected: expected{val: true}},
+		{name: "uint64 negative divisor", input: `Uint64 % -10 == 5`, expected: expected{val: true}},
+		{name: "ordering", input: `Uint8 % 100 >= 50 && Int % 2 != 0`, expected: expected{val: true}},
+		{name: "chained comparison", input: `0 <= IntPtr % 10 < 5`, expected: expected{}},
+		{name: "pointer", input: `IntPtr % 10 == 5`, expected: expected{val: true}},
+		{name: "driver valuer", input: `NullID % 5 == 2`, expected: expected{val: true}},
+		{name: "float field", input: `Float64 % 2 == 0`, expected: expected{err: `eval error: modulo requires an integer field at 1:1: "Float64" is float64`}},
+		{name: "string field", input: `String % 2 == 0`, expected: expected{err: `eval error: modulo requires an integer field at 1:1: "String" is string`}},
+		{name: "duration field", input: `Duration % 2 == 0`, expected: expected{err: `eval error: modulo requires an integer field at 1:1: "Duration" is time.Duration`}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, "", err)
+			}
+			actual, err := expr.Eval(target)
+			if test.expected.err != "" {
+				if err == nil || err.Error() != test.expected.err {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
 				}
 				return
 			}
-			if !strings.Contains(parseError.Error(), test.expected.err) {
-				t.Errorf(testTemplate, test.input, test.expected.err, parseError)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected.val, err)
+			}
+			if actual != test.expected.val {
+				t.Errorf(testTemplate, test.input, test.expected.val, actual)
+			}
+		})
+	}
+}
+
+func TestExpr_EvalBitwise(t *testing.T) {
+	const (
+		read  = 0x4
+		write = 0x2
+		exec  = 0x1
+	)
+	mask, err := Parse(`Perms & 0x4 == 0x4 && Perms & 0x2 == 0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for perms := range 8 {
+		expected := perms&read == read && perms&write == 0
+		actual, err := mask.Eval(testTarget{"Perms": perms})
+		if err != nil {
+			t.Fatalf(testTemplate, perms, expected, err)
+		}
+		if actual != expected {
+			t.Errorf(testTemplate, perms, expected, actual)
+		}
+	}
+
+	target := testTarget{
+		"Perms":   read | exec,
+		"Int8":    int8(-1),
+		"Uint8":   uint8(0xF0),
+		"Uint64":  uint64(math.MaxUint64),
+		"Int64":   int64(math.MinInt64),
+		"Float64": 4.0,
+		"String":  "4",
+	}
+	type expected struct {
+		val bool
+		err string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		expected expected
+	}{
+		{name: "and", input: `Perms & 0b100 != 0`, expected: expected{val: true}},
+		{name: "and unset", input: `Perms & 0x2 != 0`, expected: expected{val: false}},
+		{name: "or", input: `Perms | 0x2 == 7`, expected: expected{val: true}},
+		{name: "xor", input: `Perms ^ 0x5 == 0`, expected: expected{val: true}},
+		{name: "negative operand", input: `Perms & -1 == 5`, expected: expected{val: true}},
+		{name: "negative field", input: `Int8 & 0xFF == 0xFF`, expected: expected{val: true}},
+		{name: "uint8", input: `Uint8 ^ 0xFF == 0x0F`, expected: expected{val: true}},
+		{name: "uint64 beyond int64", input: `Uint64 & 0x8000000000000000 == 0x8000000000000000`, expected: expected{val: true}},
+		{name: "int64 sign bit", input: `Int64 & 0x8000000000000000 != 0`, expected: expected{val: true}},
+		{name: "ordering", input: `Perms & 0x6 >= 4`, expected: expected{val: true}},
+		{name: "chained comparison", input: `1 <= Perms & 0x3 < 2`, expected: expected{val: true}},
+		{name: "float field", input: `Float64 & 4 == 4`, expected: expected{err: `eval error: bitwise AND requires an integer field at 1:1: "Float64" is float64`}},
+		{name: "string field", input: `String | 4 == 4`, expected: expected{err: `eval error: bitwise OR requires an integer field at 1:1: "String" is string`}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, "", err)
+			}
+			actual, err := expr.Eval(target)
+			if test.expected.err != "" {
+				if err == nil || err.Error() != test.expected.err {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected.val, err)
+			}
+			if actual != test.expected.val {
+				t.Errorf(testTemplate, test.input, test.expected.val, actual)
+			}
+		})
+	}
+}
+
+func TestExpr_EvalMatches(t *testing.T) {
+	target := testTarget{
+		"Code":  "x123y",
+		"Exact": "123",
+		"Name":  "Slime",
+		"Int":   123,
+	}
+	type expected struct {
+		val bool
+		str string
+		err string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		expected expected
+	}{
+		{name: "substring regex", input: `Code =~ "[0-9]{3}"`, expected: expected{val: true, str: `Code =~ "[0-9]{3}"`}},
+		{name: "anchored substring", input: `Code matches "[0-9]{3}"`, expected: expected{val: false, str: `Code matches "[0-9]{3}"`}},
+		{name: "anchored whole value", input: `Exact matches "[0-9]{3}"`, expected: expected{val: true, str: `Exact matches "[0-9]{3}"`}},
+		{name: "alternation", input: `Name matches "Sl|Slime"`, expected: expected{val: true, str: `Name matches "Sl|Slime"`}},
+		{name: "case sensitive", input: `Name matches "slime"`, expected: expected{val: false, str: `Name matches "slime"`}},
+		{name: "case insensitive", input: `Name imatches 'sli.e'`, expected: expected{val: true, str: `Name imatches 'sli.e'`}},
+		{name: "case insensitive substring", input: `Name imatches "lim"`, expected: expected{val: false, str: `Name imatches "lim"`}},
+		{name: "list", input: `Code matches ("[0-9]+", "x[0-9]+y")`, expected: expected{val: true, str: `Code matches ("[0-9]+", "x[0-9]+y")`}},
+		{name: "negated", input: `!(Code matches "[0-9]{3}")`, expected: expected{val: true, str: `!(Code matches "[0-9]{3}")`}},
+		{name: "number field", input: `Int matches "1"`, expected: expected{err: `eval error: invalid operator for int field at 1:5: "matches"`}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, "", err)
+			}
+			actual, err := expr.Eval(target)
+			if test.expected.err != "" {
+				if err == nil || err.Error() != test.expected.err {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected.val, err)
+			}
+			if actual != test.expected.val {
+				t.Errorf(testTemplate, test.input, test.expected.val, actual)
+			}
+			if s := expr.String(); s != test.expected.str {
+				t.Errorf(testTemplate, test.input, test.expected.str, s)
+			}
+		})
+	}
+}
+
+func TestExpr_EvalApprox(t *testing.T) {
+	target := testTarget{
+		"Temp":   20.5,
+		"Count":  21,
+		"Size":   uint(19),
+		"Name":   "slime",
+		"Number": "20.25",
+	}
+	type expected struct {
+		val bool
+		err string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected expected
+	}{
+		{name: "upper boundary", input: `Temp ~= 20`, opts: []Option{WithTolerance(0.5)}, expected: expected{val: true}},
+		{name: "lower boundary", input: `Temp ~= 21`, opts: []Option{WithTolerance(0.5)}, expected: expected{val: true}},
+		{name: "beyond", input: `Temp ~= 19.99`, opts: []Option{WithTolerance(0.5)}, expected: expected{val: false}},
+		{name: "integer", input: `Count ~= 20.5`, opts: []Option{WithTolerance(0.5)}, expected: expected{val: true}},
+		{name: "integer literal", input: `Count ~= 20`, opts: []Option{WithTolerance(1)}, expected: expected{val: true}},
+		{name: "unsigned", input: `Size ~= 0x14`, opts: []Option{WithTolerance(1)}, expected: expected{val: true}},
+		{name: "negated", input: `!(Temp ~= 22)`, opts: []Option{WithTolerance(1)}, expected: expected{val: true}},
+		{name: "default tolerance", input: `Temp ~= 20.5000000001`, expected: expected{val: true}},
+		{name: "default tolerance beyond", input: `Temp ~= 20.6`, expected: expected{val: false}},
+		{name: "independent of epsilon", input: `Temp ~= 21`, opts: []Option{WithEpsilon(1)}, expected: expected{val: false}},
+		{name: "negative tolerance", input: `Temp ~= 20.5`, opts: []Option{WithTolerance(-1)}, expected: expected{val: true}},
+		{name: "NaN tolerance", input: `Temp ~= 20.5`, opts: []Option{WithTolerance(math.NaN())}, expected: expected{val: true}},
+		{name: "numeric string", input: `Number ~= 20`, opts: []Option{WithTolerance(0.25), WithNumericStringCoercion()}, expected: expected{val: true}},
+		{name: "string field", input: `Name ~= 1`, expected: expected{err: `eval error: invalid operator for string field at 1:6: "~="`}},
+		{name: "string value", input: `Temp ~= "20"`, expected: expected{err: `parse error: operator ~= requires a number value, got string at 1:6`}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err == nil {
+				var ok bool
+				ok, err = expr.Eval(target)
+				if err == nil && ok != test.expected.val {
+					t.Errorf(testTemplate, test.input, test.expected.val, ok)
+				}
+				if s := expr.String(); s != test.input {
+					t.Errorf(testTemplate, test.input, test.input, s)
+				}
+			}
+			if test.expected.err == "" && err != nil || test.expected.err != "" && (err == nil || err.Error() != test.expected.err) {
+				t.Errorf(testTemplate, test.input, test.expected.err, err)
+			}
+		})
+	}
+}
+
+func TestExpr_EvalMembership(t *testing.T) {
+	target := testTarget{
+		"Roles":  []string{"admin", "dev"},
+		"Levels": [2]int{1, 2},
+		"Groups": map[string]any{"ops": []string{"oncall"}},
+		"Name":   "slime",
+	}
+	type expected struct {
+		val bool
+		str string
+		err string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		expected expected
+	}{
+		{name: "present", input: `"admin" in Roles`, expected: expected{val: true, str: `Roles containsany ("admin")`}},
+		{name: "absent", input: `"guest" in Roles`, expected: expected{val: false, str: `Roles containsany ("guest")`}},
+		{name: "raw string", input: "`dev` in Roles", expected: expected{val: true, str: "Roles containsany (`dev`)"}},
+		{name: "number", input: `0x2 in Levels`, expected: expected{val: true, str: `Levels containsany (0x2)`}},
+		{name: "map key", input: `"oncall" in Groups["ops"]`, expected: expected{val: true, str: `Groups["ops"] containsany ("oncall")`}},
+		{name: "logical", input: `!("guest" in Roles) && "dev" in Roles`, expected: expected{val: true, str: `!(Roles containsany ("guest")) && Roles containsany ("dev")`}},
+		{name: "negated present", input: `!("admin" in Roles)`, expected: expected{val: false, str: `!(Roles containsany ("admin"))`}},
+		{name: "negated absent", input: `!("guest" in Roles)`, expected: expected{val: true, str: `!(Roles containsany ("guest"))`}},
+		{name: "double negated", input: `!!("dev" in Roles)`, expected: expected{val: true, str: `!(!(Roles containsany ("dev")))`}},
+		{name: "negated group", input: `!("guest" in Roles || 0x3 in Levels)`, expected: expected{val: true, str: `!(Roles containsany ("guest") || Levels containsany (0x3))`}},
+		{name: "non-slice field", input: `"s" in Name`, expected: expected{err: `eval error: invalid operator for non-slice field at 1:5: "containsany"`}},
+		{name: "function", input: `"s" in lower(Name)`, expected: expected{err: `parse error: invalid operator for lower at 1:5: "in"`}},
+		{name: "field on the left", input: `Roles in ("admin")`, expected: expected{err: `parse error: expected comparison operator, got membership operator at 1:7: "in"`}},
+		{name: "missing field", input: `"admin" in`, expected: expected{err: `parse error: expected identifier, got EOF at 1:11: ""`}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input)
+			if err == nil {
+				var ok bool
+				ok, err = expr.Eval(target)
+				if err == nil && ok != test.expected.val {
+					t.Errorf(testTemplate, test.input, test.expected.val, ok)
+				}
+				if s := expr.String(); err == nil && s != test.expected.str {
+					t.Errorf(testTemplate, test.input, test.expected.str, s)
+				}
+			}
+			if test.expected.err == "" && err != nil || test.expected.err != "" && (err == nil || err.Error() != test.expected.err) {
+				t.Errorf(testTemplate, test.input, test.expected.err, err)
+			}
+		})
+	}
+}
+
+func TestExpr_EvalLen(t *testing.T) {
+	type label string
+	target := testTarget{
+		"Name":    "日本語",
+		"Label":   label("abc"),
+		"Tags":    []string{"a", "b"},
+		"Empty":   []int{},
+		"Grid":    [3]int{},
+		"Headers": map[string]any{"a": 1},
+		"HP":      100,
+	}
+	type expected struct {
+		val bool
+		str string
+		err string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected expected
+	}{
+		{name: "string runes", input: `len(Name) == 3`, expected: expected{val: true, str: `len(Name) == 3`}},
+		{name: "string bytes", input: `len(Name) == 9`, opts: []Option{WithByteLength()}, expected: expected{val: true, str: `len(Name) == 9`}},
+		{name: "named string", input: `len(Label) > 2`, expected: expected{val: true, str: `len(Label) > 2`}},
+		{name: "slice", input: `len(Tags) >= 2`, expected: expected{val: true, str: `len(Tags) >= 2`}},
+		{name: "empty slice", input: `len(Empty) == 0`, expected: expected{val: true, str: `len(Empty) == 0`}},
+		{name: "array", input: `len(Grid) != 3`, expected: expected{val: false, str: `len(Grid) != 3`}},
+		{name: "map", input: `len(Headers) < 0x2`, expected: expected{val: true, str: `len(Headers) < 0x2`}},
+		{name: "map key", input: `len(Headers["a"]) > 0`, expected: expected{err: `eval error: len requires a string, slice or map field at 1:5: "Headers" is int`}},
+		{name: "chain", input: `1 < len(Tags) <= 2`, expected: expected{val: true, str: `len(Tags) > 1 && len(Tags) <= 2`}},
+		{name: "fraction", input: `len(Tags) < 2.5`, expected: expected{val: true, str: `len(Tags) < 2.5`}},
+		{name: "field named len", input: `len(Tags) == 2 || len == 1`, expected: expected{val: true, str: `len(Tags) == 2 || len == 1`}},
+		{name: "number field", input: `len(HP) > 1`, expected: expected{err: `eval error: len requires a string, slice or map field at 1:5: "HP" is int`}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, "", err)
+			}
+			actual, err := expr.Eval(target)
+			if test.expected.err != "" {
+				if err == nil || err.Error() != test.expected.err {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected.val, err)
+			}
+			if actual != test.expected.val {
+				t.Errorf(testTemplate, test.input, test.expected.val, actual)
+			}
+			if s := expr.String(); s != test.expected.str {
+				t.Errorf(testTemplate, test.input, test.expected.str, s)
+			}
+		})
+	}
+}
+
+func TestExpr_EvalBool(t *testing.T) {
+	target := testTarget{"Score": 2}
+	type expected struct {
+		val bool
+		str string
+		err string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected expected
+	}{
+		{name: "true", input: `true`, expected: expected{val: true, str: `true`}},
+		{name: "false", input: `false`, expected: expected{val: false, str: `false`}},
+		{name: "case", input: `True`, expected: expected{val: true, str: `True`}},
+		{name: "not true", input: `!true`, expected: expected{val: false, str: `!(true)`}},
+		{name: "true and comparison", input: `true && Score > 1`, expected: expected{val: true, str: `true && Score > 1`}},
+		{name: "true and false comparison", input: `true && Score > 5`, expected: expected{val: false, str: `true && Score > 5`}},
+		{name: "fallback", input: `Score > 5 || true`, expected: expected{val: true, str: `Score > 5 || true`}},
+		{name: "false short-circuit", input: `false && Missing == 1`, expected: expected{val: false, str: `false && Missing == 1`}},
+		{name: "true short-circuit", input: `true || Missing == 1`, expected: expected{val: true, str: `true || Missing == 1`}},
+		{name: "three-valued", input: `Missing == 1 || true`, opts: []Option{WithThreeValuedLogic()}, expected: expected{val: true, str: `Missing == 1 || true`}},
+		{name: "strict", input: `true || Missing == 1`, opts: []Option{WithStrictEval()}, expected: expected{err: `eval error: eval error: field not found: "Missing"`}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			actual, err := expr.Eval(target)
+			if test.expected.err != "" {
+				if err == nil || err.Error() != test.expected.err {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected.val, err)
+			}
+			if actual != test.expected.val {
+				t.Errorf(testTemplate, test.input, test.expected.val, actual)
+			}
+			if s := expr.String(); s != test.expected.str {
+				t.Errorf(testTemplate, test.input, test.expected.str, s)
+			}
+		})
+	}
+	expr := mustParse(t, `true && Score > 1`)
+	if fields := expr.Fields(); !reflect.DeepEqual(fields, []string{"Score"}) {
+		t.Errorf(testTemplate, expr, []string{"Score"}, fields)
+	}
+	if lits := expr.Literals(); len(lits) != 1 || lits[0].Field != "Score" {
+		t.Errorf(testTemplate, expr, "Score > 1", lits)
+	}
+	if val, ok := mustParse(t, `!false || Score > 1`).IsConstant(); !val || !ok {
+		t.Errorf(testTemplate, `!false || Score > 1`, true, val)
+	}
+	if !Equal(mustParse(t, `TRUE && Score > 1`), expr) || mustParse(t, `TRUE && Score > 1`).Hash() != expr.Hash() {
+		t.Errorf(testTemplate, `TRUE && Score > 1`, true, false)
+	}
+	if Equal(mustParse(t, `false && Score > 1`), expr) {
+		t.Errorf(testTemplate, `false && Score > 1`, false, true)
+	}
+}
+
+func TestExpr_EvalIndex(t *testing.T) {
+	target := testTarget{
+		"Headers": map[string]any{"content-type": "application/json", "retry": 3, "empty": nil},
+		"Labels":  map[string]string{"env": "prod"},
+		"Codes":   map[int8]string{1: "one"},
+		"Sizes":   map[uint]int{16: 100},
+		"Name":    "slime",
+		"Coords":  []int{12, 5},
+		"Tags":    []string{"a", "b"},
+		"Items":   []any{"x", 3},
+		"Grid":    [2]float64{1.5, 2.5},
+	}
+	type expected struct {
+		val bool
+		str string
+		err string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected expected
+	}{
+		{name: "present key", input: `Headers["content-type"] == "application/json"`, expected: expected{val: true, str: `Headers["content-type"] == "application/json"`}},
+		{name: "present key differs", input: `Headers["content-type"] != "application/json"`, expected: expected{val: false, str: `Headers["content-type"] != "application/json"`}},
+		{name: "number value", input: `Headers['retry'] >= 3`, expected: expected{val: true, str: `Headers['retry'] >= 3`}},
+		{name: "string map", input: "Labels[`env`] =~ \"^pr\"", expected: expected{val: true, str: "Labels[`env`] =~ \"^pr\""}},
+		{name: "integer key", input: `Codes[1] == "one"`, expected: expected{val: true, str: `Codes[1] == "one"`}},
+		{name: "unsigned key", input: `Sizes[0x10] > 50`, expected: expected{val: true, str: `Sizes[0x10] > 50`}},
+		{name: "function", input: `upper(Labels["env"]) == "PROD"`, expected: expected{val: true, str: `upper(Labels["env"]) == "PROD"`}},
+		{name: "chain", input: `1 < Headers["retry"] < 5`, expected: expected{val: true, str: `Headers["retry"] > 1 && Headers["retry"] < 5`}},
+		{name: "missing key", input: `Headers["accept"] == "*/*"`, expected: expected{err: `eval error: field not found: "Headers" has no key "accept" at 1:9`}},
+		{name: "missing key short-circuit", input: `Name == "slime" || Headers["accept"] == "*/*"`, expected: expected{val: true, str: `Name == "slime" || Headers["accept"] == "*/*"`}},
+		{name: "missing key three-valued", input: `Headers["accept"] == "*/*" || Name == "slime"`, opts: []Option{WithThreeValuedLogic()}, expected: expected{val: true, str: `Headers["accept"] == "*/*" || Name == "slime"`}},
+		{name: "nil value three-valued", input: `Headers["empty"] == 1`, opts: []Option{WithThreeValuedLogic()}, expected: expected{val: false, str: `Headers["empty"] == 1`}},
+		{name: "non-map field", input: `Name["a"] == "b"`, expected: expected{err: `eval error: cannot index string field at 1:1: "Name"`}},
+		{name: "string key for integer map", input: `Codes["1"] == "one"`, expected: expected{err: `eval error: cannot use string key for map[int8]string field at 1:7: "1"`}},
+		{name: "integer key for string map", input: `Labels[1] == "prod"`, expected: expected{err: `eval error: cannot use number key for map[string]string field at 1:8: "1"`}},
+		{name: "key overflow", input: `Codes[300] == "one"`, expected: expected{err: `eval error: cannot use number key for map[int8]string field at 1:7: "300"`}},
+		{name: "missing field", input: `Missing["a"] == 1`, expected: expected{err: `eval error: eval error: field not found: "Missing"`}},
+		{name: "slice element", input: `Coords[0] > 10 && Coords[1] < 10`, expected: expected{val: true, str: `Coords[0] > 10 && Coords[1] < 10`}},
+		{name: "string slice element", input: `Tags[1] == "b"`, expected: expected{val: true, str: `Tags[1] == "b"`}},
+		{name: "any slice element", input: `Items[1] == 3 && Items[0] =~ "^x"`, expected: expected{val: true, str: `Items[1] == 3 && Items[0] =~ "^x"`}},
+		{name: "array element", input: `Grid[1] > 2`, expected: expected{val: true, str: `Grid[1] > 2`}},
+		{name: "out of range", input: `Coords[2] > 10`, expected: expected{err: `eval error: field not found: "Coords" has no element 2 at 1:8`}},
+		{name: "negative index", input: `Tags[-1] == "b"`, expected: expected{err: `eval error: field not found: "Tags" has no element -1 at 1:6`}},
+		{name: "out of range three-valued", input: `Coords[2] > 10 || Tags[0] == "a"`, opts: []Option{WithThreeValuedLogic()}, expected: expected{val: true, str: `Coords[2] > 10 || Tags[0] == "a"`}},
+		{name: "string key for slice", input: `Coords["0"] > 10`, expected: expected{err: `eval error: cannot use string key for []int field at 1:8: "0"`}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, "", err)
+			}
+			actual, err := expr.Eval(target)
+			if test.expected.err != "" {
+				if err == nil || err.Error() != test.expected.err {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected.val, err)
+			}
+			if actual != test.expected.val {
+				t.Errorf(testTemplate, test.input, test.expected.val, actual)
+			}
+			if s := expr.String(); s != test.expected.str {
+				t.Errorf(testTemplate, test.input, test.expected.str, s)
 			}
 		})
 	}
@@ -0,0 +1,157 @@
+package filter
+
+import (
+	"strconv"
+	"strings"
+)
+
+// String returns the expression in the filter syntax, so that it can be parsed again.
+// Literals are written as they appear in the input, such as 0x1.fp3, +.8 or 1h30m,
+// except that non-integer numbers are formatted as given to WithFloatFormat,
+// and parentheses are added only where precedence requires them.
+// Chained comparisons are written in their desugared form.
+func (e *Expr) String() string {
+	if e == nil || len(e.parser.nodes) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	e.format(&b, e.root)
+	return b.String()
+}
+
+// format writes the node at index i to b.
+func (e *Expr) format(b *strings.Builder, i int) {
+	n := e.parser.nodes[i]
+	switch n.typ {
+	case nodeBinary:
+		e.formatOperand(b, n.left, n.op.typ)
+		b.WriteString(" ")
+		b.WriteString(n.op.typ.literal())
+		b.WriteString(" ")
+		e.formatOperand(b, n.right, n.op.typ)
+	case nodeNOT:
+		b.WriteString("!(")
+		e.format(b, n.left)
+		b.WriteString(")")
+	case nodeComparison:
+		if n.fn != transformNone {
+			b.WriteString(n.fn.String())
+			b.WriteString("(")
+			b.WriteString(n.ident.v)
+			e.formatKey(b, n)
+			b.WriteString(")")
+		} else {
+			b.WriteString(n.ident.v)
+			e.formatKey(b, n)
+		}
+		if n.isArith() {
+			b.WriteString(" ")
+			b.WriteString(n.arith.typ.literal())
+			b.WriteString(" ")
+			if e.parser.opts.decimalComma {
+				b.WriteString(decimalComma(n.arg.v))
+			} else {
+				b.WriteString(n.arg.v)
+			}
+		}
+		b.WriteString(" ")
+		b.WriteString(n.op.typ.literal())
+		b.WriteString(" ")
+		b.WriteString(e.literal(n))
+	case nodeBool:
+		b.WriteString(n.val.v)
+	}
+}
+
+// Tree returns the expression tree as an indented multi-line drawing for reading and debugging,
+// with one node per line: logical operators as "binary &&", "binary ||" and "not !",
+// comparisons as "comparison" followed by the comparison as String writes it, and the literals
+// true and false as "bool true" and "bool false".
+// Chained comparisons are shown in their desugared form. The output is not meant to be parsed.
+func (e *Expr) Tree() string {
+	if e == nil || len(e.parser.nodes) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	e.tree(&b, e.root, "", "")
+	return b.String()
+}
+
+// tree writes the node at index i to b, with head before the node and indent before its children.
+func (e *Expr) tree(b *strings.Builder, i int, head, indent string) {
+	n := e.parser.nodes[i]
+	b.WriteString(head)
+	switch n.typ {
+	case nodeBinary:
+		b.WriteString("binary ")
+		b.WriteString(n.op.typ.literal())
+		b.WriteString("\n")
+		e.tree(b, n.left, indent+"├── ", indent+"│   ")
+		e.tree(b, n.right, indent+"└── ", indent+"    ")
+	case nodeNOT:
+		b.WriteString("not !\n")
+		e.tree(b, n.left, indent+"└── ", indent+"    ")
+	case nodeComparison:
+		b.WriteString("comparison ")
+		e.format(b, i)
+		b.WriteString("\n")
+	case nodeBool:
+		b.WriteString("bool ")
+		b.WriteString(n.val.v)
+		b.WriteString("\n")
+	}
+}
+
+// formatOperand writes an operand of a logical operator, parenthesized if it binds looser than the operator.
+func (e *Expr) formatOperand(b *strings.Builder, i int, op tokenType) {
+	n := e.parser.nodes[i]
+	if n.typ == nodeBinary && n.op.typ == tokenOR && op == tokenAND {
+		b.WriteString("(")
+		e.format(b, i)
+		b.WriteString(")")
+		return
+	}
+	e.format(b, i)
+}
+
+// formatKey writes the map key or slice index in brackets as written in the input, if the node has one.
+func (e *Expr) formatKey(b *strings.Builder, n node) {
+	if !n.isIndex() {
+		return
+	}
+	b.WriteString("[")
+	if n.key.typ.isStringType() {
+		b.WriteString(e.parser.lexer.input[n.key.pos : n.key.pos+len(n.key.v)+2])
+	} else {
+		b.WriteString(n.key.v)
+	}
+	b.WriteString("]")
+}
+
+// literal returns the value of a comparison node as written in the input.
+func (e *Expr) literal(n node) string {
+	if n.isList() {
+		vals := make([]string, len(n.items))
+		for i, item := range n.items {
+			vals[i] = e.literal(item)
+		}
+		return "(" + strings.Join(vals, ", ") + ")"
+	}
+	if n.isOffset() {
+		return n.ref.v + " " + n.val.v[:1] + " " + n.val.v[1:]
+	}
+	if n.val.typ == tokenNumber {
+		v := n.val.v
+		if verb := e.parser.opts.floatVerb; verb != 0 && n.hasNum && !n.hasInt && !n.hasUint {
+			v = strconv.FormatFloat(n.num, verb, e.parser.opts.floatPrec, 64)
+		}
+		if e.parser.opts.decimalComma {
+			v = decimalComma(v)
+		}
+		return v
+	}
+	if !n.val.typ.isStringType() {
+		return n.val.v
+	}
+	return e.parser.lexer.input[n.val.pos : n.val.pos+len(n.writtenValue())+2]
+}
@@ -0,0 +1,153 @@
+package filter
+
+import "testing"
+
+func TestExpr_String(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected string
+	}{
+		{name: "hex float", input: `Float==0x1.fp3`, expected: `Float == 0x1.fp3`},
+		{name: "leading sign and dot", input: `Float>+.8`, expected: `Float > +.8`},
+		{name: "exponent", input: `Float<1.23E4`, expected: `Float < 1.23E4`},
+		{name: "underscore", input: `Int==1_000`, expected: `Int == 1_000`},
+		{name: "octal", input: `Mask==0o755`, expected: `Mask == 0o755`},
+		{name: "duration", input: `Latency>=1h30m`, expected: `Latency >= 1h30m`},
+		{name: "extended duration", input: `Age<2w3d`, opts: []Option{WithExtendedDurationUnits()}, expected: `Age < 2w3d`},
+		{name: "time", input: `Time<2025-01-01T00:00:00+09:00`, expected: `Time < 2025-01-01T00:00:00+09:00`},
+		{name: "now", input: `Time>now-1h`, expected: `Time > now-1h`},
+		{name: "field offset", input: `Deadline<Start+1h30m`, expected: `Deadline < Start + 1h30m`},
+		{name: "field offset subtraction", input: `Deadline>=Start - 2m`, expected: `Deadline >= Start - 2m`},
+		{name: "modulo", input: `ID%0x10==0`, expected: `ID % 0x10 == 0`},
+		{name: "bitwise", input: `Perms&4==4||Perms^0b1>1`, expected: `Perms & 4 == 4 || Perms ^ 0b1 > 1`},
+		{name: "bool", input: `Active==TRUE`, expected: `Active == TRUE`},
+		{name: "double quoted", input: `Name=="a\"b"`, expected: `Name == "a\"b"`},
+		{name: "single quoted", input: `Name=='x'`, expected: `Name == 'x'`},
+		{name: "raw", input: "Name==`a\\b`", expected: "Name == `a\\b`"},
+		{name: "case-insensitive regex", input: `Name=~*"^a"`, expected: `Name =~* "^a"`},
+		{name: "explicit case-insensitive flag", input: `Name!~*"(?i)^a"`, expected: `Name !~* "(?i)^a"`},
+		{name: "and", input: `A==1&&B==2`, expected: `A == 1 && B == 2`},
+		{name: "or in and", input: `(A==1||B==2)&&C==3`, expected: `(A == 1 || B == 2) && C == 3`},
+		{name: "and in or", input: `A==1||(B==2&&C==3)`, expected: `A == 1 || B == 2 && C == 3`},
+		{name: "not", input: `!A==1`, expected: `!(A == 1)`},
+		{name: "not or", input: `!(A==1||B==2)`, expected: `!(A == 1 || B == 2)`},
+		{name: "list", input: `Tags containsany("a",'b',0x1,1h)`, expected: `Tags containsany ("a", 'b', 0x1, 1h)`},
+		{name: "regex list", input: `Path=~*("^/API",'^/v[0-9]')`, expected: `Path =~* ("^/API", '^/v[0-9]')`},
+		{name: "empty list", input: `Tags containsall()`, expected: `Tags containsall ()`},
+		{name: "function", input: `lower(Name)=="a"||trim( Path )=~"^/"`, expected: `lower(Name) == "a" || trim(Path) =~ "^/"`},
+		{name: "chain", input: `1<A<=0x10`, expected: `A > 1 && A <= 0x10`},
+		{name: "multi line", input: "A==1\n&&\n  B==\"x\"", expected: `A == 1 && B == "x"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			actual := expr.String()
+			if actual != test.expected {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
+			}
+			again, err := Parse(actual, test.opts...)
+			if err != nil {
+				t.Fatalf(testTemplate, actual, test.expected, err)
+			}
+			if repr(again) != repr(expr) {
+				t.Errorf(testTemplate, actual, repr(expr), repr(again))
+			}
+		})
+	}
+}
+
+func TestExpr_String_nil(t *testing.T) {
+	var e *Expr
+	if actual := e.String(); actual != "" {
+		t.Errorf(testTemplate, "nil", "", actual)
+	}
+}
+
+func TestExpr_Tree(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "comparison",
+			input:    `HP>50`,
+			expected: "comparison HP > 50\n",
+		},
+		{
+			name:  "function, list and offset",
+			input: `lower(Name)=="a" || Tags containsany ("a", 1) || !(Deadline<Start+1h)`,
+			expected: `binary ||
+├── binary ||
+│   ├── comparison lower(Name) == "a"
+│   └── comparison Tags containsany ("a", 1)
+└── not !
+    └── comparison Deadline < Start + 1h
+`,
+		},
+		{
+			name:  "chain",
+			input: `1<HP<=5`,
+			expected: `binary &&
+├── comparison HP > 1
+└── comparison HP <= 5
+`,
+		},
+		{
+			name: "complex",
+			input: `
+				Class == "軍師" && Name =~ '^(諸葛亮|龐統|法正)' && Name != "" && (
+					BirthDate < '0190-01-01T00:00:00Z' && ActiveTimeBattleGauge >= '20s'
+				) && (
+					HitPoint > "50" && MagicPoint > 100 && LifePoint != 0
+				) && (
+					Magic >= 20 || !(Speed < 20)
+				)
+			`,
+			expected: `binary &&
+├── binary &&
+│   ├── binary &&
+│   │   ├── binary &&
+│   │   │   ├── binary &&
+│   │   │   │   ├── comparison Class == "軍師"
+│   │   │   │   └── comparison Name =~ '^(諸葛亮|龐統|法正)'
+│   │   │   └── comparison Name != ""
+│   │   └── binary &&
+│   │       ├── comparison BirthDate < '0190-01-01T00:00:00Z'
+│   │       └── comparison ActiveTimeBattleGauge >= '20s'
+│   └── binary &&
+│       ├── binary &&
+│       │   ├── comparison HitPoint > "50"
+│       │   └── comparison MagicPoint > 100
+│       └── comparison LifePoint != 0
+└── binary ||
+    ├── comparison Magic >= 20
+    └── not !
+        └── comparison Speed < 20
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := expr.Tree(); actual != test.expected {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestExpr_Tree_nil(t *testing.T) {
+	var e *Expr
+	if actual := e.Tree(); actual != "" {
+		t.Errorf(testTemplate, "nil", "", actual)
+	}
+}
@@ -2,6 +2,10 @@ module github.com/nekrassov01/filter
 
 go 1.26.1
 
-require github.com/mattn/go-runewidth v0.0.23
+require (
+	github.com/mattn/go-runewidth v0.0.23
+	golang.org/x/text v0.42.0
+	google.golang.org/protobuf v1.36.12
+)
 
 require github.com/clipperhouse/uax29/v2 v2.2.0 // indirect
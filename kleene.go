@@ -0,0 +1,93 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+)
+
+// truth is a truth value of three-valued logic.
+type truth int8
+
+const (
+	truthFalse   truth = iota // false
+	truthTrue                 // true
+	truthUnknown              // unknown, such as a comparison of a missing field
+)
+
+// not returns the negation of the truth value, where the negation of unknown is unknown.
+func (v truth) not() truth {
+	switch v {
+	case truthFalse:
+		return truthTrue
+	case truthTrue:
+		return truthFalse
+	default:
+		return truthUnknown
+	}
+}
+
+// truthOf returns the truth value of b.
+func truthOf(b bool) truth {
+	if b {
+		return truthTrue
+	}
+	return truthFalse
+}
+
+// evalKleene evaluates the node at index i against a target under three-valued logic.
+// Logical operators short-circuit on the value that decides them, false for && and true for ||,
+// unless WithStrictEval is given, and unknown operands are propagated otherwise.
+func (e *Expr) evalKleene(i int, t Target, st *state) (truth, error) {
+	if err := st.err(); err != nil {
+		return truthFalse, err
+	}
+	n := e.parser.nodes[i]
+	switch n.typ {
+	case nodeBinary:
+		var decisive truth
+		switch n.op.typ {
+		case tokenAND:
+			decisive = truthFalse
+		case tokenOR:
+			decisive = truthTrue
+		default:
+			return truthFalse, &Error{
+				Kind: KindEval,
+				Err:  fmt.Errorf("invalid logical operator at %d:%d: %q", n.op.line, n.op.col, n.op.typ.literal()),
+			}
+		}
+		strict := e.parser.opts.strict
+		left, lerr := e.evalKleene(n.left, t, st)
+		if !strict && (lerr != nil || left == decisive) {
+			if lerr == nil {
+				st.metrics.ShortCircuits++
+			}
+			return left, lerr
+		}
+		right, rerr := e.evalKleene(n.right, t, st)
+		switch {
+		case lerr != nil:
+			return truthFalse, lerr
+		case rerr != nil:
+			return truthFalse, rerr
+		case left == decisive || right == decisive:
+			return decisive, nil
+		case left == truthUnknown || right == truthUnknown:
+			return truthUnknown, nil
+		default:
+			return decisive.not(), nil
+		}
+	case nodeNOT:
+		v, err := e.evalKleene(n.left, t, st)
+		if err != nil {
+			return truthFalse, err
+		}
+		return v.not(), nil
+	default:
+		ok, err := e.eval(i, t, st)
+		if errors.Is(err, ErrFieldNotFound) {
+			return truthUnknown, nil
+		}
+		return truthOf(ok), err
+	}
+}
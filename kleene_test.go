@@ -0,0 +1,130 @@
+package filter
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithThreeValuedLogic(t *testing.T) {
+	target := testTarget{"Int": 42}
+	// T, F and U are comparisons that are true, false and unknown for the target.
+	operands := map[string]string{
+		"T": `Int==42`,
+		"F": `Int==0`,
+		"U": `Missing==1`,
+	}
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: `T && T`, expected: "T"},
+		{input: `T && F`, expected: "F"},
+		{input: `T && U`, expected: "U"},
+		{input: `F && T`, expected: "F"},
+		{input: `F && F`, expected: "F"},
+		{input: `F && U`, expected: "F"},
+		{input: `U && T`, expected: "U"},
+		{input: `U && F`, expected: "F"},
+		{input: `U && U`, expected: "U"},
+		{input: `T || T`, expected: "T"},
+		{input: `T || F`, expected: "T"},
+		{input: `T || U`, expected: "T"},
+		{input: `F || T`, expected: "T"},
+		{input: `F || F`, expected: "F"},
+		{input: `F || U`, expected: "U"},
+		{input: `U || T`, expected: "T"},
+		{input: `U || F`, expected: "U"},
+		{input: `U || U`, expected: "U"},
+		{input: `!T`, expected: "F"},
+		{input: `!F`, expected: "T"},
+		{input: `!U`, expected: "U"},
+		{input: `!(U && F)`, expected: "T"},
+		{input: `!(U || F)`, expected: "U"},
+	}
+	for _, opts := range [][]Option{{WithThreeValuedLogic()}, {WithThreeValuedLogic(), WithStrictEval()}} {
+		for _, test := range tests {
+			input := test.input
+			for name, operand := range operands {
+				input = strings.ReplaceAll(input, name, operand)
+			}
+			// Eval reports unknown as false, so X is unknown when neither X nor !(X) holds.
+			actual := ""
+			for _, s := range []string{input, "!(" + input + ")"} {
+				expr, err := Parse(s, opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				ok, err := expr.Eval(target)
+				if err != nil {
+					t.Fatalf(testTemplate, s, test.expected, err)
+				}
+				actual += map[bool]string{true: "1", false: "0"}[ok]
+			}
+			actual = map[string]string{"10": "T", "01": "F", "00": "U"}[actual]
+			if actual != test.expected {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
+			}
+		}
+	}
+}
+
+func TestWithThreeValuedLogic_fields(t *testing.T) {
+	target := testTarget{
+		"Int":    42,
+		"Null":   sql.NullInt64{},
+		"NilPtr": (*int)(nil),
+		"String": "a",
+		"Start":  time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	type expected struct {
+		ok  bool
+		val bool
+		err string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		expected expected
+	}{
+		{name: "missing", input: `!(Missing==1)`, expected: expected{ok: true, val: false}},
+		{name: "null", input: `!(Null==1) || Int==42`, expected: expected{ok: true, val: true}},
+		{name: "nil pointer", input: `NilPtr!=1 || !(NilPtr==1)`, expected: expected{ok: true, val: false}},
+		{name: "missing offset field", input: `!(Start<Missing + 1h)`, expected: expected{ok: true, val: false}},
+		{name: "other error", input: `Missing==1 || String>1`, expected: expected{ok: false, err: `eval error: invalid operator for string field at 1:21: ">"`}},
+		{name: "default", input: `Missing==1 || Int==42`, expected: expected{ok: true, val: true}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, WithThreeValuedLogic())
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual, err := expr.Eval(target)
+			if !test.expected.ok {
+				if err == nil || !strings.Contains(err.Error(), test.expected.err) {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected.val, err)
+			}
+			if actual != test.expected.val {
+				t.Errorf(testTemplate, test.input, test.expected.val, actual)
+			}
+		})
+	}
+}
+
+func TestWithThreeValuedLogic_default(t *testing.T) {
+	expr, err := Parse(`Missing==1 || Int==42`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := expr.Eval(testTarget{"Int": 42}); !errors.Is(err, ErrFieldNotFound) {
+		t.Errorf(testTemplate, expr, ErrFieldNotFound, err)
+	}
+}
@@ -22,32 +22,48 @@ type token struct {
 type tokenType int
 
 const (
-	tokenError     tokenType = iota // error
-	tokenEOF                        // end of file
-	tokenIdent                      // identifier
-	tokenGT                         // greater than
-	tokenGTE                        // greater than or equal to
-	tokenLT                         // less than
-	tokenLTE                        // less than or equal to
-	tokenEQ                         // equal to
-	tokenEQI                        // equal to (case insensitive)
-	tokenNEQ                        // not equal to
-	tokenNEQI                       // not equal to (case insensitive)
-	tokenREQ                        // matches regular expression
-	tokenREQI                       // matches regular expression (case insensitive)
-	tokenNREQ                       // does not match regular expression
-	tokenNREQI                      // does not match regular expression (case insensitive)
-	tokenAND                        // logical AND
-	tokenOR                         // logical OR
-	tokenNOT                        // logical NOT
-	tokenLparen                     // left parenthesis
-	tokenRparen                     // right parenthesis
-	tokenString                     // string literal
-	tokenRawString                  // raw string literal
-	tokenNumber                     // number literal
-	tokenDuration                   // duration literal
-	tokenTime                       // time literal
-	tokenBool                       // boolean literal
+	tokenError       tokenType = iota // error
+	tokenEOF                          // end of file
+	tokenIdent                        // identifier
+	tokenGT                           // greater than
+	tokenGTE                          // greater than or equal to
+	tokenLT                           // less than
+	tokenLTE                          // less than or equal to
+	tokenEQ                           // equal to
+	tokenEQI                          // equal to (case insensitive)
+	tokenNEQ                          // not equal to
+	tokenNEQI                         // not equal to (case insensitive)
+	tokenREQ                          // matches regular expression
+	tokenREQI                         // matches regular expression (case insensitive)
+	tokenNREQ                         // does not match regular expression
+	tokenNREQI                        // does not match regular expression (case insensitive)
+	tokenAND                          // logical AND
+	tokenOR                           // logical OR
+	tokenNOT                          // logical NOT
+	tokenLparen                       // left parenthesis
+	tokenRparen                       // right parenthesis
+	tokenString                       // string literal
+	tokenRawString                    // raw string literal
+	tokenNumber                       // number literal
+	tokenDuration                     // duration literal
+	tokenTime                         // time literal
+	tokenBool                         // boolean literal
+	tokenNow                          // now literal with an optional duration offset
+	tokenContainsAny                  // slice contains any of the values
+	tokenContainsAll                  // slice contains all of the values
+	tokenHas                          // string contains the substring
+	tokenHasI                         // string contains the substring (case insensitive)
+	tokenComma                        // comma separating list values
+	tokenMod                          // modulo of an integer field
+	tokenBitAnd                       // bitwise AND of an integer field
+	tokenBitOr                        // bitwise OR of an integer field
+	tokenBitXor                       // bitwise XOR of an integer field
+	tokenMatches                      // matches regular expression as a whole
+	tokenMatchesI                     // matches regular expression as a whole (case insensitive)
+	tokenLbracket                     // left bracket of a map key
+	tokenRbracket                     // right bracket of a map key
+	tokenApprox                       // approximately equal to within the tolerance
+	tokenIn                           // value is an element of the slice field
 )
 
 // String returns a string representation of the token type.
@@ -105,6 +121,32 @@ func (t tokenType) String() string {
 		return "time"
 	case tokenBool:
 		return "boolean"
+	case tokenNow:
+		return "now"
+	case tokenContainsAny:
+		return "containsany operator"
+	case tokenContainsAll:
+		return "containsall operator"
+	case tokenHas:
+		return "substring operator"
+	case tokenHasI:
+		return "case-insensitive substring operator"
+	case tokenComma:
+		return "comma"
+	case tokenMod, tokenBitAnd, tokenBitOr, tokenBitXor:
+		return t.arithName() + " operator"
+	case tokenMatches:
+		return "whole-value regex matching operator"
+	case tokenMatchesI:
+		return "case-insensitive whole-value regex matching operator"
+	case tokenLbracket:
+		return "left bracket"
+	case tokenRbracket:
+		return "right bracket"
+	case tokenApprox:
+		return "\"approximately equal to\" operator"
+	case tokenIn:
+		return "membership operator"
 	default:
 		return ""
 	}
@@ -148,6 +190,36 @@ func (t tokenType) literal() string {
 		return "("
 	case tokenRparen:
 		return ")"
+	case tokenContainsAny:
+		return "containsany"
+	case tokenContainsAll:
+		return "containsall"
+	case tokenHas:
+		return "has"
+	case tokenHasI:
+		return "ihas"
+	case tokenComma:
+		return ","
+	case tokenMod:
+		return "%"
+	case tokenBitAnd:
+		return "&"
+	case tokenBitOr:
+		return "|"
+	case tokenBitXor:
+		return "^"
+	case tokenMatches:
+		return "matches"
+	case tokenMatchesI:
+		return "imatches"
+	case tokenLbracket:
+		return "["
+	case tokenRbracket:
+		return "]"
+	case tokenApprox:
+		return "~="
+	case tokenIn:
+		return "in"
 	default:
 		return ""
 	}
@@ -156,7 +228,52 @@ func (t tokenType) literal() string {
 // isComparisonOperatorType reports whether the token is a comparison operator.
 func (t tokenType) isComparisonOperatorType() bool {
 	switch t {
-	case tokenEQ, tokenEQI, tokenNEQ, tokenNEQI, tokenGT, tokenGTE, tokenLT, tokenLTE, tokenREQ, tokenREQI, tokenNREQ, tokenNREQI:
+	case tokenEQ, tokenEQI, tokenNEQ, tokenNEQI, tokenGT, tokenGTE, tokenLT, tokenLTE, tokenREQ, tokenREQI, tokenNREQ, tokenNREQI, tokenContainsAny, tokenContainsAll, tokenHas, tokenHasI, tokenMatches, tokenMatchesI, tokenApprox:
+		return true
+	default:
+		return false
+	}
+}
+
+// isListOperatorType reports whether the token is an operator taking a list of values.
+func (t tokenType) isListOperatorType() bool {
+	switch t {
+	case tokenContainsAny, tokenContainsAll:
+		return true
+	default:
+		return false
+	}
+}
+
+// isEqualityOperatorType reports whether the token is an equality operator.
+func (t tokenType) isEqualityOperatorType() bool {
+	switch t {
+	case tokenEQ, tokenEQI, tokenNEQ, tokenNEQI:
+		return true
+	default:
+		return false
+	}
+}
+
+// isExactEqualityOperatorType reports whether the token is == or !=, which compare values of any type.
+func (t tokenType) isExactEqualityOperatorType() bool {
+	return t == tokenEQ || t == tokenNEQ
+}
+
+// isOrderingOperatorType reports whether the token is an ordering operator.
+func (t tokenType) isOrderingOperatorType() bool {
+	switch t {
+	case tokenGT, tokenGTE, tokenLT, tokenLTE:
+		return true
+	default:
+		return false
+	}
+}
+
+// isSubstringOperatorType reports whether the token is a substring operator.
+func (t tokenType) isSubstringOperatorType() bool {
+	switch t {
+	case tokenHas, tokenHasI:
 		return true
 	default:
 		return false
@@ -166,7 +283,17 @@ func (t tokenType) isComparisonOperatorType() bool {
 // isRegexOperatorType reports whether the token is a regex operator.
 func (t tokenType) isRegexOperatorType() bool {
 	switch t {
-	case tokenREQ, tokenREQI, tokenNREQ, tokenNREQI:
+	case tokenREQ, tokenREQI, tokenNREQ, tokenNREQI, tokenMatches, tokenMatchesI:
+		return true
+	default:
+		return false
+	}
+}
+
+// isCaseInsensitiveOperatorType reports whether the token is a case insensitive operator.
+func (t tokenType) isCaseInsensitiveOperatorType() bool {
+	switch t {
+	case tokenEQI, tokenNEQI, tokenREQI, tokenNREQI, tokenHasI, tokenMatchesI:
 		return true
 	default:
 		return false
@@ -176,17 +303,53 @@ func (t tokenType) isRegexOperatorType() bool {
 // isCaseInsensitiveRegexOperatorType reports whether the token is a case insensitive regex operator.
 func (t tokenType) isCaseInsensitiveRegexOperatorType() bool {
 	switch t {
-	case tokenREQI, tokenNREQI:
+	case tokenREQI, tokenNREQI, tokenMatchesI:
+		return true
+	default:
+		return false
+	}
+}
+
+// isAnchoredRegexOperatorType reports whether the token is a regex operator matching the whole value.
+func (t tokenType) isAnchoredRegexOperatorType() bool {
+	switch t {
+	case tokenMatches, tokenMatchesI:
 		return true
 	default:
 		return false
 	}
 }
 
+// isArithOperatorType reports whether the token is an arithmetic operator applied to a field.
+func (t tokenType) isArithOperatorType() bool {
+	switch t {
+	case tokenMod, tokenBitAnd, tokenBitOr, tokenBitXor:
+		return true
+	default:
+		return false
+	}
+}
+
+// arithName returns the name of an arithmetic operator used in messages.
+func (t tokenType) arithName() string {
+	switch t {
+	case tokenMod:
+		return "modulo"
+	case tokenBitAnd:
+		return "bitwise AND"
+	case tokenBitOr:
+		return "bitwise OR"
+	case tokenBitXor:
+		return "bitwise XOR"
+	default:
+		return ""
+	}
+}
+
 // isValueType reports whether the token is a value type.
 func (t tokenType) isValueType() bool {
 	switch t {
-	case tokenString, tokenRawString, tokenNumber, tokenTime, tokenDuration, tokenBool:
+	case tokenString, tokenRawString, tokenNumber, tokenTime, tokenDuration, tokenBool, tokenNow:
 		return true
 	default:
 		return false
@@ -233,6 +396,13 @@ type lexer struct {
 	startLine  int     // start line of this token
 	col        int     // 1+number of characters since last newline
 	startCol   int     // start column of this token
+
+	extendedUnits       bool                 // accept d and w duration units
+	literalSingleQuotes bool                 // treat single-quoted strings literally without escapes
+	decimalComma        bool                 // read , as the decimal point and . as grouping in numbers
+	aliases             map[string]tokenType // words lexed as comparison operators
+	identChars          string               // characters accepted within identifiers besides letters, digits and _
+	recoverUTF8         bool                 // report each invalid utf8 byte in a string and continue the scan
 }
 
 // newLexer creates a new lexer for the input string.
@@ -267,14 +437,29 @@ func (l *lexer) nextToken() token {
 }
 
 // next returns the next rune in the input.
+// \n, \r, \r\n, U+2028 and U+2029 each count as a single line break.
 func (l *lexer) next() rune {
 	if l.pos >= len(l.input) {
 		l.atEOF = true
 		return eof
 	}
+	if c := l.input[l.pos]; c < utf8.RuneSelf {
+		// ASCII fast path: every byte other than a line break is one column wide.
+		l.pos++
+		switch {
+		case c == '\n' && l.pos > 1 && l.input[l.pos-2] == '\r':
+			// The line break was counted at \r.
+		case c == '\n' || c == '\r':
+			l.line++
+			l.col = 1
+		default:
+			l.col++
+		}
+		return rune(c)
+	}
 	r, w := utf8.DecodeRuneInString(l.input[l.pos:])
 	l.pos += w
-	if r == '\n' {
+	if isLineBreak(r) {
 		l.line++
 		l.col = 1
 	} else {
@@ -294,25 +479,41 @@ func (l *lexer) peek() rune {
 // Valid only once per l.next.
 func (l *lexer) backup() {
 	if !l.atEOF && l.pos > 0 {
+		if c := l.input[l.pos-1]; c < utf8.RuneSelf && c != '\n' && c != '\r' {
+			l.pos--
+			l.col = max(l.col-1, 1)
+			return
+		}
 		r, w := utf8.DecodeLastRuneInString(l.input[:l.pos])
 		l.pos -= w
-		if r == '\n' {
+		switch {
+		case r == '\n' && l.pos > 0 && l.input[l.pos-1] == '\r':
+			// Still on the line begun by \r.
+			l.col = 1
+		case isLineBreak(r):
 			l.line--
-			col := 1
-			for i := l.pos - 1; i >= 0; i-- {
-				if l.input[i] == '\n' {
-					break
-				}
-				col++
-			}
-			l.col = col
-		} else {
+			l.col = l.column(l.pos)
+		default:
 			l.col -= max(runewidth.RuneWidth(r), 1)
 			l.col = max(l.col, 1)
 		}
 	}
 }
 
+// column returns the column of the byte offset pos, measured from the last line break before it.
+func (l *lexer) column(pos int) int {
+	start := 0
+	if i := strings.LastIndexFunc(l.input[:pos], isLineBreak); i >= 0 {
+		_, w := utf8.DecodeRuneInString(l.input[i:])
+		start = i + w
+	}
+	col := 1
+	for _, r := range l.input[start:pos] {
+		col += max(runewidth.RuneWidth(r), 1)
+	}
+	return col
+}
+
 // backupNumber steps back one character for number tokens.
 func (l *lexer) backupNumber() {
 	l.pos--
@@ -386,6 +587,27 @@ func (l *lexer) errorf(format string, args ...any) stateFn {
 	return nil
 }
 
+// invalidUTF8 reports an invalid utf8 encoding in the literal being scanned.
+// Without recovery it terminates the scan like errorf. With recovery, the error token locates
+// the invalid byte, which has already been consumed, and the scan resumes in the literal,
+// so that every invalid byte is reported and the literal is still emitted.
+func (l *lexer) invalidUTF8(literal string, resume stateFn) stateFn {
+	if !l.recoverUTF8 {
+		return l.errorf("invalid utf8 encoding in %s at %d:%d", literal, l.line, l.col)
+	}
+	_, w := utf8.DecodeLastRuneInString(l.input[:l.pos])
+	col := l.col - 1
+	l.token = token{
+		typ:  tokenError,
+		v:    fmt.Sprintf("invalid utf8 encoding in %s at %d:%d", literal, l.line, col),
+		pos:  l.pos - w,
+		line: l.line,
+		col:  col,
+	}
+	l.hasNext = true
+	return resume
+}
+
 // lexStmt is the top-level state for lexing.
 func lexStmt(l *lexer) stateFn {
 	switch r := l.next(); {
@@ -403,10 +625,22 @@ func lexStmt(l *lexer) stateFn {
 		return lexLparen
 	case r == ')':
 		return lexRparen
+	case r == ',':
+		return lexComma
+	case r == '[':
+		return lexLbracket
+	case r == ']':
+		return lexRbracket
+	case r == '%':
+		return lexMod
+	case r == '^':
+		return lexXor
 	case r == '=':
 		return lexEQ
 	case r == '!':
 		return lexNOT
+	case r == '~':
+		return lexApprox
 	case r == '<':
 		return lexLT
 	case r == '>':
@@ -415,6 +649,8 @@ func lexStmt(l *lexer) stateFn {
 		return lexAND
 	case r == '|':
 		return lexOR
+	case r == '/':
+		return lexComment
 	case unicode.IsDigit(r) || r == '.' || r == '+' || r == '-':
 		return lexNumber
 	case unicode.IsLetter(r) || r == '_':
@@ -448,6 +684,33 @@ func lexSpace(l *lexer) stateFn {
 	return lexStmt
 }
 
+// lexComment scans a block comment from /* to */, which may span lines and nest,
+// and skips it like space. One slash has already been seen.
+func lexComment(l *lexer) stateFn {
+	if l.peek() != '*' {
+		return l.errorf("unexpected character %#U at %d:%d", '/', l.startLine, l.startCol)
+	}
+	l.next()
+	for depth := 1; depth > 0; {
+		switch l.next() {
+		case eof:
+			return l.errorf("unterminated block comment at %d:%d", l.startLine, l.startCol)
+		case '/':
+			if l.peek() == '*' {
+				l.next()
+				depth++
+			}
+		case '*':
+			if l.peek() == '/' {
+				l.next()
+				depth--
+			}
+		}
+	}
+	l.ignore()
+	return lexStmt
+}
+
 // lexDoubleQuotedString scans a double-quoted string.
 // One double quote has already been seen.
 func lexDoubleQuotedString(l *lexer) stateFn {
@@ -457,6 +720,9 @@ func lexDoubleQuotedString(l *lexer) stateFn {
 // lexSingleQuotedString scans a single-quoted string.
 // One single quote has already been seen.
 func lexSingleQuotedString(l *lexer) stateFn {
+	if l.literalSingleQuotes {
+		return lexLiteralString(l, '\'')
+	}
 	return lexString(l, '\'')
 }
 
@@ -467,7 +733,7 @@ Loop:
 	for {
 		switch l.next() {
 		case utf8.RuneError:
-			return l.errorf("invalid utf8 encoding in string at %d:%d", l.line, l.col)
+			return l.invalidUTF8("string", func(l *lexer) stateFn { return lexString(l, quote) })
 		case eof, '\n':
 			return l.errorf("unterminated quoted string at %d:%d", l.line, l.col)
 		case '\\':
@@ -482,6 +748,24 @@ Loop:
 	return lexStmt
 }
 
+// lexLiteralString scans a quoted string without interpreting escape sequences.
+// A backslash is an ordinary character, so the string cannot contain the quote itself.
+func lexLiteralString(l *lexer, quote rune) stateFn {
+Loop:
+	for {
+		switch l.next() {
+		case utf8.RuneError:
+			return l.invalidUTF8("string", func(l *lexer) stateFn { return lexLiteralString(l, quote) })
+		case eof, '\n':
+			return l.errorf("unterminated quoted string at %d:%d", l.line, l.col)
+		case quote:
+			break Loop
+		}
+	}
+	l.emit(tokenString)
+	return lexStmt
+}
+
 // lexRawString scans a backtick quoted string.
 // One backtick has already been seen.
 func lexRawString(l *lexer) stateFn {
@@ -489,7 +773,7 @@ Loop:
 	for {
 		switch l.next() {
 		case utf8.RuneError:
-			return l.errorf("invalid utf8 encoding in raw string at %d:%d", l.line, l.col)
+			return l.invalidUTF8("raw string", lexRawString)
 		case eof:
 			return l.errorf("unterminated raw string at %d:%d", l.line, l.col)
 		case '`':
@@ -514,6 +798,36 @@ func lexRparen(l *lexer) stateFn {
 	return lexStmt
 }
 
+// lexComma emits a comma.
+func lexComma(l *lexer) stateFn {
+	l.emit(tokenComma)
+	return lexStmt
+}
+
+// lexLbracket emits a left bracket.
+func lexLbracket(l *lexer) stateFn {
+	l.emit(tokenLbracket)
+	return lexStmt
+}
+
+// lexRbracket emits a right bracket.
+func lexRbracket(l *lexer) stateFn {
+	l.emit(tokenRbracket)
+	return lexStmt
+}
+
+// lexMod emits a modulo operator.
+func lexMod(l *lexer) stateFn {
+	l.emit(tokenMod)
+	return lexStmt
+}
+
+// lexXor emits a bitwise XOR operator.
+func lexXor(l *lexer) stateFn {
+	l.emit(tokenBitXor)
+	return lexStmt
+}
+
 // lexEQ scans for operators starting with an equality sign.
 // The leading '=' has already been seen.
 func lexEQ(l *lexer) stateFn {
@@ -540,6 +854,17 @@ func lexEQ(l *lexer) stateFn {
 	return lexStmt
 }
 
+// lexApprox scans for the approximate equality operator.
+// The leading '~' has already been seen.
+func lexApprox(l *lexer) stateFn {
+	if l.peek() != '=' {
+		return l.errorf("unexpected character %q after '~' at %d:%d", l.peek(), l.line, l.col)
+	}
+	l.next()
+	l.emit(tokenApprox)
+	return lexStmt
+}
+
 // lexNOT scans for operators starting with a negative sign.
 // The leading '!' has already been seen.
 // If unary, it emits a negative operator.
@@ -591,28 +916,32 @@ func lexGT(l *lexer) stateFn {
 	return lexStmt
 }
 
-// lexAND scans for the logical AND operator.
+// lexAND scans for the logical AND operator, or the bitwise AND operator if '&' is not doubled.
 // The leading '&' has already been seen.
 func lexAND(l *lexer) stateFn {
-	r := l.peek()
-	if r == '&' {
+	switch r := l.peek(); r {
+	case '&':
 		l.next()
 		l.emit(tokenAND)
-	} else {
+	case '|':
 		return l.errorf("unexpected character %q after '&' at %d:%d", r, l.line, l.col)
+	default:
+		l.emit(tokenBitAnd)
 	}
 	return lexStmt
 }
 
-// lexOR scans for the logical OR operator.
+// lexOR scans for the logical OR operator, or the bitwise OR operator if '|' is not doubled.
 // The leading '|' has already been seen.
 func lexOR(l *lexer) stateFn {
-	r := l.peek()
-	if r == '|' {
+	switch r := l.peek(); r {
+	case '|':
 		l.next()
 		l.emit(tokenOR)
-	} else {
+	case '&':
 		return l.errorf("unexpected character %q after '|' at %d:%d", r, l.line, l.col)
+	default:
+		l.emit(tokenBitOr)
 	}
 	return lexStmt
 }
@@ -634,7 +963,11 @@ func lexNumber(l *lexer) stateFn {
 	l.line = line
 	l.col = col
 	l.backup()
-	if l.scanDuration() {
+	ok, err := l.scanDuration()
+	if err != nil {
+		return l.errorf("invalid duration %q at %d:%d: %v", l.input[l.startPos:l.pos], l.startLine, l.startCol, err)
+	}
+	if ok {
 		l.emit(tokenDuration)
 		return lexStmt
 	}
@@ -642,12 +975,16 @@ func lexNumber(l *lexer) stateFn {
 	l.pos = pos
 	l.line = line
 	l.col = col
+	l.atEOF = false
 	l.backup()
 	if l.scanNumber() {
 		l.emit(tokenNumber)
+		if l.decimalComma {
+			l.token.v = decimalPoint(l.token.v)
+		}
 		return lexStmt
 	}
-	return lexStmt
+	return l.errorf("invalid number %q at %d:%d", l.input[l.startPos:l.pos], l.startLine, l.startCol)
 }
 
 // lexKeywordOrIdent scans for keywords or identifiers.
@@ -655,19 +992,76 @@ func lexNumber(l *lexer) stateFn {
 func lexKeywordOrIdent(l *lexer) stateFn {
 	for {
 		r := l.next()
-		if !isAlphaNumeric(r) && r != '_' {
+		if !isAlphaNumeric(r) && !l.isIdentChar(r) {
 			l.backup()
 			break
 		}
 	}
-	if isBoolLiteral(l.input[l.startPos:l.pos]) {
+	word := l.input[l.startPos:l.pos]
+	if isBoolLiteral(word) {
 		l.emit(tokenBool)
 		return lexStmt
 	}
+	if word == "now" {
+		return lexNow
+	}
+	switch word {
+	case "containsany":
+		l.emit(tokenContainsAny)
+		return lexStmt
+	case "containsall":
+		l.emit(tokenContainsAll)
+		return lexStmt
+	case "has":
+		l.emit(tokenHas)
+		return lexStmt
+	case "ihas":
+		l.emit(tokenHasI)
+		return lexStmt
+	case "matches":
+		l.emit(tokenMatches)
+		return lexStmt
+	case "imatches":
+		l.emit(tokenMatchesI)
+		return lexStmt
+	}
+	if typ, ok := l.aliases[word]; ok {
+		l.emit(typ)
+		return lexStmt
+	}
+	if word == "in" {
+		l.emit(tokenIn)
+		return lexStmt
+	}
 	l.emit(tokenIdent)
 	return lexStmt
 }
 
+// isIdentChar reports whether r, which has just been read, is one of the characters given to
+// WithIdentChars and continues the identifier. Such a character must be followed by a letter,
+// a digit or _, so that an identifier never ends with it, and it does not continue the keyword now,
+// so that now-1h is still a now literal.
+func (l *lexer) isIdentChar(r rune) bool {
+	if l.identChars == "" || !strings.ContainsRune(l.identChars, r) {
+		return false
+	}
+	next, _ := utf8.DecodeRuneInString(l.input[l.pos:])
+	return isAlphaNumeric(next) && l.input[l.startPos:l.pos-utf8.RuneLen(r)] != "now"
+}
+
+// lexNow scans the optional offset of a now literal, such as now-1h or now+30m.
+// The keyword has already been seen. The offset is validated by the parser.
+func lexNow(l *lexer) stateFn {
+	if r := l.peek(); r == '+' || r == '-' {
+		l.next()
+		for r := l.peek(); isAlphaNumeric(r) || r == '.'; r = l.peek() {
+			l.next()
+		}
+	}
+	l.emit(tokenNow)
+	return lexStmt
+}
+
 // scanEscape handles escape sequences in strings
 // It consumes the escape character and expects a valid escape sequence.
 func (l *lexer) scanEscape() bool {
@@ -715,15 +1109,15 @@ func (l *lexer) scanTime() bool {
 	if !l.acceptDigits(4) || !l.accept("-") || !l.acceptDigits(2) || !l.accept("-") || !l.acceptDigits(2) {
 		return false
 	}
-	// 'T' separator
-	if !l.accept("T") {
+	// 'T' or a single space separator, where the space must be followed by the time
+	if !l.accept("T ") {
 		return false
 	}
 	// Time: HH:MM:SS
 	if !l.acceptDigits(2) || !l.accept(":") || !l.acceptDigits(2) || !l.accept(":") || !l.acceptDigits(2) {
 		return false
 	}
-	// Optional fractional seconds: '.' 1+DIGIT
+	// Optional fractional seconds: '.' 1+DIGIT, of which up to 9 are kept
 	if l.accept(".") {
 		r := l.next()
 		if !unicode.IsDigit(r) {
@@ -748,13 +1142,18 @@ func (l *lexer) scanTime() bool {
 // scanDuration scans for duration literals.
 // Determines validity by the longest match,
 // the remainder is treated as the next token.
-func (l *lexer) scanDuration() bool {
+// A duration with a duplicated unit or a malformed number such as 0..1h is reported as an error
+// instead of being left for time.ParseDuration to reject.
+func (l *lexer) scanDuration() (bool, error) {
 	valid := false
+	var seen uint
 	for {
 		start := l.pos
 		if !l.scanDurationNumber() {
 			break
 		}
+		num := l.input[start:l.pos]
+		unit := l.pos
 		found := false
 		switch r := l.next(); r {
 		case 'n':
@@ -776,6 +1175,14 @@ func (l *lexer) scanDuration() bool {
 			found = true
 		case 'h':
 			found = true
+		case 'd', 'w':
+			if l.extendedUnits {
+				found = true
+			} else {
+				for l.pos > start {
+					l.backupNumber()
+				}
+			}
 		default:
 			for l.pos > start {
 				l.backupNumber()
@@ -784,6 +1191,14 @@ func (l *lexer) scanDuration() bool {
 		if !found {
 			break
 		}
+		if strings.Count(num, ".") > 1 || strings.Trim(num, "+-.") == "" {
+			return false, fmt.Errorf("invalid number %q in duration", num)
+		}
+		bit := durationUnitBit(l.input[unit:l.pos])
+		if seen&bit != 0 {
+			return false, fmt.Errorf("duplicated unit %q in duration", l.input[unit:l.pos])
+		}
+		seen |= bit
 		valid = true
 		r := l.peek()
 		if r == eof || (!unicode.IsDigit(r) && r != '.') {
@@ -791,9 +1206,33 @@ func (l *lexer) scanDuration() bool {
 		}
 	}
 	if !valid {
-		return false
+		return false, nil
+	}
+	return true, nil
+}
+
+// durationUnitBit returns a distinct bit for each duration unit, treating us and μs as the same unit.
+func durationUnitBit(unit string) uint {
+	switch unit {
+	case "ns":
+		return 1 << 0
+	case "us", "μs":
+		return 1 << 1
+	case "ms":
+		return 1 << 2
+	case "s":
+		return 1 << 3
+	case "m":
+		return 1 << 4
+	case "h":
+		return 1 << 5
+	case "d":
+		return 1 << 6
+	case "w":
+		return 1 << 7
+	default:
+		return 0
 	}
-	return true
 }
 
 // scanDurationNumber scans a number in a duration literal.
@@ -813,6 +1252,7 @@ func (l *lexer) scanDurationNumber() bool {
 func (l *lexer) scanNumber() bool {
 	// Optional leading sign.
 	l.accept("+-")
+	start := l.pos
 	// Is it hex?
 	digits := "0123456789_"
 	if l.accept("0") {
@@ -825,6 +1265,9 @@ func (l *lexer) scanNumber() bool {
 			digits = "01_"
 		}
 	}
+	if l.decimalComma && len(digits) == 10+1 {
+		return l.scanDecimalComma(start)
+	}
 	l.acceptRun(digits)
 	if l.accept(".") {
 		l.acceptRun(digits)
@@ -840,9 +1283,66 @@ func (l *lexer) scanNumber() bool {
 	return true
 }
 
+// scanDecimalComma scans the rest of a decimal number written with a decimal comma, such as 1.000.000,5,
+// whose integer part starts at start. A dot groups the following three digits, and the first group
+// has at most three digits and no leading zero. A comma is the decimal point only if a digit follows,
+// so that the items of (1, 2) are still two numbers.
+func (l *lexer) scanDecimalComma(start int) bool {
+	l.acceptRun("0123456789_")
+	if l.peek() == '.' {
+		if n := l.pos - start; n == 0 || n > 3 || l.input[start] == '0' {
+			l.next()
+			return false
+		}
+		for l.accept(".") {
+			if l.acceptRun("0123456789") != 3 {
+				return false
+			}
+		}
+	}
+	if l.pos+1 < len(l.input) && l.input[l.pos] == ',' && '0' <= l.input[l.pos+1] && l.input[l.pos+1] <= '9' {
+		l.next()
+		l.acceptRun("0123456789_")
+	}
+	if l.accept("eE") {
+		l.accept("+-")
+		l.acceptRun("0123456789_")
+	}
+	return true
+}
+
+// decimalPoint rewrites a decimal number written with a decimal comma, such as 1.000,5,
+// in the syntax of Go, such as 1000.5. Numbers with a base prefix are returned as they are.
+func decimalPoint(s string) string {
+	if hasBasePrefix(s) || !strings.ContainsAny(s, ".,") {
+		return s
+	}
+	return strings.NewReplacer(".", "", ",", ".").Replace(s)
+}
+
+// decimalComma rewrites a decimal number in the syntax of Go, such as 1000.5,
+// with a decimal comma, such as 1000,5. Numbers with a base prefix are returned as they are.
+func decimalComma(s string) string {
+	if hasBasePrefix(s) {
+		return s
+	}
+	return strings.ReplaceAll(s, ".", ",")
+}
+
+// hasBasePrefix reports whether a number literal has a base prefix such as 0x, 0o or 0b.
+func hasBasePrefix(s string) bool {
+	s = strings.TrimLeft(s, "+-")
+	return len(s) > 1 && s[0] == '0' && strings.IndexByte("xXoObB", s[1]) >= 0
+}
+
 // isSpace reports whether the rune is a space character.
 func isSpace(r rune) bool {
-	return r == ' ' || r == '\t' || r == '\r' || r == '\n'
+	return r == ' ' || r == '\t' || isLineBreak(r)
+}
+
+// isLineBreak reports whether the rune ends a line.
+func isLineBreak(r rune) bool {
+	return r == '\n' || r == '\r' || r == '\u2028' || r == '\u2029'
 }
 
 // isAlphaNumeric reports whether the rune is a valid alphanumeric character.
@@ -136,6 +136,86 @@ func Test_tokenType_String(t *testing.T) {
 			typ:      tokenBool,
 			expected: "boolean",
 		},
+		{
+			name:     "now",
+			typ:      tokenNow,
+			expected: "now",
+		},
+		{
+			name:     "containsany",
+			typ:      tokenContainsAny,
+			expected: "containsany operator",
+		},
+		{
+			name:     "containsall",
+			typ:      tokenContainsAll,
+			expected: "containsall operator",
+		},
+		{
+			name:     "has",
+			typ:      tokenHas,
+			expected: "substring operator",
+		},
+		{
+			name:     "ihas",
+			typ:      tokenHasI,
+			expected: "case-insensitive substring operator",
+		},
+		{
+			name:     "matches",
+			typ:      tokenMatches,
+			expected: "whole-value regex matching operator",
+		},
+		{
+			name:     "imatches",
+			typ:      tokenMatchesI,
+			expected: "case-insensitive whole-value regex matching operator",
+		},
+		{
+			name:     "left bracket",
+			typ:      tokenLbracket,
+			expected: "left bracket",
+		},
+		{
+			name:     "right bracket",
+			typ:      tokenRbracket,
+			expected: "right bracket",
+		},
+		{
+			name:     "approx",
+			typ:      tokenApprox,
+			expected: "\"approximately equal to\" operator",
+		},
+		{
+			name:     "in",
+			typ:      tokenIn,
+			expected: "membership operator",
+		},
+		{
+			name:     "comma",
+			typ:      tokenComma,
+			expected: "comma",
+		},
+		{
+			name:     "mod",
+			typ:      tokenMod,
+			expected: "modulo operator",
+		},
+		{
+			name:     "bitwise and",
+			typ:      tokenBitAnd,
+			expected: "bitwise AND operator",
+		},
+		{
+			name:     "bitwise or",
+			typ:      tokenBitOr,
+			expected: "bitwise OR operator",
+		},
+		{
+			name:     "bitwise xor",
+			typ:      tokenBitXor,
+			expected: "bitwise XOR operator",
+		},
 		{
 			name:     "invalid",
 			typ:      256,
@@ -282,6 +362,81 @@ func Test_tokenType_literal(t *testing.T) {
 			typ:      tokenBool,
 			expected: "",
 		},
+		{
+			name:     "containsany",
+			typ:      tokenContainsAny,
+			expected: "containsany",
+		},
+		{
+			name:     "containsall",
+			typ:      tokenContainsAll,
+			expected: "containsall",
+		},
+		{
+			name:     "has",
+			typ:      tokenHas,
+			expected: "has",
+		},
+		{
+			name:     "ihas",
+			typ:      tokenHasI,
+			expected: "ihas",
+		},
+		{
+			name:     "matches",
+			typ:      tokenMatches,
+			expected: "matches",
+		},
+		{
+			name:     "imatches",
+			typ:      tokenMatchesI,
+			expected: "imatches",
+		},
+		{
+			name:     "left bracket",
+			typ:      tokenLbracket,
+			expected: "[",
+		},
+		{
+			name:     "right bracket",
+			typ:      tokenRbracket,
+			expected: "]",
+		},
+		{
+			name:     "approx",
+			typ:      tokenApprox,
+			expected: "~=",
+		},
+		{
+			name:     "in",
+			typ:      tokenIn,
+			expected: "in",
+		},
+		{
+			name:     "comma",
+			typ:      tokenComma,
+			expected: ",",
+		},
+		{
+			name:     "mod",
+			typ:      tokenMod,
+			expected: "%",
+		},
+		{
+			name:     "bitwise and",
+			typ:      tokenBitAnd,
+			expected: "&",
+		},
+		{
+			name:     "bitwise or",
+			typ:      tokenBitOr,
+			expected: "|",
+		},
+		{
+			name:     "bitwise xor",
+			typ:      tokenBitXor,
+			expected: "^",
+		},
 		{
 			name:     "invalid",
 			typ:      256,
@@ -2006,145 +2161,1286 @@ test2
 				},
 			},
 		},
-	}
-	for _, test := range tests {
-		t.Run(test.name, func(t *testing.T) {
-			l := newLexer(test.input)
-			actual := make([]token, 0, len(test.input))
-			t.Logf("input: %v", test.input)
-			for {
-				token := l.nextToken()
-				actual = append(actual, token)
-				t.Logf("token: %v", token.v)
-				if token.typ == tokenEOF || token.typ == tokenError {
-					break
-				}
-			}
-			if !reflect.DeepEqual(actual, test.expected) {
-				t.Errorf(testTemplate, test.input, test.expected, actual)
-			}
-		})
-	}
-}
-
-func Test_lexer_scanEscape(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected bool
-	}{
-		{name: "newline", input: "n", expected: true},
-		{name: "tab", input: "t", expected: true},
-		{name: "backslash", input: "\\", expected: true},
-		{name: "quote_double", input: "\"", expected: true},
-		{name: "quote_single", input: "'", expected: true},
-		{name: "null", input: "0", expected: true},
-		{name: "bell", input: "a", expected: true},
-		{name: "backspace", input: "b", expected: true},
-		{name: "formfeed", input: "f", expected: true},
-		{name: "carriage_return", input: "r", expected: true},
-		{name: "vertical_tab", input: "v", expected: true},
-		{name: "hex", input: "x41", expected: true},
-		{name: "unicode", input: "u0041", expected: true},
-		{name: "invalid_char", input: "z", expected: false},
-		{name: "empty", input: "", expected: false},
-		{name: "eof", input: string([]byte{0}), expected: false},
-		{name: "backtick", input: "`", expected: false},
-		{name: "hex_short", input: "x4", expected: false},
-		{name: "hex_nonhex", input: "x4G", expected: false},
-		{name: "unicode_short", input: "u041", expected: false},
-		{name: "unicode_nonhex", input: "u004G", expected: false},
-	}
-	for _, test := range tests {
-		t.Run(test.name, func(t *testing.T) {
-			l := &lexer{
-				input: test.input,
-				pos:   0,
-			}
-			actual := l.scanEscape()
-			if actual != test.expected {
-				t.Errorf(testTemplate, test.input, test.expected, actual)
-			}
-		})
-	}
-}
-
-func Test_lexer_scanDuration(t *testing.T) {
-	type expected struct {
-		valid   bool
-		matched string
-	}
-	tests := []struct {
-		name     string
-		input    string
-		expected expected
-	}{
-		{name: "hour", input: "1h", expected: expected{valid: true, matched: "1h"}},
-		{name: "minute", input: "1m", expected: expected{valid: true, matched: "1m"}},
-		{name: "second", input: "1s", expected: expected{valid: true, matched: "1s"}},
-		{name: "millisecond", input: "1ms", expected: expected{valid: true, matched: "1ms"}},
-		{name: "microsecond 1", input: "1us", expected: expected{valid: true, matched: "1us"}},
-		{name: "microsecond 2", input: "1μs", expected: expected{valid: true, matched: "1μs"}},
-		{name: "nanosecond", input: "1ns", expected: expected{valid: true, matched: "1ns"}},
-		{name: "sign 1", input: "+1h", expected: expected{valid: true, matched: "+1h"}},
-		{name: "sign 2", input: "-1h", expected: expected{valid: true, matched: "-1h"}},
-		{name: "float 1", input: "0.1h", expected: expected{valid: true, matched: "0.1h"}},
-		{name: "float 2", input: "1.1h", expected: expected{valid: true, matched: "1.1h"}},
-		{name: "float 3", input: ".1h", expected: expected{valid: true, matched: ".1h"}},
-		{name: "float 4", input: "1.h", expected: expected{valid: true, matched: "1.h"}},
-		{name: "mixed 1", input: "1h5000ns", expected: expected{valid: true, matched: "1h5000ns"}},
-		{name: "mixed 2", input: "5000ns1h", expected: expected{valid: true, matched: "5000ns1h"}},
-		{name: "mixed 3", input: "+1h5000ns", expected: expected{valid: true, matched: "+1h5000ns"}},
-		{name: "mixed 4", input: "-5000ns1h", expected: expected{valid: true, matched: "-5000ns1h"}},
-		{name: "mixed 5", input: "0.1h0.30m", expected: expected{valid: true, matched: "0.1h0.30m"}},
-		{name: "mixed 6", input: ".1m.30s", expected: expected{valid: true, matched: ".1m.30s"}},
-		{name: "mixed 7", input: "-1.1h.30m", expected: expected{valid: true, matched: "-1.1h.30m"}},
-		{name: "mixed 8", input: "+0.1h.30m", expected: expected{valid: true, matched: "+0.1h.30m"}},
-		{name: "mixed 9", input: "-.1h.30m", expected: expected{valid: true, matched: "-.1h.30m"}},
-		{name: "mixed 10", input: "+.1h.30m", expected: expected{valid: true, matched: "+.1h.30m"}},
-		{name: "mixed 11", input: "+1.h30.m", expected: expected{valid: true, matched: "+1.h30.m"}},
-		{name: "full", input: "1h30m15s3000ms4000us5000ns", expected: expected{valid: true, matched: "1h30m15s3000ms4000us5000ns"}},
-		{name: "duplicated", input: "1h1h", expected: expected{valid: true, matched: "1h1h"}},
-		{name: "longest match 1", input: "1h+30m", expected: expected{valid: true, matched: "1h"}},
-		{name: "longest match 2", input: "1h-30m", expected: expected{valid: true, matched: "1h"}},
-		{name: "longest match 3", input: "+1h+30m+15s+3000ms+4000us+5000ns", expected: expected{valid: true, matched: "+1h"}},
-		{name: "longest match 4", input: "-1h-30m-15s-3000ms-4000us-5000ns", expected: expected{valid: true, matched: "-1h"}},
-		{name: "longest match 5", input: "1hm", expected: expected{valid: true, matched: "1h"}},
-		{name: "longest match 6", input: "1hms", expected: expected{valid: true, matched: "1h"}},
-		{name: "longest match 7", input: "1hd", expected: expected{valid: true, matched: "1h"}},
-		{name: "longest match 8", input: "1h30m1d", expected: expected{valid: true, matched: "1h30m"}},
-		{name: "longest match 9", input: "1h30md", expected: expected{valid: true, matched: "1h30m"}},
-		{name: "longest match 10", input: "1h_", expected: expected{valid: true, matched: "1h"}},
-		{name: "invalid multiple dot but passed 1", input: "0..1h", expected: expected{valid: true, matched: "0..1h"}},
-		{name: "invalid multiple dot but passed 2", input: "..1h", expected: expected{valid: true, matched: "..1h"}},
-		{name: "number 1", input: "1", expected: expected{valid: false, matched: ""}},
-		{name: "number 2", input: "+1", expected: expected{valid: false, matched: ""}},
-		{name: "number 3", input: "-1", expected: expected{valid: false, matched: ""}},
-		{name: "invalid unit 1", input: "365d", expected: expected{valid: false, matched: ""}},
-		{name: "invalid unit 4", input: "1d30m", expected: expected{valid: false, matched: ""}},
-		{name: "only unit 1", input: "h", expected: expected{valid: false, matched: ""}},
-		{name: "only unit 2", input: "ms", expected: expected{valid: false, matched: ""}},
-		{name: "only sign 1", input: "+", expected: expected{valid: false, matched: ""}},
-		{name: "only sign 2", input: "-", expected: expected{valid: false, matched: ""}},
-		{name: "sign and unit 1", input: "+ms", expected: expected{valid: false, matched: ""}},
-		{name: "sign and unit 2", input: "-ms", expected: expected{valid: false, matched: ""}},
-		{name: "unary operator 1", input: "- 1ms", expected: expected{valid: false, matched: ""}},
-		{name: "unary operator 2", input: "+ ms", expected: expected{valid: false, matched: ""}},
-		{name: "unit repeat 1", input: "msms", expected: expected{valid: false, matched: ""}},
-		{name: "empty", input: "", expected: expected{valid: false, matched: ""}},
-	}
-	for _, test := range tests {
-		t.Run(test.name, func(t *testing.T) {
-			l := &lexer{
-				input: test.input,
-				pos:   0,
-			}
-			actual := l.scanDuration()
-			if actual != test.expected.valid {
-				t.Errorf(testTemplate, test.input, test.expected.valid, actual)
-			}
-			if test.input[l.startPos:l.pos] != test.expected.matched {
-				t.Errorf(testTemplate, test.input, test.expected.matched, test.input[l.startPos:l.pos])
+		{
+			name:  "containsany",
+			input: `Tags containsany (1,"a")`,
+			expected: []token{
+				{
+					typ:  tokenIdent,
+					v:    "Tags",
+					pos:  0,
+					line: 1,
+					col:  1,
+				},
+				{
+					typ:  tokenContainsAny,
+					v:    "containsany",
+					pos:  5,
+					line: 1,
+					col:  6,
+				},
+				{
+					typ:  tokenLparen,
+					v:    "(",
+					pos:  17,
+					line: 1,
+					col:  18,
+				},
+				{
+					typ:  tokenNumber,
+					v:    "1",
+					pos:  18,
+					line: 1,
+					col:  19,
+				},
+				{
+					typ:  tokenComma,
+					v:    ",",
+					pos:  19,
+					line: 1,
+					col:  20,
+				},
+				{
+					typ:  tokenString,
+					v:    "\"a\"",
+					pos:  20,
+					line: 1,
+					col:  21,
+				},
+				{
+					typ:  tokenRparen,
+					v:    ")",
+					pos:  23,
+					line: 1,
+					col:  24,
+				},
+				{
+					typ:  tokenEOF,
+					v:    "",
+					pos:  24,
+					line: 1,
+					col:  25,
+				},
+			},
+		},
+		{
+			name:  "arithmetic operators",
+			input: `A&4&&B|1||C^2%3`,
+			expected: []token{
+				{
+					typ:  tokenIdent,
+					v:    "A",
+					pos:  0,
+					line: 1,
+					col:  1,
+				},
+				{
+					typ:  tokenBitAnd,
+					v:    "&",
+					pos:  1,
+					line: 1,
+					col:  2,
+				},
+				{
+					typ:  tokenNumber,
+					v:    "4",
+					pos:  2,
+					line: 1,
+					col:  3,
+				},
+				{
+					typ:  tokenAND,
+					v:    "&&",
+					pos:  3,
+					line: 1,
+					col:  4,
+				},
+				{
+					typ:  tokenIdent,
+					v:    "B",
+					pos:  5,
+					line: 1,
+					col:  6,
+				},
+				{
+					typ:  tokenBitOr,
+					v:    "|",
+					pos:  6,
+					line: 1,
+					col:  7,
+				},
+				{
+					typ:  tokenNumber,
+					v:    "1",
+					pos:  7,
+					line: 1,
+					col:  8,
+				},
+				{
+					typ:  tokenOR,
+					v:    "||",
+					pos:  8,
+					line: 1,
+					col:  9,
+				},
+				{
+					typ:  tokenIdent,
+					v:    "C",
+					pos:  10,
+					line: 1,
+					col:  11,
+				},
+				{
+					typ:  tokenBitXor,
+					v:    "^",
+					pos:  11,
+					line: 1,
+					col:  12,
+				},
+				{
+					typ:  tokenNumber,
+					v:    "2",
+					pos:  12,
+					line: 1,
+					col:  13,
+				},
+				{
+					typ:  tokenMod,
+					v:    "%",
+					pos:  13,
+					line: 1,
+					col:  14,
+				},
+				{
+					typ:  tokenNumber,
+					v:    "3",
+					pos:  14,
+					line: 1,
+					col:  15,
+				},
+				{
+					typ:  tokenEOF,
+					v:    "",
+					pos:  15,
+					line: 1,
+					col:  16,
+				},
+			},
+		},
+		{
+			name:  "has",
+			input: `Message has"error"||Message ihas 'x'`,
+			expected: []token{
+				{
+					typ:  tokenIdent,
+					v:    "Message",
+					pos:  0,
+					line: 1,
+					col:  1,
+				},
+				{
+					typ:  tokenHas,
+					v:    "has",
+					pos:  8,
+					line: 1,
+					col:  9,
+				},
+				{
+					typ:  tokenString,
+					v:    `"error"`,
+					pos:  11,
+					line: 1,
+					col:  12,
+				},
+				{
+					typ:  tokenOR,
+					v:    "||",
+					pos:  18,
+					line: 1,
+					col:  19,
+				},
+				{
+					typ:  tokenIdent,
+					v:    "Message",
+					pos:  20,
+					line: 1,
+					col:  21,
+				},
+				{
+					typ:  tokenHasI,
+					v:    "ihas",
+					pos:  28,
+					line: 1,
+					col:  29,
+				},
+				{
+					typ:  tokenString,
+					v:    `'x'`,
+					pos:  33,
+					line: 1,
+					col:  34,
+				},
+				{
+					typ:  tokenEOF,
+					v:    "",
+					pos:  36,
+					line: 1,
+					col:  37,
+				},
+			},
+		},
+		{
+			name:  "containsall",
+			input: `Tags containsall()`,
+			expected: []token{
+				{
+					typ:  tokenIdent,
+					v:    "Tags",
+					pos:  0,
+					line: 1,
+					col:  1,
+				},
+				{
+					typ:  tokenContainsAll,
+					v:    "containsall",
+					pos:  5,
+					line: 1,
+					col:  6,
+				},
+				{
+					typ:  tokenLparen,
+					v:    "(",
+					pos:  16,
+					line: 1,
+					col:  17,
+				},
+				{
+					typ:  tokenRparen,
+					v:    ")",
+					pos:  17,
+					line: 1,
+					col:  18,
+				},
+				{
+					typ:  tokenEOF,
+					v:    "",
+					pos:  18,
+					line: 1,
+					col:  19,
+				},
+			},
+		},
+		{
+			name:  "now",
+			input: "now",
+			expected: []token{
+				{
+					typ:  tokenNow,
+					v:    "now",
+					pos:  0,
+					line: 1,
+					col:  1,
+				},
+				{
+					typ:  tokenEOF,
+					v:    "",
+					pos:  3,
+					line: 1,
+					col:  4,
+				},
+			},
+		},
+		{
+			name:  "now with negative offset",
+			input: "now-1h",
+			expected: []token{
+				{
+					typ:  tokenNow,
+					v:    "now-1h",
+					pos:  0,
+					line: 1,
+					col:  1,
+				},
+				{
+					typ:  tokenEOF,
+					v:    "",
+					pos:  6,
+					line: 1,
+					col:  7,
+				},
+			},
+		},
+		{
+			name:  "now with positive offset",
+			input: "now+1.5h&&",
+			expected: []token{
+				{
+					typ:  tokenNow,
+					v:    "now+1.5h",
+					pos:  0,
+					line: 1,
+					col:  1,
+				},
+				{
+					typ:  tokenAND,
+					v:    "&&",
+					pos:  8,
+					line: 1,
+					col:  9,
+				},
+				{
+					typ:  tokenEOF,
+					v:    "",
+					pos:  10,
+					line: 1,
+					col:  11,
+				},
+			},
+		},
+		{
+			name:  "now with empty offset",
+			input: "now-",
+			expected: []token{
+				{
+					typ:  tokenNow,
+					v:    "now-",
+					pos:  0,
+					line: 1,
+					col:  1,
+				},
+				{
+					typ:  tokenEOF,
+					v:    "",
+					pos:  4,
+					line: 1,
+					col:  5,
+				},
+			},
+		},
+		{
+			name:  "now with separated offset",
+			input: "now -1h",
+			expected: []token{
+				{
+					typ:  tokenNow,
+					v:    "now",
+					pos:  0,
+					line: 1,
+					col:  1,
+				},
+				{
+					typ:  tokenDuration,
+					v:    "-1h",
+					pos:  4,
+					line: 1,
+					col:  5,
+				},
+				{
+					typ:  tokenEOF,
+					v:    "",
+					pos:  7,
+					line: 1,
+					col:  8,
+				},
+			},
+		},
+		{
+			name:  "now prefixed identifier",
+			input: "nowadays",
+			expected: []token{
+				{
+					typ:  tokenIdent,
+					v:    "nowadays",
+					pos:  0,
+					line: 1,
+					col:  1,
+				},
+				{
+					typ:  tokenEOF,
+					v:    "",
+					pos:  8,
+					line: 1,
+					col:  9,
+				},
+			},
+		},
+		{
+			name:  "approx",
+			input: "Temp~=20.5",
+			expected: []token{
+				{typ: tokenIdent, v: "Temp", pos: 0, line: 1, col: 1},
+				{typ: tokenApprox, v: "~=", pos: 4, line: 1, col: 5},
+				{typ: tokenNumber, v: "20.5", pos: 6, line: 1, col: 7},
+				{typ: tokenEOF, v: "", pos: 10, line: 1, col: 11},
+			},
+		},
+		{
+			name:  "tilde without equal",
+			input: "Temp ~ 20",
+			expected: []token{
+				{typ: tokenIdent, v: "Temp", pos: 0, line: 1, col: 1},
+				{typ: tokenError, v: "unexpected character ' ' after '~' at 1:7", pos: 5, line: 1, col: 6},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := newLexer(test.input)
+			actual := make([]token, 0, len(test.input))
+			t.Logf("input: %v", test.input)
+			for {
+				token := l.nextToken()
+				actual = append(actual, token)
+				t.Logf("token: %v", token.v)
+				if token.typ == tokenEOF || token.typ == tokenError {
+					break
+				}
+			}
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
+			}
+		})
+	}
+}
+
+func Test_lex_lineBreaks(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected [][2]int // line and column of each token
+	}{
+		{
+			name:     "crlf",
+			input:    "HP>50 &&\r\n  Name==\"slime\" &&\r\n    MP<10",
+			expected: [][2]int{{1, 1}, {1, 3}, {1, 4}, {1, 7}, {2, 3}, {2, 7}, {2, 9}, {2, 17}, {3, 5}, {3, 7}, {3, 8}, {3, 10}},
+		},
+		{
+			name:     "cr",
+			input:    "HP>50 &&\r  Name==\"slime\"",
+			expected: [][2]int{{1, 1}, {1, 3}, {1, 4}, {1, 7}, {2, 3}, {2, 7}, {2, 9}, {2, 16}},
+		},
+		{
+			name:     "blank crlf lines",
+			input:    "HP>50\r\n\r\n&& MP<10\r\n",
+			expected: [][2]int{{1, 1}, {1, 3}, {1, 4}, {3, 1}, {3, 4}, {3, 6}, {3, 7}, {4, 1}},
+		},
+		{
+			name:     "line separator",
+			input:    "名前==\"孔明\"\u2028&& HP>50",
+			expected: [][2]int{{1, 1}, {1, 5}, {1, 7}, {2, 1}, {2, 4}, {2, 6}, {2, 7}, {2, 9}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := newLexer(test.input)
+			var actual [][2]int
+			for {
+				token := l.nextToken()
+				if token.typ == tokenError {
+					t.Fatalf(testTemplate, test.input, test.expected, token.v)
+				}
+				actual = append(actual, [2]int{token.line, token.col})
+				if token.typ == tokenEOF {
+					break
+				}
+			}
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
+			}
+		})
+	}
+}
+
+func Test_lexer_scanEscape(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected bool
+	}{
+		{name: "newline", input: "n", expected: true},
+		{name: "tab", input: "t", expected: true},
+		{name: "backslash", input: "\\", expected: true},
+		{name: "quote_double", input: "\"", expected: true},
+		{name: "quote_single", input: "'", expected: true},
+		{name: "null", input: "0", expected: true},
+		{name: "bell", input: "a", expected: true},
+		{name: "backspace", input: "b", expected: true},
+		{name: "formfeed", input: "f", expected: true},
+		{name: "carriage_return", input: "r", expected: true},
+		{name: "vertical_tab", input: "v", expected: true},
+		{name: "hex", input: "x41", expected: true},
+		{name: "unicode", input: "u0041", expected: true},
+		{name: "invalid_char", input: "z", expected: false},
+		{name: "empty", input: "", expected: false},
+		{name: "eof", input: string([]byte{0}), expected: false},
+		{name: "backtick", input: "`", expected: false},
+		{name: "hex_short", input: "x4", expected: false},
+		{name: "hex_nonhex", input: "x4G", expected: false},
+		{name: "unicode_short", input: "u041", expected: false},
+		{name: "unicode_nonhex", input: "u004G", expected: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := &lexer{
+				input: test.input,
+				pos:   0,
+			}
+			actual := l.scanEscape()
+			if actual != test.expected {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
+			}
+		})
+	}
+}
+
+func Test_lexer_next(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected [][2]int // line and column after each rune
+	}{
+		{name: "ascii", input: "a=1", expected: [][2]int{{1, 2}, {1, 3}, {1, 4}}},
+		{name: "control", input: "a\tb", expected: [][2]int{{1, 2}, {1, 3}, {1, 4}}},
+		{name: "newline", input: "a\nb", expected: [][2]int{{1, 2}, {2, 1}, {2, 2}}},
+		{name: "wide", input: "a諸b", expected: [][2]int{{1, 2}, {1, 4}, {1, 5}}},
+		{name: "wide then newline", input: "諸\n葛", expected: [][2]int{{1, 3}, {2, 1}, {2, 3}}},
+		{name: "crlf", input: "a\r\nb", expected: [][2]int{{1, 2}, {2, 1}, {2, 1}, {2, 2}}},
+		{name: "cr", input: "a\rb", expected: [][2]int{{1, 2}, {2, 1}, {2, 2}}},
+		{name: "lf cr", input: "a\n\rb", expected: [][2]int{{1, 2}, {2, 1}, {3, 1}, {3, 2}}},
+		{name: "line separator", input: "諸\u2028b", expected: [][2]int{{1, 3}, {2, 1}, {2, 2}}},
+		{name: "paragraph separator", input: "a\u2029\u2029b", expected: [][2]int{{1, 2}, {2, 1}, {3, 1}, {3, 2}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := newLexer(test.input)
+			for _, expected := range test.expected {
+				line, col := l.line, l.col
+				l.next()
+				l.backup()
+				if l.line != line || l.col != col {
+					t.Fatalf(testTemplate, test.input, [2]int{line, col}, [2]int{l.line, l.col})
+				}
+				l.next()
+				if actual := [2]int{l.line, l.col}; actual != expected {
+					t.Errorf(testTemplate, test.input, expected, actual)
+				}
+			}
+		})
+	}
+}
+
+func Test_lexer_scanDuration(t *testing.T) {
+	type expected struct {
+		valid   bool
+		matched string
+		err     string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		expected expected
+	}{
+		{name: "hour", input: "1h", expected: expected{valid: true, matched: "1h"}},
+		{name: "minute", input: "1m", expected: expected{valid: true, matched: "1m"}},
+		{name: "second", input: "1s", expected: expected{valid: true, matched: "1s"}},
+		{name: "millisecond", input: "1ms", expected: expected{valid: true, matched: "1ms"}},
+		{name: "microsecond 1", input: "1us", expected: expected{valid: true, matched: "1us"}},
+		{name: "microsecond 2", input: "1μs", expected: expected{valid: true, matched: "1μs"}},
+		{name: "nanosecond", input: "1ns", expected: expected{valid: true, matched: "1ns"}},
+		{name: "sign 1", input: "+1h", expected: expected{valid: true, matched: "+1h"}},
+		{name: "sign 2", input: "-1h", expected: expected{valid: true, matched: "-1h"}},
+		{name: "float 1", input: "0.1h", expected: expected{valid: true, matched: "0.1h"}},
+		{name: "float 2", input: "1.1h", expected: expected{valid: true, matched: "1.1h"}},
+		{name: "float 3", input: ".1h", expected: expected{valid: true, matched: ".1h"}},
+		{name: "float 4", input: "1.h", expected: expected{valid: true, matched: "1.h"}},
+		{name: "mixed 1", input: "1h5000ns", expected: expected{valid: true, matched: "1h5000ns"}},
+		{name: "mixed 2", input: "5000ns1h", expected: expected{valid: true, matched: "5000ns1h"}},
+		{name: "mixed 3", input: "+1h5000ns", expected: expected{valid: true, matched: "+1h5000ns"}},
+		{name: "mixed 4", input: "-5000ns1h", expected: expected{valid: true, matched: "-5000ns1h"}},
+		{name: "mixed 5", input: "0.1h0.30m", expected: expected{valid: true, matched: "0.1h0.30m"}},
+		{name: "mixed 6", input: ".1m.30s", expected: expected{valid: true, matched: ".1m.30s"}},
+		{name: "mixed 7", input: "-1.1h.30m", expected: expected{valid: true, matched: "-1.1h.30m"}},
+		{name: "mixed 8", input: "+0.1h.30m", expected: expected{valid: true, matched: "+0.1h.30m"}},
+		{name: "mixed 9", input: "-.1h.30m", expected: expected{valid: true, matched: "-.1h.30m"}},
+		{name: "mixed 10", input: "+.1h.30m", expected: expected{valid: true, matched: "+.1h.30m"}},
+		{name: "mixed 11", input: "+1.h30.m", expected: expected{valid: true, matched: "+1.h30.m"}},
+		{name: "full", input: "1h30m15s3000ms4000us5000ns", expected: expected{valid: true, matched: "1h30m15s3000ms4000us5000ns"}},
+		{name: "duplicated 1", input: "1h1h", expected: expected{err: `duplicated unit "h" in duration`}},
+		{name: "duplicated 2", input: "1h30m1h", expected: expected{err: `duplicated unit "h" in duration`}},
+		{name: "duplicated 3", input: "1us1μs", expected: expected{err: `duplicated unit "μs" in duration`}},
+		{name: "duplicated 4", input: "1ms1ms", expected: expected{err: `duplicated unit "ms" in duration`}},
+		{name: "not duplicated", input: "1m1ms", expected: expected{valid: true, matched: "1m1ms"}},
+		{name: "longest match 1", input: "1h+30m", expected: expected{valid: true, matched: "1h"}},
+		{name: "longest match 2", input: "1h-30m", expected: expected{valid: true, matched: "1h"}},
+		{name: "longest match 3", input: "+1h+30m+15s+3000ms+4000us+5000ns", expected: expected{valid: true, matched: "+1h"}},
+		{name: "longest match 4", input: "-1h-30m-15s-3000ms-4000us-5000ns", expected: expected{valid: true, matched: "-1h"}},
+		{name: "longest match 5", input: "1hm", expected: expected{valid: true, matched: "1h"}},
+		{name: "longest match 6", input: "1hms", expected: expected{valid: true, matched: "1h"}},
+		{name: "longest match 7", input: "1hd", expected: expected{valid: true, matched: "1h"}},
+		{name: "longest match 8", input: "1h30m1d", expected: expected{valid: true, matched: "1h30m"}},
+		{name: "longest match 9", input: "1h30md", expected: expected{valid: true, matched: "1h30m"}},
+		{name: "longest match 10", input: "1h_", expected: expected{valid: true, matched: "1h"}},
+		{name: "multiple dot 1", input: "0..1h", expected: expected{err: `invalid number "0..1" in duration`}},
+		{name: "multiple dot 2", input: "..1h", expected: expected{err: `invalid number "..1" in duration`}},
+		{name: "multiple dot 3", input: "1h1.2.3m", expected: expected{err: `invalid number "1.2.3" in duration`}},
+		{name: "only dot", input: ".h", expected: expected{err: `invalid number "." in duration`}},
+		{name: "sign and dot", input: "-.s", expected: expected{err: `invalid number "-." in duration`}},
+		{name: "number 1", input: "1", expected: expected{valid: false, matched: ""}},
+		{name: "number 2", input: "+1", expected: expected{valid: false, matched: ""}},
+		{name: "number 3", input: "-1", expected: expected{valid: false, matched: ""}},
+		{name: "invalid unit 1", input: "365d", expected: expected{valid: false, matched: ""}},
+		{name: "invalid unit 4", input: "1d30m", expected: expected{valid: false, matched: ""}},
+		{name: "only unit 1", input: "h", expected: expected{valid: false, matched: ""}},
+		{name: "only unit 2", input: "ms", expected: expected{valid: false, matched: ""}},
+		{name: "only sign 1", input: "+", expected: expected{valid: false, matched: ""}},
+		{name: "only sign 2", input: "-", expected: expected{valid: false, matched: ""}},
+		{name: "sign and unit 1", input: "+ms", expected: expected{valid: false, matched: ""}},
+		{name: "sign and unit 2", input: "-ms", expected: expected{valid: false, matched: ""}},
+		{name: "unary operator 1", input: "- 1ms", expected: expected{valid: false, matched: ""}},
+		{name: "unary operator 2", input: "+ ms", expected: expected{valid: false, matched: ""}},
+		{name: "unit repeat 1", input: "msms", expected: expected{valid: false, matched: ""}},
+		{name: "empty", input: "", expected: expected{valid: false, matched: ""}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := &lexer{
+				input: test.input,
+				pos:   0,
+			}
+			actual, err := l.scanDuration()
+			if test.expected.err != "" {
+				if err == nil || err.Error() != test.expected.err {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected.valid, err)
+			}
+			if actual != test.expected.valid {
+				t.Errorf(testTemplate, test.input, test.expected.valid, actual)
+			}
+			if test.input[l.startPos:l.pos] != test.expected.matched {
+				t.Errorf(testTemplate, test.input, test.expected.matched, test.input[l.startPos:l.pos])
+			}
+		})
+	}
+}
+
+func Test_lexer_scanDuration_extendedUnits(t *testing.T) {
+	type expected struct {
+		valid   bool
+		matched string
+		err     string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		expected expected
+	}{
+		{name: "day", input: "7d", expected: expected{valid: true, matched: "7d"}},
+		{name: "week", input: "2w", expected: expected{valid: true, matched: "2w"}},
+		{name: "float day", input: "1.5d", expected: expected{valid: true, matched: "1.5d"}},
+		{name: "sign", input: "-1w", expected: expected{valid: true, matched: "-1w"}},
+		{name: "mixed 1", input: "1w2d", expected: expected{valid: true, matched: "1w2d"}},
+		{name: "mixed 2", input: "1d12h30m", expected: expected{valid: true, matched: "1d12h30m"}},
+		{name: "mixed 3", input: "12h1d", expected: expected{valid: true, matched: "12h1d"}},
+		{name: "duplicated", input: "1d2d", expected: expected{err: `duplicated unit "d" in duration`}},
+		{name: "longest match", input: "1d_", expected: expected{valid: true, matched: "1d"}},
+		{name: "invalid unit", input: "1y", expected: expected{valid: false, matched: ""}},
+		{name: "only unit", input: "d", expected: expected{valid: false, matched: ""}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := &lexer{
+				input:         test.input,
+				pos:           0,
+				extendedUnits: true,
+			}
+			actual, err := l.scanDuration()
+			if test.expected.err != "" {
+				if err == nil || err.Error() != test.expected.err {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected.valid, err)
+			}
+			if actual != test.expected.valid {
+				t.Errorf(testTemplate, test.input, test.expected.valid, actual)
+			}
+			if test.input[l.startPos:l.pos] != test.expected.matched {
+				t.Errorf(testTemplate, test.input, test.expected.matched, test.input[l.startPos:l.pos])
+			}
+		})
+	}
+}
+
+func Test_lex_literalSingleQuotes(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		literal  bool
+		expected []token
+	}{
+		{
+			name:  "single quotes with invalid escape",
+			input: `'C:\path'`,
+			expected: []token{
+				{typ: tokenError, v: "invalid escape sequence in string at 1:6", pos: 0, line: 1, col: 1},
+			},
+		},
+		{
+			name:    "literal single quotes with backslash",
+			input:   `'C:\path'`,
+			literal: true,
+			expected: []token{
+				{typ: tokenString, v: `'C:\path'`, pos: 0, line: 1, col: 1},
+				{typ: tokenEOF, v: "", pos: 9, line: 1, col: 10},
+			},
+		},
+		{
+			name:    "literal single quotes with escape like sequences",
+			input:   `'C:\new\dir'`,
+			literal: true,
+			expected: []token{
+				{typ: tokenString, v: `'C:\new\dir'`, pos: 0, line: 1, col: 1},
+				{typ: tokenEOF, v: "", pos: 12, line: 1, col: 13},
+			},
+		},
+		{
+			name:    "double quotes keep escapes",
+			input:   `"C:\path"`,
+			literal: true,
+			expected: []token{
+				{typ: tokenError, v: "invalid escape sequence in string at 1:6", pos: 0, line: 1, col: 1},
+			},
+		},
+		{
+			name:  "single quotes with escaped quote",
+			input: `'it\'s'`,
+			expected: []token{
+				{typ: tokenString, v: `'it\'s'`, pos: 0, line: 1, col: 1},
+				{typ: tokenEOF, v: "", pos: 7, line: 1, col: 8},
+			},
+		},
+		{
+			name:    "literal single quotes cannot escape quote",
+			input:   `'it\'s'`,
+			literal: true,
+			expected: []token{
+				{typ: tokenString, v: `'it\'`, pos: 0, line: 1, col: 1},
+				{typ: tokenIdent, v: "s", pos: 5, line: 1, col: 6},
+				{typ: tokenError, v: "unterminated quoted string at 1:8", pos: 6, line: 1, col: 7},
+			},
+		},
+		{
+			name:    "literal single quotes unterminated",
+			input:   `'a\`,
+			literal: true,
+			expected: []token{
+				{typ: tokenError, v: "unterminated quoted string at 1:4", pos: 0, line: 1, col: 1},
+			},
+		},
+		{
+			name:    "literal single quotes with newline",
+			input:   "'a\nb'",
+			literal: true,
+			expected: []token{
+				{typ: tokenError, v: "unterminated quoted string at 2:1", pos: 0, line: 1, col: 1},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := newLexer(test.input)
+			l.literalSingleQuotes = test.literal
+			var actual []token
+			for {
+				token := l.nextToken()
+				actual = append(actual, token)
+				if token.typ == tokenEOF || token.typ == tokenError {
+					break
+				}
+			}
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
+			}
+		})
+	}
+}
+
+func Test_lex_decimalComma(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		comma    bool
+		expected []token
+	}{
+		{
+			name:  "default decimal point",
+			input: `1.5`,
+			expected: []token{
+				{typ: tokenNumber, v: "1.5", pos: 0, line: 1, col: 1},
+				{typ: tokenEOF, v: "", pos: 3, line: 1, col: 4},
+			},
+		},
+		{
+			name:  "default comma",
+			input: `1,5`,
+			expected: []token{
+				{typ: tokenNumber, v: "1", pos: 0, line: 1, col: 1},
+				{typ: tokenComma, v: ",", pos: 1, line: 1, col: 2},
+				{typ: tokenNumber, v: "5", pos: 2, line: 1, col: 3},
+				{typ: tokenEOF, v: "", pos: 3, line: 1, col: 4},
+			},
+		},
+		{
+			name:  "default underscore grouping",
+			input: `1_000_000`,
+			expected: []token{
+				{typ: tokenNumber, v: "1_000_000", pos: 0, line: 1, col: 1},
+				{typ: tokenEOF, v: "", pos: 9, line: 1, col: 10},
+			},
+		},
+		{
+			name:  "decimal comma",
+			input: `1,5`,
+			comma: true,
+			expected: []token{
+				{typ: tokenNumber, v: "1.5", pos: 0, line: 1, col: 1},
+				{typ: tokenEOF, v: "", pos: 3, line: 1, col: 4},
+			},
+		},
+		{
+			name:  "grouped with decimal comma",
+			input: `-1.000.000,50`,
+			comma: true,
+			expected: []token{
+				{typ: tokenNumber, v: "-1000000.50", pos: 0, line: 1, col: 1},
+				{typ: tokenEOF, v: "", pos: 13, line: 1, col: 14},
+			},
+		},
+		{
+			name:  "grouped integer",
+			input: `12.345`,
+			comma: true,
+			expected: []token{
+				{typ: tokenNumber, v: "12345", pos: 0, line: 1, col: 1},
+				{typ: tokenEOF, v: "", pos: 6, line: 1, col: 7},
+			},
+		},
+		{
+			name:  "underscore grouping with decimal comma",
+			input: `1_000,5e3`,
+			comma: true,
+			expected: []token{
+				{typ: tokenNumber, v: "1_000.5e3", pos: 0, line: 1, col: 1},
+				{typ: tokenEOF, v: "", pos: 9, line: 1, col: 10},
+			},
+		},
+		{
+			name:  "list separator",
+			input: `(1, 2)`,
+			comma: true,
+			expected: []token{
+				{typ: tokenLparen, v: "(", pos: 0, line: 1, col: 1},
+				{typ: tokenNumber, v: "1", pos: 1, line: 1, col: 2},
+				{typ: tokenComma, v: ",", pos: 2, line: 1, col: 3},
+				{typ: tokenNumber, v: "2", pos: 4, line: 1, col: 5},
+				{typ: tokenRparen, v: ")", pos: 5, line: 1, col: 6},
+				{typ: tokenEOF, v: "", pos: 6, line: 1, col: 7},
+			},
+		},
+		{
+			name:  "base prefix",
+			input: `0x1.fp3`,
+			comma: true,
+			expected: []token{
+				{typ: tokenNumber, v: "0x1.fp3", pos: 0, line: 1, col: 1},
+				{typ: tokenEOF, v: "", pos: 7, line: 1, col: 8},
+			},
+		},
+		{
+			name:  "duration",
+			input: `1.5h`,
+			comma: true,
+			expected: []token{
+				{typ: tokenDuration, v: "1.5h", pos: 0, line: 1, col: 1},
+				{typ: tokenEOF, v: "", pos: 4, line: 1, col: 5},
+			},
+		},
+		{
+			name:  "short group",
+			input: `1.5`,
+			comma: true,
+			expected: []token{
+				{typ: tokenError, v: `invalid number "1.5" at 1:1`, pos: 0, line: 1, col: 1},
+			},
+		},
+		{
+			name:  "long first group",
+			input: `1000.000`,
+			comma: true,
+			expected: []token{
+				{typ: tokenError, v: `invalid number "1000." at 1:1`, pos: 0, line: 1, col: 1},
+			},
+		},
+		{
+			name:  "leading zero group",
+			input: `0.500`,
+			comma: true,
+			expected: []token{
+				{typ: tokenError, v: `invalid number "0." at 1:1`, pos: 0, line: 1, col: 1},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := newLexer(test.input)
+			l.decimalComma = test.comma
+			var actual []token
+			for {
+				token := l.nextToken()
+				actual = append(actual, token)
+				if token.typ == tokenEOF || token.typ == tokenError {
+					break
+				}
+			}
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
+			}
+		})
+	}
+}
+
+func Test_lex_identChars(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		chars    string
+		expected []token
+	}{
+		{
+			name:  "default",
+			input: `x-request-id`,
+			expected: []token{
+				{typ: tokenIdent, v: "x", pos: 0, line: 1, col: 1},
+				{typ: tokenNumber, v: "-", pos: 1, line: 1, col: 2},
+				{typ: tokenIdent, v: "request", pos: 2, line: 1, col: 3},
+				{typ: tokenNumber, v: "-", pos: 9, line: 1, col: 10},
+				{typ: tokenIdent, v: "id", pos: 10, line: 1, col: 11},
+				{typ: tokenEOF, v: "", pos: 12, line: 1, col: 13},
+			},
+		},
+		{
+			name:  "hyphen",
+			input: `x-request-id=="a"`,
+			chars: "-",
+			expected: []token{
+				{typ: tokenIdent, v: "x-request-id", pos: 0, line: 1, col: 1},
+				{typ: tokenEQ, v: "==", pos: 12, line: 1, col: 13},
+				{typ: tokenString, v: `"a"`, pos: 14, line: 1, col: 15},
+				{typ: tokenEOF, v: "", pos: 17, line: 1, col: 18},
+			},
+		},
+		{
+			name:  "colon",
+			input: `http:status>=500`,
+			chars: ":-",
+			expected: []token{
+				{typ: tokenIdent, v: "http:status", pos: 0, line: 1, col: 1},
+				{typ: tokenGTE, v: ">=", pos: 11, line: 1, col: 12},
+				{typ: tokenNumber, v: "500", pos: 13, line: 1, col: 14},
+				{typ: tokenEOF, v: "", pos: 16, line: 1, col: 17},
+			},
+		},
+		{
+			name:  "trailing",
+			input: `x- 1`,
+			chars: "-",
+			expected: []token{
+				{typ: tokenIdent, v: "x", pos: 0, line: 1, col: 1},
+				{typ: tokenNumber, v: "-", pos: 1, line: 1, col: 2},
+				{typ: tokenNumber, v: "1", pos: 3, line: 1, col: 4},
+				{typ: tokenEOF, v: "", pos: 4, line: 1, col: 5},
+			},
+		},
+		{
+			name:  "now offset",
+			input: `now-1h`,
+			chars: "-",
+			expected: []token{
+				{typ: tokenNow, v: "now-1h", pos: 0, line: 1, col: 1},
+				{typ: tokenEOF, v: "", pos: 6, line: 1, col: 7},
+			},
+		},
+		{
+			name:  "field offset",
+			input: `Start-1h`,
+			chars: "-",
+			expected: []token{
+				{typ: tokenIdent, v: "Start-1h", pos: 0, line: 1, col: 1},
+				{typ: tokenEOF, v: "", pos: 8, line: 1, col: 9},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := newLexer(test.input)
+			l.identChars = test.chars
+			var actual []token
+			for {
+				token := l.nextToken()
+				actual = append(actual, token)
+				if token.typ == tokenEOF || token.typ == tokenError {
+					break
+				}
+			}
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
+			}
+		})
+	}
+}
+
+func Test_lex_comments(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []token
+	}{
+		{
+			name:  "inline",
+			input: `HP /* hit points */ > 1`,
+			expected: []token{
+				{typ: tokenIdent, v: "HP", pos: 0, line: 1, col: 1},
+				{typ: tokenGT, v: ">", pos: 20, line: 1, col: 21},
+				{typ: tokenNumber, v: "1", pos: 22, line: 1, col: 23},
+				{typ: tokenEOF, v: "", pos: 23, line: 1, col: 24},
+			},
+		},
+		{
+			name:  "adjacent",
+			input: `HP/**/>1`,
+			expected: []token{
+				{typ: tokenIdent, v: "HP", pos: 0, line: 1, col: 1},
+				{typ: tokenGT, v: ">", pos: 6, line: 1, col: 7},
+				{typ: tokenNumber, v: "1", pos: 7, line: 1, col: 8},
+				{typ: tokenEOF, v: "", pos: 8, line: 1, col: 9},
+			},
+		},
+		{
+			name:  "multi-line",
+			input: "HP > 1 /* && \n MP > 2\r\n */ && 名前 == 'a'",
+			expected: []token{
+				{typ: tokenIdent, v: "HP", pos: 0, line: 1, col: 1},
+				{typ: tokenGT, v: ">", pos: 3, line: 1, col: 4},
+				{typ: tokenNumber, v: "1", pos: 5, line: 1, col: 6},
+				{typ: tokenAND, v: "&&", pos: 27, line: 3, col: 5},
+				{typ: tokenIdent, v: "名前", pos: 30, line: 3, col: 8},
+				{typ: tokenEQ, v: "==", pos: 37, line: 3, col: 13},
+				{typ: tokenString, v: "'a'", pos: 40, line: 3, col: 16},
+				{typ: tokenEOF, v: "", pos: 43, line: 3, col: 19},
+			},
+		},
+		{
+			name:  "nested",
+			input: `/* a /* b */ c */ HP`,
+			expected: []token{
+				{typ: tokenIdent, v: "HP", pos: 18, line: 1, col: 19},
+				{typ: tokenEOF, v: "", pos: 20, line: 1, col: 21},
+			},
+		},
+		{
+			name:  "in string",
+			input: `Path == "/*" /* x */`,
+			expected: []token{
+				{typ: tokenIdent, v: "Path", pos: 0, line: 1, col: 1},
+				{typ: tokenEQ, v: "==", pos: 5, line: 1, col: 6},
+				{typ: tokenString, v: `"/*"`, pos: 8, line: 1, col: 9},
+				{typ: tokenEOF, v: "", pos: 20, line: 1, col: 21},
+			},
+		},
+		{
+			name:  "unterminated",
+			input: "HP > 1\n  /* a */ /* b",
+			expected: []token{
+				{typ: tokenIdent, v: "HP", pos: 0, line: 1, col: 1},
+				{typ: tokenGT, v: ">", pos: 3, line: 1, col: 4},
+				{typ: tokenNumber, v: "1", pos: 5, line: 1, col: 6},
+				{typ: tokenError, v: "unterminated block comment at 2:11", pos: 17, line: 2, col: 11},
+			},
+		},
+		{
+			name:  "unterminated nested",
+			input: `/* a /* b */`,
+			expected: []token{
+				{typ: tokenError, v: "unterminated block comment at 1:1", pos: 0, line: 1, col: 1},
+			},
+		},
+		{
+			name:  "slash",
+			input: `HP / 2`,
+			expected: []token{
+				{typ: tokenIdent, v: "HP", pos: 0, line: 1, col: 1},
+				{typ: tokenError, v: "unexpected character U+002F '/' at 1:4", pos: 3, line: 1, col: 4},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := newLexer(test.input)
+			var actual []token
+			for {
+				token := l.nextToken()
+				actual = append(actual, token)
+				if token.typ == tokenEOF || token.typ == tokenError {
+					break
+				}
+			}
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
+			}
+		})
+	}
+}
+
+func Test_lex_time(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []token
+	}{
+		{
+			name:  "T separator",
+			input: `T>2025-01-01T12:00:00Z`,
+			expected: []token{
+				{typ: tokenIdent, v: "T", pos: 0, line: 1, col: 1},
+				{typ: tokenGT, v: ">", pos: 1, line: 1, col: 2},
+				{typ: tokenTime, v: "2025-01-01T12:00:00Z", pos: 2, line: 1, col: 3},
+				{typ: tokenEOF, v: "", pos: 22, line: 1, col: 23},
+			},
+		},
+		{
+			name:  "space separator",
+			input: `T > 2025-01-01 12:00:00.123456789+09:00 && A`,
+			expected: []token{
+				{typ: tokenIdent, v: "T", pos: 0, line: 1, col: 1},
+				{typ: tokenGT, v: ">", pos: 2, line: 1, col: 3},
+				{typ: tokenTime, v: "2025-01-01 12:00:00.123456789+09:00", pos: 4, line: 1, col: 5},
+				{typ: tokenAND, v: "&&", pos: 40, line: 1, col: 41},
+				{typ: tokenIdent, v: "A", pos: 43, line: 1, col: 44},
+				{typ: tokenEOF, v: "", pos: 44, line: 1, col: 45},
+			},
+		},
+		{
+			name:  "space separator without zone",
+			input: `2025-01-01 12:00:00`,
+			expected: []token{
+				{typ: tokenTime, v: "2025-01-01 12:00:00", pos: 0, line: 1, col: 1},
+				{typ: tokenEOF, v: "", pos: 19, line: 1, col: 20},
+			},
+		},
+		{
+			name:  "two spaces",
+			input: `2025-01-01  12:00:00`,
+			expected: []token{
+				{typ: tokenNumber, v: "2025", pos: 0, line: 1, col: 1},
+				{typ: tokenNumber, v: "-01", pos: 4, line: 1, col: 5},
+				{typ: tokenNumber, v: "-01", pos: 7, line: 1, col: 8},
+				{typ: tokenNumber, v: "12", pos: 12, line: 1, col: 13},
+				{typ: tokenError, v: "unexpected character U+003A ':' at 1:15", pos: 14, line: 1, col: 15},
+			},
+		},
+		{
+			name:  "date then number",
+			input: `2025-01-01 12`,
+			expected: []token{
+				{typ: tokenNumber, v: "2025", pos: 0, line: 1, col: 1},
+				{typ: tokenNumber, v: "-01", pos: 4, line: 1, col: 5},
+				{typ: tokenNumber, v: "-01", pos: 7, line: 1, col: 8},
+				{typ: tokenNumber, v: "12", pos: 11, line: 1, col: 12},
+				{typ: tokenEOF, v: "", pos: 13, line: 1, col: 14},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := newLexer(test.input)
+			var actual []token
+			for {
+				token := l.nextToken()
+				actual = append(actual, token)
+				if token.typ == tokenEOF || token.typ == tokenError {
+					break
+				}
+			}
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
+			}
+		})
+	}
+}
+
+func Test_lex_recoverUTF8(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []token
+	}{
+		{
+			name:  "two strings",
+			input: "A == \"x\xff\" || B == `\xfey`",
+			expected: []token{
+				{typ: tokenIdent, v: "A", pos: 0, line: 1, col: 1},
+				{typ: tokenEQ, v: "==", pos: 2, line: 1, col: 3},
+				{typ: tokenError, v: "invalid utf8 encoding in string at 1:8", pos: 7, line: 1, col: 8},
+				{typ: tokenString, v: "\"x\xff\"", pos: 5, line: 1, col: 6},
+				{typ: tokenOR, v: "||", pos: 10, line: 1, col: 11},
+				{typ: tokenIdent, v: "B", pos: 13, line: 1, col: 14},
+				{typ: tokenEQ, v: "==", pos: 15, line: 1, col: 16},
+				{typ: tokenError, v: "invalid utf8 encoding in raw string at 1:20", pos: 19, line: 1, col: 20},
+				{typ: tokenRawString, v: "`\xfey`", pos: 18, line: 1, col: 19},
+				{typ: tokenEOF, v: "", pos: 22, line: 1, col: 23},
+			},
+		},
+		{
+			name:  "two bytes in one string",
+			input: "'\xff\xfe'",
+			expected: []token{
+				{typ: tokenError, v: "invalid utf8 encoding in string at 1:2", pos: 1, line: 1, col: 2},
+				{typ: tokenError, v: "invalid utf8 encoding in string at 1:3", pos: 2, line: 1, col: 3},
+				{typ: tokenString, v: "'\xff\xfe'", pos: 0, line: 1, col: 1},
+				{typ: tokenEOF, v: "", pos: 4, line: 1, col: 5},
+			},
+		},
+		{
+			name:  "other error stops",
+			input: "\"\xff\" \"a",
+			expected: []token{
+				{typ: tokenError, v: "invalid utf8 encoding in string at 1:2", pos: 1, line: 1, col: 2},
+				{typ: tokenString, v: "\"\xff\"", pos: 0, line: 1, col: 1},
+				{typ: tokenError, v: "unterminated quoted string at 1:7", pos: 4, line: 1, col: 5},
+				{typ: tokenEOF, v: "", pos: 6, line: 1, col: 7},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := newLexer(test.input)
+			l.recoverUTF8 = true
+			var actual []token
+			for {
+				token := l.nextToken()
+				actual = append(actual, token)
+				if token.typ == tokenEOF {
+					break
+				}
+			}
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
 			}
 		})
 	}
@@ -0,0 +1,122 @@
+package filter
+
+// LiteralKind represents the kind of a literal value in an expression.
+type LiteralKind int
+
+const (
+	// LiteralString is a string value.
+	LiteralString LiteralKind = iota
+
+	// LiteralNumber is a number value.
+	LiteralNumber
+
+	// LiteralDuration is a duration value such as 1h30m.
+	LiteralDuration
+
+	// LiteralTime is a time value, or now with an optional offset such as now-1h.
+	LiteralTime
+
+	// LiteralBool is a boolean value.
+	LiteralBool
+
+	// LiteralRegex is a pattern of a regex operator.
+	LiteralRegex
+)
+
+// String returns a string representation of the literal kind.
+func (k LiteralKind) String() string {
+	switch k {
+	case LiteralString:
+		return "string"
+	case LiteralNumber:
+		return "number"
+	case LiteralDuration:
+		return "duration"
+	case LiteralTime:
+		return "time"
+	case LiteralBool:
+		return "bool"
+	case LiteralRegex:
+		return "regex"
+	default:
+		return "unknown"
+	}
+}
+
+// Literal is a value that a comparison of the expression compares a field against.
+type Literal struct {
+	Field    string      // field of the comparison
+	Operator string      // operator literal, e.g. "==", "containsany"
+	Value    string      // value as written, without quotes
+	Kind     LiteralKind // kind of the value
+}
+
+// Literals returns the values that the comparisons of the expression compare against,
+// in depth-first order, such as for building an index of the string constants a filter tests for.
+// Each value of a list is returned separately with the operator of the list, and chained
+// comparisons are returned in their desugared form. Comparisons against another field
+// with an offset, such as Deadline < Start + 1h, have no literal and are skipped.
+func (e *Expr) Literals() []Literal {
+	if e == nil || len(e.parser.nodes) == 0 {
+		return nil
+	}
+	return e.literals(e.root, nil)
+}
+
+// literals appends the literals of the node at index i and its children to lits.
+func (e *Expr) literals(i int, lits []Literal) []Literal {
+	n := e.parser.nodes[i]
+	switch n.typ {
+	case nodeBinary:
+		lits = e.literals(n.left, lits)
+		return e.literals(n.right, lits)
+	case nodeNOT:
+		return e.literals(n.left, lits)
+	case nodeBool:
+		return lits
+	}
+	if n.isOffset() {
+		return lits
+	}
+	start := len(lits)
+	if n.isList() {
+		for _, item := range n.items {
+			lits = append(lits, newLiteral(n, item))
+		}
+	} else {
+		lits = append(lits, newLiteral(n, n))
+	}
+	if e.parser.opts.decimalComma {
+		for k := start; k < len(lits); k++ {
+			if lits[k].Kind == LiteralNumber {
+				lits[k].Value = decimalComma(lits[k].Value)
+			}
+		}
+	}
+	return lits
+}
+
+// newLiteral describes the value of item, which is n itself or an item of its list.
+func newLiteral(n, item node) Literal {
+	lit := Literal{
+		Field:    n.ident.v,
+		Operator: n.op.typ.literal(),
+		Value:    item.writtenValue(),
+	}
+	switch item.val.typ {
+	case tokenNumber:
+		lit.Kind = LiteralNumber
+	case tokenDuration:
+		lit.Kind = LiteralDuration
+	case tokenTime, tokenNow:
+		lit.Kind = LiteralTime
+	case tokenBool:
+		lit.Kind = LiteralBool
+	default:
+		lit.Kind = LiteralString
+	}
+	if n.op.typ.isRegexOperatorType() {
+		lit.Kind = LiteralRegex
+	}
+	return lit
+}
@@ -0,0 +1,123 @@
+package filter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpr_Literals(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []Literal
+	}{
+		{
+			name:     "string",
+			input:    `Status == "active"`,
+			expected: []Literal{{Field: "Status", Operator: "==", Value: "active", Kind: LiteralString}},
+		},
+		{
+			name:  "kinds",
+			input: `HP > 0x10 && Uptime < 1h30m && Start >= 2025-01-01T00:00:00Z && Last > now-1h && Flag == true`,
+			expected: []Literal{
+				{Field: "HP", Operator: ">", Value: "0x10", Kind: LiteralNumber},
+				{Field: "Uptime", Operator: "<", Value: "1h30m", Kind: LiteralDuration},
+				{Field: "Start", Operator: ">=", Value: "2025-01-01T00:00:00Z", Kind: LiteralTime},
+				{Field: "Last", Operator: ">", Value: "now-1h", Kind: LiteralTime},
+				{Field: "Flag", Operator: "==", Value: "true", Kind: LiteralBool},
+			},
+		},
+		{
+			name:  "regex",
+			input: `Name =~* "^sl" || Path !~ ("a", "b")`,
+			expected: []Literal{
+				{Field: "Name", Operator: "=~*", Value: "^sl", Kind: LiteralRegex},
+				{Field: "Path", Operator: "!~", Value: "a", Kind: LiteralRegex},
+				{Field: "Path", Operator: "!~", Value: "b", Kind: LiteralRegex},
+			},
+		},
+		{
+			name:  "list",
+			input: `!(Tags containsany ("a", 1))`,
+			expected: []Literal{
+				{Field: "Tags", Operator: "containsany", Value: "a", Kind: LiteralString},
+				{Field: "Tags", Operator: "containsany", Value: "1", Kind: LiteralNumber},
+			},
+		},
+		{
+			name:  "chain",
+			input: `1 < HP <= 100`,
+			expected: []Literal{
+				{Field: "HP", Operator: ">", Value: "1", Kind: LiteralNumber},
+				{Field: "HP", Operator: "<=", Value: "100", Kind: LiteralNumber},
+			},
+		},
+		{
+			name:     "offset",
+			input:    `Deadline < Start + 1h && HP > 1`,
+			expected: []Literal{{Field: "HP", Operator: ">", Value: "1", Kind: LiteralNumber}},
+		},
+		{
+			name: "complex",
+			input: `
+				Class == "軍師" && Name =~ '^(諸葛亮|龐統|法正)' && Name != "" && (
+					BirthDate < '0190-01-01T00:00:00Z' && ActiveTimeBattleGauge >= '20s'
+				) && (
+					HitPoint > "50" && MagicPoint > 100 && LifePoint != 0
+				) && (
+					Magic >= 20 || !(Speed < 20)
+				)
+			`,
+			expected: []Literal{
+				{Field: "Class", Operator: "==", Value: "軍師", Kind: LiteralString},
+				{Field: "Name", Operator: "=~", Value: "^(諸葛亮|龐統|法正)", Kind: LiteralRegex},
+				{Field: "Name", Operator: "!=", Value: "", Kind: LiteralString},
+				{Field: "BirthDate", Operator: "<", Value: "0190-01-01T00:00:00Z", Kind: LiteralString},
+				{Field: "ActiveTimeBattleGauge", Operator: ">=", Value: "20s", Kind: LiteralString},
+				{Field: "HitPoint", Operator: ">", Value: "50", Kind: LiteralString},
+				{Field: "MagicPoint", Operator: ">", Value: "100", Kind: LiteralNumber},
+				{Field: "LifePoint", Operator: "!=", Value: "0", Kind: LiteralNumber},
+				{Field: "Magic", Operator: ">=", Value: "20", Kind: LiteralNumber},
+				{Field: "Speed", Operator: "<", Value: "20", Kind: LiteralNumber},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			if actual := expr.Literals(); !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestExpr_Literals_nil(t *testing.T) {
+	var e *Expr
+	if actual := e.Literals(); actual != nil {
+		t.Errorf(testTemplate, "nil", nil, actual)
+	}
+}
+
+func TestLiteralKind_String(t *testing.T) {
+	tests := []struct {
+		kind     LiteralKind
+		expected string
+	}{
+		{kind: LiteralString, expected: "string"},
+		{kind: LiteralNumber, expected: "number"},
+		{kind: LiteralDuration, expected: "duration"},
+		{kind: LiteralTime, expected: "time"},
+		{kind: LiteralBool, expected: "bool"},
+		{kind: LiteralRegex, expected: "regex"},
+		{kind: LiteralKind(-1), expected: "unknown"},
+	}
+	for _, test := range tests {
+		if actual := test.kind.String(); actual != test.expected {
+			t.Errorf(testTemplate, int(test.kind), test.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package filter
+
+import "time"
+
+// Metrics counts the work done by an evaluation.
+type Metrics struct {
+	Comparisons   int // comparisons evaluated, where a list counts as one comparison
+	FieldFetches  int // calls to GetField of the target, made once per field when fields are cached
+	RegexMatches  int // regex patterns matched against a value, one per pattern tried in a list
+	ShortCircuits int // && and || operators whose right operand was skipped
+}
+
+// EvalMetrics evaluates the expression against a target like Eval and also returns counts of
+// the work it did, such as for finding the filters that are expensive in production.
+// Comparisons of fields given to WithSkipFields are not counted. The metrics up to the error
+// are returned if an error occurs.
+func (e *Expr) EvalMetrics(t Target) (bool, Metrics, error) {
+	var cache map[string]any
+	if n := e.cacheSize(); n > 0 {
+		cache = make(map[string]any, n)
+	}
+	st := newState(cache, time.Now)
+	ok, err := e.evalRoot(t, st)
+	return ok, st.metrics, err
+}
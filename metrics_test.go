@@ -0,0 +1,52 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpr_EvalMetrics(t *testing.T) {
+	target := testTarget{"A": 1, "B": 2, "Name": "slime", "Tags": []string{"a", "b"}}
+	type expected struct {
+		val     bool
+		metrics Metrics
+		err     string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected expected
+	}{
+		{name: "and short-circuit", input: `A == 2 && B == 2`, expected: expected{val: false, metrics: Metrics{Comparisons: 1, FieldFetches: 1, ShortCircuits: 1}}},
+		{name: "and full", input: `A == 1 && B == 2`, expected: expected{val: true, metrics: Metrics{Comparisons: 2, FieldFetches: 2}}},
+		{name: "and strict", input: `A == 2 && B == 2`, opts: []Option{WithStrictEval()}, expected: expected{val: false, metrics: Metrics{Comparisons: 2, FieldFetches: 2}}},
+		{name: "or short-circuit", input: `A == 1 || B == 1`, expected: expected{val: true, metrics: Metrics{Comparisons: 1, FieldFetches: 1, ShortCircuits: 1}}},
+		{name: "cached field", input: `A > 0 && A < 2 && A != 3`, expected: expected{val: true, metrics: Metrics{Comparisons: 3, FieldFetches: 1}}},
+		{name: "regex", input: `Name =~ "^s" && Name !~ "x$"`, expected: expected{val: true, metrics: Metrics{Comparisons: 2, FieldFetches: 1, RegexMatches: 2}}},
+		{name: "regex list", input: `Name =~ ("^x", "^y", "^s")`, expected: expected{val: true, metrics: Metrics{Comparisons: 1, FieldFetches: 1, RegexMatches: 3}}},
+		{name: "list", input: `Tags containsany ("x", "b")`, expected: expected{val: true, metrics: Metrics{Comparisons: 1, FieldFetches: 1}}},
+		{name: "skipped field", input: `A == 1 && B == 3`, opts: []Option{WithSkipFields("B")}, expected: expected{val: true, metrics: Metrics{Comparisons: 1, FieldFetches: 1}}},
+		{name: "three-valued", input: `Missing == 1 || A == 2 && B == 2`, opts: []Option{WithThreeValuedLogic()}, expected: expected{val: false, metrics: Metrics{Comparisons: 2, FieldFetches: 2, ShortCircuits: 1}}},
+		{name: "error", input: `A == 1 && Missing == 1`, expected: expected{metrics: Metrics{Comparisons: 2, FieldFetches: 2}, err: `field not found: "Missing"`}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			val, metrics, err := expr.EvalMetrics(target)
+			if test.expected.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expected.err) {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+			} else if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			if actual := (expected{val: val, metrics: metrics, err: test.expected.err}); actual != test.expected {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package filter
 
 import (
 	"regexp"
+	"strings"
 	"time"
 )
 
@@ -12,6 +13,7 @@ const (
 	nodeBinary     nodeType = iota // binary operator node type
 	nodeNOT                        // logical NOT node type
 	nodeComparison                 // comparison node type
+	nodeBool                       // boolean literal node type
 )
 
 // String returns a string representation of the node type.
@@ -23,10 +25,70 @@ func (t nodeType) String() string {
 		return "not node"
 	case nodeComparison:
 		return "comparison node"
+	case nodeBool:
+		return "bool node"
 	}
 	return ""
 }
 
+// transform represents a function applied to a field before comparison.
+type transform int
+
+const (
+	transformNone  transform = iota // no function
+	transformLower                  // lower(Field)
+	transformUpper                  // upper(Field)
+	transformTrim                   // trim(Field)
+	transformLen                    // len(Field)
+)
+
+// String returns the function name of the transform.
+func (f transform) String() string {
+	switch f {
+	case transformLower:
+		return "lower"
+	case transformUpper:
+		return "upper"
+	case transformTrim:
+		return "trim"
+	case transformLen:
+		return "len"
+	default:
+		return ""
+	}
+}
+
+// lookupTransform returns the transform for a function name.
+func lookupTransform(name string) (transform, bool) {
+	switch name {
+	case "lower":
+		return transformLower, true
+	case "upper":
+		return transformUpper, true
+	case "trim":
+		return transformTrim, true
+	case "len":
+		return transformLen, true
+	default:
+		return transformNone, false
+	}
+}
+
+// apply applies the string function of the transform to s. len is not applied to strings
+// but evaluated as a number by evalLen.
+func (f transform) apply(s string) string {
+	switch f {
+	case transformLower:
+		return strings.ToLower(s)
+	case transformUpper:
+		return strings.ToUpper(s)
+	case transformTrim:
+		return strings.TrimSpace(s)
+	default:
+		return s
+	}
+}
+
 // node represents a node in the expression tree.
 type node struct {
 	// Node metadata
@@ -37,18 +99,68 @@ type node struct {
 	op    token          // operator token for binary and comparison nodes
 	val   token          // value token for literal nodes
 	re    *regexp.Regexp // regular expression for pattern matching
+	items []node         // values of list operators, each compared with ==
+	fn    transform      // string function applied to the field
+	ref   token          // field on the right-hand side of an offset comparison such as Start + 1h
+	fold  bool           // compare strings with == and != ignoring case
+	skip  bool           // always hold, for fields given to WithSkipFields
+	arith token          // arithmetic operator applied to the field, such as % in ID % 10
+	arg   token          // right operand of the arithmetic operator, such as 10 in ID % 10
+	key   token          // key of a map field, such as "a" in Headers["a"], unquoted
+	unit  time.Duration  // duration unit of integer values of the field, for WithFieldDurationUnit
 
 	// Cached values
 	num  float64       // cached numeric value
-	dur  time.Duration // cached duration value
+	int  int64         // cached signed integer value
+	uint uint64        // cached unsigned integer value
+	dur  time.Duration // cached duration value, or offset of a now literal
 	time time.Time     // cached time value
+	bits uint64        // cached operand of the arithmetic operator in two's complement
 
 	// Cached flags
 	hasNum  bool // indicates if num is cached
+	hasInt  bool // indicates if int is cached
+	hasUint bool // indicates if uint is cached
 	hasDur  bool // indicates if dur is cached
 	hasTime bool // indicates if time is cached
 }
 
+// writtenValue returns the value of the node as written, without the (?i) prefix
+// that is added to the patterns of case-insensitive regex operators.
+func (n node) writtenValue() string {
+	if n.op.typ.isCaseInsensitiveRegexOperatorType() {
+		return strings.TrimPrefix(n.val.v, "(?i)")
+	}
+	return n.val.v
+}
+
+// isList reports whether the node compares against a parenthesized list of values.
+func (n node) isList() bool {
+	return n.typ == nodeComparison && n.val.typ == tokenLparen
+}
+
+// isOffset reports whether the node compares against another field with a duration offset.
+func (n node) isOffset() bool {
+	return n.typ == nodeComparison && n.ref.typ == tokenIdent
+}
+
+// isArith reports whether the node compares the result of an arithmetic operator on its field,
+// such as ID % 10 or Perms & 0x4.
+func (n node) isArith() bool {
+	return n.typ == nodeComparison && n.arith.typ.isArithOperatorType()
+}
+
+// isIndex reports whether the node compares the value of a key of a map field, such as Headers["a"],
+// or an element of a slice or array field, such as Coords[0].
+func (n node) isIndex() bool {
+	return n.typ == nodeComparison && (n.key.typ.isStringType() || n.key.typ == tokenNumber)
+}
+
+// isTrue reports whether the node is the boolean literal true, ignoring case.
+func (n node) isTrue() bool {
+	return n.typ == nodeBool && strings.EqualFold(n.val.v, "true")
+}
+
 // newNodeBinary creates a new binary expression node.
 func newNodeBinary(p *parser, left int, op token, right int) int {
 	node := node{
@@ -72,6 +184,16 @@ func newNodeNOT(p *parser, child int, op token) int {
 	return len(p.nodes) - 1
 }
 
+// newNodeBool creates a new boolean literal node, which holds regardless of the target if val is true.
+func newNodeBool(p *parser, val token) int {
+	node := node{
+		typ: nodeBool,
+		val: val,
+	}
+	p.nodes = append(p.nodes, node)
+	return len(p.nodes) - 1
+}
+
 // newNodeComparison creates a new comparison expression node.
 func newNodeComparison(p *parser, ident token, op token, val token) int {
 	node := node{
@@ -79,6 +201,7 @@ func newNodeComparison(p *parser, ident token, op token, val token) int {
 		ident: ident,
 		op:    op,
 		val:   val,
+		skip:  p.opts.hasField(p.opts.skip, ident.v),
 	}
 	p.nodes = append(p.nodes, node)
 	return len(p.nodes) - 1
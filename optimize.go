@@ -0,0 +1,227 @@
+package filter
+
+// Optimize returns a simplified copy of the expression.
+// It removes double negation, applies De Morgan's laws where it reduces nodes,
+// and removes duplicated operands under the same AND / OR chain.
+// Evaluation results, short-circuit behavior, and the order of errors are preserved,
+// since every rewrite keeps the left-to-right evaluation order of the remaining operands.
+func Optimize(e *Expr) *Expr {
+	return optimize(e, false)
+}
+
+// Simplify returns a simplified copy of the expression like Optimize, and also applies the
+// absorption laws to structurally equal operands: A || (A && B) becomes A, and A && (A || B) becomes A.
+// An operand is absorbed only if an earlier operand of the same chain equals the leading operands
+// of its own chain, because then evaluation never gets past them, and B could not have failed.
+// So (A && B) || A and A || (B && A) are kept. Neither law is applied under
+// WithStrictEval or WithThreeValuedLogic, where B could still be evaluated.
+func (e *Expr) Simplify() *Expr {
+	if e == nil {
+		return nil
+	}
+	return optimize(e, !e.parser.opts.strict && !e.parser.opts.threeValued)
+}
+
+// optimize returns an optimized copy of the expression, also applying the absorption laws if absorb is true.
+func optimize(e *Expr, absorb bool) *Expr {
+	if e == nil || len(e.parser.nodes) == 0 {
+		return e
+	}
+	o := optimizer{
+		src:    e.parser.nodes,
+		dst:    make([]node, 0, len(e.parser.nodes)),
+		absorb: absorb,
+	}
+	root := o.optimize(e.root)
+	nodes := make([]node, 0, len(o.dst))
+	nodes, root = compact(o.dst, root, nodes)
+	p := e.parser
+	p.nodes = nodes
+	return &Expr{
+		parser: p,
+		root:   root,
+	}
+}
+
+// optimizer rewrites the nodes of src into dst.
+type optimizer struct {
+	src    []node // nodes of the original expression
+	dst    []node // nodes of the optimized expression, may contain unreachable nodes
+	absorb bool   // apply the absorption laws
+}
+
+// optimize rewrites the node at index i of src and returns its index in dst.
+func (o *optimizer) optimize(i int) int {
+	n := o.src[i]
+	switch n.typ {
+	case nodeNOT:
+		child := o.optimize(n.left)
+		if o.dst[child].typ == nodeNOT {
+			return o.dst[child].left
+		}
+		return o.push(node{typ: nodeNOT, left: child, op: n.op})
+	case nodeBinary:
+		var operands []int
+		for _, j := range o.flatten(i, n.op.typ, nil) {
+			k := o.optimize(j)
+			if !o.contains(operands, k) && !o.absorbed(operands, k, n.op.typ) {
+				operands = append(operands, k)
+			}
+		}
+		return o.chain(operands, n.op)
+	default:
+		return o.push(n)
+	}
+}
+
+// flatten collects the operands of a chain of the same logical operator in evaluation order.
+// AND / OR are associative including short-circuit behavior, so grouping does not matter.
+func (o *optimizer) flatten(i int, typ tokenType, operands []int) []int {
+	n := o.src[i]
+	if n.typ != nodeBinary || n.op.typ != typ {
+		return append(operands, i)
+	}
+	operands = o.flatten(n.left, typ, operands)
+	return o.flatten(n.right, typ, operands)
+}
+
+// contains reports whether the operands contain a node equal to the node at index i of dst.
+// A repeated operand is redundant because evaluation only reaches it when the
+// earlier equal operand did not short-circuit nor fail.
+func (o *optimizer) contains(operands []int, i int) bool {
+	for _, j := range operands {
+		if equalNodes(o.dst, j, o.dst, i, sameLiteral) {
+			return true
+		}
+	}
+	return false
+}
+
+// absorbed reports whether the node at index i of dst is a chain of the dual operator of typ
+// whose leading operands, such as A or A && B in A && B && C, equal one of the operands,
+// so that it cannot change the result of the chain of typ: evaluation only reaches it when
+// that operand did not short-circuit, and then its leading operands short-circuit it in turn.
+func (o *optimizer) absorbed(operands []int, i int, typ tokenType) bool {
+	if !o.absorb {
+		return false
+	}
+	n := o.dst[i]
+	if n.typ != nodeBinary || n.op.typ == typ {
+		return false
+	}
+	for j := n.left; ; j = o.dst[j].left {
+		if o.contains(operands, j) {
+			return true
+		}
+		if o.dst[j].typ != nodeBinary || o.dst[j].op.typ != n.op.typ {
+			return false
+		}
+	}
+}
+
+// chain builds a left-associative chain of the operands in dst.
+// Runs of two or more negated operands are merged by De Morgan's laws:
+// !A && !B becomes !(A || B) and !A || !B becomes !(A && B).
+func (o *optimizer) chain(operands []int, op token) int {
+	var merged []int
+	for start := 0; start < len(operands); {
+		end := start
+		for end < len(operands) && o.dst[operands[end]].typ == nodeNOT {
+			end++
+		}
+		if end-start < 2 {
+			merged = append(merged, operands[start])
+			start++
+			continue
+		}
+		children := make([]int, 0, end-start)
+		for _, j := range operands[start:end] {
+			children = append(children, o.dst[j].left)
+		}
+		not := o.dst[operands[start]].op
+		merged = append(merged, o.push(node{typ: nodeNOT, left: o.chain(children, flip(op)), op: not}))
+		start = end
+	}
+	left := merged[0]
+	for _, right := range merged[1:] {
+		left = o.push(node{typ: nodeBinary, left: left, right: right, op: op})
+	}
+	return left
+}
+
+// push appends a node to dst and returns its index.
+func (o *optimizer) push(n node) int {
+	o.dst = append(o.dst, n)
+	return len(o.dst) - 1
+}
+
+// flip returns the dual logical operator token of op.
+func flip(op token) token {
+	switch op.typ {
+	case tokenAND:
+		op.typ = tokenOR
+	case tokenOR:
+		op.typ = tokenAND
+	}
+	op.v = op.typ.literal()
+	return op
+}
+
+// equalNodes reports whether the subtree at index i of a is structurally equal to the subtree at index j of b,
+// where comparisons with the same operator are compared by same.
+func equalNodes(a []node, i int, b []node, j int, same func(x, y node) bool) bool {
+	x, y := a[i], b[j]
+	if x.typ != y.typ || x.op.typ != y.op.typ {
+		return false
+	}
+	switch x.typ {
+	case nodeBinary:
+		return equalNodes(a, x.left, b, y.left, same) && equalNodes(a, x.right, b, y.right, same)
+	case nodeNOT:
+		return equalNodes(a, x.left, b, y.left, same)
+	case nodeComparison:
+		if len(x.items) != len(y.items) {
+			return false
+		}
+		for k := range x.items {
+			if !equalNodes(x.items, k, y.items, k, same) {
+				return false
+			}
+		}
+		return x.ident.v == y.ident.v && x.fn == y.fn && x.ref.v == y.ref.v && x.arith.typ == y.arith.typ && x.bits == y.bits && sameKey(x, y) && same(x, y)
+	case nodeBool:
+		return x.isTrue() == y.isTrue()
+	default:
+		return false
+	}
+}
+
+// sameKey reports whether two comparisons read the same key of a map field, or both read no key.
+// Integer keys are compared by value, so [0x10] and [16] are the same key.
+func sameKey(x, y node) bool {
+	if x.key.typ == tokenNumber && y.key.typ == tokenNumber {
+		i, _ := parseInt(x.key.v)
+		j, _ := parseInt(y.key.v)
+		return i == j
+	}
+	return x.isIndex() == y.isIndex() && x.key.typ.isStringType() == y.key.typ.isStringType() && x.key.v == y.key.v
+}
+
+// sameLiteral reports whether two comparisons have the same value written the same way.
+func sameLiteral(x, y node) bool {
+	return x.val.typ == y.val.typ && x.val.v == y.val.v
+}
+
+// compact copies the nodes reachable from index i of src into dst.
+func compact(src []node, i int, dst []node) ([]node, int) {
+	n := src[i]
+	switch n.typ {
+	case nodeBinary:
+		dst, n.left = compact(src, n.left, dst)
+		dst, n.right = compact(src, n.right, dst)
+	case nodeNOT:
+		dst, n.left = compact(src, n.left, dst)
+	}
+	dst = append(dst, n)
+	return dst, len(dst) - 1
+}
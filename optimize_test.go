@@ -0,0 +1,365 @@
+package filter
+
+import (
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	type expected struct {
+		repr  string
+		nodes int
+	}
+	tests := []struct {
+		name     string
+		input    string
+		expected expected
+	}{
+		{
+			name:  "no change",
+			input: `A>1 && B=="x"`,
+			expected: expected{
+				repr:  `((A > 1) && (B == "x"))`,
+				nodes: 3,
+			},
+		},
+		{
+			name:  "double not",
+			input: `!(!(A>1))`,
+			expected: expected{
+				repr:  `(A > 1)`,
+				nodes: 1,
+			},
+		},
+		{
+			name:  "triple not",
+			input: `!(!(!(A>1)))`,
+			expected: expected{
+				repr:  `(! (A > 1))`,
+				nodes: 2,
+			},
+		},
+		{
+			name:  "double not in binary",
+			input: `!(!(A>1)) || B=="x"`,
+			expected: expected{
+				repr:  `((A > 1) || (B == "x"))`,
+				nodes: 3,
+			},
+		},
+		{
+			name:  "dedupe and",
+			input: `(A>1) && (A>1)`,
+			expected: expected{
+				repr:  `(A > 1)`,
+				nodes: 1,
+			},
+		},
+		{
+			name:  "dedupe or",
+			input: `A>1 || B=="x" || A>1`,
+			expected: expected{
+				repr:  `((A > 1) || (B == "x"))`,
+				nodes: 3,
+			},
+		},
+		{
+			name:  "dedupe across grouping",
+			input: `A>1 && (B=="x" && A>1)`,
+			expected: expected{
+				repr:  `((A > 1) && (B == "x"))`,
+				nodes: 3,
+			},
+		},
+		{
+			name:  "dedupe subtree",
+			input: `(A>1 && B=="x") || (A>1 && B=="x")`,
+			expected: expected{
+				repr:  `((A > 1) && (B == "x"))`,
+				nodes: 3,
+			},
+		},
+		{
+			name:  "dedupe list",
+			input: `T containsany ("a", "b") && T containsany ("a", "b")`,
+			expected: expected{
+				repr:  `(T containsany ("a", "b"))`,
+				nodes: 1,
+			},
+		},
+		{
+			name:  "no dedupe different list",
+			input: `T containsany ("a", "b") && T containsany ("a") && T containsall ("a")`,
+			expected: expected{
+				repr:  `(((T containsany ("a", "b")) && (T containsany ("a"))) && (T containsall ("a")))`,
+				nodes: 5,
+			},
+		},
+		{
+			name:  "no dedupe different value type",
+			input: `A==1 && A=="1"`,
+			expected: expected{
+				repr:  `((A == 1) && (A == 1))`,
+				nodes: 3,
+			},
+		},
+		{
+			name:  "de morgan and",
+			input: `!(A>1) && !(B=="x")`,
+			expected: expected{
+				repr:  `(! ((A > 1) || (B == "x")))`,
+				nodes: 4,
+			},
+		},
+		{
+			name:  "de morgan or",
+			input: `!(A>1) || !(B=="x") || !(C==true)`,
+			expected: expected{
+				repr:  `(! (((A > 1) && (B == "x")) && (C == true)))`,
+				nodes: 6,
+			},
+		},
+		{
+			name:  "de morgan run",
+			input: `C==true && !(A>1) && !(B=="x")`,
+			expected: expected{
+				repr:  `((C == true) && (! ((A > 1) || (B == "x"))))`,
+				nodes: 6,
+			},
+		},
+		{
+			name:  "de morgan single not",
+			input: `!(A>1) && B=="x" && !(C==true)`,
+			expected: expected{
+				repr:  `(((! (A > 1)) && (B == "x")) && (! (C == true)))`,
+				nodes: 7,
+			},
+		},
+		{
+			name:  "de morgan under not",
+			input: `!(!(A>1) && !(B=="x"))`,
+			expected: expected{
+				repr:  `((A > 1) || (B == "x"))`,
+				nodes: 3,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, "", err)
+			}
+			optimized := Optimize(expr)
+			if actual := repr(optimized); actual != test.expected.repr {
+				t.Errorf(testTemplate, test.input, test.expected.repr, actual)
+			}
+			if actual := len(optimized.parser.nodes); actual != test.expected.nodes {
+				t.Errorf(testTemplate, test.input, test.expected.nodes, actual)
+			}
+		})
+	}
+}
+
+func TestOptimize_semantics(t *testing.T) {
+	inputs := []string{
+		`!(!(A>1))`,
+		`A>1 && A>1`,
+		`A>1 || B=="x" || A>1`,
+		`A>1 && (B=="x" && A>1)`,
+		`(A>1 && B=="x") || (A>1 && B=="x")`,
+		`!(A>1) && !(B=="x")`,
+		`!(A>1) || !(B=="x") || !(C==true)`,
+		`C==true && !(A>1) && !(B=="x")`,
+		`!(!(A>1) && !(B=="x"))`,
+		`!(A>1) && B=="x" && !(C==true) && !(A>1)`,
+		`(A>=1 || !(B!="y")) && (A>=1 || !(B!="y")) && !(!(C==false))`,
+		`B=~"^x" && !(A<2) && !(B=~"^x")`,
+	}
+	var targets []testTarget
+	for _, a := range []any{0, 1, 2, nil} {
+		for _, b := range []any{"x", "y", nil} {
+			for _, c := range []any{true, false, nil} {
+				target := testTarget{}
+				if a != nil {
+					target["A"] = a
+				}
+				if b != nil {
+					target["B"] = b
+				}
+				if c != nil {
+					target["C"] = c
+				}
+				targets = append(targets, target)
+			}
+		}
+	}
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			expr, err := Parse(input)
+			if err != nil {
+				t.Fatalf(testTemplate, input, "", err)
+			}
+			optimized := Optimize(expr)
+			for _, target := range targets {
+				expected, expectedErr := expr.Eval(target)
+				actual, actualErr := optimized.Eval(target)
+				if expected != actual {
+					t.Errorf(testTemplate, target, expected, actual)
+				}
+				if (expectedErr == nil) != (actualErr == nil) ||
+					(expectedErr != nil && expectedErr.Error() != actualErr.Error()) {
+					t.Errorf(testTemplate, target, expectedErr, actualErr)
+				}
+			}
+		})
+	}
+}
+
+func TestOptimize_nil(t *testing.T) {
+	if actual := Optimize(nil); actual != nil {
+		t.Errorf("expected %v, actual %v", nil, actual)
+	}
+}
+
+func TestExpr_Simplify(t *testing.T) {
+	type expected struct {
+		repr  string
+		nodes int
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected expected
+	}{
+		{
+			name:     "absorb or",
+			input:    `A>1 || (A>1 && B=="x")`,
+			expected: expected{repr: `(A > 1)`, nodes: 1},
+		},
+		{
+			name:     "absorb and",
+			input:    `A>1 && (A>1 || B=="x")`,
+			expected: expected{repr: `(A > 1)`, nodes: 1},
+		},
+		{
+			name:     "absorb longer chain",
+			input:    `A>1 || C==true || (C==true && B=="x" && A<0)`,
+			expected: expected{repr: `((A > 1) || (C == true))`, nodes: 3},
+		},
+		{
+			name:     "absorb subtree",
+			input:    `(A>1 && B=="x") || ((A>1 && B=="x") && C==true)`,
+			expected: expected{repr: `((A > 1) && (B == "x"))`, nodes: 3},
+		},
+		{
+			name:     "absorb nested",
+			input:    `C==true && (A>1 || (A>1 && B=="x"))`,
+			expected: expected{repr: `((C == true) && (A > 1))`, nodes: 3},
+		},
+		{
+			name:     "idempotence",
+			input:    `A>1 || A>1 || !(!(A>1))`,
+			expected: expected{repr: `(A > 1)`, nodes: 1},
+		},
+		{
+			name:     "absorber after",
+			input:    `(A>1 && B=="x") || A>1`,
+			expected: expected{repr: `(((A > 1) && (B == "x")) || (A > 1))`, nodes: 5},
+		},
+		{
+			name:     "absorber not first",
+			input:    `A>1 || (B=="x" && A>1)`,
+			expected: expected{repr: `((A > 1) || ((B == "x") && (A > 1)))`, nodes: 5},
+		},
+		{
+			name:     "strict",
+			input:    `A>1 || (A>1 && B=="x")`,
+			opts:     []Option{WithStrictEval()},
+			expected: expected{repr: `((A > 1) || ((A > 1) && (B == "x")))`, nodes: 5},
+		},
+		{
+			name:     "three-valued",
+			input:    `A>1 && (A>1 || B=="x")`,
+			opts:     []Option{WithThreeValuedLogic()},
+			expected: expected{repr: `((A > 1) && ((A > 1) || (B == "x")))`, nodes: 5},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, "", err)
+			}
+			simplified := expr.Simplify()
+			if actual := repr(simplified); actual != test.expected.repr {
+				t.Errorf(testTemplate, test.input, test.expected.repr, actual)
+			}
+			if actual := len(simplified.parser.nodes); actual != test.expected.nodes {
+				t.Errorf(testTemplate, test.input, test.expected.nodes, actual)
+			}
+		})
+	}
+}
+
+func TestExpr_Simplify_semantics(t *testing.T) {
+	inputs := []string{
+		`A>1 || (A>1 && B=="x")`,
+		`A>1 && (A>1 || B=="x")`,
+		`A>1 || C==true || (C==true && B=="x" && A<0)`,
+		`(A>1 && B=="x") || ((A>1 && B=="x") && C==true)`,
+		`C==true && (A>1 || (A>1 && B=="x"))`,
+		`!(A>1) || (!(A>1) && B=="x")`,
+		`(A>1 && B=="x") || A>1`,
+		`A>1 || (B=="x" && A>1)`,
+		`!(A>1 || (A>1 && B=="x")) && (C==true || (C==true && B=="y"))`,
+	}
+	var targets []testTarget
+	for _, a := range []any{0, 1, 2, "s", nil} {
+		for _, b := range []any{"x", "y", 1, nil} {
+			for _, c := range []any{true, false, nil} {
+				target := testTarget{}
+				if a != nil {
+					target["A"] = a
+				}
+				if b != nil {
+					target["B"] = b
+				}
+				if c != nil {
+					target["C"] = c
+				}
+				targets = append(targets, target)
+			}
+		}
+	}
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			expr, err := Parse(input)
+			if err != nil {
+				t.Fatalf(testTemplate, input, "", err)
+			}
+			simplified := expr.Simplify()
+			if len(simplified.parser.nodes) > len(expr.parser.nodes) {
+				t.Errorf(testTemplate, input, len(expr.parser.nodes), len(simplified.parser.nodes))
+			}
+			for _, target := range targets {
+				expected, expectedErr := expr.Eval(target)
+				actual, actualErr := simplified.Eval(target)
+				if expected != actual {
+					t.Errorf(testTemplate, target, expected, actual)
+				}
+				if (expectedErr == nil) != (actualErr == nil) ||
+					(expectedErr != nil && expectedErr.Error() != actualErr.Error()) {
+					t.Errorf(testTemplate, target, expectedErr, actualErr)
+				}
+			}
+		})
+	}
+}
+
+func TestExpr_Simplify_nil(t *testing.T) {
+	var e *Expr
+	if actual := e.Simplify(); actual != nil {
+		t.Errorf("expected %v, actual %v", nil, actual)
+	}
+}
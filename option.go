@@ -0,0 +1,450 @@
+package filter
+
+import (
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Option configures the behavior of an expression.
+type Option func(*options)
+
+// options holds the configuration of an expression.
+type options struct {
+	strict      bool                     // evaluate every operand of logical operators
+	threeValued bool                     // treat comparisons of missing fields as unknown
+	opAsFalse   bool                     // treat operators that do not apply to the type of a field as false
+	normalize   bool                     // normalize string operands of equality operators
+	form        norm.Form                // unicode normalization form
+	coerce      bool                     // compare numeric strings as numbers
+	truthy      bool                     // compare integers and strings with boolean literals by truthiness
+	runes       bool                     // compare rune and byte fields with strings by code point
+	epsilon     float64                  // tolerance of numerical equality
+	tolerance   float64                  // tolerance of the ~= operator
+	fold        bool                     // lowercase identifiers
+	noRegex     bool                     // reject regex operators
+	ordering    bool                     // allow ordering operators on strings
+	collator    *collator                // locale-aware string ordering, nil for byte order
+	maxTokens   int                      // maximum number of tokens, unlimited if 0 or less
+	maxNodes    int                      // maximum number of nodes, unlimited if 0 or less
+	maxMatchLen int                      // maximum length of values matched against regexes, unlimited if 0 or less
+	aliases     map[string]Operator      // words accepted as comparison operators
+	identChars  string                   // characters accepted within identifiers besides letters, digits and _
+	subFilters  map[string]*Expr         // expressions referenced by name as operands
+	ignoreCase  []string                 // fields compared with == and != ignoring case
+	foldStrings bool                     // compare all string values with == and != ignoring case
+	skip        []string                 // fields whose comparisons always hold
+	units       map[string]time.Duration // duration units of integer fields
+
+	extendedUnits       bool // accept d and w duration units
+	decimalComma        bool // read , as the decimal point and . as grouping in numbers
+	contradictions      bool // reject && of number comparisons that cannot all hold
+	emptyRegex          bool // accept empty regex patterns, which match every string
+	floatVerb           byte // format of non-integer numbers written by String, 0 for as written
+	floatPrec           int  // precision of non-integer numbers written by String
+	byteLength          bool // count the length of strings in bytes rather than runes
+	literalSingleQuotes bool // treat single-quoted strings literally without escapes
+}
+
+// WithStrictEval disables short-circuit evaluation of && and ||.
+// Both operands are always evaluated, so errors in branches that would
+// otherwise be skipped are reported. The first error encountered is returned.
+func WithStrictEval() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// WithThreeValuedLogic evaluates comparisons of missing fields as unknown rather than as errors,
+// and combines them with the Kleene logic of SQL: false && unknown is false, true || unknown is true,
+// and !unknown as well as the other combinations with unknown are unknown.
+// A field is missing when GetField returns an error wrapping ErrFieldNotFound, or when its value
+// is a nil pointer or a null driver.Valuer such as sql.NullString. Other errors are still returned.
+// Eval reports an unknown result as false, like a WHERE clause does.
+func WithThreeValuedLogic() Option {
+	return func(o *options) {
+		o.threeValued = true
+	}
+}
+
+// WithUnsupportedOpAsFalse makes a comparison false, rather than an eval error, when its operator
+// does not apply to the type of the value of its field, such as =~ on an int field that a regex was
+// meant for when fields are typed dynamically. It covers string, number and duration fields, and
+// applies before the value is checked, so Int =~ "x" is false as well. Other errors are kept.
+func WithUnsupportedOpAsFalse() Option {
+	return func(o *options) {
+		o.opAsFalse = true
+	}
+}
+
+// WithNormalization normalizes both the field value and the literal with the given
+// Unicode normalization form before comparing strings with ==, ==*, != and !=*.
+func WithNormalization(form norm.Form) Option {
+	return func(o *options) {
+		o.normalize = true
+		o.form = form
+	}
+}
+
+// WithNumericStringCoercion compares string field values numerically with >, >=, < and <=
+// when the value is a plain decimal number such as "123", "-0.5" or "1e3".
+// Leading zeros are decimal, and prefixed forms such as "0x10" are not coerced.
+// Other strings keep the string semantics, so ordering operators still fail on them.
+func WithNumericStringCoercion() Option {
+	return func(o *options) {
+		o.coerce = true
+	}
+}
+
+// WithTruthyBool compares integer and string field values with boolean literals by their truthiness,
+// for flags stored as 0 and 1 or as "true" and "false". An integer is true unless it is zero,
+// and a string is interpreted by strconv.ParseBool, so "1", "t", "TRUE" and "True" are true as well.
+// Other strings are reported as eval errors. Only == and != apply to boolean literals.
+// Without it, comparing an integer field with a boolean literal is an eval error.
+func WithTruthyBool() Option {
+	return func(o *options) {
+		o.truthy = true
+	}
+}
+
+// WithRuneComparison compares rune (int32) and byte (uint8) field values with string literals
+// by code point, so FirstChar == "A" holds for 'A' and FirstChar < "a" for uppercase ASCII letters.
+// The string must be a single character, and other strings are reported as eval errors.
+// ==, !=, the ordering operators and ==* / !=*, which fold the case of the character, apply.
+// Without it, such fields are numbers, and strings compared with them are parsed as numbers.
+func WithRuneComparison() Option {
+	return func(o *options) {
+		o.runes = true
+	}
+}
+
+// WithExtendedDurationUnits accepts d (24h) and w (168h) units in duration literals, such as 7d or 2w.
+// Days and weeks have a fixed length, and daylight saving time transitions are not taken into account.
+func WithExtendedDurationUnits() Option {
+	return func(o *options) {
+		o.extendedUnits = true
+	}
+}
+
+// WithEpsilon sets the tolerance used by == and != on numbers, overriding Epsilon.
+// WithEpsilon(0) means exact comparison. A negative or NaN value is treated as 0.
+func WithEpsilon(e float64) Option {
+	return func(o *options) {
+		if math.IsNaN(e) {
+			e = 0
+		}
+		o.epsilon = max(e, 0)
+	}
+}
+
+// WithTolerance sets the tolerance of the ~= operator on numbers, which holds when the field is
+// within t of the value inclusive, so Temp ~= 20 holds for 19.5 and 20.5 under WithTolerance(0.5).
+// Unlike the epsilon of ==, which only absorbs rounding errors and is not applied to integers
+// compared with integer literals, the tolerance is meant for measurements and applies to every
+// number field. It defaults to Epsilon, and a negative or NaN value is treated as 0.
+func WithTolerance(t float64) Option {
+	return func(o *options) {
+		if math.IsNaN(t) {
+			t = 0
+		}
+		o.tolerance = max(t, 0)
+	}
+}
+
+// WithLiteralSingleQuotes treats single-quoted strings literally like in shells:
+// backslashes are ordinary characters, so 'C:\path' is accepted as is.
+// Double-quoted strings keep validating escape sequences.
+func WithLiteralSingleQuotes() Option {
+	return func(o *options) {
+		o.literalSingleQuotes = true
+	}
+}
+
+// WithCaseInsensitiveFields lowercases identifiers at parse time, so HP, Hp and hp
+// all ask the target for the field "hp". Targets should match keys ignoring case,
+// for example by wrapping a map with CaseInsensitiveTarget.
+func WithCaseInsensitiveFields() Option {
+	return func(o *options) {
+		o.fold = true
+	}
+}
+
+// WithCaseInsensitiveValues makes == and != compare string values of the named fields
+// ignoring case, like ==* and !=*, so Level == "info" holds for "INFO" when Level is named.
+// Other fields and operators are not affected. Fields of several calls are merged, and
+// under WithCaseInsensitiveFields the names are matched ignoring case as well.
+func WithCaseInsensitiveValues(fields ...string) Option {
+	fields = slices.Clone(fields)
+	return func(o *options) {
+		o.ignoreCase = append(slices.Clip(o.ignoreCase), fields...)
+	}
+}
+
+// WithCaseInsensitiveStrings makes == and != compare the string values of every field ignoring case,
+// like ==* and !=*, such as for user-facing search where Name == "arthur" should hold for "Arthur".
+// Regex, substring and ordering operators are not affected; use their * variants to ignore case.
+func WithCaseInsensitiveStrings() Option {
+	return func(o *options) {
+		o.foldStrings = true
+	}
+}
+
+// WithSkipFields disables the comparisons of the named fields, such as for rolling out a feature
+// flag without editing filters: each comparison of such a field, including one that uses it as
+// the offset field of Start + 1h, holds without asking the target for the field.
+// A negated comparison is still negated, so !(Beta == true) never holds while Beta is skipped.
+// Fields of several calls are merged, and under WithCaseInsensitiveFields the names are
+// matched ignoring case as well.
+func WithSkipFields(fields ...string) Option {
+	fields = slices.Clone(fields)
+	return func(o *options) {
+		o.skip = append(slices.Clip(o.skip), fields...)
+	}
+}
+
+// WithDecimalComma reads number literals in the European style, with a comma as the decimal
+// point and dots grouping the thousands, so that 1.000,50 is 1000.5. A dot must be followed by
+// three digits, so 1.5 is an error rather than 15, and the first group has at most three digits.
+// A comma is the decimal point only if a digit follows it, so list items separated by a comma
+// and a space, as in (1, 2), are still separate numbers. Parse returns an error for a number
+// with a decimal comma in a list, such as (1,2), which could be read either way.
+// Numbers with a base prefix such as 0x1.fp3 and durations such as 1.5h are not affected.
+// String writes numbers back with a decimal comma and without grouping.
+func WithDecimalComma() Option {
+	return func(o *options) {
+		o.decimalComma = true
+	}
+}
+
+// WithContradictionCheck makes Parse return an error for a chain of && that compares the same
+// field against number literals in ways that cannot all hold, such as X > 5 && X < 1 or
+// X == 1 && X == 2, which is usually a mistake. The check is conservative and detects the same
+// cases as IsConstant: only the operands of one chain of && are compared with each other,
+// so X > 5 || X < 1 and (X > 5 || Y == 1) && X < 1 are accepted. A chain of && under || or !
+// is still checked on its own.
+func WithContradictionCheck() Option {
+	return func(o *options) {
+		o.contradictions = true
+	}
+}
+
+// WithAllowEmptyRegex accepts an empty pattern for the regex operators, such as Name =~ "",
+// which Parse otherwise rejects as a likely mistake. As in the regexp package, an empty pattern
+// matches every string, so Name =~ "" holds for any string field and Name !~ "" never holds,
+// while matches "" holds only for the empty string, since its pattern must match the whole value.
+func WithAllowEmptyRegex() Option {
+	return func(o *options) {
+		o.emptyRegex = true
+	}
+}
+
+// WithByteLength makes len count the bytes of string fields like the Go len function,
+// so len(Name) == 6 holds for "日本". Without it, len counts runes, and len("日本") is 2.
+// The length of slices, arrays and maps is the number of elements either way.
+func WithByteLength() Option {
+	return func(o *options) {
+		o.byteLength = true
+	}
+}
+
+// WithFieldDurationUnit makes integer values of the named field count in unit when the field is
+// compared with a duration literal, such as time.Millisecond for a TimeoutMs field holding 1500,
+// so that TimeoutMs == 1500ms and TimeoutMs == 1.5s hold. Comparisons with number literals are
+// not affected, and values whose duration is out of range are reported as eval errors.
+// A later call for the same field replaces the unit, and under WithCaseInsensitiveFields the name
+// is matched ignoring case as well. Parse returns an error if unit is not positive.
+func WithFieldDurationUnit(field string, unit time.Duration) Option {
+	return func(o *options) {
+		units := make(map[string]time.Duration, len(o.units)+1)
+		maps.Copy(units, o.units)
+		units[field] = unit
+		o.units = units
+	}
+}
+
+// durationUnit returns the unit given to WithFieldDurationUnit for field, or 0 if there is none.
+func (o *options) durationUnit(field string) time.Duration {
+	if unit, ok := o.units[field]; ok || !o.fold {
+		return unit
+	}
+	for f, unit := range o.units {
+		if strings.EqualFold(f, field) {
+			return unit
+		}
+	}
+	return 0
+}
+
+// WithFloatFormat makes String write number literals that are not integers with strconv.FormatFloat
+// in the format verb and precision prec, such as 'f' and 2 for 3.14 instead of 3.14159, rather than
+// as written in the input. Integers, including those with a base prefix such as 0x10, are written
+// as is. verb is one of 'e', 'E', 'f', 'g' and 'G', and prec -1 uses the fewest digits that read
+// back the same value. Parse returns an error for other verbs. Evaluation is not affected.
+func WithFloatFormat(verb byte, prec int) Option {
+	return func(o *options) {
+		o.floatVerb = verb
+		o.floatPrec = prec
+	}
+}
+
+// hasField reports whether field is one of the fields given to an option,
+// ignoring case under WithCaseInsensitiveFields.
+func (o *options) hasField(fields []string, field string) bool {
+	for _, f := range fields {
+		if f == field || (o.fold && strings.EqualFold(f, field)) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithRegexDisabled rejects the regex operators =~, =~*, !~, !~*, matches and imatches at parse time,
+// which is useful for filters written by untrusted users.
+func WithRegexDisabled() Option {
+	return func(o *options) {
+		o.noRegex = true
+	}
+}
+
+// WithMaxTokens sets the maximum number of tokens in an expression, overriding DefaultMaxTokens.
+// Longer input fails to parse, which bounds the work spent on filters written by untrusted users.
+// WithMaxTokens(0) means no limit.
+func WithMaxTokens(n int) Option {
+	return func(o *options) {
+		o.maxTokens = n
+	}
+}
+
+// WithMaxNodes sets the maximum number of nodes in the tree of an expression, overriding DefaultMaxNodes.
+// Each comparison, && / || and ! is a node, and a chained comparison is three of them.
+// WithMaxNodes(0) means no limit.
+func WithMaxNodes(n int) Option {
+	return func(o *options) {
+		o.maxNodes = n
+	}
+}
+
+// WithMaxMatchLen sets the maximum length in bytes of a string value that is matched against a regex
+// by =~, !~, matches and their case-insensitive forms, which bounds the work spent on large values
+// of untrusted data. Matching a longer value is an eval error rather than false, so that !~ does not
+// hold for it either. WithMaxMatchLen(0) means no limit, which is the default.
+func WithMaxMatchLen(n int) Option {
+	return func(o *options) {
+		o.maxMatchLen = n
+	}
+}
+
+// WithOperatorAliases registers words that are accepted as comparison operators,
+// such as "eq" for OperatorEQ so that Status eq "active" is read as Status == "active".
+// A registered word takes precedence over identifiers, so it can no longer be used as a field name,
+// while words that are not registered stay identifiers. Aliases of several calls are merged.
+// Parse returns an error if a word is not a valid identifier, is a keyword such as true or
+// containsany, or is mapped to an unknown operator. The keyword in may be registered,
+// replacing the membership test "admin" in Roles. Expressions are written with the
+// built-in operators by String.
+func WithOperatorAliases(aliases map[string]Operator) Option {
+	aliases = maps.Clone(aliases)
+	return func(o *options) {
+		merged := make(map[string]Operator, len(o.aliases)+len(aliases))
+		maps.Copy(merged, o.aliases)
+		maps.Copy(merged, aliases)
+		o.aliases = merged
+	}
+}
+
+// WithIdentChars accepts the characters of extra within identifiers besides letters, digits and _,
+// such as "-:" for fields named x-request-id or http:status. An identifier still starts with a letter
+// or _, and such a character must be followed by a letter, a digit or _, so x- is x followed by -.
+// Since the characters become part of the identifier, a field offset must be written with spaces
+// around its sign, as in Start - 1h, while now-1h is still read as now with an offset.
+// Parse returns an error if extra holds a space, a letter, a digit, _, a quote, a bracket,
+// a comma or a character of an operator such as = or &. Characters of several calls are merged.
+func WithIdentChars(extra string) Option {
+	return func(o *options) {
+		o.identChars += extra
+	}
+}
+
+// WithSubFilters registers expressions that can be referenced by name as operands of other
+// expressions, such as IsAdmin in IsAdmin && Score > 10 with an expression for Role == "admin".
+// A name written without an operator refers to the sub-filter, which holds where its expression
+// holds, while a name followed by an operator is still a field. The trees of the sub-filters are
+// copied into the expression when it is parsed, so later changes to the map are not seen, and they
+// are evaluated with the options of the expression, so WithSkipFields, WithCaseInsensitiveValues,
+// WithCaseInsensitiveStrings and WithFieldDurationUnit given to it apply to their fields, and WithMaxNodes
+// and WithRegexDisabled to their nodes; String writes them expanded in parentheses where needed.
+// Parse returns an error for a name without an operator that is not registered, and for a nil
+// sub-filter. Sub-filters of several calls are merged, and under WithCaseInsensitiveFields
+// names are matched ignoring case.
+func WithSubFilters(filters map[string]*Expr) Option {
+	filters = maps.Clone(filters)
+	return func(o *options) {
+		merged := make(map[string]*Expr, len(o.subFilters)+len(filters))
+		maps.Copy(merged, o.subFilters)
+		maps.Copy(merged, filters)
+		o.subFilters = merged
+	}
+}
+
+// subFilter returns the sub-filter given to WithSubFilters under name, or nil if there is none.
+func (o *options) subFilter(name string) *Expr {
+	if sub, ok := o.subFilters[name]; ok || !o.fold {
+		return sub
+	}
+	for n, sub := range o.subFilters {
+		if strings.EqualFold(n, name) {
+			return sub
+		}
+	}
+	return nil
+}
+
+// WithStringOrdering allows >, >=, < and <= on string fields, comparing strings in byte order
+// like the Go operators, so Name > "M" holds for names sorting after "M".
+// Without it, ordering operators are invalid for string fields.
+// Under WithNumericStringCoercion, decimal strings are still compared numerically.
+func WithStringOrdering() Option {
+	return func(o *options) {
+		o.ordering = true
+	}
+}
+
+// WithCollator allows >, >=, < and <= on string fields like WithStringOrdering,
+// comparing strings with the collator for locale-aware ordering, such as "é" before "f" in French.
+// The collator is used under a lock, since it is not safe for concurrent use.
+func WithCollator(c *collate.Collator) Option {
+	return func(o *options) {
+		o.ordering = true
+		o.collator = &collator{c: c}
+	}
+}
+
+// collator serializes the use of a collate.Collator.
+type collator struct {
+	mu sync.Mutex
+	c  *collate.Collator
+}
+
+// collate returns the collate.Collator of the collator, or nil if there is none.
+func (c *collator) collate() *collate.Collator {
+	if c == nil {
+		return nil
+	}
+	return c.c
+}
+
+// compare returns an integer comparing a and b in the order of the collator.
+func (c *collator) compare(a, b string) int {
+	if c == nil || c.c == nil {
+		return strings.Compare(a, b)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.c.CompareString(a, b)
+}
@@ -0,0 +1,2066 @@
+package filter
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+	"golang.org/x/text/unicode/norm"
+)
+
+func TestWithStrictEval(t *testing.T) {
+	type expected struct {
+		ok  bool
+		val bool
+		err string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected expected
+	}{
+		{
+			name:  "lazy or skips missing field",
+			input: `Bool==true || Missing==1`,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:  "strict or reports missing field",
+			input: `Bool==true || Missing==1`,
+			opts:  []Option{WithStrictEval()},
+			expected: expected{
+				ok:  false,
+				err: `field not found: "Missing"`,
+			},
+		},
+		{
+			name:  "lazy and skips missing field",
+			input: `Bool==false && Missing==1`,
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:  "strict and reports missing field",
+			input: `Bool==false && Missing==1`,
+			opts:  []Option{WithStrictEval()},
+			expected: expected{
+				ok:  false,
+				err: `field not found: "Missing"`,
+			},
+		},
+		{
+			name:  "strict returns first error",
+			input: `First==1 || Second==1`,
+			opts:  []Option{WithStrictEval()},
+			expected: expected{
+				ok:  false,
+				err: `field not found: "First"`,
+			},
+		},
+		{
+			name:  "strict and true",
+			input: `Bool==true && Int==42`,
+			opts:  []Option{WithStrictEval()},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:  "strict and false",
+			input: `Bool==false && Int==42`,
+			opts:  []Option{WithStrictEval()},
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:  "strict or true",
+			input: `Bool==false || Int==42`,
+			opts:  []Option{WithStrictEval()},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:  "strict or false",
+			input: `Bool==false || Int==0`,
+			opts:  []Option{WithStrictEval()},
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:  "strict nested not",
+			input: `!(Bool==true || Int==0) || String=="HelloWorld"`,
+			opts:  []Option{WithStrictEval()},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, "", err)
+			}
+			actual, err := expr.Eval(testObject)
+			if !test.expected.ok {
+				if err == nil || !strings.Contains(err.Error(), test.expected.err) {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected.val, err)
+			}
+			if actual != test.expected.val {
+				t.Errorf(testTemplate, test.input, test.expected.val, actual)
+			}
+		})
+	}
+}
+
+func TestWithNormalization(t *testing.T) {
+	target := testTarget{
+		"City":       "İstanbul",
+		"Composed":   "caf\u00e9",
+		"Decomposed": "cafe\u0301",
+		"Upper":      "CAFE\u0301",
+		"Ligature":   "\ufb01le",
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected bool
+	}{
+		{
+			name:     "dotted capital I does not fold to i",
+			input:    `City==*"istanbul"`,
+			expected: false,
+		},
+		{
+			name:     "dotted capital I folds to itself",
+			input:    `City==*"İSTANBUL"`,
+			expected: true,
+		},
+		{
+			name:     "dotted capital I not equal",
+			input:    `City!=*"istanbul"`,
+			expected: true,
+		},
+		{
+			name:     "combining character without normalization",
+			input:    "Decomposed==\"caf\u00e9\"",
+			expected: false,
+		},
+		{
+			name:     "combining character with nfc",
+			input:    "Decomposed==\"caf\u00e9\"",
+			opts:     []Option{WithNormalization(norm.NFC)},
+			expected: true,
+		},
+		{
+			name:     "combining character with nfd",
+			input:    "Composed==\"cafe\u0301\"",
+			opts:     []Option{WithNormalization(norm.NFD)},
+			expected: true,
+		},
+		{
+			name:     "combining character neq with nfc",
+			input:    "Decomposed!=\"caf\u00e9\"",
+			opts:     []Option{WithNormalization(norm.NFC)},
+			expected: false,
+		},
+		{
+			name:     "combining character eqi with nfc",
+			input:    "Upper==*\"caf\u00e9\"",
+			opts:     []Option{WithNormalization(norm.NFC)},
+			expected: true,
+		},
+		{
+			name:     "combining character neqi with nfc",
+			input:    "Upper!=*\"caf\u00e9\"",
+			opts:     []Option{WithNormalization(norm.NFC)},
+			expected: false,
+		},
+		{
+			name:     "ligature with nfc",
+			input:    `Ligature=="file"`,
+			opts:     []Option{WithNormalization(norm.NFC)},
+			expected: false,
+		},
+		{
+			name:     "ligature with nfkc",
+			input:    `Ligature=="file"`,
+			opts:     []Option{WithNormalization(norm.NFKC)},
+			expected: true,
+		},
+		{
+			name:     "regex not normalized",
+			input:    "Decomposed=~\"caf\u00e9\"",
+			opts:     []Option{WithNormalization(norm.NFC)},
+			expected: false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, "", err)
+			}
+			actual, err := expr.Eval(target)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			if actual != test.expected {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestWithNumericStringCoercion(t *testing.T) {
+	target := testTarget{
+		"StringNumber": "123",
+		"Negative":     "-0.5",
+		"Exponent":     "1e3",
+		"LeadingZero":  "007",
+		"Hex":          "0x10",
+		"Separator":    "1_000",
+		"Space":        " 123",
+		"Inf":          "Inf",
+		"String":       "abc",
+	}
+	type expected struct {
+		ok  bool
+		val bool
+		err string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected expected
+	}{
+		{
+			name:  "strict by default",
+			input: `StringNumber>100`,
+			expected: expected{
+				ok:  false,
+				err: `invalid operator for string field`,
+			},
+		},
+		{
+			name:  "gt",
+			input: `StringNumber>100`,
+			opts:  []Option{WithNumericStringCoercion()},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:  "gte",
+			input: `StringNumber>=123`,
+			opts:  []Option{WithNumericStringCoercion()},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:  "lt",
+			input: `StringNumber<100`,
+			opts:  []Option{WithNumericStringCoercion()},
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:  "lte",
+			input: `Negative<=-0.5`,
+			opts:  []Option{WithNumericStringCoercion()},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:  "exponent",
+			input: `Exponent>999`,
+			opts:  []Option{WithNumericStringCoercion()},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:  "leading zero is decimal",
+			input: `LeadingZero>6`,
+			opts:  []Option{WithNumericStringCoercion()},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:  "equality keeps string semantics",
+			input: `LeadingZero==7`,
+			opts:  []Option{WithNumericStringCoercion()},
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:  "quoted literal",
+			input: `StringNumber>"100"`,
+			opts:  []Option{WithNumericStringCoercion()},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:  "non numeric string",
+			input: `String>100`,
+			opts:  []Option{WithNumericStringCoercion()},
+			expected: expected{
+				ok:  false,
+				err: `invalid operator for string field`,
+			},
+		},
+		{
+			name:  "hex prefix not coerced",
+			input: `Hex>1`,
+			opts:  []Option{WithNumericStringCoercion()},
+			expected: expected{
+				ok:  false,
+				err: `invalid operator for string field`,
+			},
+		},
+		{
+			name:  "separator not coerced",
+			input: `Separator>1`,
+			opts:  []Option{WithNumericStringCoercion()},
+			expected: expected{
+				ok:  false,
+				err: `invalid operator for string field`,
+			},
+		},
+		{
+			name:  "space not coerced",
+			input: `Space>1`,
+			opts:  []Option{WithNumericStringCoercion()},
+			expected: expected{
+				ok:  false,
+				err: `invalid operator for string field`,
+			},
+		},
+		{
+			name:  "inf not coerced",
+			input: `Inf>1`,
+			opts:  []Option{WithNumericStringCoercion()},
+			expected: expected{
+				ok:  false,
+				err: `invalid operator for string field`,
+			},
+		},
+		{
+			name:  "invalid literal",
+			input: `StringNumber>"abc"`,
+			opts:  []Option{WithNumericStringCoercion()},
+			expected: expected{
+				ok:  false,
+				err: `cannot compare string field "StringNumber" with non-numeric value "abc"`,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, "", err)
+			}
+			actual, err := expr.Eval(target)
+			if !test.expected.ok {
+				if err == nil || !strings.Contains(err.Error(), test.expected.err) {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected.val, err)
+			}
+			if actual != test.expected.val {
+				t.Errorf(testTemplate, test.input, test.expected.val, actual)
+			}
+		})
+	}
+}
+
+func TestWithTruthyBool(t *testing.T) {
+	target := testTarget{
+		"Zero":     0,
+		"One":      int8(1),
+		"Two":      int64(2),
+		"UintZero": uint(0),
+		"Text":     "true",
+		"Upper":    "TRUE",
+		"Off":      "false",
+		"Digit":    "1",
+		"Word":     "yes",
+		"Flag":     true,
+		"Float":    1.0,
+		"Duration": time.Second,
+	}
+	type expected struct {
+		ok  bool
+		val bool
+		err string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected expected
+	}{
+		{
+			name:  "strict by default",
+			input: `Zero==false`,
+			expected: expected{
+				ok:  false,
+				err: `cannot compare int field with boolean`,
+			},
+		},
+		{
+			name:  "int zero",
+			input: `Zero==false`,
+			opts:  []Option{WithTruthyBool()},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:  "int one",
+			input: `One==true`,
+			opts:  []Option{WithTruthyBool()},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:  "int nonzero",
+			input: `Two==true`,
+			opts:  []Option{WithTruthyBool()},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:  "uint zero not equal",
+			input: `UintZero!=false`,
+			opts:  []Option{WithTruthyBool()},
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:  "string true",
+			input: `Text==true`,
+			opts:  []Option{WithTruthyBool()},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:  "string upper",
+			input: `Upper==TRUE`,
+			opts:  []Option{WithTruthyBool()},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:  "string false",
+			input: `Off==true`,
+			opts:  []Option{WithTruthyBool()},
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:  "string digit",
+			input: `Digit==true`,
+			opts:  []Option{WithTruthyBool()},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:  "unrecognized string",
+			input: `Word==true`,
+			opts:  []Option{WithTruthyBool()},
+			expected: expected{
+				ok:  false,
+				err: `cannot interpret string field as boolean at 1:1: "yes"`,
+			},
+		},
+		{
+			name:  "bool field",
+			input: `Flag!=False`,
+			opts:  []Option{WithTruthyBool()},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:  "float field",
+			input: `Float==true`,
+			opts:  []Option{WithTruthyBool()},
+			expected: expected{
+				ok:  false,
+				err: `cannot compare float64 field with boolean at 1:8: "true"`,
+			},
+		},
+		{
+			name:  "duration field",
+			input: `Duration==true`,
+			opts:  []Option{WithTruthyBool()},
+			expected: expected{
+				ok:  false,
+				err: `cannot compare time.Duration field with boolean`,
+			},
+		},
+		{
+			name:  "number literal",
+			input: `One==1`,
+			opts:  []Option{WithTruthyBool()},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, "", err)
+			}
+			actual, err := expr.Eval(target)
+			if !test.expected.ok {
+				if err == nil || !strings.Contains(err.Error(), test.expected.err) {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected.val, err)
+			}
+			if actual != test.expected.val {
+				t.Errorf(testTemplate, test.input, test.expected.val, actual)
+			}
+		})
+	}
+}
+
+func TestWithExtendedDurationUnits(t *testing.T) {
+	target := testTarget{
+		"Age": 10 * 24 * time.Hour,
+	}
+	type expected struct {
+		ok  bool
+		val bool
+		err string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected expected
+	}{
+		{
+			name:  "rejected by default",
+			input: `Age>7d`,
+			expected: expected{
+				ok:  false,
+				err: `parse error`,
+			},
+		},
+		{
+			name:  "day gt",
+			input: `Age>7d`,
+			opts:  []Option{WithExtendedDurationUnits()},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:  "week gt",
+			input: `Age>2w`,
+			opts:  []Option{WithExtendedDurationUnits()},
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:  "mixed eq",
+			input: `Age==1w3d`,
+			opts:  []Option{WithExtendedDurationUnits()},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:  "mixed with standard units",
+			input: `Age<1w2d24h1ns`,
+			opts:  []Option{WithExtendedDurationUnits()},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:  "quoted literal",
+			input: `Age=='10d'`,
+			opts:  []Option{WithExtendedDurationUnits()},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:  "overflow",
+			input: `Age>100000000w`,
+			opts:  []Option{WithExtendedDurationUnits()},
+			expected: expected{
+				ok:  false,
+				err: `invalid duration`,
+			},
+		},
+		{
+			name:  "quoted overflow",
+			input: `Age>'100000000w'`,
+			opts:  []Option{WithExtendedDurationUnits()},
+			expected: expected{
+				ok:  false,
+				err: `invalid duration`,
+			},
+		},
+		{
+			name:  "quoted literal rejected by default",
+			input: `Age=='10d'`,
+			expected: expected{
+				ok:  false,
+				err: `invalid duration`,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err != nil {
+				if test.expected.ok || !strings.Contains(err.Error(), test.expected.err) {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+				return
+			}
+			actual, err := expr.Eval(target)
+			if !test.expected.ok {
+				if err == nil || !strings.Contains(err.Error(), test.expected.err) {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected.val, err)
+			}
+			if actual != test.expected.val {
+				t.Errorf(testTemplate, test.input, test.expected.val, actual)
+			}
+		})
+	}
+}
+
+func TestWithEpsilon(t *testing.T) {
+	target := testTarget{
+		"Price":  0.30000000000000004,
+		"Sensor": 20.004,
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected bool
+	}{
+		{
+			name:     "default epsilon eq",
+			input:    `Price==0.3`,
+			expected: true,
+		},
+		{
+			name:     "exact eq",
+			input:    `Price==0.3`,
+			opts:     []Option{WithEpsilon(0)},
+			expected: false,
+		},
+		{
+			name:     "exact neq",
+			input:    `Price!=0.3`,
+			opts:     []Option{WithEpsilon(0)},
+			expected: true,
+		},
+		{
+			name:     "negative is exact",
+			input:    `Price==0.3`,
+			opts:     []Option{WithEpsilon(-1)},
+			expected: false,
+		},
+		{
+			name:     "NaN is exact",
+			input:    `Price!=0.3`,
+			opts:     []Option{WithEpsilon(math.NaN())},
+			expected: true,
+		},
+		{
+			name:     "default epsilon loose value",
+			input:    `Sensor==20`,
+			expected: false,
+		},
+		{
+			name:     "loose eq",
+			input:    `Sensor==20`,
+			opts:     []Option{WithEpsilon(0.01)},
+			expected: true,
+		},
+		{
+			name:     "loose neq",
+			input:    `Sensor!=20`,
+			opts:     []Option{WithEpsilon(0.01)},
+			expected: false,
+		},
+		{
+			name:     "loose eq out of range",
+			input:    `Sensor==20`,
+			opts:     []Option{WithEpsilon(0.001)},
+			expected: false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, "", err)
+			}
+			actual, err := expr.Eval(target)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			if actual != test.expected {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestWithLiteralSingleQuotes(t *testing.T) {
+	target := testTarget{
+		"Path": `C:\path\new`,
+	}
+	type expected struct {
+		ok  bool
+		val bool
+		err string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected expected
+	}{
+		{
+			name:  "escape error by default",
+			input: `Path=='C:\path\new'`,
+			expected: expected{
+				ok:  false,
+				err: `invalid escape sequence`,
+			},
+		},
+		{
+			name:  "literal single quotes",
+			input: `Path=='C:\path\new'`,
+			opts:  []Option{WithLiteralSingleQuotes()},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:  "double quotes still validated",
+			input: `Path=="C:\path\new"`,
+			opts:  []Option{WithLiteralSingleQuotes()},
+			expected: expected{
+				ok:  false,
+				err: `invalid escape sequence`,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if !test.expected.ok {
+				if err == nil || !strings.Contains(err.Error(), test.expected.err) {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, "", err)
+			}
+			actual, err := expr.Eval(target)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected.val, err)
+			}
+			if actual != test.expected.val {
+				t.Errorf(testTemplate, test.input, test.expected.val, actual)
+			}
+		})
+	}
+}
+
+func TestWithRegexDisabled(t *testing.T) {
+	target := testTarget{
+		"Name": "slime",
+		"HP":   50,
+	}
+	type expected struct {
+		ok  bool
+		val bool
+		err string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected expected
+	}{
+		{
+			name:  "regex allowed by default",
+			input: `Name=~"^sl"`,
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:  "match",
+			input: `Name=~"^sl"`,
+			opts:  []Option{WithRegexDisabled()},
+			expected: expected{
+				ok:  false,
+				err: `parse error: regex operators are disabled at 1:5: "=~"`,
+			},
+		},
+		{
+			name:  "case-insensitive match",
+			input: `Name=~*"^SL"`,
+			opts:  []Option{WithRegexDisabled()},
+			expected: expected{
+				ok:  false,
+				err: `regex operators are disabled at 1:5: "=~*"`,
+			},
+		},
+		{
+			name:  "not match",
+			input: `Name!~"^x"`,
+			opts:  []Option{WithRegexDisabled()},
+			expected: expected{
+				ok:  false,
+				err: `regex operators are disabled at 1:5: "!~"`,
+			},
+		},
+		{
+			name:  "case-insensitive not match",
+			input: "HP>1 &&\n  Name!~*\"^X\"",
+			opts:  []Option{WithRegexDisabled()},
+			expected: expected{
+				ok:  false,
+				err: `regex operators are disabled at 2:7: "!~*"`,
+			},
+		},
+		{
+			name:  "matches",
+			input: `Name matches "^sl"`,
+			opts:  []Option{WithRegexDisabled()},
+			expected: expected{
+				ok:  false,
+				err: `regex operators are disabled at 1:6: "matches"`,
+			},
+		},
+		{
+			name:  "imatches",
+			input: `Name imatches "^SL"`,
+			opts:  []Option{WithRegexDisabled()},
+			expected: expected{
+				ok:  false,
+				err: `regex operators are disabled at 1:6: "imatches"`,
+			},
+		},
+		{
+			name:  "equality allowed",
+			input: `Name=="slime" && Name==*"SLIME" && Name!="x" && Name!=*"X"`,
+			opts:  []Option{WithRegexDisabled()},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:  "ordering allowed",
+			input: `HP>=50 && 1<HP<100`,
+			opts:  []Option{WithRegexDisabled()},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if !test.expected.ok {
+				if err == nil || !strings.Contains(err.Error(), test.expected.err) {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, "", err)
+			}
+			actual, err := expr.Eval(target)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected.val, err)
+			}
+			if actual != test.expected.val {
+				t.Errorf(testTemplate, test.input, test.expected.val, actual)
+			}
+		})
+	}
+}
+
+func TestWithEpsilon_integer(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected bool
+	}{
+		{
+			name:     "int exact by default",
+			input:    `Int==41`,
+			expected: false,
+		},
+		{
+			name:     "int within epsilon",
+			input:    `Int==41`,
+			opts:     []Option{WithEpsilon(1)},
+			expected: true,
+		},
+		{
+			name:     "int neq within epsilon",
+			input:    `Int!=43`,
+			opts:     []Option{WithEpsilon(1)},
+			expected: false,
+		},
+		{
+			name:     "uint within epsilon",
+			input:    `Uint64==7`,
+			opts:     []Option{WithEpsilon(2)},
+			expected: true,
+		},
+		{
+			name:     "uint out of epsilon",
+			input:    `Uint64!=8`,
+			opts:     []Option{WithEpsilon(2)},
+			expected: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, "", err)
+			}
+			actual, err := expr.Eval(testObject)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			if actual != test.expected {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestWithStringOrdering(t *testing.T) {
+	target := testTarget{
+		"Name":   "slime",
+		"Accent": "école",
+		"Number": "10",
+	}
+	type expected struct {
+		ok  bool
+		val bool
+		err string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected expected
+	}{
+		{
+			name:  "strict by default",
+			input: `Name>"M"`,
+			expected: expected{
+				ok:  false,
+				err: `invalid operator for string field`,
+			},
+		},
+		{
+			name:  "gt",
+			input: `Name>"M"`,
+			opts:  []Option{WithStringOrdering()},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:  "gt false",
+			input: `Name>"z"`,
+			opts:  []Option{WithStringOrdering()},
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:  "gte equal",
+			input: `Name>="slime"`,
+			opts:  []Option{WithStringOrdering()},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:  "lt",
+			input: `Name<"t"`,
+			opts:  []Option{WithStringOrdering()},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:  "lte",
+			input: `Name<="slim"`,
+			opts:  []Option{WithStringOrdering()},
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:  "byte order of case",
+			input: `Name>"Z"`,
+			opts:  []Option{WithStringOrdering()},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:  "byte order of accents",
+			input: `Accent<"f"`,
+			opts:  []Option{WithStringOrdering()},
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:  "collator accents",
+			input: `Accent<"f"`,
+			opts:  []Option{WithCollator(collate.New(language.French))},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:  "collator accents reversed",
+			input: `Accent>="f"`,
+			opts:  []Option{WithCollator(collate.New(language.French))},
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:  "collator case",
+			input: `Name>"Z"`,
+			opts:  []Option{WithCollator(collate.New(language.English))},
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:  "chain",
+			input: `"a"<Name<"t"`,
+			opts:  []Option{WithStringOrdering()},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:  "coercion first",
+			input: `Number>"9"`,
+			opts:  []Option{WithStringOrdering(), WithNumericStringCoercion()},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+		{
+			name:  "without coercion",
+			input: `Number>"9"`,
+			opts:  []Option{WithStringOrdering()},
+			expected: expected{
+				ok:  true,
+				val: false,
+			},
+		},
+		{
+			name:  "equality unchanged",
+			input: `Accent=="école"`,
+			opts:  []Option{WithCollator(collate.New(language.French))},
+			expected: expected{
+				ok:  true,
+				val: true,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, "", err)
+			}
+			actual, err := expr.Eval(target)
+			if !test.expected.ok {
+				if err == nil || !strings.Contains(err.Error(), test.expected.err) {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected.val, err)
+			}
+			if actual != test.expected.val {
+				t.Errorf(testTemplate, test.input, test.expected.val, actual)
+			}
+		})
+	}
+}
+
+func TestWithCollator_concurrent(t *testing.T) {
+	expr, err := Parse(`Name>"f"`, WithCollator(collate.New(language.French)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			for range 100 {
+				if ok, err := expr.Eval(testTarget{"Name": "étoile"}); err != nil || ok {
+					t.Errorf(testTemplate, "concurrent", false, ok)
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
+
+func TestWithMaxTokens(t *testing.T) {
+	// n comparisons joined by && have 4n-1 tokens.
+	input := func(n int) string {
+		return strings.Repeat(`HP>1 && `, n-1) + `HP>1`
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected string
+	}{
+		{name: "at limit", input: input(25), opts: []Option{WithMaxTokens(99)}},
+		{name: "over limit", input: input(25), opts: []Option{WithMaxTokens(98)}, expected: `parse error: too many tokens: exceeded limit 98 at 1:196`},
+		{name: "over limit in list", input: `Tags containsany ("a", "b")`, opts: []Option{WithMaxTokens(6)}, expected: `parse error: too many tokens: exceeded limit 6 at 1:27`},
+		{name: "unlimited", input: input(20000), opts: []Option{WithMaxTokens(0)}},
+		{name: "default", input: input(DefaultMaxTokens/4 + 1), expected: `parse error: too many tokens: exceeded limit 65536`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(test.input, test.opts...)
+			if test.expected == "" {
+				if err != nil {
+					t.Errorf(testTemplate, test.name, nil, err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), test.expected) {
+				t.Errorf(testTemplate, test.name, test.expected, err)
+			}
+			var e *Error
+			if !errors.As(err, &e) || e.Kind != KindParse || e.Line != 1 || e.Col == 0 {
+				t.Errorf(testTemplate, test.name, "located parse error", err)
+			}
+		})
+	}
+}
+
+func TestWithMaxNodes(t *testing.T) {
+	// n comparisons joined by && have 2n-1 nodes.
+	input := func(n int) string {
+		return strings.Repeat(`HP>1 && `, n-1) + `HP>1`
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected string
+	}{
+		{name: "at limit", input: input(25), opts: []Option{WithMaxNodes(49)}},
+		{name: "over limit", input: input(25), opts: []Option{WithMaxNodes(48)}, expected: `parse error: too many nodes: exceeded limit 48 at 1:`},
+		{name: "over limit in middle", input: input(25), opts: []Option{WithMaxNodes(10)}, expected: `parse error: too many nodes: exceeded limit 10 at 1:`},
+		{name: "chain", input: `1<HP<100`, opts: []Option{WithMaxNodes(2)}, expected: `parse error: too many nodes: exceeded limit 2 at 1:`},
+		{name: "not", input: `!!HP>1`, opts: []Option{WithMaxNodes(3)}},
+		{name: "unlimited", input: input(40000), opts: []Option{WithMaxNodes(-1), WithMaxTokens(-1)}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(test.input, test.opts...)
+			if test.expected == "" {
+				if err != nil {
+					t.Errorf(testTemplate, test.name, nil, err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), test.expected) {
+				t.Errorf(testTemplate, test.name, test.expected, err)
+			}
+			var e *Error
+			if !errors.As(err, &e) || e.Kind != KindParse || e.Line != 1 || e.Col == 0 {
+				t.Errorf(testTemplate, test.name, "located parse error", err)
+			}
+		})
+	}
+}
+
+func TestWithMaxMatchLen(t *testing.T) {
+	target := testTarget{
+		"Short": strings.Repeat("a", 8),
+		"Long":  strings.Repeat("a", 9),
+	}
+	type expected struct {
+		val bool
+		err string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected expected
+	}{
+		{name: "at limit", input: `Short =~ "^a+$"`, opts: []Option{WithMaxMatchLen(8)}, expected: expected{val: true}},
+		{name: "over limit", input: `Long =~ "^a+$"`, opts: []Option{WithMaxMatchLen(8)}, expected: expected{err: `eval error: value of "Long" too long to match: 9 bytes exceeds limit 8 at 1:6`}},
+		{name: "negated over limit", input: `Long !~* "^b"`, opts: []Option{WithMaxMatchLen(8)}, expected: expected{err: `eval error: value of "Long" too long to match: 9 bytes exceeds limit 8 at 1:6`}},
+		{name: "matches over limit", input: `Long matches "a+"`, opts: []Option{WithMaxMatchLen(8)}, expected: expected{err: `eval error: value of "Long" too long to match: 9 bytes exceeds limit 8 at 1:6`}},
+		{name: "pattern list over limit", input: `Long =~ ("^b", "^a")`, opts: []Option{WithMaxMatchLen(8)}, expected: expected{err: `eval error: value of "Long" too long to match: 9 bytes exceeds limit 8 at 1:6`}},
+		{name: "equality over limit", input: `Long != "b" && Long has "aa"`, opts: []Option{WithMaxMatchLen(8)}, expected: expected{val: true}},
+		{name: "skipped by short-circuit", input: `Short == "b" && Long =~ "^a"`, opts: []Option{WithMaxMatchLen(8)}, expected: expected{val: false}},
+		{name: "unlimited", input: `Long =~ "^a+$"`, opts: []Option{WithMaxMatchLen(0)}, expected: expected{val: true}},
+		{name: "default", input: `Long =~ "^a+$"`, expected: expected{val: true}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			actual, err := expr.Eval(target)
+			if test.expected.err != "" {
+				if err == nil || err.Error() != test.expected.err {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected.val, err)
+			}
+			if actual != test.expected.val {
+				t.Errorf(testTemplate, test.input, test.expected.val, actual)
+			}
+		})
+	}
+}
+
+func TestWithOperatorAliases(t *testing.T) {
+	aliases := map[string]Operator{
+		"eq":   OperatorEQ,
+		"ne":   OperatorNEQ,
+		"gt":   OperatorGT,
+		"like": OperatorREQ,
+		"in":   OperatorContainsAny,
+	}
+	target := testTarget{
+		"Status": "active",
+		"HP":     100,
+		"Tags":   []string{"a", "b"},
+		"gt":     1,
+	}
+	type expected struct {
+		ok     bool
+		val    bool
+		format string
+		err    string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected expected
+	}{
+		{
+			name:     "eq",
+			input:    `Status eq "active"`,
+			opts:     []Option{WithOperatorAliases(aliases)},
+			expected: expected{ok: true, val: true, format: `Status == "active"`},
+		},
+		{
+			name:     "mixed with symbols",
+			input:    `Status ne "idle" && HP gt 50 && HP<=100`,
+			opts:     []Option{WithOperatorAliases(aliases)},
+			expected: expected{ok: true, val: true, format: `Status != "idle" && HP > 50 && HP <= 100`},
+		},
+		{
+			name:     "regex",
+			input:    `Status like "^act"`,
+			opts:     []Option{WithOperatorAliases(aliases)},
+			expected: expected{ok: true, val: true, format: `Status =~ "^act"`},
+		},
+		{
+			name:     "list",
+			input:    `Tags in ("b", "c")`,
+			opts:     []Option{WithOperatorAliases(aliases)},
+			expected: expected{ok: true, val: true, format: `Tags containsany ("b", "c")`},
+		},
+		{
+			name:     "merged",
+			input:    `HP eq 100 && HP above 50`,
+			opts:     []Option{WithOperatorAliases(aliases), WithOperatorAliases(map[string]Operator{"above": OperatorGT})},
+			expected: expected{ok: true, val: true, format: `HP == 100 && HP > 50`},
+		},
+		{
+			name:     "unregistered word stays identifier",
+			input:    `gt==1 && Status=="active"`,
+			opts:     []Option{WithOperatorAliases(map[string]Operator{"eq": OperatorEQ})},
+			expected: expected{ok: true, val: true, format: `gt == 1 && Status == "active"`},
+		},
+		{
+			name:     "without option",
+			input:    `Status eq "active"`,
+			expected: expected{ok: false, err: `parse error: expected comparison operator, got identifier at 1:8: "eq"`},
+		},
+		{
+			name:     "alias takes precedence over identifier",
+			input:    `gt==1`,
+			opts:     []Option{WithOperatorAliases(aliases)},
+			expected: expected{ok: false, err: `parse error: expected left parenthesis or identifier, got "greater than" operator at 1:1: "gt"`},
+		},
+		{
+			name:     "case-sensitive",
+			input:    `Status EQ "active"`,
+			opts:     []Option{WithOperatorAliases(aliases)},
+			expected: expected{ok: false, err: `parse error: expected comparison operator, got identifier at 1:8: "EQ"`},
+		},
+		{
+			name:     "keyword alias",
+			input:    `HP==1`,
+			opts:     []Option{WithOperatorAliases(map[string]Operator{"true": OperatorEQ})},
+			expected: expected{ok: false, err: `parse error: invalid operator alias "true": not an identifier`},
+		},
+		{
+			name:     "symbol alias",
+			input:    `HP==1`,
+			opts:     []Option{WithOperatorAliases(map[string]Operator{"<>": OperatorNEQ})},
+			expected: expected{ok: false, err: `parse error: invalid operator alias "<>": not an identifier`},
+		},
+		{
+			name:     "invalid operator",
+			input:    `HP==1`,
+			opts:     []Option{WithOperatorAliases(map[string]Operator{"eq": Operator(100)})},
+			expected: expected{ok: false, err: `parse error: invalid operator alias "eq": invalid operator: 100`},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if !test.expected.ok {
+				if err == nil || err.Error() != test.expected.err {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected.val, err)
+			}
+			actual, err := expr.Eval(target)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected.val, err)
+			}
+			if actual != test.expected.val {
+				t.Errorf(testTemplate, test.input, test.expected.val, actual)
+			}
+			if s := expr.String(); s != test.expected.format {
+				t.Errorf(testTemplate, test.input, test.expected.format, s)
+			}
+		})
+	}
+}
+
+func TestWithOperatorAliases_copy(t *testing.T) {
+	aliases := map[string]Operator{"eq": OperatorEQ}
+	opt := WithOperatorAliases(aliases)
+	aliases["eq"] = OperatorNEQ
+	expr, err := Parse(`HP eq 1`, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := expr.String(); s != `HP == 1` {
+		t.Errorf(testTemplate, "HP eq 1", "HP == 1", s)
+	}
+}
+
+func TestWithUnsupportedOpAsFalse(t *testing.T) {
+	target := testTarget{
+		"Int":      42,
+		"Uint":     uint64(42),
+		"Float":    1.5,
+		"Name":     "slime",
+		"Timeout":  2 * time.Second,
+		"Interval": Seconds(1.5),
+	}
+	type expected struct {
+		val bool
+		err string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected expected
+	}{
+		{name: "int regex", input: `Int=~"x"`, opts: []Option{WithUnsupportedOpAsFalse()}, expected: expected{val: false}},
+		{name: "int regex default", input: `Int=~"x"`, expected: expected{err: `eval error: invalid operator for int field at 1:4: "=~"`}},
+		{name: "int numeric regex", input: `Int=~"42"`, opts: []Option{WithUnsupportedOpAsFalse()}, expected: expected{val: false}},
+		{name: "int numeric regex default", input: `Int=~"42"`, expected: expected{err: `eval error: invalid operator for int field at 1:4: "=~"`}},
+		{name: "negated regex", input: `Int!~"x"`, opts: []Option{WithUnsupportedOpAsFalse()}, expected: expected{val: false}},
+		{name: "or", input: `Int=~"x" || Name == "slime"`, opts: []Option{WithUnsupportedOpAsFalse()}, expected: expected{val: true}},
+		{name: "not", input: `!(Int=~"x")`, opts: []Option{WithUnsupportedOpAsFalse()}, expected: expected{val: true}},
+		{name: "uint substring", input: `Uint has "4"`, opts: []Option{WithUnsupportedOpAsFalse()}, expected: expected{val: false}},
+		{name: "float case-insensitive", input: `Float ==* "1.5"`, opts: []Option{WithUnsupportedOpAsFalse()}, expected: expected{val: false}},
+		{name: "string approx", input: `Name ~= 1`, opts: []Option{WithUnsupportedOpAsFalse()}, expected: expected{val: false}},
+		{name: "duration regex", input: `Timeout=~"x"`, opts: []Option{WithUnsupportedOpAsFalse()}, expected: expected{val: false}},
+		{name: "duration regex default", input: `Timeout=~"2s"`, expected: expected{err: `eval error: invalid operator for time.Duration field at 1:8: "=~"`}},
+		{name: "seconds substring", input: `Interval has "1s"`, opts: []Option{WithUnsupportedOpAsFalse()}, expected: expected{val: false}},
+		{name: "valid operator", input: `Int > 40 && Timeout < 3s`, opts: []Option{WithUnsupportedOpAsFalse()}, expected: expected{val: true}},
+		{name: "non-numeric value kept", input: `Int > "abc"`, opts: []Option{WithUnsupportedOpAsFalse()}, expected: expected{err: `eval error: cannot compare int field "Int" with non-numeric value "abc" at 1:7`}},
+		{name: "missing field kept", input: `Missing=~"x"`, opts: []Option{WithUnsupportedOpAsFalse()}, expected: expected{err: `eval error: eval error: field not found: "Missing"`}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			actual, err := expr.Eval(target)
+			if test.expected.err != "" {
+				if err == nil || err.Error() != test.expected.err {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected.val, err)
+			}
+			if actual != test.expected.val {
+				t.Errorf(testTemplate, test.input, test.expected.val, actual)
+			}
+		})
+	}
+}
+
+func TestWithCaseInsensitiveValues(t *testing.T) {
+	target := CaseInsensitiveTarget{
+		"Level":  "INFO",
+		"Status": "ACTIVE",
+		"Tags":   []string{"Debug", "Trace"},
+		"HP":     100,
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected bool
+	}{
+		{name: "configured field", input: `Level == "info"`, opts: []Option{WithCaseInsensitiveValues("Level")}, expected: true},
+		{name: "configured field not equal", input: `Level != "info"`, opts: []Option{WithCaseInsensitiveValues("Level")}, expected: false},
+		{name: "unconfigured field", input: `Status == "active"`, opts: []Option{WithCaseInsensitiveValues("Level")}, expected: false},
+		{name: "unconfigured field not equal", input: `Status != "active"`, opts: []Option{WithCaseInsensitiveValues("Level")}, expected: true},
+		{name: "without option", input: `Level == "info"`, expected: false},
+		{name: "merged", input: `Level == "info" && Status == "active"`, opts: []Option{WithCaseInsensitiveValues("Level"), WithCaseInsensitiveValues("Status")}, expected: true},
+		{name: "list", input: `Tags containsany ("debug")`, opts: []Option{WithCaseInsensitiveValues("Tags")}, expected: true},
+		{name: "regex unaffected", input: `Level =~ "^info$"`, opts: []Option{WithCaseInsensitiveValues("Level")}, expected: false},
+		{name: "number unaffected", input: `HP == 100`, opts: []Option{WithCaseInsensitiveValues("HP")}, expected: true},
+		{name: "folded field", input: `level == "info"`, opts: []Option{WithCaseInsensitiveFields(), WithCaseInsensitiveValues("Level")}, expected: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			actual, err := expr.Eval(target)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			if actual != test.expected {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestWithCaseInsensitiveStrings(t *testing.T) {
+	target := testTarget{
+		"Name":  "Arthur",
+		"Title": "King",
+		"Tags":  []string{"Round", "Table"},
+		"HP":    100,
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected bool
+	}{
+		{name: "equal", input: `Name == "arthur"`, opts: []Option{WithCaseInsensitiveStrings()}, expected: true},
+		{name: "equal without option", input: `Name == "arthur"`, expected: false},
+		{name: "not equal", input: `Name != "ARTHUR"`, opts: []Option{WithCaseInsensitiveStrings()}, expected: false},
+		{name: "not equal without option", input: `Name != "ARTHUR"`, expected: true},
+		{name: "every field", input: `Name == "arthur" && Title == "king"`, opts: []Option{WithCaseInsensitiveStrings()}, expected: true},
+		{name: "membership", input: `"round" in Tags`, opts: []Option{WithCaseInsensitiveStrings()}, expected: true},
+		{name: "regex unaffected", input: `Name =~ "^arthur$"`, opts: []Option{WithCaseInsensitiveStrings()}, expected: false},
+		{name: "substring unaffected", input: `Name has "art"`, opts: []Option{WithCaseInsensitiveStrings()}, expected: false},
+		{name: "number unaffected", input: `HP == 100`, opts: []Option{WithCaseInsensitiveStrings()}, expected: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			actual, err := expr.Eval(target)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			if actual != test.expected {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestWithSkipFields(t *testing.T) {
+	target := testTarget{
+		"Name":  "slime",
+		"HP":    100,
+		"Start": time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	type expected struct {
+		ok  bool
+		val bool
+		err string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected expected
+	}{
+		{name: "equal", input: `Beta == true`, opts: []Option{WithSkipFields("Beta")}, expected: expected{ok: true, val: true}},
+		{name: "not equal", input: `Beta != true`, opts: []Option{WithSkipFields("Beta")}, expected: expected{ok: true, val: true}},
+		{name: "ordering", input: `Beta > 5 && Beta < 1`, opts: []Option{WithSkipFields("Beta")}, expected: expected{ok: true, val: true}},
+		{name: "string", input: `Beta ==* "x" && Beta has "y"`, opts: []Option{WithSkipFields("Beta")}, expected: expected{ok: true, val: true}},
+		{name: "regex", input: `Beta =~ "^x" && Beta !~ ("a", "b")`, opts: []Option{WithSkipFields("Beta")}, expected: expected{ok: true, val: true}},
+		{name: "list", input: `Beta containsall ("a", 1)`, opts: []Option{WithSkipFields("Beta")}, expected: expected{ok: true, val: true}},
+		{name: "function", input: `lower(Beta) == "x"`, opts: []Option{WithSkipFields("Beta")}, expected: expected{ok: true, val: true}},
+		{name: "arithmetic", input: `Beta % 2 == 1`, opts: []Option{WithSkipFields("Beta")}, expected: expected{ok: true, val: true}},
+		{name: "offset field", input: `Start < Beta + 1h`, opts: []Option{WithSkipFields("Beta")}, expected: expected{ok: true, val: true}},
+		{name: "other fields evaluated", input: `Beta == 1 && HP < 50`, opts: []Option{WithSkipFields("Beta")}, expected: expected{ok: true, val: false}},
+		{name: "or", input: `Name == "x" || Beta == 1`, opts: []Option{WithSkipFields("Beta")}, expected: expected{ok: true, val: true}},
+		{name: "not", input: `!(Beta == 1)`, opts: []Option{WithSkipFields("Beta")}, expected: expected{ok: true, val: false}},
+		{name: "merged", input: `Beta == 1 && Gamma == 1`, opts: []Option{WithSkipFields("Beta"), WithSkipFields("Gamma")}, expected: expected{ok: true, val: true}},
+		{name: "folded field", input: `BETA == 1`, opts: []Option{WithCaseInsensitiveFields(), WithSkipFields("Beta")}, expected: expected{ok: true, val: true}},
+		{name: "three-valued", input: `Beta == 1 && Missing == 1`, opts: []Option{WithThreeValuedLogic(), WithSkipFields("Beta")}, expected: expected{ok: true, val: false}},
+		{name: "without option", input: `Beta == 1`, expected: expected{ok: false, err: `field not found: "Beta"`}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			actual, err := expr.Eval(target)
+			if !test.expected.ok {
+				if err == nil || !strings.Contains(err.Error(), test.expected.err) {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected.val, err)
+			}
+			if actual != test.expected.val {
+				t.Errorf(testTemplate, test.input, test.expected.val, actual)
+			}
+		})
+	}
+}
+
+func TestWithRuneComparison(t *testing.T) {
+	target := testTarget{
+		"Rune":  'A',
+		"Kanji": '字',
+		"Byte":  byte('a'),
+		"Runes": []rune("xyz"),
+		"Int":   65,
+	}
+	type expected struct {
+		ok  bool
+		val bool
+		err string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected expected
+	}{
+		{name: "rune equal", input: `Rune == "A"`, opts: []Option{WithRuneComparison()}, expected: expected{ok: true, val: true}},
+		{name: "rune not equal", input: `Rune != 'B'`, opts: []Option{WithRuneComparison()}, expected: expected{ok: true, val: true}},
+		{name: "rune multibyte", input: `Kanji == "字"`, opts: []Option{WithRuneComparison()}, expected: expected{ok: true, val: true}},
+		{name: "rune ordering", input: `"A" <= Rune < "Z"`, opts: []Option{WithRuneComparison()}, expected: expected{ok: true, val: true}},
+		{name: "rune case-insensitive", input: `Rune ==* "a" && Rune !=* "b"`, opts: []Option{WithRuneComparison()}, expected: expected{ok: true, val: true}},
+		{name: "rune number", input: `Rune == 65`, opts: []Option{WithRuneComparison()}, expected: expected{ok: true, val: true}},
+		{name: "byte equal", input: `Byte == "a"`, opts: []Option{WithRuneComparison()}, expected: expected{ok: true, val: true}},
+		{name: "byte ordering", input: `Byte > "Z"`, opts: []Option{WithRuneComparison()}, expected: expected{ok: true, val: true}},
+		{name: "byte case-insensitive", input: `Byte ==* "A"`, opts: []Option{WithRuneComparison()}, expected: expected{ok: true, val: true}},
+		{name: "list", input: `Runes containsany ("y")`, opts: []Option{WithRuneComparison()}, expected: expected{ok: true, val: true}},
+		{name: "int unaffected", input: `Int == "65"`, opts: []Option{WithRuneComparison()}, expected: expected{ok: true, val: true}},
+		{name: "multiple characters", input: `Rune == "AB"`, opts: []Option{WithRuneComparison()}, expected: expected{ok: false, err: `eval error: cannot compare int32 field with string of 2 characters at 1:9: "AB"`}},
+		{name: "empty string", input: `Byte == ""`, opts: []Option{WithRuneComparison()}, expected: expected{ok: false, err: `eval error: cannot compare uint8 field with string of 0 characters at 1:9: ""`}},
+		{name: "regex", input: `Rune =~ "A"`, opts: []Option{WithRuneComparison()}, expected: expected{ok: false, err: `eval error: invalid operator for int32 field at 1:6: "=~"`}},
+		{name: "without option", input: `Rune == "A"`, expected: expected{ok: false, err: `eval error: cannot compare int32 field "Rune" with non-numeric value "A" at 1:9`}},
+		{name: "number string without option", input: `Rune == "65"`, expected: expected{ok: true, val: true}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			actual, err := expr.Eval(target)
+			if !test.expected.ok {
+				if err == nil || err.Error() != test.expected.err {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected.val, err)
+			}
+			if actual != test.expected.val {
+				t.Errorf(testTemplate, test.input, test.expected.val, actual)
+			}
+		})
+	}
+}
+
+func TestWithContradictionCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected string
+	}{
+		{name: "range", input: `X > 5 && X < 1`, opts: []Option{WithContradictionCheck()}, expected: `parse error: contradictory comparison of "X" at 1:10: "X < 1"`},
+		{name: "equal", input: `X == 1 && Y == 2 && X == 2`, opts: []Option{WithContradictionCheck()}, expected: `parse error: contradictory comparison of "X" at 1:21: "X == 2"`},
+		{name: "chain", input: `5 < X < 1`, opts: []Option{WithContradictionCheck()}, expected: `parse error: contradictory comparison of "X" at 1:5: "X < 1"`},
+		{name: "under or", input: `Y == 1 || (X >= 2 && X <= 1)`, opts: []Option{WithContradictionCheck()}, expected: `parse error: contradictory comparison of "X" at 1:22: "X <= 1"`},
+		{name: "under not", input: `!(X > 1 && X < 1)`, opts: []Option{WithContradictionCheck()}, expected: `parse error: contradictory comparison of "X" at 1:12: "X < 1"`},
+		{name: "multi line", input: "X > 5 &&\n  X < 1", opts: []Option{WithContradictionCheck()}, expected: `parse error: contradictory comparison of "X" at 2:3: "X < 1"`},
+		{name: "satisfiable", input: `X > 1 && X < 5`, opts: []Option{WithContradictionCheck()}},
+		{name: "closed point", input: `X >= 1 && X <= 1`, opts: []Option{WithContradictionCheck()}},
+		{name: "or", input: `X > 5 || X < 1`, opts: []Option{WithContradictionCheck()}},
+		{name: "or operand", input: `(X > 5 || Y == 1) && X < 1`, opts: []Option{WithContradictionCheck()}},
+		{name: "different fields", input: `X > 5 && Y < 1`, opts: []Option{WithContradictionCheck()}},
+		{name: "arithmetic", input: `X % 10 > 5 && X < 1`, opts: []Option{WithContradictionCheck()}},
+		{name: "skipped", input: `X > 5 && X < 1`, opts: []Option{WithContradictionCheck(), WithSkipFields("X")}},
+		{name: "without option", input: `X > 5 && X < 1`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(test.input, test.opts...)
+			if test.expected == "" {
+				if err != nil {
+					t.Errorf(testTemplate, test.input, nil, err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.expected {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			var e *Error
+			if !errors.As(err, &e) || e.Kind != KindParse {
+				t.Errorf(testTemplate, test.input, KindParse, err)
+			}
+		})
+	}
+}
+
+func TestWithAllowEmptyRegex(t *testing.T) {
+	target := testTarget{"Name": "slime", "Empty": ""}
+	type expected struct {
+		val bool
+		err string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected expected
+	}{
+		{name: "match all", input: `Name =~ ""`, opts: []Option{WithAllowEmptyRegex()}, expected: expected{val: true}},
+		{name: "match empty", input: `Empty =~ ''`, opts: []Option{WithAllowEmptyRegex()}, expected: expected{val: true}},
+		{name: "negated", input: `Name !~ ""`, opts: []Option{WithAllowEmptyRegex()}, expected: expected{val: false}},
+		{name: "list", input: `Name =~ ("^x", "")`, opts: []Option{WithAllowEmptyRegex()}, expected: expected{val: true}},
+		{name: "anchored", input: `Name matches ""`, opts: []Option{WithAllowEmptyRegex()}, expected: expected{val: false}},
+		{name: "anchored empty", input: `Empty matches ""`, opts: []Option{WithAllowEmptyRegex()}, expected: expected{val: true}},
+		{name: "default", input: `Name =~ ""`, expected: expected{err: `parse error: invalid regex "" at 1:9: empty pattern`}},
+		{name: "default list", input: `Name =~ ("^x", "")`, expected: expected{err: `parse error: invalid regex "" at 1:16: empty pattern`}},
+		{name: "default case-insensitive", input: `Name =~* ""`, expected: expected{err: `parse error: invalid regex "" at 1:10: empty pattern`}},
+		{name: "default negated case-insensitive", input: `Name !~* ""`, expected: expected{err: `parse error: invalid regex "" at 1:10: empty pattern`}},
+		{name: "default matches", input: `Name matches ""`, expected: expected{err: `parse error: invalid regex "" at 1:14: empty pattern`}},
+		{name: "default imatches", input: `Name imatches ""`, expected: expected{err: `parse error: invalid regex "" at 1:15: empty pattern`}},
+		{name: "default case-insensitive list", input: `Name =~* ("^x", "")`, expected: expected{err: `parse error: invalid regex "" at 1:17: empty pattern`}},
+		{name: "case-insensitive", input: `Name =~* ""`, opts: []Option{WithAllowEmptyRegex()}, expected: expected{val: true}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if test.expected.err != "" {
+				if err == nil || err.Error() != test.expected.err {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			actual, err := expr.Eval(target)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			if actual != test.expected.val {
+				t.Errorf(testTemplate, test.input, test.expected.val, actual)
+			}
+			if s := expr.String(); s != test.input {
+				t.Errorf(testTemplate, test.input, test.input, s)
+			}
+		})
+	}
+}
+
+func TestWithFieldDurationUnit(t *testing.T) {
+	target := testTarget{"TimeoutMs": 1500, "Retries": uint8(3), "Huge": int64(math.MaxInt64), "Name": "slime"}
+	type expected struct {
+		val bool
+		err string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected expected
+	}{
+		{name: "milliseconds", input: `TimeoutMs == 1500ms`, opts: []Option{WithFieldDurationUnit("TimeoutMs", time.Millisecond)}, expected: expected{val: true}},
+		{name: "fractional seconds", input: `TimeoutMs == 1.5s`, opts: []Option{WithFieldDurationUnit("TimeoutMs", time.Millisecond)}, expected: expected{val: true}},
+		{name: "ordering", input: `TimeoutMs < 2s && TimeoutMs > 1s`, opts: []Option{WithFieldDurationUnit("TimeoutMs", time.Millisecond)}, expected: expected{val: true}},
+		{name: "not equal", input: `TimeoutMs == 2s`, opts: []Option{WithFieldDurationUnit("TimeoutMs", time.Millisecond)}, expected: expected{val: false}},
+		{name: "number literal", input: `TimeoutMs == 1500`, opts: []Option{WithFieldDurationUnit("TimeoutMs", time.Millisecond)}, expected: expected{val: true}},
+		{name: "unsigned", input: `Retries == 3m`, opts: []Option{WithFieldDurationUnit("Retries", time.Minute)}, expected: expected{val: true}},
+		{name: "chain", input: `1s < TimeoutMs <= 1500ms`, opts: []Option{WithFieldDurationUnit("TimeoutMs", time.Millisecond)}, expected: expected{val: true}},
+		{name: "replaced", input: `TimeoutMs == 1500s`, opts: []Option{WithFieldDurationUnit("TimeoutMs", time.Millisecond), WithFieldDurationUnit("TimeoutMs", time.Second)}, expected: expected{val: true}},
+		{name: "string field", input: `Name != 1s`, opts: []Option{WithFieldDurationUnit("Name", time.Millisecond)}, expected: expected{val: true}},
+		{name: "out of range", input: `Huge > 1s`, opts: []Option{WithFieldDurationUnit("Huge", time.Millisecond)}, expected: expected{err: `eval error: duration out of range for "Huge" at 1:1: 9223372036854775807 in units of 1ms`}},
+		{name: "invalid unit", input: `TimeoutMs > 1s`, opts: []Option{WithFieldDurationUnit("TimeoutMs", 0)}, expected: expected{err: `parse error: invalid duration unit of field "TimeoutMs": 0s`}},
+		{name: "default", input: `TimeoutMs == 1500ms`, expected: expected{err: `eval error: cannot compare int field "TimeoutMs" with non-numeric value "1500ms" at 1:14`}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err == nil {
+				var ok bool
+				ok, err = expr.Eval(target)
+				if err == nil && ok != test.expected.val {
+					t.Errorf(testTemplate, test.input, test.expected.val, ok)
+				}
+			}
+			if test.expected.err == "" && err != nil || test.expected.err != "" && (err == nil || err.Error() != test.expected.err) {
+				t.Errorf(testTemplate, test.input, test.expected.err, err)
+			}
+		})
+	}
+}
+
+func TestWithIdentChars(t *testing.T) {
+	target := testTarget{"x-request-id": "abc", "http:status": 503, "Start": time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), "End": time.Date(2025, 1, 1, 0, 30, 0, 0, time.UTC)}
+	type expected struct {
+		val bool
+		err string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected expected
+	}{
+		{name: "hyphen", input: `x-request-id == "abc"`, opts: []Option{WithIdentChars("-")}, expected: expected{val: true}},
+		{name: "merged", input: `x-request-id != "" && http:status >= 500`, opts: []Option{WithIdentChars("-"), WithIdentChars(":")}, expected: expected{val: true}},
+		{name: "offset with spaces", input: `End < Start + 1h && End > Start - 1h`, opts: []Option{WithIdentChars("-+")}, expected: expected{val: true}},
+		{name: "offset without spaces", input: `End > Start-1h`, opts: []Option{WithIdentChars("-")}, expected: expected{err: `parse error: expected value (string, number, duration, time or bool), got identifier at 1:7: "Start-1h"`}},
+		{name: "reserved", input: `a=b == 1`, opts: []Option{WithIdentChars("=")}, expected: expected{err: `parse error: invalid identifier character '='`}},
+		{name: "space", input: `a b == 1`, opts: []Option{WithIdentChars(" ")}, expected: expected{err: `parse error: invalid identifier character ' '`}},
+		{name: "default", input: `x-request-id == "abc"`, expected: expected{err: `parse error: expected comparison operator, got number at 1:2: "-"`}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err == nil {
+				var ok bool
+				ok, err = expr.Eval(target)
+				if err == nil && ok != test.expected.val {
+					t.Errorf(testTemplate, test.input, test.expected.val, ok)
+				}
+				if s := expr.String(); err == nil && s != test.input {
+					t.Errorf(testTemplate, test.input, test.input, s)
+				}
+			}
+			if test.expected.err == "" && err != nil || test.expected.err != "" && (err == nil || err.Error() != test.expected.err) {
+				t.Errorf(testTemplate, test.input, test.expected.err, err)
+			}
+		})
+	}
+}
+
+func TestWithSubFilters(t *testing.T) {
+	isAdmin, err := Parse(`Role == "admin" || Role == "root"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	isActive, err := Parse(`!(Disabled == true)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subs := map[string]*Expr{"IsAdmin": isAdmin, "IsActive": isActive}
+	target := testTarget{"Role": "root", "Score": 20, "Disabled": false, "IsAdmin": "field", "TimeoutMs": 1500}
+	type expected struct {
+		val bool
+		str string
+		err string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected expected
+	}{
+		{name: "and", input: `IsAdmin && Score > 10`, opts: []Option{WithSubFilters(subs)}, expected: expected{val: true, str: `(Role == "admin" || Role == "root") && Score > 10`}},
+		{name: "alone", input: `IsAdmin`, opts: []Option{WithSubFilters(subs)}, expected: expected{val: true, str: `Role == "admin" || Role == "root"`}},
+		{name: "nested", input: `Score < 10 || (IsActive && !IsAdmin)`, opts: []Option{WithSubFilters(subs)}, expected: expected{val: false, str: `Score < 10 || !(Disabled == true) && !(Role == "admin" || Role == "root")`}},
+		{name: "field of the same name", input: `IsAdmin == "field" && IsAdmin`, opts: []Option{WithSubFilters(subs)}, expected: expected{val: true, str: `IsAdmin == "field" && (Role == "admin" || Role == "root")`}},
+		{name: "merged", input: `IsAdmin && IsActive`, opts: []Option{WithSubFilters(map[string]*Expr{"IsAdmin": isAdmin}), WithSubFilters(map[string]*Expr{"IsActive": isActive})}, expected: expected{val: true, str: `(Role == "admin" || Role == "root") && !(Disabled == true)`}},
+		{name: "fold", input: `ISADMIN`, opts: []Option{WithCaseInsensitiveFields(), WithSubFilters(subs)}, expected: expected{val: true, str: `Role == "admin" || Role == "root"`}},
+		{name: "skip fields", input: `IsAdmin && Score > 10`, opts: []Option{WithSkipFields("Role"), WithSubFilters(map[string]*Expr{"IsAdmin": mustParse(t, `Role == "admin"`)})}, expected: expected{val: true, str: `Role == "admin" && Score > 10`}},
+		{name: "case-insensitive values", input: `IsAdmin && Score > 10`, opts: []Option{WithCaseInsensitiveValues("Role"), WithSubFilters(map[string]*Expr{"IsAdmin": mustParse(t, `Role == "ROOT"`)})}, expected: expected{val: true, str: `Role == "ROOT" && Score > 10`}},
+		{name: "case-insensitive strings", input: `IsAdmin && Score > 10`, opts: []Option{WithCaseInsensitiveStrings(), WithSubFilters(map[string]*Expr{"IsAdmin": mustParse(t, `Role == "ROOT"`)})}, expected: expected{val: true, str: `Role == "ROOT" && Score > 10`}},
+		{name: "duration unit", input: `IsSlow`, opts: []Option{WithFieldDurationUnit("TimeoutMs", time.Millisecond), WithSubFilters(map[string]*Expr{"IsSlow": mustParse(t, `TimeoutMs > 1s`)})}, expected: expected{val: true, str: `TimeoutMs > 1s`}},
+		{name: "max nodes", input: `All`, opts: []Option{WithMaxNodes(2), WithSubFilters(map[string]*Expr{"All": mustParse(t, `A == 1 && B == 2 && C == 3 && D == 4`)})}, expected: expected{err: `parse error: too many nodes: exceeded limit 2 at 1:4`}},
+		{name: "regex disabled", input: `Score > 10 && IsRoot`, opts: []Option{WithRegexDisabled(), WithSubFilters(map[string]*Expr{"IsRoot": mustParse(t, `Role =~ "ro+t"`)})}, expected: expected{err: `parse error: regex operators are disabled in sub-filter "IsRoot" at 1:15: "=~"`}},
+		{name: "unknown", input: `IsAdmin && IsOwner`, opts: []Option{WithSubFilters(subs)}, expected: expected{err: `parse error: unknown sub-filter "IsOwner" at 1:12`}},
+		{name: "nil", input: `IsAdmin`, opts: []Option{WithSubFilters(map[string]*Expr{"IsAdmin": nil})}, expected: expected{err: `parse error: invalid sub-filter "IsAdmin": empty expression`}},
+		{name: "without option", input: `IsAdmin && Score > 10`, expected: expected{err: `parse error: expected comparison operator, got logical AND operator at 1:9: "&&"`}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err == nil {
+				var ok bool
+				ok, err = expr.Eval(target)
+				if err == nil && ok != test.expected.val {
+					t.Errorf(testTemplate, test.input, test.expected.val, ok)
+				}
+				if s := expr.String(); err == nil && s != test.expected.str {
+					t.Errorf(testTemplate, test.input, test.expected.str, s)
+				}
+			}
+			if test.expected.err == "" && err != nil || test.expected.err != "" && (err == nil || err.Error() != test.expected.err) {
+				t.Errorf(testTemplate, test.input, test.expected.err, err)
+			}
+		})
+	}
+}
+
+func TestWithDecimalComma(t *testing.T) {
+	target := testTarget{"Price": 1000.5, "Count": 1000000, "Rate": 0.25, "Counts": []float64{1, 1000}}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected bool
+		str      string
+	}{
+		{name: "decimal comma", input: `Price == 1.000,5`, opts: []Option{WithDecimalComma()}, expected: true, str: `Price == 1000,5`},
+		{name: "grouped integer", input: `Count == 1.000.000`, opts: []Option{WithDecimalComma()}, expected: true, str: `Count == 1000000`},
+		{name: "fraction", input: `Rate < 0,3 && Rate > -0,3`, opts: []Option{WithDecimalComma()}, expected: true, str: `Rate < 0,3 && Rate > -0,3`},
+		{name: "list", input: `Counts containsall (1, 1.000)`, opts: []Option{WithDecimalComma()}, expected: true, str: `Counts containsall (1, 1000)`},
+		{name: "arithmetic", input: `Count % 1.000 == 0`, opts: []Option{WithDecimalComma()}, expected: true, str: `Count % 1000 == 0`},
+		{name: "base prefix", input: `Count > 0x10`, opts: []Option{WithDecimalComma()}, expected: true, str: `Count > 0x10`},
+		{name: "default", input: `Price == 1000.5`, expected: true, str: `Price == 1000.5`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			actual, err := expr.Eval(target)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			if actual != test.expected {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
+			}
+			if s := expr.String(); s != test.str {
+				t.Errorf(testTemplate, test.input, test.str, s)
+			}
+			if _, err := Parse(expr.String(), test.opts...); err != nil {
+				t.Errorf(testTemplate, test.input, nil, err)
+			}
+		})
+	}
+	errs := []struct {
+		input    string
+		expected string
+	}{
+		{input: `Counts containsany (1,5)`, expected: `parse error: ambiguous decimal comma in list at 1:21: "1,5"`},
+		{input: `Counts containsany (1, 1.000,5)`, expected: `parse error: ambiguous decimal comma in list at 1:24: "1000,5"`},
+	}
+	for _, test := range errs {
+		if _, err := Parse(test.input, WithDecimalComma()); err == nil || err.Error() != test.expected {
+			t.Errorf(testTemplate, test.input, test.expected, err)
+		}
+	}
+}
+
+func TestWithFloatFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected string
+	}{
+		{name: "default", input: `Temp > 3.14159 && Rate == 2.50`, expected: `Temp > 3.14159 && Rate == 2.50`},
+		{name: "fixed", input: `Temp > 3.14159`, opts: []Option{WithFloatFormat('f', 2)}, expected: `Temp > 3.14`},
+		{name: "shortest", input: `Temp > 2.50`, opts: []Option{WithFloatFormat('g', -1)}, expected: `Temp > 2.5`},
+		{name: "exponent", input: `Temp > 1500.0`, opts: []Option{WithFloatFormat('e', 1)}, expected: `Temp > 1.5e+03`},
+		{name: "integer", input: `HP > 5 && Mask == 0x10`, opts: []Option{WithFloatFormat('f', 2)}, expected: `HP > 5 && Mask == 0x10`},
+		{name: "list", input: `Temps containsany (1.125, 2)`, opts: []Option{WithFloatFormat('f', 1)}, expected: `Temps containsany (1.1, 2)`},
+		{name: "decimal comma", input: `Temp > 3,14159`, opts: []Option{WithDecimalComma(), WithFloatFormat('f', 2)}, expected: `Temp > 3,14`},
+		{name: "invalid verb", input: `Temp > 1.5`, opts: []Option{WithFloatFormat('x', 2)}, expected: `parse error: invalid float format 'x'`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err != nil {
+				if err.Error() != test.expected {
+					t.Errorf(testTemplate, test.input, test.expected, err)
+				}
+				return
+			}
+			if actual := expr.String(); actual != test.expected {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
+			}
+			if _, err := Parse(expr.String(), test.opts...); err != nil {
+				t.Errorf(testTemplate, test.input, nil, err)
+			}
+		})
+	}
+}
@@ -3,15 +3,18 @@ package filter
 import (
 	"errors"
 	"fmt"
+	"math"
 	"regexp"
-	"strconv"
+	"slices"
+	"strings"
 	"sync"
-	"time"
+	"unicode"
 )
 
 // Parse parses a string expression into an Expr.
-func Parse(input string) (*Expr, error) {
-	p, err := newParser(input)
+// Options configure the behavior of the returned expression.
+func Parse(input string, opts ...Option) (*Expr, error) {
+	p, err := newParser(input, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -19,25 +22,98 @@ func Parse(input string) (*Expr, error) {
 	if err != nil {
 		return nil, err
 	}
-	if p.peek().typ != tokenEOF {
+	if err := p.checkLimits(p.current); err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.typ != tokenEOF {
+		if t.typ == tokenRparen {
+			return nil, &Error{
+				Kind: KindParse,
+				Err:  fmt.Errorf("unexpected right parenthesis at %d:%d", t.line, t.col),
+				Line: t.line,
+				Col:  t.col,
+			}
+		}
+		if t.typ == tokenBitAnd || t.typ == tokenBitOr {
+			// A single & or | between comparisons is a mistyped logical operator rather than arithmetic.
+			return nil, &Error{
+				Kind: KindParse,
+				Err:  fmt.Errorf("unexpected %s between comparisons at %d:%d: %q, use %q", t.typ, t.line, t.col, t.v, t.v+t.v),
+				Line: t.line,
+				Col:  t.col,
+			}
+		}
 		return nil, &Error{
 			Kind: KindParse,
-			Err:  fmt.Errorf("unexpected token after parsing: %s", p.peek().v),
+			Err:  fmt.Errorf("unexpected token after parsing at %d:%d: %q", t.line, t.col, t.v),
+			Line: t.line,
+			Col:  t.col,
 		}
 	}
-	return &Expr{
+	if err := p.expandSubFilters(); err != nil {
+		return nil, err
+	}
+	if err := p.checkLimits(p.current); err != nil {
+		return nil, err
+	}
+	expr := &Expr{
 		parser: p,
 		root:   n,
-	}, nil
+	}
+	if p.opts.contradictions {
+		if err := expr.checkContradictions(n); err != nil {
+			return nil, err
+		}
+	}
+	return expr, nil
+}
+
+// LexErrors scans the input with the lexer options and returns its lexical errors, such as for a linter.
+// Unlike Parse, which stops at the first error, the scan skips each invalid utf8 byte in a string
+// literal and continues, so that every invalid encoding is reported. It stops at any other lexical
+// error, which is returned last. The input is not parsed, so syntax errors are not reported.
+func LexErrors(input string, opts ...Option) []error {
+	p, err := newParser(input, opts...)
+	if err != nil {
+		return []error{err}
+	}
+	p.lexer.recoverUTF8 = true
+	var errs []error
+	for {
+		t := p.lexer.nextToken()
+		switch t.typ {
+		case tokenEOF:
+			return errs
+		case tokenError:
+			errs = append(errs, &Error{
+				Kind: KindLex,
+				Err:  errors.New(t.v),
+				Line: t.line,
+				Col:  t.col,
+			})
+			if p.lexer.state == nil {
+				return errs
+			}
+		}
+	}
 }
 
 // Epsilon is a small value used to compare numerical equality.
+// It is the default tolerance, which can be changed per expression with WithEpsilon.
 const Epsilon = 1e-9
 
 // MaxParen is the maximum number of opening '(' tokens allowed in one expression.
 // Guards against pathological inputs causing excessive work. Counts total openings, not current depth.
 const MaxParen = 256
 
+// DefaultMaxTokens is the default maximum number of tokens allowed in one expression,
+// which can be changed per expression with WithMaxTokens.
+const DefaultMaxTokens = 65536
+
+// DefaultMaxNodes is the default maximum number of nodes allowed in the tree of one expression,
+// which can be changed per expression with WithMaxNodes.
+const DefaultMaxNodes = 65536
+
 // regexMap stores compiled regex patterns to reduce allocations on repeated parses.
 // key: pattern string, value: *regexp.Regexp
 var regexMap sync.Map
@@ -49,46 +125,157 @@ type parser struct {
 	current    token               // current token
 	peeked     bool                // indicates if the next token has been peeked
 	parenCount int                 // Number of opening parentheses
+	tokens     int                 // Number of tokens consumed
+	parens     []token             // Stack of unclosed left parentheses
 	idents     map[string]struct{} // Unique identifier encountered in field cache size settings
+	opts       options             // Configuration of the expression
+	subs       []subFilter         // References to sub-filters, expanded after parsing
+}
+
+// subFilter is a reference to a sub-filter given to WithSubFilters at the node of index i.
+type subFilter struct {
+	i    int
+	expr *Expr
 }
 
 // newParser creates a new parser for the given input.
-func newParser(input string) (parser, error) {
+func newParser(input string, opts ...Option) (parser, error) {
 	if input == "" {
 		return parser{}, &Error{
 			Kind: KindParse,
 			Err:  fmt.Errorf("empty input"),
 		}
 	}
-	return parser{
+	p := parser{
 		lexer:  newLexer(input),
-		nodes:  make([]node, 0, 16),
+		nodes:  make([]node, 0, nodeCapacity(input)),
 		idents: make(map[string]struct{}),
-	}, nil
+		opts: options{
+			epsilon:   Epsilon,
+			tolerance: Epsilon,
+			maxTokens: DefaultMaxTokens,
+			maxNodes:  DefaultMaxNodes,
+		},
+	}
+	for _, opt := range opts {
+		opt(&p.opts)
+	}
+	p.lexer.extendedUnits = p.opts.extendedUnits
+	p.lexer.literalSingleQuotes = p.opts.literalSingleQuotes
+	p.lexer.decimalComma = p.opts.decimalComma
+	for _, r := range p.opts.identChars {
+		if isAlphaNumeric(r) || unicode.IsSpace(r) || !unicode.IsPrint(r) || strings.ContainsRune(reservedIdentChars, r) {
+			return parser{}, &Error{
+				Kind: KindParse,
+				Err:  fmt.Errorf("invalid identifier character %q", r),
+			}
+		}
+	}
+	p.lexer.identChars = p.opts.identChars
+	if len(p.opts.aliases) > 0 {
+		p.lexer.aliases = make(map[string]tokenType, len(p.opts.aliases))
+		for word, op := range p.opts.aliases {
+			typ, ok := op.tokenType()
+			if !ok {
+				return parser{}, &Error{
+					Kind: KindParse,
+					Err:  fmt.Errorf("invalid operator alias %q: invalid operator: %d", word, op),
+				}
+			}
+			// in may be registered in place of the membership operator.
+			if !isIdentifier(word) && word != "in" {
+				return parser{}, &Error{
+					Kind: KindParse,
+					Err:  fmt.Errorf("invalid operator alias %q: not an identifier", word),
+				}
+			}
+			p.lexer.aliases[word] = typ
+		}
+	}
+	for name, sub := range p.opts.subFilters {
+		if sub.empty() {
+			return parser{}, &Error{
+				Kind: KindParse,
+				Err:  fmt.Errorf("invalid sub-filter %q: empty expression", name),
+			}
+		}
+	}
+	if p.opts.floatVerb != 0 && strings.IndexByte("eEfgG", p.opts.floatVerb) < 0 {
+		return parser{}, &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("invalid float format %q", p.opts.floatVerb),
+		}
+	}
+	for field, unit := range p.opts.units {
+		if unit <= 0 {
+			return parser{}, &Error{
+				Kind: KindParse,
+				Err:  fmt.Errorf("invalid duration unit of field %q: %s", field, unit),
+			}
+		}
+	}
+	return p, nil
 }
 
 // next returns the next token from the lexer.
 func (p *parser) next() (token, error) {
 	if p.peeked {
 		p.peeked = false
-		if p.current.typ == tokenError {
-			return p.current, &Error{
-				Kind: KindLex,
-				Err:  errors.New(p.current.v),
-			}
-		}
-		return p.current, nil
+	} else {
+		p.current = p.lexer.nextToken()
 	}
-	p.current = p.lexer.nextToken()
 	if p.current.typ == tokenError {
-		return p.current, &Error{
-			Kind: KindLex,
-			Err:  errors.New(p.current.v),
-		}
+		return p.current, p.lexError(p.current)
+	}
+	if p.current.typ != tokenEOF {
+		p.tokens++
+	}
+	if err := p.checkLimits(p.current); err != nil {
+		return p.current, err
 	}
 	return p.current, nil
 }
 
+// checkLimits reports an error at the token if the expression has more tokens or nodes than allowed.
+func (p *parser) checkLimits(t token) error {
+	if n := p.opts.maxTokens; n > 0 && p.tokens > n {
+		return &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("too many tokens: exceeded limit %d at %d:%d", n, t.line, t.col),
+			Line: t.line,
+			Col:  t.col,
+		}
+	}
+	if n := p.opts.maxNodes; n > 0 && len(p.nodes) > n {
+		return &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("too many nodes: exceeded limit %d at %d:%d", n, t.line, t.col),
+			Line: t.line,
+			Col:  t.col,
+		}
+	}
+	return nil
+}
+
+// lexError converts an error token into an error.
+// The lexer reports unclosed parentheses only at the end of input, so the error
+// is attributed to the innermost left parenthesis that is still open.
+func (p *parser) lexError(t token) error {
+	if t.pos == len(p.lexer.input) && len(p.parens) > 0 {
+		lp := p.parens[len(p.parens)-1]
+		return &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("unclosed left parenthesis at %d:%d", lp.line, lp.col),
+			Line: lp.line,
+			Col:  lp.col,
+		}
+	}
+	return &Error{
+		Kind: KindLex,
+		Err:  errors.New(t.v),
+	}
+}
+
 // peek returns the next token without consuming it.
 func (p *parser) peek() token {
 	if !p.peeked {
@@ -122,28 +309,106 @@ func unquote(t token) string {
 	return t.v
 }
 
+// isNonFiniteLiteral reports whether the identifier spells a non-finite number such as Inf or NaN.
+func isNonFiniteLiteral(s string) bool {
+	switch strings.ToLower(s) {
+	case "inf", "infinity", "nan":
+		return true
+	default:
+		return false
+	}
+}
+
+// reservedIdentChars lists the characters that cannot be given to WithIdentChars,
+// since they delimit strings, lists and keys or start operators.
+const reservedIdentChars = "\"'`()[],=!<>&|~%^*"
+
+// nodeCapacity estimates the number of nodes of the input from its logical operators,
+// so that a single comparison such as Status == "active" allocates a single node,
+// while longer expressions rarely need to grow the slice. It is at most 16.
+func nodeCapacity(input string) int {
+	n := strings.Count(input, "&&") + strings.Count(input, "||") + strings.Count(input, "!")
+	return min(2*n+1, 16)
+}
+
 // handleRegex processes a regex token and associates it with a node.
 // Caches compiled regex patterns to reduce allocations on repeated parses.
 func (p *parser) handleRegex(t token, i int) error {
-	if t.v == "" {
-		return &Error{
+	re, err := p.compileRegex(t, p.nodes[i].op.typ)
+	if err != nil {
+		return err
+	}
+	p.nodes[i].re = re
+	return nil
+}
+
+// compileRegex compiles the pattern of a regex token for the operator, or loads it from the cache.
+func (p *parser) compileRegex(t token, op tokenType) (*regexp.Regexp, error) {
+	written := t.v
+	if op.isCaseInsensitiveRegexOperatorType() {
+		written = strings.TrimPrefix(written, "(?i)")
+	}
+	if written == "" && !p.opts.emptyRegex {
+		return nil, &Error{
 			Kind: KindParse,
-			Err:  fmt.Errorf("invalid regex %q at %d:%d: empty pattern", t.v, t.line, t.col),
+			Err:  fmt.Errorf("invalid regex %q at %d:%d: empty pattern", written, t.line, t.col),
 		}
 	}
-	if cached, ok := regexMap.Load(t.v); ok {
-		p.nodes[i].re = cached.(*regexp.Regexp)
-	} else {
-		re, err := regexp.Compile(t.v)
-		if err != nil {
-			return &Error{
-				Kind: KindParse,
-				Err:  fmt.Errorf("invalid regex %q at %d:%d: %w", t.v, t.line, t.col, err),
+	pattern := t.v
+	if op.isAnchoredRegexOperatorType() {
+		// The anchored pattern is cached under its own key, apart from the pattern of =~.
+		pattern = "^(?:" + pattern + ")$"
+	}
+	if cached, ok := regexMap.Load(pattern); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("invalid regex %q at %d:%d: %w", written, t.line, t.col, err),
+		}
+	}
+	regexMap.Store(pattern, re)
+	return re, nil
+}
+
+// Recompile compiles the patterns of the regex comparisons of the expression again from the patterns
+// as written, such as after the compiled regexes were lost when the expression was restored
+// from another representation. Patterns are taken from the regex cache when they are cached.
+// The first invalid pattern is returned as a parse error, and the expression is then left unchanged.
+// The nodes are copied before they are written, so expressions derived from this one, such as by
+// Optimize or Not, are not affected. Recompile still modifies the expression itself, so it must not be
+// called while the expression is being evaluated, nor on an expression shared by ParseCached.
+func (e *Expr) Recompile() error {
+	if e == nil {
+		return nil
+	}
+	p := &e.parser
+	nodes := slices.Clone(p.nodes)
+	for i := range nodes {
+		n := &nodes[i]
+		if n.typ != nodeComparison || !n.op.typ.isRegexOperatorType() {
+			continue
+		}
+		if n.isList() {
+			n.items = slices.Clone(n.items)
+			for j := range n.items {
+				re, err := p.compileRegex(n.items[j].val, n.op.typ)
+				if err != nil {
+					return err
+				}
+				n.items[j].re = re
 			}
+			continue
+		}
+		re, err := p.compileRegex(n.val, n.op.typ)
+		if err != nil {
+			return err
 		}
-		regexMap.Store(t.v, re)
-		p.nodes[i].re = re
+		n.re = re
 	}
+	p.nodes = nodes
 	return nil
 }
 
@@ -195,14 +460,16 @@ func (p *parser) parseAND() (int, error) {
 	return left, nil
 }
 
-// parseNOT parses a NOT expression.
+// parseNOT parses a NOT expression. ! binds tighter than && and ||, and applies to the
+// following comparison or group as a whole, so !HP>50 means !(HP>50).
+// It may be repeated, as in !!X, where each ! negates the rest.
 func (p *parser) parseNOT() (int, error) {
 	if p.peek().typ == tokenNOT {
 		t, err := p.next()
 		if err != nil {
 			return 0, err
 		}
-		child, err := p.parsePrimary()
+		child, err := p.parseNOT()
 		if err != nil {
 			return 0, err
 		}
@@ -211,7 +478,8 @@ func (p *parser) parseNOT() (int, error) {
 	return p.parsePrimary()
 }
 
-// parsePrimary parses a primary expression.
+// parsePrimary parses a primary expression. A lone boolean literal, such as true in true && Score > 1,
+// is a constant that holds or fails regardless of the target, such as for a fallback rule.
 func (p *parser) parsePrimary() (int, error) {
 	t := p.peek()
 	switch t.typ {
@@ -226,6 +494,7 @@ func (p *parser) parsePrimary() (int, error) {
 				Err:  fmt.Errorf("too many parentheses: exceeded limit %d at %d:%d", MaxParen, t.line, t.col),
 			}
 		}
+		p.parens = append(p.parens, t)
 		expr, err := p.parseExpr()
 		if err != nil {
 			return 0, err
@@ -233,9 +502,17 @@ func (p *parser) parsePrimary() (int, error) {
 		if _, err := p.expect(tokenRparen); err != nil {
 			return 0, err
 		}
+		p.parens = p.parens[:len(p.parens)-1]
 		return expr, nil
 	case tokenIdent:
 		return p.parseComparison()
+	case tokenBool:
+		if _, err := p.next(); err != nil {
+			return 0, err
+		}
+		return newNodeBool(p, t), nil
+	case tokenString, tokenRawString, tokenNumber, tokenTime, tokenDuration, tokenNow:
+		return p.parseChain()
 	default:
 		return 0, &Error{
 			Kind: KindParse,
@@ -246,12 +523,89 @@ func (p *parser) parsePrimary() (int, error) {
 
 // parseComparison parses a comparison expression.
 func (p *parser) parseComparison() (int, error) {
-	ident, err := p.expect(tokenIdent)
+	ident, fn, key, err := p.parseField()
 	if err != nil {
 		return 0, err
 	}
-	if p.idents != nil {
-		p.idents[ident.v] = struct{}{}
+	if p.opts.subFilters != nil && fn == transformNone && key.typ == 0 {
+		switch p.peek().typ {
+		case tokenAND, tokenOR, tokenRparen, tokenEOF:
+			return p.parseSubFilter(ident)
+		}
+	}
+	i, err := p.parseOperation(ident, fn)
+	if err != nil {
+		return 0, err
+	}
+	p.nodes[i].key = key
+	return i, nil
+}
+
+// parseSubFilter creates a placeholder node for the sub-filter named by an identifier without an operator,
+// such as IsAdmin in IsAdmin && Score > 10. The node is replaced by the sub-filter after parsing.
+func (p *parser) parseSubFilter(ident token) (int, error) {
+	sub := p.opts.subFilter(ident.v)
+	if sub == nil {
+		return 0, &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("unknown sub-filter %q at %d:%d", ident.v, ident.line, ident.col),
+			Line: ident.line,
+			Col:  ident.col,
+		}
+	}
+	delete(p.idents, ident.v)
+	i := newNodeComparison(p, ident, token{}, token{})
+	p.subs = append(p.subs, subFilter{i: i, expr: sub})
+	return i, nil
+}
+
+// expandSubFilters replaces the placeholder nodes of sub-filters with the trees of the sub-filters,
+// whose inputs are appended to the input so that the positions of their tokens stay valid.
+// The flags that follow from the options for fields are set again from the options of the expression,
+// and regex operators of the sub-filters are rejected under WithRegexDisabled at the placeholder.
+func (p *parser) expandSubFilters() error {
+	for _, s := range p.subs {
+		ident := p.nodes[s.i].ident
+		base := p.graft(&s.expr.parser)
+		for i := base; i < len(p.nodes); i++ {
+			n := p.nodes[i]
+			if n.typ != nodeComparison {
+				continue
+			}
+			if p.opts.noRegex && n.op.typ.isRegexOperatorType() {
+				return &Error{
+					Kind: KindParse,
+					Err:  fmt.Errorf("regex operators are disabled in sub-filter %q at %d:%d: %q", ident.v, ident.line, ident.col, n.op.v),
+					Line: ident.line,
+					Col:  ident.col,
+				}
+			}
+			p.setFieldFlags(i)
+		}
+		p.nodes[s.i] = p.nodes[base+s.expr.root]
+	}
+	p.subs = nil
+	return nil
+}
+
+// setFieldFlags sets the skip, fold and unit of the comparison node at index i from
+// WithSkipFields, WithCaseInsensitiveValues, WithCaseInsensitiveStrings and WithFieldDurationUnit.
+func (p *parser) setFieldFlags(i int) {
+	n := &p.nodes[i]
+	n.skip = p.opts.hasField(p.opts.skip, n.ident.v) || n.isOffset() && p.opts.hasField(p.opts.skip, n.ref.v)
+	n.fold = (n.op.typ == tokenEQ || n.op.typ == tokenNEQ) && (p.opts.foldStrings || p.opts.hasField(p.opts.ignoreCase, n.ident.v))
+	n.unit = 0
+	if n.val.typ == tokenDuration {
+		n.unit = p.opts.durationUnit(n.ident.v)
+	}
+}
+
+// parseOperation parses the rest of a comparison after its field: an optional arithmetic operator,
+// the comparison operator and the value, a list of values or another field with an offset.
+func (p *parser) parseOperation(ident token, fn transform) (int, error) {
+	arith, arg, err := p.parseArith(ident, fn)
+	if err != nil {
+		return 0, err
 	}
 	op, err := p.next()
 	if err != nil {
@@ -263,44 +617,613 @@ func (p *parser) parseComparison() (int, error) {
 			Err:  fmt.Errorf("expected comparison operator, got %s at %d:%d: %q", op.typ, op.line, op.col, op.v),
 		}
 	}
+	if arith.typ.isArithOperatorType() {
+		if op.typ != tokenEQ && op.typ != tokenNEQ && !op.typ.isOrderingOperatorType() {
+			return 0, &Error{
+				Kind: KindParse,
+				Err:  fmt.Errorf("invalid operator for %s at %d:%d: %q", arith.typ.arithName(), op.line, op.col, op.typ.literal()),
+				Line: op.line,
+				Col:  op.col,
+			}
+		}
+		val, err := p.next()
+		if err != nil {
+			return 0, err
+		}
+		i, err := p.newComparison(ident, fn, op, val)
+		if err != nil {
+			return 0, err
+		}
+		return i, p.setArith(i, arith, arg)
+	}
+	if fn == transformLen {
+		return p.parseLen(ident, op)
+	}
+	if p.opts.noRegex && op.typ.isRegexOperatorType() {
+		return 0, &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("regex operators are disabled at %d:%d: %q", op.line, op.col, op.v),
+			Line: op.line,
+			Col:  op.col,
+		}
+	}
+	if op.typ.isListOperatorType() || (op.typ.isRegexOperatorType() && p.peek().typ == tokenLparen) {
+		return p.parseList(ident, fn, op)
+	}
+	val, err := p.next()
+	if err != nil {
+		return 0, err
+	}
+	if val.typ == tokenIdent {
+		offset, ok, err := p.parseOffset(val)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			return p.newOffsetComparison(ident, fn, op, val, offset)
+		}
+	}
+	return p.newComparison(ident, fn, op, val)
+}
+
+// parseLen parses the value of a comparison of the length of a field, such as 3 in len(Name) > 3.
+// The length is compared as a number, so only ==, != and the ordering operators apply.
+func (p *parser) parseLen(ident, op token) (int, error) {
+	if op.typ != tokenEQ && op.typ != tokenNEQ && !op.typ.isOrderingOperatorType() {
+		return 0, &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("invalid operator for len at %d:%d: %q", op.line, op.col, op.typ.literal()),
+			Line: op.line,
+			Col:  op.col,
+		}
+	}
 	val, err := p.next()
 	if err != nil {
 		return 0, err
 	}
+	return p.newComparison(ident, transformLen, op, val)
+}
+
+// parseArith parses an arithmetic operator and its operand after a field, such as % 10 in ID % 10 == 0
+// or & 0x4 in Perms & 0x4 == 0x4. The operand must be an integer literal, and a non-zero one for %.
+// If the next token is not an arithmetic operator, nothing is consumed and zero tokens are returned.
+func (p *parser) parseArith(ident token, fn transform) (token, token, error) {
+	if !p.peek().typ.isArithOperatorType() {
+		return token{}, token{}, nil
+	}
+	op, err := p.next()
+	if err != nil {
+		return token{}, token{}, err
+	}
+	if fn != transformNone {
+		return token{}, token{}, &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("%s cannot be used with %s at %d:%d", fn, op.typ.arithName(), op.line, op.col),
+			Line: op.line,
+			Col:  op.col,
+		}
+	}
+	arg, err := p.next()
+	if err != nil {
+		return token{}, token{}, err
+	}
+	bits, ok := arithOperand(op, arg)
+	if !ok {
+		name := "operand"
+		if op.typ == tokenMod {
+			name = "divisor"
+		}
+		return token{}, token{}, &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("%s of field %q must be an integer, got %s at %d:%d: %q", name, ident.v, arg.typ, arg.line, arg.col, arg.v),
+			Line: arg.line,
+			Col:  arg.col,
+		}
+	}
+	if op.typ == tokenMod && bits == 0 {
+		return token{}, token{}, &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("division by zero in modulo of field %q at %d:%d", ident.v, arg.line, arg.col),
+			Line: arg.line,
+			Col:  arg.col,
+		}
+	}
+	return op, arg, nil
+}
+
+// arithOperand returns the operand of an arithmetic operator in two's complement.
+// The divisor of % is a signed integer, and bitwise operands may also be unsigned integers
+// beyond the range of int64, such as 0xFFFFFFFFFFFFFFFF.
+func arithOperand(op, arg token) (uint64, bool) {
+	if arg.typ != tokenNumber {
+		return 0, false
+	}
+	if i, ok := parseInt(arg.v); ok {
+		return uint64(i), true
+	}
+	if op.typ == tokenMod {
+		return 0, false
+	}
+	return parseUint(arg.v)
+}
+
+// setArith makes the comparison node at index i compare the result of the arithmetic operator on its field.
+// The result is an integer, so the value must be an integer as well.
+func (p *parser) setArith(i int, op, arg token) error {
+	n := &p.nodes[i]
+	if !n.hasInt && !n.hasUint {
+		return &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("%s of field %q must be compared with an integer, got %s at %d:%d: %q", op.typ.arithName(), n.ident.v, n.val.typ, n.val.line, n.val.col, n.val.v),
+			Line: n.val.line,
+			Col:  n.val.col,
+		}
+	}
+	n.arith = op
+	n.arg = arg
+	n.bits, _ = arithOperand(op, arg)
+	return nil
+}
+
+// parseOffset parses the duration offset after a field on the right-hand side, such as + 1h in Start + 1h.
+// The sign may be separate from the duration or part of it, as in Start+1h.
+// If the next token does not start an offset, nothing is consumed and false is returned.
+func (p *parser) parseOffset(ref token) (token, bool, error) {
+	t := p.peek()
+	if t.typ != tokenNumber && t.typ != tokenDuration || !strings.ContainsAny(t.v[:1], "+-") {
+		return token{}, false, nil
+	}
+	if _, err := p.next(); err != nil {
+		return token{}, false, err
+	}
+	if t.typ == tokenDuration {
+		return t, true, nil
+	}
+	if len(t.v) == 1 {
+		d, err := p.next()
+		if err != nil {
+			return token{}, false, err
+		}
+		if d.typ == tokenDuration && !strings.ContainsAny(d.v[:1], "+-") {
+			return token{typ: tokenDuration, v: t.v + d.v, pos: t.pos, line: t.line, col: t.col}, true, nil
+		}
+		if d.typ == tokenDuration || d.typ == tokenNumber && strings.ContainsAny(d.v[:1], "+-") {
+			return token{}, false, &Error{
+				Kind: KindParse,
+				Err:  fmt.Errorf("unexpected sign in offset of field %q at %d:%d: %q", ref.v, d.line, d.col, d.v),
+				Line: d.line,
+				Col:  d.col,
+			}
+		}
+		t = d
+	}
+	return token{}, false, &Error{
+		Kind: KindParse,
+		Err:  fmt.Errorf("offset of field %q must be a duration, got %s at %d:%d: %q", ref.v, t.typ, t.line, t.col, t.v),
+		Line: t.line,
+		Col:  t.col,
+	}
+}
+
+// newOffsetComparison creates a comparison node of a time field against another time field
+// moved by a duration offset. Only == , != and the ordering operators apply to it.
+func (p *parser) newOffsetComparison(ident token, fn transform, op, ref, offset token) (int, error) {
+	if fn != transformNone {
+		return 0, &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("%s cannot be compared with a field offset at %d:%d", fn, ident.line, ident.col),
+			Line: ident.line,
+			Col:  ident.col,
+		}
+	}
+	if op.typ != tokenEQ && op.typ != tokenNEQ && !op.typ.isOrderingOperatorType() {
+		return 0, &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("invalid operator for field offset at %d:%d: %q", op.line, op.col, op.typ.literal()),
+			Line: op.line,
+			Col:  op.col,
+		}
+	}
+	i, err := p.newComparison(ident, fn, op, offset)
+	if err != nil {
+		return 0, err
+	}
+	p.nodes[i].ref = p.registerIdent(ref)
+	p.nodes[i].skip = p.nodes[i].skip || p.opts.hasField(p.opts.skip, p.nodes[i].ref.v)
+	return i, nil
+}
+
+// parseList parses the parenthesized, comma-separated values of a list operator, such as ("a", "b").
+// Values are whole tokens from the lexer, so commas and parentheses inside quoted strings are not structural.
+// A trailing comma is not allowed. For containsany and containsall, each value is kept as an ==
+// comparison against an element of the field, and the list may be empty. For regex operators,
+// each value is a pattern compiled as usual, and the list must hold at least one string.
+func (p *parser) parseList(ident token, fn transform, op token) (int, error) {
+	lp, err := p.expect(tokenLparen)
+	if err != nil {
+		return 0, err
+	}
+	p.parens = append(p.parens, lp)
+	regex := op.typ.isRegexOperatorType()
+	var items []node
+	if p.peek().typ == tokenRparen {
+		if _, err := p.next(); err != nil {
+			return 0, err
+		}
+		if regex {
+			return 0, &Error{
+				Kind: KindParse,
+				Err:  fmt.Errorf("empty pattern list at %d:%d", lp.line, lp.col),
+			}
+		}
+	} else {
+		for {
+			val, err := p.next()
+			if err != nil {
+				return 0, err
+			}
+			if regex && !val.typ.isStringType() {
+				return 0, &Error{
+					Kind: KindParse,
+					Err:  fmt.Errorf("expected string pattern, got %s at %d:%d: %q", val.typ, val.line, val.col, val.v),
+					Line: val.line,
+					Col:  val.col,
+				}
+			}
+			if p.opts.decimalComma && val.typ == tokenNumber && !hasBasePrefix(val.v) && strings.Contains(val.v, ".") {
+				// (1,5) could be the list of 1 and 5 as well as the single number 1,5.
+				return 0, &Error{
+					Kind: KindParse,
+					Err:  fmt.Errorf("ambiguous decimal comma in list at %d:%d: %q", val.line, val.col, decimalComma(val.v)),
+					Line: val.line,
+					Col:  val.col,
+				}
+			}
+			itemOp := op
+			if !regex {
+				itemOp = token{typ: tokenEQ, v: tokenEQ.literal(), pos: val.pos, line: val.line, col: val.col}
+			}
+			j, err := p.newComparison(ident, fn, itemOp, val)
+			if err != nil {
+				return 0, err
+			}
+			// The item is evaluated through the list node, so it is not kept in the tree.
+			items = append(items, p.nodes[j])
+			p.nodes = p.nodes[:j]
+			t, err := p.next()
+			if err != nil {
+				return 0, err
+			}
+			if t.typ == tokenRparen {
+				break
+			}
+			if t.typ != tokenComma {
+				return 0, &Error{
+					Kind: KindParse,
+					Err:  fmt.Errorf("expected comma or right parenthesis, got %s at %d:%d: %q", t.typ, t.line, t.col, t.v),
+				}
+			}
+			if p.peek().typ == tokenRparen {
+				return 0, &Error{
+					Kind: KindParse,
+					Err:  fmt.Errorf("trailing comma in list at %d:%d", t.line, t.col),
+					Line: t.line,
+					Col:  t.col,
+				}
+			}
+		}
+	}
+	p.parens = p.parens[:len(p.parens)-1]
+	i := newNodeComparison(p, ident, op, lp)
+	p.nodes[i].fn = fn
+	p.nodes[i].items = items
+	return i, nil
+}
+
+// parseChain parses a chained comparison such as 40 < Int < 100,
+// which is desugared to 40 < Int && Int < 100 with the left comparison flipped to Int > 40.
+// Both operators must be ordering operators of the same direction. A value followed by in
+// is a membership test instead, parsed by parseMembership.
+func (p *parser) parseChain() (int, error) {
+	lval, err := p.next()
+	if err != nil {
+		return 0, err
+	}
+	lop, err := p.next()
+	if err != nil {
+		return 0, err
+	}
+	if lop.typ == tokenIn {
+		return p.parseMembership(lval, lop)
+	}
+	if !lop.typ.isOrderingOperatorType() {
+		return 0, &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("expected ordering operator after value, got %s at %d:%d: %q", lop.typ, lop.line, lop.col, lop.v),
+		}
+	}
+	ident, fn, key, err := p.parseField()
+	if err != nil {
+		return 0, err
+	}
+	arith, arg, err := p.parseArith(ident, fn)
+	if err != nil {
+		return 0, err
+	}
+	rop, err := p.next()
+	if err != nil {
+		return 0, err
+	}
+	if !rop.typ.isOrderingOperatorType() {
+		return 0, &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("expected ordering operator in chained comparison, got %s at %d:%d: %q", rop.typ, rop.line, rop.col, rop.v),
+		}
+	}
+	if isAscending(lop.typ) != isAscending(rop.typ) {
+		return 0, &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("mixed directions in chained comparison at %d:%d: %q", rop.line, rop.col, lop.v+" "+rop.v),
+		}
+	}
+	rval, err := p.next()
+	if err != nil {
+		return 0, err
+	}
+	left, err := p.newComparison(ident, fn, reverse(lop), lval)
+	if err != nil {
+		return 0, err
+	}
+	right, err := p.newComparison(ident, fn, rop, rval)
+	if err != nil {
+		return 0, err
+	}
+	p.nodes[left].key = key
+	p.nodes[right].key = key
+	if arith.typ.isArithOperatorType() {
+		if err := p.setArith(left, arith, arg); err != nil {
+			return 0, err
+		}
+		if err := p.setArith(right, arith, arg); err != nil {
+			return 0, err
+		}
+	}
+	and := token{typ: tokenAND, v: tokenAND.literal(), pos: rop.pos, line: rop.line, col: rop.col}
+	return newNodeBinary(p, left, and, right), nil
+}
+
+// parseMembership parses a membership test of a value in a slice field, such as "admin" in Roles,
+// which is desugared to Roles containsany ("admin").
+func (p *parser) parseMembership(val, in token) (int, error) {
+	ident, fn, key, err := p.parseField()
+	if err != nil {
+		return 0, err
+	}
+	if fn != transformNone {
+		return 0, &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("invalid operator for %s at %d:%d: %q", fn, in.line, in.col, in.v),
+			Line: in.line,
+			Col:  in.col,
+		}
+	}
+	eq := token{typ: tokenEQ, v: tokenEQ.literal(), pos: val.pos, line: val.line, col: val.col}
+	j, err := p.newComparison(ident, fn, eq, val)
+	if err != nil {
+		return 0, err
+	}
+	item := p.nodes[j]
+	p.nodes = p.nodes[:j]
+	op := token{typ: tokenContainsAny, v: tokenContainsAny.literal(), pos: in.pos, line: in.line, col: in.col}
+	lp := token{typ: tokenLparen, v: tokenLparen.literal(), pos: val.pos, line: val.line, col: val.col}
+	i := newNodeComparison(p, ident, op, lp)
+	p.nodes[i].key = key
+	p.nodes[i].items = []node{item}
+	return i, nil
+}
+
+// isAscending reports whether the ordering operator is < or <=.
+func isAscending(t tokenType) bool {
+	return t == tokenLT || t == tokenLTE
+}
+
+// reverse returns the ordering operator with its operands swapped, such as > for <.
+func reverse(op token) token {
+	switch op.typ {
+	case tokenGT:
+		op.typ = tokenLT
+	case tokenGTE:
+		op.typ = tokenLTE
+	case tokenLT:
+		op.typ = tokenGT
+	case tokenLTE:
+		op.typ = tokenGTE
+	}
+	op.v = op.typ.literal()
+	return op
+}
+
+// parseField parses an identifier, optionally wrapped in a function such as lower(Name) or len(Tags).
+func (p *parser) parseField() (token, transform, token, error) {
+	ident, err := p.expect(tokenIdent)
+	if err != nil {
+		return token{}, transformNone, token{}, err
+	}
+	if p.peek().typ != tokenLparen {
+		key, err := p.parseKey()
+		if err != nil {
+			return token{}, transformNone, token{}, err
+		}
+		return p.registerIdent(ident), transformNone, key, nil
+	}
+	fn, ok := lookupTransform(ident.v)
+	if !ok {
+		return token{}, transformNone, token{}, &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("unknown function %q at %d:%d", ident.v, ident.line, ident.col),
+			Line: ident.line,
+			Col:  ident.col,
+		}
+	}
+	lp, err := p.next()
+	if err != nil {
+		return token{}, transformNone, token{}, err
+	}
+	p.parens = append(p.parens, lp)
+	arg, err := p.expect(tokenIdent)
+	if err != nil {
+		return token{}, transformNone, token{}, err
+	}
+	key, err := p.parseKey()
+	if err != nil {
+		return token{}, transformNone, token{}, err
+	}
+	if _, err := p.expect(tokenRparen); err != nil {
+		return token{}, transformNone, token{}, err
+	}
+	p.parens = p.parens[:len(p.parens)-1]
+	return p.registerIdent(arg), fn, key, nil
+}
+
+// parseKey parses the key of a map field in brackets after its identifier, such as ["a"] in Headers["a"],
+// or the index of a slice or array field, such as [0] in Coords[0]. The key is a string or an integer
+// literal. If the next token is not a left bracket, nothing is consumed and a zero token is returned.
+func (p *parser) parseKey() (token, error) {
+	if p.peek().typ != tokenLbracket {
+		return token{}, nil
+	}
+	if _, err := p.next(); err != nil {
+		return token{}, err
+	}
+	key, err := p.next()
+	if err != nil {
+		return token{}, err
+	}
+	if _, ok := parseInt(key.v); !key.typ.isStringType() && (key.typ != tokenNumber || !ok) {
+		return token{}, &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("expected string or integer key, got %s at %d:%d: %q", key.typ, key.line, key.col, key.v),
+			Line: key.line,
+			Col:  key.col,
+		}
+	}
+	if _, err := p.expect(tokenRbracket); err != nil {
+		return token{}, err
+	}
+	key.v = unquote(key)
+	return key, nil
+}
+
+// registerIdent normalizes an identifier and registers it for the field cache.
+func (p *parser) registerIdent(ident token) token {
+	if p.opts.fold {
+		ident.v = strings.ToLower(ident.v)
+	}
+	if p.idents != nil {
+		p.idents[ident.v] = struct{}{}
+	}
+	return ident
+}
+
+// valueCategories lists the kinds of values accepted on the right-hand side of a comparison.
+const valueCategories = "string, number, duration, time or bool"
+
+// newComparison validates the value and creates a comparison node with its value cached.
+func (p *parser) newComparison(ident token, fn transform, op, val token) (int, error) {
+	if val.typ == tokenIdent && isNonFiniteLiteral(val.v) {
+		return 0, &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("invalid number %q at %d:%d: NaN and Inf are not supported", val.v, val.line, val.col),
+		}
+	}
 	if !val.typ.isValueType() {
 		return 0, &Error{
 			Kind: KindParse,
-			Err:  fmt.Errorf("expected value, got %s at %d:%d: %q", val.typ, val.line, val.col, val.v),
+			Err:  fmt.Errorf("expected value (%s), got %s at %d:%d: %q", valueCategories, val.typ, val.line, val.col, val.v),
+			Line: val.line,
+			Col:  val.col,
+		}
+	}
+	if op.typ == tokenApprox && val.typ != tokenNumber {
+		return 0, &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("operator %s requires a number value, got %s at %d:%d", op.typ.literal(), val.typ, op.line, op.col),
+			Line: op.line,
+			Col:  op.col,
+		}
+	}
+	if (op.typ.isCaseInsensitiveOperatorType() || op.typ.isSubstringOperatorType()) && !val.typ.isStringType() {
+		return 0, &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("operator %s requires a string value, got %s at %d:%d", op.typ.literal(), val.typ, op.line, op.col),
+			Line: op.line,
+			Col:  op.col,
 		}
 	}
 	if val.typ == tokenString || val.typ == tokenRawString {
 		val.v = unquote(val)
 	}
+	if fn == transformLen && val.typ != tokenNumber {
+		return 0, &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("len of field %q must be compared with a number, got %s at %d:%d: %q", ident.v, val.typ, val.line, val.col, val.v),
+			Line: val.line,
+			Col:  val.col,
+		}
+	}
 	if op.typ.isCaseInsensitiveRegexOperatorType() {
 		val.v = "(?i)" + val.v
 	}
 	i := newNodeComparison(p, ident, op, val)
+	p.nodes[i].fn = fn
+	p.setFieldFlags(i)
 	if op.typ.isRegexOperatorType() {
 		if err := p.handleRegex(val, i); err != nil {
 			return 0, err
 		}
 	}
 	if val.typ == tokenTime {
-		if t, err := time.Parse(time.RFC3339, val.v); err == nil {
+		if t, err := parseTime(val.v); err == nil {
 			p.nodes[i].time = t
 			p.nodes[i].hasTime = true
 		}
 	}
 	if val.typ == tokenDuration {
-		if d, err := time.ParseDuration(val.v); err == nil {
+		if d, err := parseDuration(val.v, p.opts.extendedUnits); err == nil {
 			p.nodes[i].dur = d
 			p.nodes[i].hasDur = true
 		}
 	}
+	if val.typ == tokenNow {
+		if offset := val.v[len("now"):]; offset != "" {
+			d, err := parseDuration(offset, p.opts.extendedUnits)
+			if err != nil {
+				return 0, &Error{
+					Kind: KindParse,
+					Err:  fmt.Errorf("invalid offset %q for now at %d:%d", offset, val.line, val.col),
+				}
+			}
+			p.nodes[i].dur = d
+		}
+	}
 	if val.typ == tokenNumber {
-		if f, err := strconv.ParseFloat(val.v, 64); err == nil {
+		f, err := parseNumber(val.v)
+		if err == nil {
 			p.nodes[i].num = f
 			p.nodes[i].hasNum = true
+		} else if math.IsInf(f, 0) {
+			return 0, &Error{
+				Kind: KindParse,
+				Err:  fmt.Errorf("number out of range %q at %d:%d", val.v, val.line, val.col),
+			}
+		}
+		if n, ok := parseInt(val.v); ok {
+			p.nodes[i].int = n
+			p.nodes[i].hasInt = true
+		}
+		if u, ok := parseUint(val.v); ok {
+			p.nodes[i].uint = u
+			p.nodes[i].hasUint = true
 		}
 	}
 	return i, nil
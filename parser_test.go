@@ -1,6 +1,9 @@
 package filter
 
 import (
+	"errors"
+	"fmt"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -41,250 +44,1147 @@ func TestParse(t *testing.T) {
 				repr: `(Tag ==* Admin)`,
 			},
 		},
+		{
+			name:  "eqi number",
+			input: `Name==*1`,
+			expected: expected{
+				ok:  false,
+				err: `parse error: operator ==* requires a string value, got number at 1:5`,
+			},
+		},
+		{
+			name:  "eqi duration",
+			input: `Name==*1s`,
+			expected: expected{
+				ok:  false,
+				err: `parse error: operator ==* requires a string value, got duration at 1:5`,
+			},
+		},
+		{
+			name:  "eqi bool",
+			input: `Name==*true`,
+			expected: expected{
+				ok:  false,
+				err: `parse error: operator ==* requires a string value, got boolean at 1:5`,
+			},
+		},
+		{
+			name:  "neqi number",
+			input: `Name!=*1`,
+			expected: expected{
+				ok:  false,
+				err: `parse error: operator !=* requires a string value, got number at 1:5`,
+			},
+		},
+		{
+			name:  "neqi duration",
+			input: `Name!=*1s`,
+			expected: expected{
+				ok:  false,
+				err: `parse error: operator !=* requires a string value, got duration at 1:5`,
+			},
+		},
+		{
+			name:  "neqi bool",
+			input: `Name!=*true`,
+			expected: expected{
+				ok:  false,
+				err: `parse error: operator !=* requires a string value, got boolean at 1:5`,
+			},
+		},
+		{
+			name:  "reqi number",
+			input: `Name=~*1`,
+			expected: expected{
+				ok:  false,
+				err: `parse error: operator =~* requires a string value, got number at 1:5`,
+			},
+		},
+		{
+			name:  "reqi duration",
+			input: `Name=~*1s`,
+			expected: expected{
+				ok:  false,
+				err: `parse error: operator =~* requires a string value, got duration at 1:5`,
+			},
+		},
+		{
+			name:  "reqi bool",
+			input: `Name=~*true`,
+			expected: expected{
+				ok:  false,
+				err: `parse error: operator =~* requires a string value, got boolean at 1:5`,
+			},
+		},
+		{
+			name:  "nreqi number",
+			input: `Name!~*1`,
+			expected: expected{
+				ok:  false,
+				err: `parse error: operator !~* requires a string value, got number at 1:5`,
+			},
+		},
+		{
+			name:  "nreqi duration",
+			input: `Name!~*1s`,
+			expected: expected{
+				ok:  false,
+				err: `parse error: operator !~* requires a string value, got duration at 1:5`,
+			},
+		},
+		{
+			name:  "nreqi bool",
+			input: `Name!~*true`,
+			expected: expected{
+				ok:  false,
+				err: `parse error: operator !~* requires a string value, got boolean at 1:5`,
+			},
+		},
 		{
 			name:  "regex",
 			input: `Name=~'A.*'`,
 			expected: expected{
 				ok:   true,
-				repr: `(Name =~ "A.*")`,
+				repr: `(Name =~ "A.*")`,
+			},
+		},
+		{
+			name:  "regex not",
+			input: `Name!~'A.*'`,
+			expected: expected{
+				ok:   true,
+				repr: `(Name !~ "A.*")`,
+			},
+		},
+		{
+			name:  "regex raw string",
+			input: "Name=~`^H.*d$`",
+			expected: expected{
+				ok:   true,
+				repr: `(Name =~ "^H.*d$")`,
+			},
+		},
+		{
+			name:  "regex case insensitive",
+			input: "Name=~*`A`",
+			expected: expected{
+				ok:   true,
+				repr: `(Name =~* "(?i)A")`,
+			},
+		},
+		{
+			name:  "regex case insensitive not",
+			input: "Name!~*`A`",
+			expected: expected{
+				ok:   true,
+				repr: `(Name !~* "(?i)A")`,
+			},
+		},
+		// Numbers
+		{
+			name:  "gt number",
+			input: `HP>50`,
+			expected: expected{
+				ok:   true,
+				repr: `(HP > 50)`,
+			},
+		},
+		{
+			name:  "gte number",
+			input: `MP>=100`,
+			expected: expected{
+				ok:   true,
+				repr: `(MP >= 100)`,
+			},
+		},
+		{
+			name:  "lt number float",
+			input: `Rate<0.75`,
+			expected: expected{
+				ok:   true,
+				repr: `(Rate < 0.75)`,
+			},
+		},
+		{
+			name:  "hex float",
+			input: `X==0x1.fp3`,
+			expected: expected{
+				ok:   true,
+				repr: `(X == 0x1.fp3)`,
+			},
+		},
+		// Now
+		{
+			name:  "now",
+			input: `LastSeen>now`,
+			expected: expected{
+				ok:   true,
+				repr: `(LastSeen > "now")`,
+			},
+		},
+		{
+			name:  "now with offset",
+			input: `LastSeen>now-1h30m`,
+			expected: expected{
+				ok:   true,
+				repr: `(LastSeen > now-1h30m)`,
+			},
+		},
+		{
+			name:  "field offset",
+			input: `Deadline<Start + 1h`,
+			expected: expected{
+				ok:   true,
+				repr: `(Deadline < Start+1h)`,
+			},
+		},
+		{
+			name:  "field offset attached sign",
+			input: `Deadline>=Start-1h30m`,
+			expected: expected{
+				ok:   true,
+				repr: `(Deadline >= Start-1h30m)`,
+			},
+		},
+		{
+			name:  "field offset in and",
+			input: `Deadline<Start+1h && HP>1`,
+			expected: expected{
+				ok:   true,
+				repr: `((Deadline < Start+1h) && (HP > 1))`,
+			},
+		},
+		{
+			name:  "modulo",
+			input: `ID % 10 == 0`,
+			expected: expected{
+				ok:   true,
+				repr: `(ID % 10 == 0)`,
+			},
+		},
+		{
+			name:  "modulo without spaces",
+			input: `ID%0x10>=-1 && HP>1`,
+			expected: expected{
+				ok:   true,
+				repr: `((ID % 0x10 >= -1) && (HP > 1))`,
+			},
+		},
+		{
+			name:  "bitwise and",
+			input: `Perms & 0x4 == 0x4`,
+			expected: expected{
+				ok:   true,
+				repr: `(Perms & 0x4 == 0x4)`,
+			},
+		},
+		{
+			name:  "bitwise and with logical and",
+			input: `Perms&4==4&&HP>1`,
+			expected: expected{
+				ok:   true,
+				repr: `((Perms & 4 == 4) && (HP > 1))`,
+			},
+		},
+		{
+			name:  "bitwise or with logical or",
+			input: `Perms|1!=7||Perms^0b11==0`,
+			expected: expected{
+				ok:   true,
+				repr: `((Perms | 1 != 7) || (Perms ^ 0b11 == 0))`,
+			},
+		},
+		{
+			name:  "bitwise and beyond int64",
+			input: `Mask & 0xFFFFFFFFFFFFFFFF == 0x8000000000000000`,
+			expected: expected{
+				ok:   true,
+				repr: `(Mask & 0xFFFFFFFFFFFFFFFF == 0x8000000000000000)`,
+			},
+		},
+		{
+			name:  "modulo in chained comparison",
+			input: `0 < ID % 10 <= 5`,
+			expected: expected{
+				ok:   true,
+				repr: `((ID % 10 > 0) && (ID % 10 <= 5))`,
+			},
+		},
+		{
+			name:  "now with empty offset",
+			input: `LastSeen>now-`,
+			expected: expected{
+				ok:  false,
+				err: `parse error: invalid offset "-" for now at 1:10`,
+			},
+		},
+		{
+			name:  "now with malformed offset",
+			input: `LastSeen>now+1x`,
+			expected: expected{
+				ok:  false,
+				err: `parse error: invalid offset "+1x" for now at 1:10`,
+			},
+		},
+		{
+			name:  "now as identifier",
+			input: `now>1`,
+			expected: expected{
+				ok:  false,
+				err: `expected identifier, got number at 1:5`,
+			},
+		},
+		{
+			name:  "inf literal",
+			input: `X>Inf`,
+			expected: expected{
+				ok:  false,
+				err: `parse error: invalid number "Inf" at 1:3: NaN and Inf are not supported`,
+			},
+		},
+		{
+			name:  "nan literal",
+			input: `X==nan`,
+			expected: expected{
+				ok:  false,
+				err: `parse error: invalid number "nan" at 1:4: NaN and Inf are not supported`,
+			},
+		},
+		{
+			name:  "number out of range",
+			input: `X<-1e999`,
+			expected: expected{
+				ok:  false,
+				err: `parse error: number out of range "-1e999" at 1:3`,
+			},
+		},
+		{
+			name:  "hex number out of range",
+			input: `X<0x1p9999`,
+			expected: expected{
+				ok:  false,
+				err: `parse error: number out of range "0x1p9999" at 1:3`,
+			},
+		},
+		// Durations
+		{
+			name:  "duration gte",
+			input: `Delay>=1h30m`,
+			expected: expected{
+				ok:   true,
+				repr: `(Delay >= 1h30m)`,
+			},
+		},
+		{
+			name:  "duration lt",
+			input: `Timeout<500ms`,
+			expected: expected{
+				ok:   true,
+				repr: `(Timeout < 500ms)`,
+			},
+		},
+		{
+			name:  "duration micro",
+			input: `Mic==4000μs`,
+			expected: expected{
+				ok:   true,
+				repr: `(Mic == 4000μs)`,
+			},
+		},
+		// Times
+		{
+			name:  "time before",
+			input: `Time>=2023-01-02T15:04:05Z`,
+			expected: expected{
+				ok:   true,
+				repr: `(Time >= "2023-01-02T15:04:05Z")`,
+			},
+		},
+		{
+			name:  "time after",
+			input: `Time<2023-01-02T15:04:05Z`,
+			expected: expected{
+				ok:   true,
+				repr: `(Time < "2023-01-02T15:04:05Z")`,
+			},
+		},
+		// Booleans
+		{
+			name:  "bool eq",
+			input: `Flag==true`,
+			expected: expected{
+				ok:   true,
+				repr: `(Flag == true)`,
+			},
+		},
+		{
+			name:  "bool neq",
+			input: `Flag!=False`,
+			expected: expected{
+				ok:   true,
+				repr: `(Flag != False)`,
+			},
+		},
+		// Logic and precedence
+		{
+			name:  "and or precedence",
+			input: `HP>50&&MP>=100||LP==0`,
+			expected: expected{
+				ok:   true,
+				repr: `(((HP > 50) && (MP >= 100)) || (LP == 0))`,
+			},
+		},
+		{
+			name:  "paren grouping",
+			input: `(HP>50&&MP>=100)||LP==0`,
+			expected: expected{
+				ok:   true,
+				repr: `(((HP > 50) && (MP >= 100)) || (LP == 0))`,
+			},
+		},
+		{
+			name:  "not group",
+			input: `!(SPD<20)`,
+			expected: expected{
+				ok:   true,
+				repr: `(! (SPD < 20))`,
+			},
+		},
+		{
+			name:  "negative duration",
+			input: `Skew < -100ms`,
+			expected: expected{
+				ok:   true,
+				repr: `(Skew < -100ms)`,
+			},
+		},
+		{
+			name:  "negative duration without spaces",
+			input: `Skew<-1h30m`,
+			expected: expected{
+				ok:   true,
+				repr: `(Skew < -1h30m)`,
+			},
+		},
+		{
+			name:  "negative duration chain",
+			input: `-1s<Skew<=0s`,
+			expected: expected{
+				ok:   true,
+				repr: `((Skew > -1s) && (Skew <= 0s))`,
+			},
+		},
+		{
+			name:  "not comparison",
+			input: `!HP>50`,
+			expected: expected{
+				ok:   true,
+				repr: `(! (HP > 50))`,
+			},
+		},
+		{
+			name:  "not comparison and",
+			input: `!HP>50 && MP<10`,
+			expected: expected{
+				ok:   true,
+				repr: `((! (HP > 50)) && (MP < 10))`,
+			},
+		},
+		{
+			name:  "double not",
+			input: `!!HP>50`,
+			expected: expected{
+				ok:   true,
+				repr: `(! (! (HP > 50)))`,
+			},
+		},
+		{
+			name:  "triple not",
+			input: `!!!(HP>50)`,
+			expected: expected{
+				ok:   true,
+				repr: `(! (! (! (HP > 50))))`,
+			},
+		},
+		{
+			name:  "not with spaces",
+			input: `! ! Flag==true`,
+			expected: expected{
+				ok:   true,
+				repr: `(! (! (Flag == true)))`,
+			},
+		},
+		{
+			name:  "not chained comparison",
+			input: `!1<HP<100`,
+			expected: expected{
+				ok:   true,
+				repr: `(! ((HP > 1) && (HP < 100)))`,
+			},
+		},
+		{
+			name:  "not without operand",
+			input: `!!`,
+			expected: expected{
+				ok:  false,
+				err: `expected left parenthesis or identifier`,
+			},
+		},
+		{
+			name:  "complex",
+			input: `Class=="軍師"&&Name=~'孔明'&&(HP>50&&MP>=100&&LP!=0)&&(MAG>=20||!(SPD<20))`,
+			expected: expected{
+				ok:   true,
+				repr: `((((Class == "軍師") && (Name =~ "孔明")) && (((HP > 50) && (MP >= 100)) && (LP != 0))) && ((MAG >= 20) || (! (SPD < 20))))`,
+			},
+		},
+		// Errors
+		{
+			name:  "regex empty pattern",
+			input: `Name=~''`,
+			expected: expected{
+				ok:  false,
+				err: `invalid regex`,
+			},
+		},
+		{
+			name:  "invalid regex",
+			input: `Name=~'['`,
+			expected: expected{
+				ok:  false,
+				err: `invalid regex`,
+			},
+		},
+		{
+			name:  "missing op",
+			input: `HP 50`,
+			expected: expected{
+				ok:  false,
+				err: `expected comparison operator`,
+			},
+		},
+		{
+			name:  "missing right",
+			input: `HP>`,
+			expected: expected{
+				ok:  false,
+				err: `expected value`,
+			},
+		},
+		{
+			name:  "unexpected trailing",
+			input: `HP>50 extra`,
+			expected: expected{
+				ok:  false,
+				err: `unexpected token after parsing`,
+			},
+		},
+		{
+			name:  "leading not without operand",
+			input: `!`,
+			expected: expected{
+				ok:  false,
+				err: `expected left parenthesis or identifier`,
+			},
+		},
+		{
+			name:  "empty",
+			input: ``,
+			expected: expected{
+				ok:  false,
+				err: `empty input`,
+			},
+		},
+		{
+			name:  "unclosed paren",
+			input: `(HP>1`,
+			expected: expected{
+				ok:  false,
+				err: `unclosed left parenthesis`,
+			},
+		},
+		{
+			name:  "unclosed paren position",
+			input: `HP>1 && (MP>2 || SP>3`,
+			expected: expected{
+				ok:  false,
+				err: `parse error: unclosed left parenthesis at 1:9`,
+			},
+		},
+		{
+			name:  "unclosed paren missing value",
+			input: `(HP>`,
+			expected: expected{
+				ok:  false,
+				err: `parse error: unclosed left parenthesis at 1:1`,
+			},
+		},
+		{
+			name:  "unclosed outer paren",
+			input: `((HP>1)`,
+			expected: expected{
+				ok:  false,
+				err: `parse error: unclosed left parenthesis at 1:1`,
+			},
+		},
+		{
+			name:  "unclosed inner paren",
+			input: `(HP>1 && (MP>2`,
+			expected: expected{
+				ok:  false,
+				err: `parse error: unclosed left parenthesis at 1:10`,
+			},
+		},
+		{
+			name:  "unclosed paren multiline",
+			input: "HP>1 &&\n  (MP>2 ||\n   SP>3\n",
+			expected: expected{
+				ok:  false,
+				err: `parse error: unclosed left parenthesis at 2:3`,
+			},
+		},
+		{
+			name:  "unclosed paren with other lex error",
+			input: `(Name=='abc`,
+			expected: expected{
+				ok:  false,
+				err: `token error: unterminated quoted string`,
+			},
+		},
+		{
+			name:  "extra right paren",
+			input: `HP>1)`,
+			expected: expected{
+				ok:  false,
+				err: `parse error: unexpected right parenthesis at 1:5`,
+			},
+		},
+		{
+			name:  "extra right paren after group",
+			input: `(HP>1) && (MP>2))`,
+			expected: expected{
+				ok:  false,
+				err: `parse error: unexpected right parenthesis at 1:17`,
+			},
+		},
+		{
+			name:  "extra right paren multiline",
+			input: "(HP>1 &&\n MP>2))",
+			expected: expected{
+				ok:  false,
+				err: `parse error: unexpected right parenthesis at 2:7`,
+			},
+		},
+		{
+			name:  "double logical op",
+			input: `HP>1&&||MP>2`,
+			expected: expected{
+				ok:  false,
+				err: `expected left parenthesis or identifier`,
+			},
+		},
+		{
+			name:  "chain lt",
+			input: `40<Int<100`,
+			expected: expected{
+				ok:   true,
+				repr: `((Int > 40) && (Int < 100))`,
+			},
+		},
+		{
+			name:  "chain lte",
+			input: `40<=Int<=100`,
+			expected: expected{
+				ok:   true,
+				repr: `((Int >= 40) && (Int <= 100))`,
+			},
+		},
+		{
+			name:  "chain gt",
+			input: `100>Int>40`,
+			expected: expected{
+				ok:   true,
+				repr: `((Int < 100) && (Int > 40))`,
+			},
+		},
+		{
+			name:  "chain mixed inclusive",
+			input: `100>=Int>40`,
+			expected: expected{
+				ok:   true,
+				repr: `((Int <= 100) && (Int > 40))`,
+			},
+		},
+		{
+			name:  "chain duration",
+			input: `1s<Latency<=2s`,
+			expected: expected{
+				ok:   true,
+				repr: `((Latency > 1s) && (Latency <= 2s))`,
+			},
+		},
+		{
+			name:  "chain time",
+			input: `2025-01-01T00:00:00Z<=Time<2026-01-01T00:00:00Z`,
+			expected: expected{
+				ok:   true,
+				repr: `((Time >= "2025-01-01T00:00:00Z") && (Time < "2026-01-01T00:00:00Z"))`,
+			},
+		},
+		{
+			name:  "chain string",
+			input: `"a"<=Name<"n"`,
+			expected: expected{
+				ok:   true,
+				repr: `((Name >= "a") && (Name < "n"))`,
+			},
+		},
+		{
+			name:  "chain in logical",
+			input: `0<A<1 || B==2`,
+			expected: expected{
+				ok:   true,
+				repr: `(((A > 0) && (A < 1)) || (B == 2))`,
+			},
+		},
+		{
+			name:  "chain in paren",
+			input: `!(0<A<1)`,
+			expected: expected{
+				ok:   true,
+				repr: `(! ((A > 0) && (A < 1)))`,
+			},
+		},
+		{
+			name:  "chain mixed directions",
+			input: `1<Int>0`,
+			expected: expected{
+				ok:  false,
+				err: `mixed directions in chained comparison at 1:6: "< >"`,
+			},
+		},
+		{
+			name:  "chain mixed directions inclusive",
+			input: `1<=Int>=0`,
+			expected: expected{
+				ok:  false,
+				err: `mixed directions in chained comparison`,
+			},
+		},
+		{
+			name:  "chain equality left",
+			input: `1==Int<2`,
+			expected: expected{
+				ok:  false,
+				err: `expected ordering operator after value`,
+			},
+		},
+		{
+			name:  "chain equality right",
+			input: `1<Int==2`,
+			expected: expected{
+				ok:  false,
+				err: `expected ordering operator in chained comparison`,
+			},
+		},
+		{
+			name:  "chain missing right",
+			input: `1<Int`,
+			expected: expected{
+				ok:  false,
+				err: `expected ordering operator in chained comparison`,
+			},
+		},
+		{
+			name:  "chain value middle",
+			input: `1<2<3`,
+			expected: expected{
+				ok:  false,
+				err: `expected identifier`,
+			},
+		},
+		{
+			name:  "chain too long",
+			input: `1<Int<2<3`,
+			expected: expected{
+				ok:  false,
+				err: `unexpected token after parsing`,
+			},
+		},
+		{
+			name:  "has",
+			input: `Message has "error" && lower(Message) ihas 'Warn'`,
+			expected: expected{
+				ok:   true,
+				repr: `((Message has "error") && (lower(Message) ihas "Warn"))`,
+			},
+		},
+		{
+			name:  "has number",
+			input: `Message has 1`,
+			expected: expected{
+				ok:  false,
+				err: `operator has requires a string value, got number at 1:9`,
+			},
+		},
+		{
+			name:  "ihas list",
+			input: `Message ihas ("a", "b")`,
+			expected: expected{
+				ok:  false,
+				err: `expected value (string, number, duration, time or bool), got left parenthesis at 1:14: "("`,
+			},
+		},
+		{
+			name:  "containsany",
+			input: `Tags containsany ("a", "b")`,
+			expected: expected{
+				ok:   true,
+				repr: `(Tags containsany ("a", "b"))`,
+			},
+		},
+		{
+			name:  "containsall",
+			input: `Tags containsall(1,2.5,1h)`,
+			expected: expected{
+				ok:   true,
+				repr: `(Tags containsall (1, 2.5, 1h))`,
+			},
+		},
+		{
+			name:  "contains single",
+			input: `Tags containsany ("x")`,
+			expected: expected{
+				ok:   true,
+				repr: `(Tags containsany ("x"))`,
+			},
+		},
+		{
+			name:  "contains empty",
+			input: `Tags containsall ()`,
+			expected: expected{
+				ok:   true,
+				repr: `(Tags containsall ())`,
+			},
+		},
+		{
+			name:  "contains in logical",
+			input: `!(Tags containsany ("a")) && N==1`,
+			expected: expected{
+				ok:   true,
+				repr: `((! (Tags containsany ("a"))) && (N == 1))`,
+			},
+		},
+		{
+			name:  "negated membership",
+			input: `!("a" in Roles)`,
+			expected: expected{
+				ok:   true,
+				repr: `(! (Roles containsany ("a")))`,
+			},
+		},
+		{
+			name:  "negated membership without parentheses",
+			input: `!"a" in Roles && N==1`,
+			expected: expected{
+				ok:   true,
+				repr: `((! (Roles containsany ("a"))) && (N == 1))`,
+			},
+		},
+		{
+			name:  "double negated membership",
+			input: `!!(1 in Levels)`,
+			expected: expected{
+				ok:   true,
+				repr: `(! (! (Levels containsany (1))))`,
+			},
+		},
+		{
+			name:  "bool true",
+			input: `true`,
+			expected: expected{
+				ok:   true,
+				repr: `true`,
+			},
+		},
+		{
+			name:  "bool false",
+			input: `FALSE`,
+			expected: expected{
+				ok:   true,
+				repr: `FALSE`,
+			},
+		},
+		{
+			name:  "negated bool",
+			input: `!true`,
+			expected: expected{
+				ok:   true,
+				repr: `(! true)`,
+			},
+		},
+		{
+			name:  "bool and comparison",
+			input: `true && Score > 1`,
+			expected: expected{
+				ok:   true,
+				repr: `(true && (Score > 1))`,
+			},
+		},
+		{
+			name:  "bool in group",
+			input: `(Score > 1 || false)`,
+			expected: expected{
+				ok:   true,
+				repr: `((Score > 1) || false)`,
+			},
+		},
+		{
+			name:  "bool compared",
+			input: `true == Active`,
+			expected: expected{
+				ok:  false,
+				err: `unexpected token after parsing`,
+			},
+		},
+		{
+			name:  "negated group of memberships",
+			input: `!(("a" in Tags) || "b" in Tags)`,
+			expected: expected{
+				ok:   true,
+				repr: `(! ((Tags containsany ("a")) || (Tags containsany ("b"))))`,
 			},
 		},
 		{
-			name:  "regex not",
-			input: `Name!~'A.*'`,
+			name:  "list quoted comma",
+			input: `Name containsany ("a,b", "c")`,
 			expected: expected{
 				ok:   true,
-				repr: `(Name !~ "A.*")`,
+				repr: `(Name containsany ("a,b", "c"))`,
 			},
 		},
 		{
-			name:  "regex raw string",
-			input: "Name=~`^H.*d$`",
+			name:  "list quoted parens",
+			input: `Name containsany ("c(d)", ")", "(")`,
 			expected: expected{
 				ok:   true,
-				repr: `(Name =~ "^H.*d$")`,
+				repr: `(Name containsany ("c(d)", ")", "("))`,
 			},
 		},
 		{
-			name:  "regex case insensitive",
-			input: "Name=~*`A`",
+			name:  "list single quoted",
+			input: `Name containsall ('x, y', "z)")`,
 			expected: expected{
 				ok:   true,
-				repr: `(Name =~* "(?i)A")`,
+				repr: `(Name containsall ("x, y", "z)"))`,
 			},
 		},
 		{
-			name:  "regex case insensitive not",
-			input: "Name!~*`A`",
+			name:  "list raw string",
+			input: `Name containsany (` + "`" + `a,(b)` + "`" + `, "c")`,
 			expected: expected{
 				ok:   true,
-				repr: `(Name !~* "(?i)A")`,
+				repr: `(Name containsany ("a,(b)", "c"))`,
 			},
 		},
-		// Numbers
 		{
-			name:  "gt number",
-			input: `HP>50`,
+			name:  "contains without list",
+			input: `Tags containsany "a"`,
 			expected: expected{
-				ok:   true,
-				repr: `(HP > 50)`,
+				ok:  false,
+				err: `expected left parenthesis, got string`,
 			},
 		},
 		{
-			name:  "gte number",
-			input: `MP>=100`,
+			name:  "contains missing comma",
+			input: `Tags containsany ("a" "b")`,
 			expected: expected{
-				ok:   true,
-				repr: `(MP >= 100)`,
+				ok:  false,
+				err: `expected comma or right parenthesis, got string at 1:23`,
 			},
 		},
 		{
-			name:  "lt number float",
-			input: `Rate<0.75`,
+			name:  "contains trailing comma",
+			input: `Tags containsany ("a",)`,
 			expected: expected{
-				ok:   true,
-				repr: `(Rate < 0.75)`,
+				ok:  false,
+				err: `parse error: trailing comma in list at 1:22`,
 			},
 		},
 		{
-			name:  "hex float",
-			input: `X==0x1.fp3`,
+			name:  "contains trailing comma after values",
+			input: `Tags containsall ("a", "b" ,)`,
 			expected: expected{
-				ok:   true,
-				repr: `(X == 0x1.fp3)`,
+				ok:  false,
+				err: `parse error: trailing comma in list at 1:28`,
 			},
 		},
-		// Durations
 		{
-			name:  "duration gte",
-			input: `Delay>=1h30m`,
+			name:  "contains leading comma",
+			input: `Tags containsany (,"a")`,
 			expected: expected{
-				ok:   true,
-				repr: `(Delay >= 1h30m)`,
+				ok:  false,
+				err: `got comma at 1:19: ","`,
 			},
 		},
 		{
-			name:  "duration lt",
-			input: `Timeout<500ms`,
+			name:  "contains unclosed",
+			input: `Tags containsany ("a"`,
 			expected: expected{
-				ok:   true,
-				repr: `(Timeout < 500ms)`,
+				ok:  false,
+				err: `unclosed left parenthesis at 1:18`,
 			},
 		},
 		{
-			name:  "duration micro",
-			input: `Mic==4000μs`,
+			name:  "contains identifier value",
+			input: `Tags containsany (a)`,
 			expected: expected{
-				ok:   true,
-				repr: `(Mic == 4000μs)`,
+				ok:  false,
+				err: `got identifier at 1:19: "a"`,
 			},
 		},
-		// Times
 		{
-			name:  "time before",
-			input: `Time>=2023-01-02T15:04:05Z`,
+			name:  "regex list",
+			input: `Path=~("^/api", "^/beta")`,
 			expected: expected{
 				ok:   true,
-				repr: `(Time >= "2023-01-02T15:04:05Z")`,
+				repr: `(Path =~ ("^/api", "^/beta"))`,
 			},
 		},
 		{
-			name:  "time after",
-			input: `Time<2023-01-02T15:04:05Z`,
+			name:  "regex list negated case-insensitive",
+			input: `Path!~*("^/api")`,
 			expected: expected{
 				ok:   true,
-				repr: `(Time < "2023-01-02T15:04:05Z")`,
+				repr: `(Path !~* ("(?i)^/api"))`,
 			},
 		},
-		// Booleans
 		{
-			name:  "bool eq",
-			input: `Flag==true`,
+			name:  "regex list empty",
+			input: `Path=~()`,
 			expected: expected{
-				ok:   true,
-				repr: `(Flag == true)`,
+				ok:  false,
+				err: `parse error: empty pattern list at 1:7`,
 			},
 		},
 		{
-			name:  "bool neq",
-			input: `Flag!=False`,
+			name:  "regex list number",
+			input: `Path=~("^/api", 1)`,
 			expected: expected{
-				ok:   true,
-				repr: `(Flag != False)`,
+				ok:  false,
+				err: `parse error: expected string pattern, got number at 1:17: "1"`,
 			},
 		},
-		// Logic and precedence
 		{
-			name:  "and or precedence",
-			input: `HP>50&&MP>=100||LP==0`,
+			name:  "regex list invalid pattern",
+			input: `Path=~("^/api", "[")`,
 			expected: expected{
-				ok:   true,
-				repr: `(((HP > 50) && (MP >= 100)) || (LP == 0))`,
+				ok:  false,
+				err: `invalid regex`,
 			},
 		},
 		{
-			name:  "paren grouping",
-			input: `(HP>50&&MP>=100)||LP==0`,
+			name:  "contains as identifier",
+			input: `containsany==1`,
 			expected: expected{
-				ok:   true,
-				repr: `(((HP > 50) && (MP >= 100)) || (LP == 0))`,
+				ok:  false,
+				err: `expected left parenthesis or identifier`,
 			},
 		},
 		{
-			name:  "not group",
-			input: `!(SPD<20)`,
+			name:  "function lower",
+			input: `lower(Name)=="alice"`,
 			expected: expected{
 				ok:   true,
-				repr: `(! (SPD < 20))`,
+				repr: `(lower(Name) == "alice")`,
 			},
 		},
 		{
-			name:  "complex",
-			input: `Class=="軍師"&&Name=~'孔明'&&(HP>50&&MP>=100&&LP!=0)&&(MAG>=20||!(SPD<20))`,
+			name:  "function upper",
+			input: `upper( Name )!=*"X"`,
 			expected: expected{
 				ok:   true,
-				repr: `((((Class == "軍師") && (Name =~ "孔明")) && (((HP > 50) && (MP >= 100)) && (LP != 0))) && ((MAG >= 20) || (! (SPD < 20))))`,
+				repr: `(upper(Name) !=* "X")`,
 			},
 		},
-		// Errors
 		{
-			name:  "regex empty pattern",
-			input: `Name=~''`,
+			name:  "function trim",
+			input: `trim(Path)=~"^/api"`,
 			expected: expected{
-				ok:  false,
-				err: `invalid regex`,
+				ok:   true,
+				repr: `(trim(Path) =~ "^/api")`,
 			},
 		},
 		{
-			name:  "invalid regex",
-			input: `Name=~'['`,
+			name:  "function in logical",
+			input: `!trim(A)=="x" || lower(B)=="y"`,
 			expected: expected{
-				ok:  false,
-				err: `invalid regex`,
+				ok:   true,
+				repr: `((! (trim(A) == "x")) || (lower(B) == "y"))`,
 			},
 		},
 		{
-			name:  "missing op",
-			input: `HP 50`,
+			name:  "function in chain",
+			input: `"a"<=lower(Name)<"n"`,
 			expected: expected{
-				ok:  false,
-				err: `expected comparison operator`,
+				ok:   true,
+				repr: `((lower(Name) >= "a") && (lower(Name) < "n"))`,
 			},
 		},
 		{
-			name:  "missing right",
-			input: `HP>`,
+			name:  "unknown function",
+			input: `size(Name)==1`,
 			expected: expected{
 				ok:  false,
-				err: `expected value`,
+				err: `unknown function "size" at 1:1`,
 			},
 		},
 		{
-			name:  "unexpected trailing",
-			input: `HP>50 extra`,
+			name:  "function case-sensitive",
+			input: `Lower(Name)=="a"`,
 			expected: expected{
 				ok:  false,
-				err: `unexpected token after parsing`,
+				err: `unknown function "Lower" at 1:1`,
 			},
 		},
 		{
-			name:  "leading not without operand",
-			input: `!`,
+			name:  "function missing argument",
+			input: `lower()=="a"`,
 			expected: expected{
 				ok:  false,
-				err: `expected left parenthesis or identifier`,
+				err: `expected identifier`,
 			},
 		},
 		{
-			name:  "empty",
-			input: ``,
+			name:  "function value argument",
+			input: `lower("a")=="a"`,
 			expected: expected{
 				ok:  false,
-				err: `empty input`,
+				err: `expected identifier`,
 			},
 		},
 		{
-			name:  "unclosed paren",
-			input: `(HP>1`,
+			name:  "function unclosed",
+			input: `lower(Name`,
 			expected: expected{
 				ok:  false,
-				err: `unclosed left parenthesis`,
+				err: `unclosed left parenthesis at 1:6`,
 			},
 		},
 		{
-			name:  "extra right paren",
-			input: `HP>1)`,
+			name:  "function two arguments",
+			input: `lower(A B)=="a"`,
 			expected: expected{
 				ok:  false,
-				err: `unexpected token after parsing`,
+				err: `expected right parenthesis`,
 			},
 		},
 		{
-			name:  "double logical op",
-			input: `HP>1&&||MP>2`,
+			name:  "function nested",
+			input: `lower(upper(Name))=="a"`,
 			expected: expected{
 				ok:  false,
-				err: `expected left parenthesis or identifier`,
+				err: `expected right parenthesis`,
 			},
 		},
 		{
@@ -292,7 +1192,7 @@ func TestParse(t *testing.T) {
 			input: `123==456`,
 			expected: expected{
 				ok:  false,
-				err: `expected left parenthesis or identifier`,
+				err: `expected ordering operator after value`,
 			},
 		},
 		{
@@ -311,6 +1211,22 @@ func TestParse(t *testing.T) {
 				err: `expected comparison operator`,
 			},
 		},
+		{
+			name:  "duration duplicated unit",
+			input: `Delay>1h30m1h`,
+			expected: expected{
+				ok:  false,
+				err: `token error: invalid duration "1h30m1h" at 1:7: duplicated unit "h" in duration`,
+			},
+		},
+		{
+			name:  "duration multiple dot",
+			input: "Delay>1s &&\n  Delay<0..1h",
+			expected: expected{
+				ok:  false,
+				err: `token error: invalid duration "0..1h" at 2:9: invalid number "0..1" in duration`,
+			},
+		},
 		{
 			name:  "duration segment missing unit",
 			input: `Delay==1h30`,
@@ -356,7 +1272,7 @@ func TestParse(t *testing.T) {
 			input: `HP>1&X==1`,
 			expected: expected{
 				ok:  false,
-				err: `unexpected character`,
+				err: `parse error: unexpected bitwise AND operator between comparisons at 1:5: "&", use "&&"`,
 			},
 		},
 		{
@@ -474,6 +1390,228 @@ func TestParse(t *testing.T) {
 	}
 }
 
+func TestParse_errorPosition(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		line  int
+		col   int
+		err   string
+	}{
+		{
+			name:  "single line",
+			input: `HP>50 extra`,
+			line:  1,
+			col:   7,
+			err:   `parse error: unexpected token after parsing at 1:7: "extra"`,
+		},
+		{
+			name:  "multi line",
+			input: "HP>50 &&\n  Name==\"slime\"\n    extra",
+			line:  3,
+			col:   5,
+			err:   `parse error: unexpected token after parsing at 3:5: "extra"`,
+		},
+		{
+			name:  "crlf",
+			input: "HP>50 &&\r\n  Name==\"slime\"\r\n    extra",
+			line:  3,
+			col:   5,
+			err:   `parse error: unexpected token after parsing at 3:5: "extra"`,
+		},
+		{
+			name:  "identifier value",
+			input: `Duration>bad`,
+			line:  1,
+			col:   10,
+			err:   `parse error: expected value (string, number, duration, time or bool), got identifier at 1:10: "bad"`,
+		},
+		{
+			name:  "field offset number",
+			input: `Deadline<Start - 5`,
+			line:  1,
+			col:   18,
+			err:   `parse error: offset of field "Start" must be a duration, got number at 1:18: "5"`,
+		},
+		{
+			name:  "field offset double sign",
+			input: `Deadline<Start - -5m`,
+			line:  1,
+			col:   18,
+			err:   `parse error: unexpected sign in offset of field "Start" at 1:18: "-5m"`,
+		},
+		{
+			name:  "field offset function",
+			input: `lower(Name)==Other+1h`,
+			line:  1,
+			col:   7,
+			err:   `parse error: lower cannot be compared with a field offset at 1:7`,
+		},
+		{
+			name:  "modulo by zero",
+			input: `ID % 0 == 0`,
+			line:  1,
+			col:   6,
+			err:   `parse error: division by zero in modulo of field "ID" at 1:6`,
+		},
+		{
+			name:  "modulo by float",
+			input: `ID % 2.5 == 0`,
+			line:  1,
+			col:   6,
+			err:   `parse error: divisor of field "ID" must be an integer, got number at 1:6: "2.5"`,
+		},
+		{
+			name:  "map key of identifier",
+			input: `Headers[accept] == 1`,
+			line:  1,
+			col:   9,
+			err:   `parse error: expected string or integer key, got identifier at 1:9: "accept"`,
+		},
+		{
+			name:  "map key of float",
+			input: `Headers[1.5] == 1`,
+			line:  1,
+			col:   9,
+			err:   `parse error: expected string or integer key, got number at 1:9: "1.5"`,
+		},
+		{
+			name:  "len with string",
+			input: `len(Name) > "3"`,
+			line:  1,
+			col:   13,
+			err:   `parse error: len of field "Name" must be compared with a number, got string at 1:13: "3"`,
+		},
+		{
+			name:  "len with regex",
+			input: `len(Name) =~ "3"`,
+			line:  1,
+			col:   11,
+			err:   `parse error: invalid operator for len at 1:11: "=~"`,
+		},
+		{
+			name:  "len in chain",
+			input: `1 < len(Tags) < 5s`,
+			line:  1,
+			col:   17,
+			err:   `parse error: len of field "Tags" must be compared with a number, got duration at 1:17: "5s"`,
+		},
+		{
+			name:  "modulo by string",
+			input: `ID % "2" == 0`,
+			line:  1,
+			col:   6,
+			err:   `parse error: divisor of field "ID" must be an integer, got string at 1:6: "\"2\""`,
+		},
+		{
+			name:  "modulo compared with float",
+			input: `ID % 2 == 0.5`,
+			line:  1,
+			col:   11,
+			err:   `parse error: modulo of field "ID" must be compared with an integer, got number at 1:11: "0.5"`,
+		},
+		{
+			name:  "modulo with regex",
+			input: `ID % 2 =~ "0"`,
+			line:  1,
+			col:   8,
+			err:   `parse error: invalid operator for modulo at 1:8: "=~"`,
+		},
+		{
+			name:  "bitwise and with field operand",
+			input: `Perms & Mask == 0`,
+			line:  1,
+			col:   9,
+			err:   `parse error: operand of field "Perms" must be an integer, got identifier at 1:9: "Mask"`,
+		},
+		{
+			name:  "modulo beyond int64",
+			input: `ID % 0xFFFFFFFFFFFFFFFF == 0`,
+			line:  1,
+			col:   6,
+			err:   `parse error: divisor of field "ID" must be an integer, got number at 1:6: "0xFFFFFFFFFFFFFFFF"`,
+		},
+		{
+			name:  "bitwise or between comparisons",
+			input: `HP>1 | MP>1`,
+			line:  1,
+			col:   6,
+			err:   `parse error: unexpected bitwise OR operator between comparisons at 1:6: "|", use "||"`,
+		},
+		{
+			name:  "bitwise xor with list",
+			input: `Perms ^ 1 containsany (1)`,
+			line:  1,
+			col:   11,
+			err:   `parse error: invalid operator for bitwise XOR at 1:11: "containsany"`,
+		},
+		{
+			name:  "modulo function",
+			input: `lower(Name) % 2 == 0`,
+			line:  1,
+			col:   13,
+			err:   `parse error: lower cannot be used with modulo at 1:13`,
+		},
+		{
+			name:  "operator value",
+			input: `HP> ==5`,
+			line:  1,
+			col:   5,
+			err:   `parse error: expected value (string, number, duration, time or bool), got "equal to" operator at 1:5: "=="`,
+		},
+		{
+			name:  "unclosed left parenthesis",
+			input: "HP > 1 &&\n  (Name == \"a\"",
+			line:  2,
+			col:   3,
+			err:   `parse error: unclosed left parenthesis at 2:3`,
+		},
+		{
+			name:  "unexpected right parenthesis",
+			input: `HP>1)`,
+			line:  1,
+			col:   5,
+			err:   `parse error: unexpected right parenthesis at 1:5`,
+		},
+		{
+			name:  "unknown function",
+			input: `foo(Name) == "a"`,
+			line:  1,
+			col:   1,
+			err:   `parse error: unknown function "foo" at 1:1`,
+		},
+		{
+			name:  "non-string pattern",
+			input: `Name =~ ("a", 1)`,
+			line:  1,
+			col:   15,
+			err:   `parse error: expected string pattern, got number at 1:15: "1"`,
+		},
+		{
+			name:  "trailing comma",
+			input: `Tags containsany ("a", )`,
+			line:  1,
+			col:   22,
+			err:   `parse error: trailing comma in list at 1:22`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(test.input)
+			var e *Error
+			if !errors.As(err, &e) {
+				t.Fatalf(testTemplate, test.input, test.err, err)
+			}
+			if e.Line != test.line || e.Col != test.col {
+				t.Errorf(testTemplate, test.input, fmt.Sprintf("%d:%d", test.line, test.col), fmt.Sprintf("%d:%d", e.Line, e.Col))
+			}
+			if err.Error() != test.err {
+				t.Errorf(testTemplate, test.input, test.err, err)
+			}
+		})
+	}
+}
+
 // repr converts ast to a string.
 func repr(e *Expr) string {
 	val := func(v string) string {
@@ -491,7 +1629,26 @@ func repr(e *Expr) string {
 		case nodeNOT:
 			return "(! " + walk(n.left) + ")"
 		case nodeComparison:
-			return "(" + n.ident.v + " " + n.op.typ.literal() + " " + val(n.val.v) + ")"
+			ident := n.ident.v
+			if n.fn != transformNone {
+				ident = n.fn.String() + "(" + ident + ")"
+			}
+			if n.isArith() {
+				ident += " " + n.arith.typ.literal() + " " + n.arg.v
+			}
+			if n.isList() {
+				vals := make([]string, len(n.items))
+				for i, item := range n.items {
+					vals[i] = val(item.val.v)
+				}
+				return "(" + ident + " " + n.op.typ.literal() + " (" + strings.Join(vals, ", ") + "))"
+			}
+			if n.isOffset() {
+				return "(" + ident + " " + n.op.typ.literal() + " " + n.ref.v + n.val.v + ")"
+			}
+			return "(" + ident + " " + n.op.typ.literal() + " " + val(n.val.v) + ")"
+		case nodeBool:
+			return n.val.v
 		default:
 			return "<unknown>"
 		}
@@ -535,3 +1692,119 @@ func isDurationLike(s string) bool {
 	}
 	return false
 }
+
+func TestExpr_Recompile(t *testing.T) {
+	target := testTarget{"Name": "slime", "Path": "/api/v1"}
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{name: "regex", input: `Name =~ "^sl"`, err: `eval error: regex not compiled at 1:9: "^sl"`},
+		{name: "case-insensitive", input: `Name =~* "^SL" && Name !~* "X"`, err: `eval error: regex not compiled at 1:10: "(?i)^SL"`},
+		{name: "anchored", input: `Name matches "sl.*"`, err: `eval error: regex not compiled at 1:14: "sl.*"`},
+		{name: "list", input: `Path =~ ("^/health", "^/api")`, err: `eval error: regex not compiled at 1:10: "^/health"`},
+		{name: "chain", input: `Name != "" && (Path =~ "v1$" || Name == "x")`, err: `eval error: regex not compiled at 1:24: "v1$"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, "", err)
+			}
+			// Drop the compiled regexes as if the expression was restored without them.
+			for i := range expr.parser.nodes {
+				expr.parser.nodes[i].re = nil
+				items := slices.Clone(expr.parser.nodes[i].items)
+				for j := range items {
+					items[j].re = nil
+				}
+				expr.parser.nodes[i].items = items
+			}
+			if _, err := expr.Eval(target); err == nil || err.Error() != test.err {
+				t.Errorf(testTemplate, test.input, test.err, err)
+			}
+			if err := expr.Recompile(); err != nil {
+				t.Fatalf(testTemplate, test.input, nil, err)
+			}
+			if ok, err := expr.Eval(target); !ok || err != nil {
+				t.Errorf(testTemplate, test.input, true, err)
+			}
+		})
+	}
+}
+
+func TestExpr_Recompile_invalid(t *testing.T) {
+	expr, err := Parse(`Name =~ "a"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expr.parser.nodes[expr.root].val.v = "["
+	expected := "parse error: invalid regex \"[\" at 1:9: error parsing regexp: missing closing ]: `[`"
+	if err := expr.Recompile(); err == nil || err.Error() != expected {
+		t.Errorf(testTemplate, "[", expected, err)
+	}
+	var e *Expr
+	if err := e.Recompile(); err != nil {
+		t.Errorf(testTemplate, "nil", nil, err)
+	}
+}
+
+func TestExpr_Recompile_derived(t *testing.T) {
+	expr, err := Parse(`Path =~ ("^/health", "^/api")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	derived := Optimize(expr)
+	items := derived.parser.nodes[derived.root].items
+	if err := expr.Recompile(); err != nil {
+		t.Fatal(err)
+	}
+	if &expr.parser.nodes[expr.root].items[0] == &items[0] {
+		t.Errorf(testTemplate, expr, "items copied", "items shared")
+	}
+}
+
+func TestLexErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected []string
+	}{
+		{name: "valid", input: `A == "x" && B =~ "^y"`, expected: nil},
+		{name: "syntax error", input: `A == && B`, expected: nil},
+		{name: "two sequences", input: "A == \"x\xff\" || B == \"\xc3(\"", expected: []string{
+			"token error: invalid utf8 encoding in string at 1:8",
+			"token error: invalid utf8 encoding in string at 1:20",
+		}},
+		{name: "multi-line", input: "A == `\xff`\n&& B == '\xfe'", opts: []Option{WithLiteralSingleQuotes()}, expected: []string{
+			"token error: invalid utf8 encoding in raw string at 1:7",
+			"token error: invalid utf8 encoding in string at 2:10",
+		}},
+		{name: "stops at other error", input: "A == \"\xff\" && B == 1 $ C == \"\xfe\"", expected: []string{
+			"token error: invalid utf8 encoding in string at 1:7",
+			"token error: unexpected character U+0024 '$' at 1:20",
+		}},
+		{name: "empty", input: "", expected: []string{"parse error: empty input"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var actual []string
+			for _, err := range LexErrors(test.input, test.opts...) {
+				actual = append(actual, err.Error())
+			}
+			if !slices.Equal(actual, test.expected) {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
+			}
+		})
+	}
+	input := "A == \"x\xff\" || B == \"\xc3(\""
+	if _, err := Parse(input); err == nil || err.Error() != "token error: invalid utf8 encoding in string at 1:9" {
+		t.Errorf(testTemplate, input, "token error: invalid utf8 encoding in string at 1:9", err)
+	}
+	var e *Error
+	if errs := LexErrors(input); len(errs) != 2 || !errors.As(errs[1], &e) || e.Line != 1 || e.Col != 20 {
+		t.Errorf(testTemplate, input, "1:20", errs)
+	}
+}
@@ -0,0 +1,84 @@
+package filter
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// EvalReason evaluates the expression against a target like Eval and, if the result is false,
+// also returns the clause that decided it, written as String writes it: the comparison that
+// evaluated false and stopped a &&, the last operand tried by a || none of whose operands held,
+// or a negation whose operand held, such as !(A == 1). Under WithThreeValuedLogic, the comparison
+// of a missing field is returned when it made the result unknown.
+// The reason is empty if the result is true or an error occurs.
+func (e *Expr) EvalReason(t Target) (bool, string, error) {
+	var cache map[string]any
+	if n := e.cacheSize(); n > 0 {
+		cache = make(map[string]any, n)
+	}
+	v, i, err := e.evalReason(e.root, t, newState(cache, time.Now))
+	if err != nil {
+		return false, "", err
+	}
+	if v == truthTrue {
+		return true, "", nil
+	}
+	var b strings.Builder
+	e.format(&b, i)
+	return false, b.String(), nil
+}
+
+// evalReason evaluates the node at index i against a target like evalKleene,
+// and also returns the index of the node that decided the value.
+// Without WithThreeValuedLogic, comparisons are never unknown, so it yields the result of eval.
+func (e *Expr) evalReason(i int, t Target, st *state) (truth, int, error) {
+	if err := st.err(); err != nil {
+		return truthFalse, i, err
+	}
+	n := e.parser.nodes[i]
+	switch n.typ {
+	case nodeBinary:
+		decisive := truthFalse
+		if n.op.typ == tokenOR {
+			decisive = truthTrue
+		}
+		strict := e.parser.opts.strict
+		left, l, lerr := e.evalReason(n.left, t, st)
+		if !strict && (lerr != nil || left == decisive) {
+			return left, l, lerr
+		}
+		right, r, rerr := e.evalReason(n.right, t, st)
+		switch {
+		case lerr != nil:
+			return truthFalse, l, lerr
+		case rerr != nil:
+			return truthFalse, r, rerr
+		case left == decisive:
+			return decisive, l, nil
+		case right == decisive:
+			return decisive, r, nil
+		case left == truthUnknown:
+			return truthUnknown, l, nil
+		case right == truthUnknown:
+			return truthUnknown, r, nil
+		default:
+			return decisive.not(), r, nil
+		}
+	case nodeNOT:
+		v, j, err := e.evalReason(n.left, t, st)
+		if err != nil {
+			return truthFalse, j, err
+		}
+		if v == truthTrue {
+			return truthFalse, i, nil
+		}
+		return v.not(), j, nil
+	default:
+		ok, err := e.eval(i, t, st)
+		if e.parser.opts.threeValued && errors.Is(err, ErrFieldNotFound) {
+			return truthUnknown, i, nil
+		}
+		return truthOf(ok), i, err
+	}
+}
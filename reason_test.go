@@ -0,0 +1,67 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpr_EvalReason(t *testing.T) {
+	target := testTarget{"A": 1, "B": 3, "Name": "slime"}
+	type expected struct {
+		val    bool
+		reason string
+		err    string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected expected
+	}{
+		{name: "true", input: `A==1 && B==3`, expected: expected{val: true}},
+		{name: "and", input: `A==1 && B==2`, expected: expected{reason: `B == 2`}},
+		{name: "and short-circuit", input: `A==2 && B==2`, expected: expected{reason: `A == 2`}},
+		{name: "and chain", input: `A==1 && B==2 && Name=="x"`, expected: expected{reason: `B == 2`}},
+		{name: "or", input: `A==2 || B==2`, expected: expected{reason: `B == 2`}},
+		{name: "or of and", input: `A==1 && B==2 || Name=~"^x"`, expected: expected{reason: `Name =~ "^x"`}},
+		{name: "and of or", input: `(A==2 || B==2) && Name=="slime"`, expected: expected{reason: `B == 2`}},
+		{name: "not", input: `A==1 && !(Name=="slime")`, expected: expected{reason: `!(Name == "slime")`}},
+		{name: "not of or", input: `!(A==2 || B==3)`, expected: expected{reason: `!(A == 2 || B == 3)`}},
+		{name: "double not", input: `A==1 && !(!(B==2))`, expected: expected{reason: `!(!(B == 2))`}},
+		{name: "chained comparison", input: `0<A<1`, expected: expected{reason: `A < 1`}},
+		{name: "strict", input: `A==2 && B==3`, opts: []Option{WithStrictEval()}, expected: expected{reason: `A == 2`}},
+		{name: "strict error", input: `A==2 && Missing==1`, opts: []Option{WithStrictEval()}, expected: expected{err: `field not found: "Missing"`}},
+		{name: "error", input: `A==1 && Missing==1`, expected: expected{err: `field not found: "Missing"`}},
+		{name: "skipped error", input: `A==2 && Missing==1`, expected: expected{reason: `A == 2`}},
+		{name: "unknown", input: `A==1 && Missing==1`, opts: []Option{WithThreeValuedLogic()}, expected: expected{reason: `Missing == 1`}},
+		{name: "false over unknown", input: `Missing==1 && B==2`, opts: []Option{WithThreeValuedLogic()}, expected: expected{reason: `B == 2`}},
+		{name: "unknown or false", input: `Missing==1 || B==2`, opts: []Option{WithThreeValuedLogic()}, expected: expected{reason: `Missing == 1`}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			val, reason, err := expr.EvalReason(target)
+			if test.expected.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expected.err) {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+				if val || reason != "" {
+					t.Errorf(testTemplate, test.input, "", reason)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			if actual := (expected{val: val, reason: reason}); actual != test.expected {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
+			}
+			if ok, _ := expr.Eval(target); ok != val {
+				t.Errorf(testTemplate, test.input, ok, val)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package filter
+
+import (
+	"context"
+	"iter"
+)
+
+// Result represents the outcome of evaluating an expression against a target.
+// Matched is always false when Err is not nil.
+type Result struct {
+	Matched bool  // whether the target matched the expression
+	Err     error // error that occurred during evaluation
+}
+
+// EvalResult evaluates the expression against a target and returns the outcome as a Result.
+func (e *Expr) EvalResult(t Target) Result {
+	ok, err := e.Eval(t)
+	if err != nil {
+		return Result{Err: err}
+	}
+	return Result{Matched: ok}
+}
+
+// Partition holds targets partitioned by the outcome of evaluation.
+type Partition[T Target] struct {
+	Matched   []T     // targets that matched the expression
+	Unmatched []T     // targets that did not match the expression
+	Errored   []T     // targets whose evaluation failed
+	Errs      []error // errors corresponding to Errored by index
+}
+
+// Filter evaluates the expression against each target and partitions them
+// into matched, unmatched, and errored targets, preserving the input order.
+func Filter[T Target](e *Expr, targets []T) Partition[T] {
+	var p Partition[T]
+	for _, t := range targets {
+		r := e.EvalResult(t)
+		switch {
+		case r.Err != nil:
+			p.Errored = append(p.Errored, t)
+			p.Errs = append(p.Errs, r.Err)
+		case r.Matched:
+			p.Matched = append(p.Matched, t)
+		default:
+			p.Unmatched = append(p.Unmatched, t)
+		}
+	}
+	return p
+}
+
+// Matches returns an iterator over the targets of targets that match the expression, in order,
+// for use with range-over-func such as for t := range expr.Matches(seq).
+// Targets whose evaluation fails are skipped; use MatchesWithErrors to receive them.
+func (e *Expr) Matches(targets iter.Seq[Target]) iter.Seq[Target] {
+	return func(yield func(Target) bool) {
+		for t, err := range e.MatchesWithErrors(targets) {
+			if err == nil && !yield(t) {
+				return
+			}
+		}
+	}
+}
+
+// MatchesWithErrors returns an iterator over the targets of targets that match the expression,
+// paired with a nil error, and the targets whose evaluation failed, paired with the error, in order.
+// Unmatched targets are skipped, as in FilterChan.
+func (e *Expr) MatchesWithErrors(targets iter.Seq[Target]) iter.Seq2[Target, error] {
+	return func(yield func(Target, error) bool) {
+		for t := range targets {
+			ok, err := e.Eval(t)
+			if !ok && err == nil {
+				continue
+			}
+			if !yield(t, err) {
+				return
+			}
+		}
+	}
+}
+
+// Match is a target forwarded by FilterChan.
+// Err is not nil if the evaluation against the target failed.
+type Match[T Target] struct {
+	Target T     // target that matched the expression or whose evaluation failed
+	Err    error // error that occurred during evaluation
+}
+
+// FilterChan evaluates the expression against each target received from in and sends
+// the matched targets, and the targets whose evaluation failed with the error, to the returned channel.
+// Unmatched targets are dropped. The returned channel is unbuffered, so a slow receiver slows down
+// the reading of in, and it is closed when in is closed or ctx is done.
+// Evaluation stops as in EvalContext when ctx is done, and the target being evaluated is then dropped.
+func FilterChan[T Target](ctx context.Context, e *Expr, in <-chan T) <-chan Match[T] {
+	out := make(chan Match[T])
+	go func() {
+		defer close(out)
+		for {
+			var t T
+			select {
+			case <-ctx.Done():
+				return
+			case v, ok := <-in:
+				if !ok {
+					return
+				}
+				t = v
+			}
+			ok, err := e.EvalContext(ctx, t)
+			if ctx.Err() != nil {
+				return
+			}
+			if !ok && err == nil {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case out <- Match[T]{Target: t, Err: err}:
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,253 @@
+package filter
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpr_EvalResult(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		target   testTarget
+		expected Result
+		err      string
+	}{
+		{
+			name:     "matched",
+			input:    `Int==42`,
+			target:   testObject,
+			expected: Result{Matched: true},
+		},
+		{
+			name:     "unmatched",
+			input:    `Int==0`,
+			target:   testObject,
+			expected: Result{Matched: false},
+		},
+		{
+			name:   "errored",
+			input:  `Int==42 && Missing==1`,
+			target: testObject,
+			err:    `field not found: "Missing"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, "", err)
+			}
+			actual := expr.EvalResult(test.target)
+			if test.err != "" {
+				if actual.Matched || actual.Err == nil || !strings.Contains(actual.Err.Error(), test.err) {
+					t.Errorf(testTemplate, test.input, test.err, actual)
+				}
+				return
+			}
+			if actual != test.expected {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestFilter(t *testing.T) {
+	input := `Name=~"^a" && Score>10`
+	targets := []testTarget{
+		{"Name": "alice", "Score": 20},
+		{"Name": "bob", "Score": 30},
+		{"Name": "anna"},
+		{"Name": "amy", "Score": 5},
+		{"Score": 50},
+		{"Name": "arthur", "Score": 11},
+	}
+	expr, err := Parse(input)
+	if err != nil {
+		t.Fatalf(testTemplate, input, "", err)
+	}
+	actual := Filter(expr, targets)
+	matched := []testTarget{targets[0], targets[5]}
+	unmatched := []testTarget{targets[1], targets[3]}
+	errored := []testTarget{targets[2], targets[4]}
+	if !reflect.DeepEqual(actual.Matched, matched) {
+		t.Errorf(testTemplate, input, matched, actual.Matched)
+	}
+	if !reflect.DeepEqual(actual.Unmatched, unmatched) {
+		t.Errorf(testTemplate, input, unmatched, actual.Unmatched)
+	}
+	if !reflect.DeepEqual(actual.Errored, errored) {
+		t.Errorf(testTemplate, input, errored, actual.Errored)
+	}
+	errs := []string{`field not found: "Score"`, `field not found: "Name"`}
+	if len(actual.Errs) != len(errs) {
+		t.Fatalf(testTemplate, input, errs, actual.Errs)
+	}
+	for i, err := range actual.Errs {
+		if !strings.Contains(err.Error(), errs[i]) {
+			t.Errorf(testTemplate, input, errs[i], err)
+		}
+	}
+}
+
+func TestExpr_Matches(t *testing.T) {
+	input := `Name=~"^a" && Score>10`
+	targets := []Target{
+		testTarget{"Name": "alice", "Score": 20},
+		testTarget{"Name": "bob", "Score": 30},
+		testTarget{"Name": "anna"},
+		testTarget{"Name": "amy", "Score": 5},
+		testTarget{"Name": "arthur", "Score": 11},
+	}
+	expr, err := Parse(input)
+	if err != nil {
+		t.Fatalf(testTemplate, input, "", err)
+	}
+	var actual []Target
+	for target := range expr.Matches(slices.Values(targets)) {
+		actual = append(actual, target)
+	}
+	expected := []Target{targets[0], targets[4]}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf(testTemplate, input, expected, actual)
+	}
+	actual = nil
+	for target := range expr.Matches(slices.Values(targets)) {
+		actual = append(actual, target)
+		break
+	}
+	if !reflect.DeepEqual(actual, expected[:1]) {
+		t.Errorf(testTemplate, input, expected[:1], actual)
+	}
+}
+
+func TestExpr_MatchesWithErrors(t *testing.T) {
+	input := `Name=~"^a" && Score>10`
+	targets := []Target{
+		testTarget{"Name": "alice", "Score": 20},
+		testTarget{"Name": "bob", "Score": 30},
+		testTarget{"Name": "anna"},
+		testTarget{"Name": "amy", "Score": 5},
+		testTarget{"Name": "arthur", "Score": 11},
+	}
+	expr, err := Parse(input)
+	if err != nil {
+		t.Fatalf(testTemplate, input, "", err)
+	}
+	var actual []Target
+	var errs []error
+	for target, err := range expr.MatchesWithErrors(slices.Values(targets)) {
+		actual = append(actual, target)
+		errs = append(errs, err)
+	}
+	expected := []Target{targets[0], targets[2], targets[4]}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf(testTemplate, input, expected, actual)
+	}
+	if errs[0] != nil || errs[2] != nil {
+		t.Errorf(testTemplate, input, nil, errs)
+	}
+	if errs[1] == nil || !strings.Contains(errs[1].Error(), `field not found: "Score"`) {
+		t.Errorf(testTemplate, input, `field not found: "Score"`, errs[1])
+	}
+}
+
+func TestFilterChan(t *testing.T) {
+	input := `Name=~"^a" && Score>10`
+	targets := []testTarget{
+		{"Name": "alice", "Score": 20},
+		{"Name": "bob", "Score": 30},
+		{"Name": "anna"},
+		{"Name": "amy", "Score": 5},
+		{"Name": "arthur", "Score": 11},
+	}
+	expr, err := Parse(input)
+	if err != nil {
+		t.Fatalf(testTemplate, input, "", err)
+	}
+	in := make(chan testTarget)
+	go func() {
+		defer close(in)
+		for _, target := range targets {
+			in <- target
+		}
+	}()
+	var actual []Match[testTarget]
+	for m := range FilterChan(context.Background(), expr, in) {
+		actual = append(actual, m)
+	}
+	if len(actual) != 3 {
+		t.Fatalf(testTemplate, input, 3, actual)
+	}
+	expected := []testTarget{targets[0], targets[2], targets[4]}
+	for i, m := range actual {
+		if !reflect.DeepEqual(m.Target, expected[i]) {
+			t.Errorf(testTemplate, input, expected[i], m.Target)
+		}
+	}
+	if actual[0].Err != nil || actual[2].Err != nil {
+		t.Errorf(testTemplate, input, nil, []error{actual[0].Err, actual[2].Err})
+	}
+	if actual[1].Err == nil || !strings.Contains(actual[1].Err.Error(), `field not found: "Score"`) {
+		t.Errorf(testTemplate, input, `field not found: "Score"`, actual[1].Err)
+	}
+}
+
+func TestFilterChan_cancel(t *testing.T) {
+	input := `Name=~"^a"`
+	expr, err := Parse(input)
+	if err != nil {
+		t.Fatalf(testTemplate, input, "", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	in := make(chan testTarget)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case in <- testTarget{"Name": "alice"}:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	out := FilterChan(ctx, expr, in)
+	if m := <-out; m.Err != nil {
+		t.Fatalf(testTemplate, input, nil, m.Err)
+	}
+	cancel()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-out:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatalf(testTemplate, input, "closed", "open")
+		}
+	}
+}
+
+func TestFilterChan_cancelIdle(t *testing.T) {
+	expr, err := Parse(`Name=~"^a"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	out := FilterChan(ctx, expr, make(chan testTarget))
+	cancel()
+	select {
+	case _, ok := <-out:
+		if ok {
+			t.Errorf(testTemplate, "idle", "closed", "match")
+		}
+	case <-time.After(time.Second):
+		t.Errorf(testTemplate, "idle", "closed", "open")
+	}
+}
@@ -0,0 +1,63 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Route evaluates the expression against a target and, if it holds, derives a routing key from the string
+// value of a field, replacing matches of pattern with template as regexp.Regexp.ReplaceAllString does,
+// such as user-$1 for ^/users/(\d+)/.*$. The key applies only when the pattern matches; otherwise,
+// or if the expression does not hold, it returns false with an empty key. Parts of the value outside
+// the match are kept, so a pattern usually spans the whole value. The pattern is shared through the
+// regex cache, and an invalid pattern is returned as a parse error.
+func (e *Expr) Route(t Target, field, pattern, template string) (string, bool, error) {
+	re, err := routeRegex(pattern)
+	if err != nil {
+		return "", false, err
+	}
+	st := newState(make(map[string]any, len(e.parser.idents)+1), time.Now)
+	ok, err := e.evalRoot(t, st)
+	if err != nil || !ok {
+		return "", false, err
+	}
+	v, err := st.field(t, field)
+	if err != nil {
+		return "", false, &Error{Kind: KindEval, Err: err}
+	}
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	case fmt.Stringer:
+		s = v.String()
+	default:
+		return "", false, &Error{
+			Kind: KindEval,
+			Err:  fmt.Errorf("cannot route on %q: %T is not a string", field, v),
+		}
+	}
+	if !re.MatchString(s) {
+		return "", false, nil
+	}
+	return re.ReplaceAllString(s, template), true, nil
+}
+
+// routeRegex compiles the pattern of Route, or loads it from the regex cache.
+func routeRegex(pattern string) (*regexp.Regexp, error) {
+	if cached, ok := regexMap.Load(pattern); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, &Error{
+			Kind: KindParse,
+			Err:  fmt.Errorf("invalid regex %q: %w", pattern, err),
+		}
+	}
+	regexMap.Store(pattern, re)
+	return re, nil
+}
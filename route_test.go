@@ -0,0 +1,55 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpr_Route(t *testing.T) {
+	target := testTarget{"Method": "GET", "Path": "/users/42/posts", "Raw": []byte("/users/7"), "HP": 100}
+	type expected struct {
+		key string
+		ok  bool
+		err string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		field    string
+		pattern  string
+		template string
+		expected expected
+	}{
+		{name: "numeric id", input: `Method == "GET"`, field: "Path", pattern: `^/users/(\d+)/.*$`, template: "user-$1", expected: expected{key: "user-42", ok: true}},
+		{name: "named group", input: `Path =~ "^/users/"`, field: "Path", pattern: `^/users/(?P<id>\d+)/(?P<kind>\w+)$`, template: "${kind}:${id}", expected: expected{key: "posts:42", ok: true}},
+		{name: "partial match", input: `Method == "GET"`, field: "Path", pattern: `\d+`, template: "{id}", expected: expected{key: "/users/{id}/posts", ok: true}},
+		{name: "bytes", input: `Method == "GET"`, field: "Raw", pattern: `^/users/(\d+)$`, template: "user-$1", expected: expected{key: "user-7", ok: true}},
+		{name: "no match", input: `Method == "GET"`, field: "Path", pattern: `^/groups/(\d+)`, template: "group-$1", expected: expected{}},
+		{name: "expression false", input: `Method == "POST"`, field: "Path", pattern: `^/users/(\d+)/.*$`, template: "user-$1", expected: expected{}},
+		{name: "missing field", input: `Method == "GET"`, field: "Host", pattern: `.`, template: "x", expected: expected{err: `field not found: "Host"`}},
+		{name: "not a string", input: `Method == "GET"`, field: "HP", pattern: `.`, template: "x", expected: expected{err: `eval error: cannot route on "HP": int is not a string`}},
+		{name: "invalid pattern", input: `Method == "GET"`, field: "Path", pattern: `(`, template: "x", expected: expected{err: `parse error: invalid regex "("`}},
+		{name: "eval error", input: `Missing == 1`, field: "Path", pattern: `.`, template: "x", expected: expected{err: `field not found: "Missing"`}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			key, ok, err := expr.Route(target, test.field, test.pattern, test.template)
+			if test.expected.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expected.err) {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			if actual := (expected{key: key, ok: ok}); actual != test.expected {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package filter
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// Ruleset is a set of named expressions, such as routing rules, evaluated against a target together.
+// Rules are tried in the order of their names, so the result does not depend on map iteration order.
+type Ruleset struct {
+	names []string
+	exprs []*Expr
+}
+
+// NewRuleset parses each filter of rules, keyed by the name of the rule, with the options.
+// The rules share the regex cache of Parse, so a pattern used by several rules is compiled once.
+// The first rule in name order that fails to parse is reported with its name, such as
+// rule "admin": parse error: ..., and the error wraps the *Error of Parse.
+func NewRuleset(rules map[string]string, opts ...Option) (*Ruleset, error) {
+	r := &Ruleset{
+		names: slices.Sorted(maps.Keys(rules)),
+		exprs: make([]*Expr, 0, len(rules)),
+	}
+	for _, name := range r.names {
+		expr, err := Parse(rules[name], opts...)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", name, err)
+		}
+		r.exprs = append(r.exprs, expr)
+	}
+	return r, nil
+}
+
+// Match returns the name of the first rule in name order that matches the target.
+// If no rule matches, the name is empty and ok is false. Evaluation stops at the first rule
+// whose evaluation fails, and the error is reported with the name of the rule.
+func (r *Ruleset) Match(t Target) (name string, ok bool, err error) {
+	for i, expr := range r.exprs {
+		ok, err := expr.Eval(t)
+		if err != nil {
+			return "", false, fmt.Errorf("rule %q: %w", r.names[i], err)
+		}
+		if ok {
+			return r.names[i], true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// MatchAll returns the names of all rules that match the target, in name order.
+// Evaluation stops at the first rule whose evaluation fails, and the error is reported
+// with the name of the rule.
+func (r *Ruleset) MatchAll(t Target) ([]string, error) {
+	var names []string
+	for i, expr := range r.exprs {
+		ok, err := expr.Eval(t)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", r.names[i], err)
+		}
+		if ok {
+			names = append(names, r.names[i])
+		}
+	}
+	return names, nil
+}
+
+// Names returns the names of the rules in the order they are tried.
+func (r *Ruleset) Names() []string {
+	return slices.Clone(r.names)
+}
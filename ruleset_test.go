@@ -0,0 +1,84 @@
+package filter
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestRuleset_Match(t *testing.T) {
+	rules := map[string]string{
+		"b-admin":  `Role == "admin"`,
+		"a-api":    `Path =~ "^/api"`,
+		"c-health": `Path == "/health"`,
+		"d-any":    `Path != ""`,
+	}
+	r, err := NewRuleset(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := r.Names(); !slices.Equal(names, []string{"a-api", "b-admin", "c-health", "d-any"}) {
+		t.Errorf(testTemplate, "names", "sorted", names)
+	}
+	type expected struct {
+		name string
+		ok   bool
+		all  []string
+	}
+	tests := []struct {
+		name     string
+		target   testTarget
+		expected expected
+	}{
+		{name: "overlapping", target: testTarget{"Role": "admin", "Path": "/api/v1"}, expected: expected{name: "a-api", ok: true, all: []string{"a-api", "b-admin", "d-any"}}},
+		{name: "second", target: testTarget{"Role": "admin", "Path": "/health"}, expected: expected{name: "b-admin", ok: true, all: []string{"b-admin", "c-health", "d-any"}}},
+		{name: "last", target: testTarget{"Role": "user", "Path": "/"}, expected: expected{name: "d-any", ok: true, all: []string{"d-any"}}},
+		{name: "none", target: testTarget{"Role": "user", "Path": ""}, expected: expected{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for range 10 {
+				name, ok, err := r.Match(test.target)
+				if err != nil {
+					t.Fatalf(testTemplate, test.target, test.expected, err)
+				}
+				if name != test.expected.name || ok != test.expected.ok {
+					t.Errorf(testTemplate, test.target, test.expected.name, name)
+				}
+			}
+			all, err := r.MatchAll(test.target)
+			if err != nil {
+				t.Fatalf(testTemplate, test.target, test.expected, err)
+			}
+			if !slices.Equal(all, test.expected.all) {
+				t.Errorf(testTemplate, test.target, test.expected.all, all)
+			}
+		})
+	}
+}
+
+func TestRuleset_errors(t *testing.T) {
+	_, err := NewRuleset(map[string]string{"ok": `A == 1`, "broken": `A ==`, "worse": `(`})
+	expected := `rule "broken": parse error: expected value (string, number, duration, time or bool), got EOF at 1:5: ""`
+	if err == nil || err.Error() != expected {
+		t.Errorf(testTemplate, "broken", expected, err)
+	}
+	var e *Error
+	if !errors.As(err, &e) || e.Kind != KindParse {
+		t.Errorf(testTemplate, "broken", KindParse, err)
+	}
+	r, err := NewRuleset(map[string]string{"a": `A == 1`, "b": `Missing == 1`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := r.Match(testTarget{"A": 2}); err == nil || !errors.Is(err, ErrFieldNotFound) {
+		t.Errorf(testTemplate, "match", ErrFieldNotFound, err)
+	}
+	if name, ok, err := r.Match(testTarget{"A": 1}); name != "a" || !ok || err != nil {
+		t.Errorf(testTemplate, "match", "a", name)
+	}
+	expected = `rule "b": eval error: eval error: field not found: "Missing"`
+	if _, err := r.MatchAll(testTarget{"A": 1}); err == nil || err.Error() != expected {
+		t.Errorf(testTemplate, "match all", expected, err)
+	}
+}
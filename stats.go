@@ -0,0 +1,40 @@
+package filter
+
+// Stats describes the size and shape of a parsed expression.
+type Stats struct {
+	Nodes           int // number of nodes in the tree, where a list counts as one comparison
+	Depth           int // number of nodes on the longest path from the root to a comparison
+	ComparisonNodes int // number of comparison nodes, including regex matches
+	RegexNodes      int // number of comparison nodes with a regex operator
+}
+
+// Stats returns the size and shape of the expression, such as for limiting or monitoring
+// how complex filters given by users are. Chained comparisons count in their desugared form.
+// It walks the tree once without allocating.
+func (e *Expr) Stats() Stats {
+	var s Stats
+	if e == nil || len(e.parser.nodes) == 0 {
+		return s
+	}
+	e.stats(e.root, 1, &s)
+	return s
+}
+
+// stats adds the node at index i, found at the given depth, and its children to s.
+func (e *Expr) stats(i, depth int, s *Stats) {
+	n := e.parser.nodes[i]
+	s.Nodes++
+	s.Depth = max(s.Depth, depth)
+	switch n.typ {
+	case nodeBinary:
+		e.stats(n.left, depth+1, s)
+		e.stats(n.right, depth+1, s)
+	case nodeNOT:
+		e.stats(n.left, depth+1, s)
+	case nodeComparison:
+		s.ComparisonNodes++
+		if n.op.typ.isRegexOperatorType() {
+			s.RegexNodes++
+		}
+	}
+}
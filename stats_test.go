@@ -0,0 +1,63 @@
+package filter
+
+import "testing"
+
+func TestExpr_Stats(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected Stats
+	}{
+		{name: "single comparison", input: `HP>50`, expected: Stats{Nodes: 1, Depth: 1, ComparisonNodes: 1}},
+		{name: "regex", input: `Name=~"^A"`, expected: Stats{Nodes: 1, Depth: 1, ComparisonNodes: 1, RegexNodes: 1}},
+		{name: "not", input: `!(HP>50)`, expected: Stats{Nodes: 2, Depth: 2, ComparisonNodes: 1}},
+		{name: "list", input: `Tags containsany ("a", "b", "c")`, expected: Stats{Nodes: 1, Depth: 1, ComparisonNodes: 1}},
+		{name: "regex list", input: `Path =~ ("a", "b")`, expected: Stats{Nodes: 1, Depth: 1, ComparisonNodes: 1, RegexNodes: 1}},
+		{name: "chain", input: `1<HP<100`, expected: Stats{Nodes: 3, Depth: 2, ComparisonNodes: 2}},
+		{name: "left associative", input: `A==1 && B==2 && C==3`, expected: Stats{Nodes: 5, Depth: 3, ComparisonNodes: 3}},
+		{
+			// ((((Class && Name=~) && Name!=) && (BirthDate && ATB)) && ((HitPoint && MagicPoint) && LifePoint)) && (Magic || !Speed):
+			// 10 comparisons, 9 logical operators and 1 negation. The deepest path runs from the root
+			// through 4 nested && on the left down to Class, which is 6 nodes.
+			name: "complex",
+			input: `
+				Class == "軍師" && Name =~ '^(諸葛亮|龐統|法正)' && Name != "" && (
+					BirthDate < '0190-01-01T00:00:00Z' && ActiveTimeBattleGauge >= '20s'
+				) && (
+					HitPoint > "50" && MagicPoint > 100 && LifePoint != 0
+				) && (
+					Magic >= 20 || !(Speed < 20)
+				)
+			`,
+			expected: Stats{Nodes: 20, Depth: 6, ComparisonNodes: 10, RegexNodes: 1},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			if actual := expr.Stats(); actual != test.expected {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestExpr_Stats_nil(t *testing.T) {
+	var e *Expr
+	if actual := e.Stats(); actual != (Stats{}) {
+		t.Errorf(testTemplate, "nil", Stats{}, actual)
+	}
+}
+
+func TestExpr_Stats_allocs(t *testing.T) {
+	expr, err := Parse(`A==1 && (B=~"x" || !(C<2))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { expr.Stats() }); allocs != 0 {
+		t.Errorf(testTemplate, "allocs", 0, allocs)
+	}
+}
@@ -0,0 +1,54 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CaseInsensitiveTarget is a Target backed by a map whose keys are matched ignoring case.
+// An exact match takes precedence. Otherwise keys are compared with simple Unicode case folding,
+// and if two or more keys fold to the requested key, such as "HP" and "hp" for "Hp",
+// the lookup fails rather than picking one of them arbitrarily.
+type CaseInsensitiveTarget map[string]any
+
+// GetField returns the value of the key matched ignoring case.
+func (t CaseInsensitiveTarget) GetField(key string) (any, error) {
+	if v, ok := t[key]; ok {
+		return v, nil
+	}
+	var (
+		value any
+		count int
+	)
+	for k, v := range t {
+		if strings.EqualFold(k, key) {
+			value = v
+			count++
+		}
+	}
+	switch count {
+	case 0:
+		return nil, fmt.Errorf("%w: %q", ErrFieldNotFound, key)
+	case 1:
+		return value, nil
+	default:
+		return nil, fmt.Errorf("ambiguous field %q: %d keys match ignoring case", key, count)
+	}
+}
+
+// Seconds is a number of seconds to be compared with duration literals.
+// Wrap float seconds returned by GetField, such as Seconds(2.5), to compare them with 2s or 2500ms.
+// The value is rounded to the nearest nanosecond before comparing, and NaN, infinite
+// or out of range values are reported as eval errors. Against number literals,
+// Seconds is compared as a plain number.
+type Seconds float64
+
+// FuncTarget is a Target backed by a function, so that fields can be resolved lazily without
+// declaring a type. The function is called at most once per field in each evaluation,
+// and only for fields that are reached; it should return an error wrapping ErrFieldNotFound if the field does not exist.
+type FuncTarget func(key string) (any, error)
+
+// GetField calls the function with the key.
+func (f FuncTarget) GetField(key string) (any, error) {
+	return f(key)
+}
@@ -0,0 +1,126 @@
+//go:build protobuf
+
+package filter
+
+import (
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ProtoTarget is a Target backed by a protobuf message, built with the protobuf build tag.
+// Fields are looked up by their name in the .proto file, such as user_id, or by their JSON name, such as userId.
+// Values are returned as the Go types that the evaluator compares:
+//
+//   - bool, string, int32, int64, uint32, uint64, float32 and float64 as themselves, and bytes as a string
+//   - enums as the name of the value, such as "ROLE_ADMIN", or as an int32 if the number has no name
+//   - google.protobuf.Timestamp and google.protobuf.Duration as time.Time and time.Duration
+//   - repeated fields as a []any of their elements, so that containsany, in and len apply
+//   - map fields as a map keyed by string, int64, uint64 or bool, so that keys can be indexed
+//
+// Other messages are returned as proto.Message. A field that is not set and tracks presence,
+// such as a message field or an optional scalar, is reported as a missing field.
+type ProtoTarget struct {
+	m protoreflect.Message
+}
+
+// NewProtoTarget returns a Target for the protobuf message.
+func NewProtoTarget(m proto.Message) Target {
+	return &ProtoTarget{m: m.ProtoReflect()}
+}
+
+// GetField returns the value of the field of the message.
+func (t *ProtoTarget) GetField(key string) (any, error) {
+	fields := t.m.Descriptor().Fields()
+	fd := fields.ByName(protoreflect.Name(key))
+	if fd == nil {
+		fd = fields.ByJSONName(key)
+	}
+	if fd == nil || fd.HasPresence() && !t.m.Has(fd) {
+		return nil, fmt.Errorf("%w: %q", ErrFieldNotFound, key)
+	}
+	v := t.m.Get(fd)
+	switch {
+	case fd.IsList():
+		l := v.List()
+		s := make([]any, l.Len())
+		for i := range s {
+			s[i] = protoValue(fd, l.Get(i))
+		}
+		return s, nil
+	case fd.IsMap():
+		return protoMap(fd, v.Map()), nil
+	default:
+		return protoValue(fd, v), nil
+	}
+}
+
+// protoValue converts a singular value of the field, or an element of a repeated field, to a Go value.
+func protoValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) any {
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		n := v.Enum()
+		if ev := fd.Enum().Values().ByNumber(n); ev != nil {
+			return string(ev.Name())
+		}
+		return int32(n)
+	case protoreflect.BytesKind:
+		return string(v.Bytes())
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		m := v.Message()
+		switch m.Descriptor().FullName() {
+		case "google.protobuf.Timestamp":
+			seconds, nanos := protoSecondsNanos(m)
+			return time.Unix(seconds, nanos).UTC()
+		case "google.protobuf.Duration":
+			seconds, nanos := protoSecondsNanos(m)
+			return time.Duration(seconds)*time.Second + time.Duration(nanos)
+		}
+		return m.Interface()
+	default:
+		return v.Interface()
+	}
+}
+
+// protoSecondsNanos returns the seconds and nanos fields of a Timestamp or Duration message.
+func protoSecondsNanos(m protoreflect.Message) (int64, int64) {
+	fields := m.Descriptor().Fields()
+	return m.Get(fields.ByName("seconds")).Int(), int64(m.Get(fields.ByName("nanos")).Int())
+}
+
+// protoMap converts the value of a map field to a Go map keyed by the Go type of its keys.
+func protoMap(fd protoreflect.FieldDescriptor, m protoreflect.Map) any {
+	vd := fd.MapValue()
+	switch fd.MapKey().Kind() {
+	case protoreflect.StringKind:
+		out := make(map[string]any, m.Len())
+		m.Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+			out[k.String()] = protoValue(vd, v)
+			return true
+		})
+		return out
+	case protoreflect.BoolKind:
+		out := make(map[bool]any, m.Len())
+		m.Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+			out[k.Bool()] = protoValue(vd, v)
+			return true
+		})
+		return out
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		out := make(map[uint64]any, m.Len())
+		m.Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+			out[k.Uint()] = protoValue(vd, v)
+			return true
+		})
+		return out
+	default:
+		out := make(map[int64]any, m.Len())
+		m.Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+			out[k.Int()] = protoValue(vd, v)
+			return true
+		})
+		return out
+	}
+}
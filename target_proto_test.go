@@ -0,0 +1,166 @@
+//go:build protobuf
+
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// testProtoMessage returns a message of the following type, built at run time:
+//
+//	enum Role { ROLE_UNSPECIFIED = 0; ROLE_ADMIN = 1; ROLE_USER = 2; }
+//	message User {
+//	  string name = 1; int32 level = 2; uint64 id = 3; double score = 4; bool active = 5; bytes token = 6;
+//	  Role role = 7; repeated string tags = 8; repeated Role roles = 9; map<string, int32> quota = 10;
+//	  google.protobuf.Timestamp created_at = 11; google.protobuf.Duration ttl = 12; optional string nickname = 13;
+//	}
+func testProtoMessage(t *testing.T) proto.Message {
+	t.Helper()
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:   typ.Enum(),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	repeated := func(f *descriptorpb.FieldDescriptorProto) *descriptorpb.FieldDescriptorProto {
+		f.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+		return f
+	}
+	nickname := field("nickname", 13, descriptorpb.FieldDescriptorProto_TYPE_STRING, "")
+	nickname.Proto3Optional = proto.Bool(true)
+	nickname.OneofIndex = proto.Int32(0)
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("filter_test.proto"),
+		Package:    proto.String("filtertest"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/timestamp.proto", "google/protobuf/duration.proto"},
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("Role"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("ROLE_UNSPECIFIED"), Number: proto.Int32(0)},
+				{Name: proto.String("ROLE_ADMIN"), Number: proto.Int32(1)},
+				{Name: proto.String("ROLE_USER"), Number: proto.Int32(2)},
+			},
+		}},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("User"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+				field("level", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32, ""),
+				field("id", 3, descriptorpb.FieldDescriptorProto_TYPE_UINT64, ""),
+				field("score", 4, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, ""),
+				field("active", 5, descriptorpb.FieldDescriptorProto_TYPE_BOOL, ""),
+				field("token", 6, descriptorpb.FieldDescriptorProto_TYPE_BYTES, ""),
+				field("role", 7, descriptorpb.FieldDescriptorProto_TYPE_ENUM, ".filtertest.Role"),
+				repeated(field("tags", 8, descriptorpb.FieldDescriptorProto_TYPE_STRING, "")),
+				repeated(field("roles", 9, descriptorpb.FieldDescriptorProto_TYPE_ENUM, ".filtertest.Role")),
+				repeated(field("quota", 10, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".filtertest.User.QuotaEntry")),
+				field("created_at", 11, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".google.protobuf.Timestamp"),
+				field("ttl", 12, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".google.protobuf.Duration"),
+				nickname,
+			},
+			NestedType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("QuotaEntry"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("key", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+					field("value", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32, ""),
+				},
+				Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+			}},
+			OneofDecl: []*descriptorpb.OneofDescriptorProto{{Name: proto.String("_nickname")}},
+		}},
+	}
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := fd.Messages().ByName("User")
+	fields := md.Fields()
+	m := dynamicpb.NewMessage(md)
+	m.Set(fields.ByName("name"), protoreflect.ValueOfString("slime"))
+	m.Set(fields.ByName("level"), protoreflect.ValueOfInt32(7))
+	m.Set(fields.ByName("id"), protoreflect.ValueOfUint64(1<<60))
+	m.Set(fields.ByName("score"), protoreflect.ValueOfFloat64(98.5))
+	m.Set(fields.ByName("active"), protoreflect.ValueOfBool(true))
+	m.Set(fields.ByName("token"), protoreflect.ValueOfBytes([]byte("abc")))
+	m.Set(fields.ByName("role"), protoreflect.ValueOfEnum(1))
+	tags := m.Mutable(fields.ByName("tags")).List()
+	tags.Append(protoreflect.ValueOfString("blue"))
+	tags.Append(protoreflect.ValueOfString("small"))
+	roles := m.Mutable(fields.ByName("roles")).List()
+	roles.Append(protoreflect.ValueOfEnum(2))
+	roles.Append(protoreflect.ValueOfEnum(9))
+	quota := m.Mutable(fields.ByName("quota")).Map()
+	quota.Set(protoreflect.ValueOfString("disk").MapKey(), protoreflect.ValueOfInt32(10))
+	createdAt := timestamppb.New(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	m.Set(fields.ByName("created_at"), protoreflect.ValueOfMessage(createdAt.ProtoReflect()))
+	m.Set(fields.ByName("ttl"), protoreflect.ValueOfMessage(durationpb.New(90*time.Minute).ProtoReflect()))
+	return m
+}
+
+func TestProtoTarget(t *testing.T) {
+	target := NewProtoTarget(testProtoMessage(t))
+	type expected struct {
+		val bool
+		err string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		expected expected
+	}{
+		{name: "string", input: `name == "slime"`, expected: expected{val: true}},
+		{name: "int32", input: `level >= 7 && level < 8`, expected: expected{val: true}},
+		{name: "uint64", input: `id == 0x1000000000000000`, expected: expected{val: true}},
+		{name: "double", input: `score > 98.4`, expected: expected{val: true}},
+		{name: "bool", input: `active == true`, expected: expected{val: true}},
+		{name: "bytes", input: `token == "abc"`, expected: expected{val: true}},
+		{name: "enum", input: `role == "ROLE_ADMIN"`, expected: expected{val: true}},
+		{name: "repeated", input: `"blue" in tags && len(tags) == 2`, expected: expected{val: true}},
+		{name: "repeated absent", input: `tags containsany ("red", "green")`, expected: expected{val: false}},
+		{name: "repeated enum", input: `roles containsall ("ROLE_USER", 9)`, expected: expected{val: true}},
+		{name: "map", input: `quota["disk"] == 10`, expected: expected{val: true}},
+		{name: "timestamp", input: `created_at == 2025-01-01T00:00:00Z`, expected: expected{val: true}},
+		{name: "json name", input: `createdAt < 2025-01-02T00:00:00Z`, expected: expected{val: true}},
+		{name: "duration", input: `ttl == 1h30m`, expected: expected{val: true}},
+		{name: "unset optional", input: `nickname == "s"`, expected: expected{err: `eval error: field not found: "nickname"`}},
+		{name: "unknown field", input: `mp == 1`, expected: expected{err: `eval error: field not found: "mp"`}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			actual, err := expr.Eval(target)
+			if test.expected.err != "" {
+				if err == nil || err.Error() != test.expected.err {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected.val, err)
+			}
+			if actual != test.expected.val {
+				t.Errorf(testTemplate, test.input, test.expected.val, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package filter
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCaseInsensitiveTarget_GetField(t *testing.T) {
+	target := CaseInsensitiveTarget{
+		"HP":    100,
+		"Name":  "slime",
+		"speed": 3,
+		"Level": 1,
+		"LEVEL": 2,
+	}
+	type expected struct {
+		ok  bool
+		val any
+		err string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		expected expected
+	}{
+		{name: "exact", input: "HP", expected: expected{ok: true, val: 100}},
+		{name: "lower", input: "hp", expected: expected{ok: true, val: 100}},
+		{name: "mixed", input: "nAmE", expected: expected{ok: true, val: "slime"}},
+		{name: "upper", input: "SPEED", expected: expected{ok: true, val: 3}},
+		{name: "exact wins over collision", input: "LEVEL", expected: expected{ok: true, val: 2}},
+		{name: "collision", input: "level", expected: expected{ok: false, err: `ambiguous field "level": 2 keys match ignoring case`}},
+		{name: "not found", input: "mp", expected: expected{ok: false, err: `field not found: "mp"`}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := target.GetField(test.input)
+			if !test.expected.ok {
+				if err == nil || err.Error() != test.expected.err {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected.val, err)
+			}
+			if actual != test.expected.val {
+				t.Errorf(testTemplate, test.input, test.expected.val, actual)
+			}
+		})
+	}
+}
+
+func TestWithCaseInsensitiveFields(t *testing.T) {
+	target := CaseInsensitiveTarget{
+		"HP":    100,
+		"Name":  "slime",
+		"Level": 1,
+		"LEVEL": 2,
+	}
+	type expected struct {
+		ok  bool
+		val bool
+		err string
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		target   Target
+		expected expected
+	}{
+		{name: "upper", input: `HP==100`, opts: []Option{WithCaseInsensitiveFields()}, target: target, expected: expected{ok: true, val: true}},
+		{name: "lower", input: `hp==100`, opts: []Option{WithCaseInsensitiveFields()}, target: target, expected: expected{ok: true, val: true}},
+		{name: "mixed", input: `Hp>50 && nAME=="slime"`, opts: []Option{WithCaseInsensitiveFields()}, target: target, expected: expected{ok: true, val: true}},
+		{name: "collision", input: `Level==1`, opts: []Option{WithCaseInsensitiveFields()}, target: target, expected: expected{ok: false, err: `ambiguous field "level"`}},
+		{name: "default wrapper", input: `hp==100`, target: target, expected: expected{ok: true, val: true}},
+		{name: "default case-sensitive", input: `hp==100`, target: testTarget{"HP": 100}, expected: expected{ok: false, err: `field not found: "hp"`}},
+		{name: "folded key", input: `HP==100`, opts: []Option{WithCaseInsensitiveFields()}, target: testTarget{"hp": 100}, expected: expected{ok: true, val: true}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, "", err)
+			}
+			actual, err := expr.Eval(test.target)
+			if !test.expected.ok {
+				if err == nil || !strings.Contains(err.Error(), test.expected.err) {
+					t.Errorf(testTemplate, test.input, test.expected.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected.val, err)
+			}
+			if actual != test.expected.val {
+				t.Errorf(testTemplate, test.input, test.expected.val, actual)
+			}
+		})
+	}
+}
+
+func TestFuncTarget(t *testing.T) {
+	vals := map[string]any{"HP": 100, "Name": "slime", "Class": "mage"}
+	tests := []struct {
+		name     string
+		input    string
+		expected bool
+		fields   []string
+	}{
+		{name: "and", input: `HP > 50 && Name == "slime"`, expected: true, fields: []string{"HP", "Name"}},
+		{name: "short-circuit and", input: `HP < 50 && Name == "slime" && Class == "mage"`, expected: false, fields: []string{"HP"}},
+		{name: "short-circuit or", input: `Name == "slime" || (HP < 50 && Class == "mage")`, expected: true, fields: []string{"Name"}},
+		{name: "repeated", input: `Name != "" && HP > 50 && Name =~ "^sl"`, expected: true, fields: []string{"HP", "Name"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			calls := map[string]int{}
+			target := FuncTarget(func(key string) (any, error) {
+				calls[key]++
+				v, ok := vals[key]
+				if !ok {
+					return nil, fmt.Errorf("%w: %q", ErrFieldNotFound, key)
+				}
+				return v, nil
+			})
+			actual, err := expr.Eval(target)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, test.expected, err)
+			}
+			if actual != test.expected {
+				t.Errorf(testTemplate, test.input, test.expected, actual)
+			}
+			fields := slices.Sorted(maps.Keys(calls))
+			if !slices.Equal(fields, test.fields) {
+				t.Errorf(testTemplate, test.input, test.fields, fields)
+			}
+			for key, n := range calls {
+				if n != 1 {
+					t.Errorf(testTemplate, key, 1, n)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package filter
+
+import "fmt"
+
+// FieldKind represents the declared kind of a field for validation.
+type FieldKind int
+
+const (
+	// FieldString is a string field.
+	FieldString FieldKind = iota
+
+	// FieldNumber is an integer or floating-point field.
+	FieldNumber
+
+	// FieldDuration is a time.Duration field.
+	FieldDuration
+
+	// FieldTime is a time.Time field.
+	FieldTime
+
+	// FieldBool is a boolean field.
+	FieldBool
+)
+
+// String returns a string representation of the field kind.
+func (k FieldKind) String() string {
+	switch k {
+	case FieldString:
+		return "string"
+	case FieldNumber:
+		return "number"
+	case FieldDuration:
+		return "duration"
+	case FieldTime:
+		return "time"
+	case FieldBool:
+		return "bool"
+	default:
+		return "unknown"
+	}
+}
+
+// Validate checks that the operator of each comparison is legal for the kind of its field
+// declared in schema, without evaluating against a target. For example, > on a string field
+// and =~ on a number field are rejected, as are boolean values compared with number, duration
+// or time fields, such as Count == true. Fields not declared in schema are not checked,
+// nor are the values of map keys such as Headers["a"].
+// The first violation found in depth-first order is returned.
+func (e *Expr) Validate(schema map[string]FieldKind) error {
+	if e == nil || len(e.parser.nodes) == 0 {
+		return nil
+	}
+	return e.validate(e.root, schema)
+}
+
+// validate checks the node at index i and its children.
+func (e *Expr) validate(i int, schema map[string]FieldKind) error {
+	n := e.parser.nodes[i]
+	switch n.typ {
+	case nodeBinary:
+		if err := e.validate(n.left, schema); err != nil {
+			return err
+		}
+		return e.validate(n.right, schema)
+	case nodeNOT:
+		return e.validate(n.left, schema)
+	case nodeComparison:
+		kind, ok := schema[n.ident.v]
+		if !ok || n.isIndex() {
+			return nil
+		}
+		if n.fn == transformLen {
+			if kind != FieldString {
+				return &Error{
+					Kind: KindEval,
+					Err:  fmt.Errorf("len requires a string, slice or map field at %d:%d: %q is %s", n.ident.line, n.ident.col, n.ident.v, kind),
+					Line: n.ident.line,
+					Col:  n.ident.col,
+				}
+			}
+			return nil
+		}
+		if n.fn != transformNone && kind != FieldString {
+			return &Error{
+				Kind: KindEval,
+				Err:  fmt.Errorf("%s requires a string field at %d:%d: %q is %s", n.fn, n.ident.line, n.ident.col, n.ident.v, kind),
+				Line: n.ident.line,
+				Col:  n.ident.col,
+			}
+		}
+		if n.isOffset() {
+			if ref, ok := schema[n.ref.v]; kind != FieldTime || ok && ref != FieldTime {
+				t := n.ident
+				if kind == FieldTime {
+					t, kind = n.ref, ref
+				}
+				return &Error{
+					Kind: KindEval,
+					Err:  fmt.Errorf("field offset requires time fields at %d:%d: %q is %s", t.line, t.col, t.v, kind),
+					Line: t.line,
+					Col:  t.col,
+				}
+			}
+		}
+		if n.isArith() && kind != FieldNumber {
+			return &Error{
+				Kind: KindEval,
+				Err:  fmt.Errorf("%s requires an integer field at %d:%d: %q is %s", n.arith.typ.arithName(), n.ident.line, n.ident.col, n.ident.v, kind),
+				Line: n.ident.line,
+				Col:  n.ident.col,
+			}
+		}
+		if !e.isLegalOperator(kind, n.op.typ) {
+			return &Error{
+				Kind: KindEval,
+				Err:  fmt.Errorf("invalid operator for %s field at %d:%d: %q", kind, n.op.line, n.op.col, n.op.typ.literal()),
+				Line: n.op.line,
+				Col:  n.op.col,
+			}
+		}
+		if n.val.typ == tokenBool && kind != FieldBool && kind != FieldString && (kind != FieldNumber || !e.parser.opts.truthy) {
+			return &Error{
+				Kind: KindEval,
+				Err:  fmt.Errorf("cannot compare %s field with boolean at %d:%d: %q", kind, n.val.line, n.val.col, n.val.v),
+				Line: n.val.line,
+				Col:  n.val.col,
+			}
+		}
+	}
+	return nil
+}
+
+// isLegalOperator reports whether the operator can be applied to a field of the kind.
+func (e *Expr) isLegalOperator(kind FieldKind, t tokenType) bool {
+	switch kind {
+	case FieldString:
+		return t.isEqualityOperatorType() || t.isRegexOperatorType() || t.isSubstringOperatorType() || ((e.parser.opts.coerce || e.parser.opts.ordering) && t.isOrderingOperatorType()) || (e.parser.opts.coerce && t == tokenApprox)
+	case FieldNumber:
+		return t == tokenEQ || t == tokenNEQ || t == tokenApprox || t.isOrderingOperatorType() || (e.parser.opts.runes && (t == tokenEQI || t == tokenNEQI))
+	case FieldDuration, FieldTime:
+		return t == tokenEQ || t == tokenNEQ || t.isOrderingOperatorType()
+	case FieldBool:
+		return t.isEqualityOperatorType()
+	default:
+		return false
+	}
+}
@@ -0,0 +1,112 @@
+package filter
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFieldKind_String(t *testing.T) {
+	tests := []struct {
+		name     string
+		kind     FieldKind
+		expected string
+	}{
+		{name: "string", kind: FieldString, expected: "string"},
+		{name: "number", kind: FieldNumber, expected: "number"},
+		{name: "duration", kind: FieldDuration, expected: "duration"},
+		{name: "time", kind: FieldTime, expected: "time"},
+		{name: "bool", kind: FieldBool, expected: "bool"},
+		{name: "invalid", kind: 256, expected: "unknown"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := test.kind.String(); actual != test.expected {
+				t.Errorf("expected %v, actual %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestExpr_Validate(t *testing.T) {
+	schema := map[string]FieldKind{
+		"Name":    FieldString,
+		"HP":      FieldNumber,
+		"Latency": FieldDuration,
+		"Created": FieldTime,
+		"Active":  FieldBool,
+	}
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected string
+	}{
+		{name: "string equality", input: `Name=="slime" || Name!=*"SLIME"`},
+		{name: "string regex", input: `Name=~"^s" && Name!~*"x"`},
+		{name: "number ordering", input: `HP>50 && HP<=100 && HP!=75`},
+		{name: "duration ordering", input: `Latency>=1s`},
+		{name: "time ordering", input: `Created<2025-01-01T00:00:00Z`},
+		{name: "bool equality", input: `Active==true && Active!=*"FALSE"`},
+		{name: "undeclared field", input: `Other=~"x" && Other>1`},
+		{name: "string gt with coercion", input: `Name>"10"`, opts: []Option{WithNumericStringCoercion()}},
+		{name: "string gt with ordering", input: `Name>"a"`, opts: []Option{WithStringOrdering()}},
+		{name: "string gt", input: `Name>"a"`, expected: `eval error: invalid operator for string field at 1:5: ">"`},
+		{name: "number regex", input: `HP=~"^5"`, expected: `eval error: invalid operator for number field at 1:3: "=~"`},
+		{name: "number case-insensitive", input: `HP==*"5"`, expected: `eval error: invalid operator for number field at 1:3: "==*"`},
+		{name: "duration regex", input: `Latency!~"s$"`, expected: `eval error: invalid operator for duration field at 1:8: "!~"`},
+		{name: "time case-insensitive", input: `Created!=*"2025-01-01T00:00:00Z"`, expected: `eval error: invalid operator for time field at 1:8: "!=*"`},
+		{name: "time offset", input: `Created<Created + 1h && Created>Other - 1h`},
+		{name: "time offset number field", input: `Created<HP + 1h`, expected: `eval error: field offset requires time fields at 1:9: "HP" is number`},
+		{name: "number with time offset", input: `HP<Created + 1h`, expected: `eval error: field offset requires time fields at 1:1: "HP" is number`},
+		{name: "modulo", input: `HP % 2 == 0`},
+		{name: "modulo string field", input: `Name % 2 == 0`, expected: `eval error: modulo requires an integer field at 1:1: "Name" is string`},
+		{name: "string substring", input: `Name has "li" && Name ihas "ME"`},
+		{name: "number substring", input: `HP has "5"`, expected: `eval error: invalid operator for number field at 1:4: "has"`},
+		{name: "bool substring", input: `Active ihas "t"`, expected: `eval error: invalid operator for bool field at 1:8: "ihas"`},
+		{name: "bool ordering", input: `Active>true`, expected: `eval error: invalid operator for bool field at 1:7: ">"`},
+		{name: "nested", input: `HP>1 && !(Name=="a" || Name<"b")`, expected: `eval error: invalid operator for string field at 1:28: "<"`},
+		{name: "string function", input: `lower(Name)=="a" && trim(Name)=~"b"`},
+		{name: "string function on number", input: `upper(HP)=="1"`, expected: `eval error: upper requires a string field at 1:7: "HP" is number`},
+		{name: "len", input: `len(Name)>3 && len(Tags)==0`},
+		{name: "len on number", input: `len(HP)>3`, expected: `eval error: len requires a string, slice or map field at 1:5: "HP" is number`},
+		{name: "number approx", input: `HP~=50`},
+		{name: "string approx", input: `Name~=50`, expected: `eval error: invalid operator for string field at 1:5: "~="`},
+		{name: "number with boolean", input: `HP==true`, expected: `eval error: cannot compare number field with boolean at 1:5: "true"`},
+		{name: "duration with boolean", input: `Latency!=false`, expected: `eval error: cannot compare duration field with boolean at 1:10: "false"`},
+		{name: "number with boolean truthiness", input: `HP==true`, opts: []Option{WithTruthyBool()}},
+		{name: "duration with boolean truthiness", input: `Latency!=false`, opts: []Option{WithTruthyBool()}, expected: `eval error: cannot compare duration field with boolean at 1:10: "false"`},
+		{name: "string with boolean", input: `Name==true`},
+		{name: "number case-insensitive", input: `HP==*"a"`, expected: `eval error: invalid operator for number field at 1:3: "==*"`},
+		{name: "number case-insensitive with runes", input: `HP==*"a"`, opts: []Option{WithRuneComparison()}},
+		{name: "first violation", input: `Name>"a" && HP=~"1"`, expected: `eval error: invalid operator for string field at 1:5: ">"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input, test.opts...)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, "", err)
+			}
+			err = expr.Validate(schema)
+			if test.expected == "" {
+				if err != nil {
+					t.Errorf(testTemplate, test.input, nil, err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.expected {
+				t.Errorf(testTemplate, test.input, test.expected, err)
+			}
+			var e *Error
+			if !errors.As(err, &e) || e.Line != 1 || e.Col == 0 {
+				t.Errorf(testTemplate, test.input, "position", err)
+			}
+		})
+	}
+}
+
+func TestExpr_Validate_nil(t *testing.T) {
+	var e *Expr
+	if err := e.Validate(nil); err != nil {
+		t.Errorf(testTemplate, "nil", nil, err)
+	}
+}
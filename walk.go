@@ -0,0 +1,87 @@
+package filter
+
+// NodeKind represents the kind of a node in the expression tree.
+type NodeKind int
+
+const (
+	// NodeBinary is a logical AND / OR node.
+	NodeBinary NodeKind = iota
+
+	// NodeNOT is a logical NOT node.
+	NodeNOT
+
+	// NodeComparison is a comparison node.
+	NodeComparison
+
+	// NodeBool is a boolean literal node, true or false.
+	NodeBool
+)
+
+// String returns a string representation of the node kind.
+func (k NodeKind) String() string {
+	return nodeType(k).String()
+}
+
+// NodeInfo is a read-only view of a node in the expression tree.
+type NodeInfo struct {
+	Kind     NodeKind // kind of the node
+	Operator string   // operator literal, e.g. "&&", "!", "=="
+	Ident    string   // identifier of comparison nodes
+	Func     string   // function applied to the field of comparison nodes, such as "len" of len(Tags) > 1
+	Value    string   // value of comparison and bool nodes, or the offset such as +1h of Start + 1h
+	Ref      string   // field on the right-hand side of comparison nodes such as Start + 1h
+	Key      string   // map key or slice index of comparison nodes, such as a of Headers["a"] or 0 of Coords[0]
+	Arith    string   // arithmetic applied to the field of comparison nodes, such as "% 10" of ID % 10
+	Values   []string // values of comparison nodes with a list, e.g. containsany ("a", "b")
+}
+
+// newNodeInfo creates a read-only view of the node.
+func newNodeInfo(n node) NodeInfo {
+	info := NodeInfo{
+		Kind:     NodeKind(n.typ),
+		Operator: n.op.typ.literal(),
+	}
+	if n.typ == nodeComparison {
+		info.Ident = n.ident.v
+		info.Func = n.fn.String()
+		info.Ref = n.ref.v
+		info.Key = n.key.v
+		if n.isArith() {
+			info.Arith = n.arith.typ.literal() + " " + n.arg.v
+		}
+		if !n.isList() {
+			info.Value = n.writtenValue()
+		}
+		for _, item := range n.items {
+			info.Values = append(info.Values, item.writtenValue())
+		}
+	}
+	if n.typ == nodeBool {
+		info.Value = n.val.v
+	}
+	return info
+}
+
+// Walk traverses the expression tree in depth-first order, calling fn for each node.
+// If fn returns false, the children of that node are not visited.
+func Walk(e *Expr, fn func(NodeInfo) bool) {
+	if e == nil || len(e.parser.nodes) == 0 {
+		return
+	}
+	walk(e.parser.nodes, e.root, fn)
+}
+
+// walk visits the node at index i and its children.
+func walk(nodes []node, i int, fn func(NodeInfo) bool) {
+	n := nodes[i]
+	if !fn(newNodeInfo(n)) {
+		return
+	}
+	switch n.typ {
+	case nodeBinary:
+		walk(nodes, n.left, fn)
+		walk(nodes, n.right, fn)
+	case nodeNOT:
+		walk(nodes, n.left, fn)
+	}
+}
@@ -0,0 +1,217 @@
+package filter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNodeKind_String(t *testing.T) {
+	tests := []struct {
+		name     string
+		kind     NodeKind
+		expected string
+	}{
+		{
+			name:     "binary",
+			kind:     NodeBinary,
+			expected: "binary node",
+		},
+		{
+			name:     "not",
+			kind:     NodeNOT,
+			expected: "not node",
+		},
+		{
+			name:     "comparison",
+			kind:     NodeComparison,
+			expected: "comparison node",
+		},
+		{
+			name:     "bool",
+			kind:     NodeBool,
+			expected: "bool node",
+		},
+		{
+			name:     "invalid",
+			kind:     256,
+			expected: "",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := test.kind.String(); actual != test.expected {
+				t.Errorf("expected %v, actual %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestWalk(t *testing.T) {
+	type expected struct {
+		comparisons int
+		ops         []string
+		nodes       []NodeInfo
+	}
+	tests := []struct {
+		name     string
+		input    string
+		prune    NodeKind
+		expected expected
+	}{
+		{
+			name:  "single comparison",
+			input: `HP>50`,
+			prune: -1,
+			expected: expected{
+				comparisons: 1,
+				ops:         []string{">"},
+				nodes: []NodeInfo{
+					{Kind: NodeComparison, Operator: ">", Ident: "HP", Value: "50"},
+				},
+			},
+		},
+		{
+			name:  "complex",
+			input: `Class=="軍師" && (HP>50 || !(Name=~'^A')) && Delay<=1h`,
+			prune: -1,
+			expected: expected{
+				comparisons: 4,
+				ops:         []string{"&&", "&&", "==", "||", ">", "!", "=~", "<="},
+				nodes: []NodeInfo{
+					{Kind: NodeBinary, Operator: "&&"},
+					{Kind: NodeBinary, Operator: "&&"},
+					{Kind: NodeComparison, Operator: "==", Ident: "Class", Value: "軍師"},
+					{Kind: NodeBinary, Operator: "||"},
+					{Kind: NodeComparison, Operator: ">", Ident: "HP", Value: "50"},
+					{Kind: NodeNOT, Operator: "!"},
+					{Kind: NodeComparison, Operator: "=~", Ident: "Name", Value: "^A"},
+					{Kind: NodeComparison, Operator: "<=", Ident: "Delay", Value: "1h"},
+				},
+			},
+		},
+		{
+			name:  "list",
+			input: `Tags containsany ("a", 1) && Tags containsall ()`,
+			prune: -1,
+			expected: expected{
+				comparisons: 2,
+				ops:         []string{"&&", "containsany", "containsall"},
+				nodes: []NodeInfo{
+					{Kind: NodeBinary, Operator: "&&"},
+					{Kind: NodeComparison, Operator: "containsany", Ident: "Tags", Values: []string{"a", "1"}},
+					{Kind: NodeComparison, Operator: "containsall", Ident: "Tags"},
+				},
+			},
+		},
+		{
+			name:  "key",
+			input: `Headers["a"] == "x" && Coords[0] > 1`,
+			prune: -1,
+			expected: expected{
+				comparisons: 2,
+				ops:         []string{"&&", "==", ">"},
+				nodes: []NodeInfo{
+					{Kind: NodeBinary, Operator: "&&"},
+					{Kind: NodeComparison, Operator: "==", Ident: "Headers", Value: "x", Key: "a"},
+					{Kind: NodeComparison, Operator: ">", Ident: "Coords", Value: "1", Key: "0"},
+				},
+			},
+		},
+		{
+			name:  "func",
+			input: `len(Tags) > 1 && lower(Name) == "a"`,
+			prune: -1,
+			expected: expected{
+				comparisons: 2,
+				ops:         []string{"&&", ">", "=="},
+				nodes: []NodeInfo{
+					{Kind: NodeBinary, Operator: "&&"},
+					{Kind: NodeComparison, Operator: ">", Ident: "Tags", Func: "len", Value: "1"},
+					{Kind: NodeComparison, Operator: "==", Ident: "Name", Func: "lower", Value: "a"},
+				},
+			},
+		},
+		{
+			name:  "case-insensitive regex",
+			input: `Name =~* "abc" && Tags imatches ("a", "b")`,
+			prune: -1,
+			expected: expected{
+				comparisons: 2,
+				ops:         []string{"&&", "=~*", "imatches"},
+				nodes: []NodeInfo{
+					{Kind: NodeBinary, Operator: "&&"},
+					{Kind: NodeComparison, Operator: "=~*", Ident: "Name", Value: "abc"},
+					{Kind: NodeComparison, Operator: "imatches", Ident: "Tags", Values: []string{"a", "b"}},
+				},
+			},
+		},
+		{
+			name:  "bool",
+			input: `true && !(false)`,
+			prune: -1,
+			expected: expected{
+				comparisons: 0,
+				ops:         []string{"&&", "", "!", ""},
+				nodes: []NodeInfo{
+					{Kind: NodeBinary, Operator: "&&"},
+					{Kind: NodeBool, Value: "true"},
+					{Kind: NodeNOT, Operator: "!"},
+					{Kind: NodeBool, Value: "false"},
+				},
+			},
+		},
+		{
+			name:  "prune not",
+			input: `HP>50 || !(Name=~'^A' && MP<10)`,
+			prune: NodeNOT,
+			expected: expected{
+				comparisons: 1,
+				ops:         []string{"||", ">", "!"},
+				nodes: []NodeInfo{
+					{Kind: NodeBinary, Operator: "||"},
+					{Kind: NodeComparison, Operator: ">", Ident: "HP", Value: "50"},
+					{Kind: NodeNOT, Operator: "!"},
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := Parse(test.input)
+			if err != nil {
+				t.Fatalf(testTemplate, test.input, "", err)
+			}
+			comparisons := 0
+			var ops []string
+			var nodes []NodeInfo
+			Walk(expr, func(n NodeInfo) bool {
+				if n.Kind == NodeComparison {
+					comparisons++
+				}
+				ops = append(ops, n.Operator)
+				nodes = append(nodes, n)
+				return n.Kind != test.prune
+			})
+			if comparisons != test.expected.comparisons {
+				t.Errorf(testTemplate, test.input, test.expected.comparisons, comparisons)
+			}
+			if !reflect.DeepEqual(ops, test.expected.ops) {
+				t.Errorf(testTemplate, test.input, test.expected.ops, ops)
+			}
+			if !reflect.DeepEqual(nodes, test.expected.nodes) {
+				t.Errorf(testTemplate, test.input, test.expected.nodes, nodes)
+			}
+		})
+	}
+}
+
+func TestWalk_nil(t *testing.T) {
+	called := false
+	Walk(nil, func(NodeInfo) bool {
+		called = true
+		return true
+	})
+	if called {
+		t.Errorf("expected %v, actual %v", false, called)
+	}
+}